@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocr-tool/pdfocr"
+)
+
+// runBatch implements the "batch" subcommand: it extracts the text of
+// every PDF and image under the given directories and globs into -out, mirroring
+// their directory structure, running -jobs files at a time.
+func runBatch(args []string) {
+	config := pdfocr.DefaultConfig()
+	var specs []string
+	outDir, manifestFile, format := "", "", pdfocr.FormatText
+	jobs := runtime.NumCPU()
+	maxOpenDocs, maxMemory := 0, int64(0)
+	force, fast, best, dpiGiven := false, false, false, false
+	progress := pdfocr.ProgressText
+	sandboxed, sandboxMemory, sandboxTimeout := false, defaultSandboxMemory, time.Duration(0)
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-out":
+			if i+1 < len(args) {
+				outDir = args[i+1]
+				i++
+			}
+		case "-manifest":
+			if i+1 < len(args) {
+				manifestFile = args[i+1]
+				i++
+			}
+		case "-format":
+			if i+1 < len(args) {
+				format = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-progress":
+			if i+1 < len(args) {
+				progress = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-jobs":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -jobs value %q\n", args[i+1])
+				}
+				jobs = n
+				i++
+			}
+		case "-sandbox":
+			sandboxed = true
+		case "-sandbox-memory":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 0 {
+					fatalf("Error: invalid -sandbox-memory value %q\n", args[i+1])
+				}
+				sandboxMemory = n
+				i++
+			}
+		case "-sandbox-timeout":
+			if i+1 < len(args) {
+				d, err := time.ParseDuration(args[i+1])
+				if err != nil || d < 0 {
+					fatalf("Error: invalid -sandbox-timeout value %q\n", args[i+1])
+				}
+				sandboxTimeout = d
+				i++
+			}
+		case "-max-open-docs":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -max-open-docs value %q\n", args[i+1])
+				}
+				maxOpenDocs = n
+				i++
+			}
+		case "-max-memory":
+			if i+1 < len(args) {
+				n, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -max-memory value %q\n", args[i+1])
+				}
+				maxMemory = n << 20
+				i++
+			}
+		case "-on-error":
+			if i+1 < len(args) {
+				config.ErrorPolicy = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-min-confidence":
+			if i+1 < len(args) {
+				threshold, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || pdfocr.ValidateMinConfidence(threshold) != nil {
+					fatalf("Error: invalid -min-confidence value %q\n", args[i+1])
+				}
+				config.MinConfidence = threshold
+				i++
+			}
+		case "-lang":
+			if i+1 < len(args) {
+				config.Language = args[i+1]
+				i++
+			}
+		case "-dpi":
+			if i+1 < len(args) {
+				dpi, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || dpi <= 0 {
+					fatalf("Error: invalid -dpi value %q\n", args[i+1])
+				}
+				config.DPI, dpiGiven = dpi, true
+				i++
+			}
+		case "-ocr-mode":
+			if i+1 < len(args) {
+				config.OCRMode = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-renderer":
+			if i+1 < len(args) {
+				config.Renderer = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-engine":
+			if i+1 < len(args) {
+				config.Engine = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-offline-queue":
+			if i+1 < len(args) {
+				config.OfflineQueue = args[i+1]
+				i++
+			}
+		case "-cloud-batch":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -cloud-batch value %q\n", args[i+1])
+				}
+				config.CloudBatch = n
+				i++
+			}
+		case "-tess-clients":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -tess-clients value %q\n", args[i+1])
+				}
+				config.TessClients = n
+				i++
+			}
+		case "-psm":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 || n > pdfocr.MaxPSM {
+					fatalf("Error: invalid -psm value %q\n", args[i+1])
+				}
+				config.PSM = n
+				i++
+			}
+		case "-oem":
+			if i+1 < len(args) {
+				oem, err := pdfocr.ParseOEM(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				config.OEM = oem
+				i++
+			}
+		case "-tess-param":
+			if i+1 < len(args) {
+				name, value, err := pdfocr.ParseTessParam(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				if config.TessParams == nil {
+					config.TessParams = map[string]string{}
+				}
+				config.TessParams[name] = value
+				i++
+			}
+		case "-tessdata":
+			if i+1 < len(args) {
+				config.TessdataDir = args[i+1]
+				i++
+			}
+		case "-onnx-models":
+			if i+1 < len(args) {
+				models, err := pdfocr.ParseONNXModels(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				config.ONNXModels = models
+				i++
+			}
+		case "-model-cache":
+			if i+1 < len(args) {
+				config.ModelCache = args[i+1]
+				i++
+			}
+		case "-quiet", "-v", "-verbose":
+			// Applied by configureLogging
+		case "-log-format":
+			if i+1 < len(args) {
+				i++
+			}
+		case "-fast":
+			fast = true
+		case "-best":
+			best = true
+		case "-two-pass":
+			config.TwoPass = true
+		case "-only":
+			if i+1 < len(args) {
+				kinds, err := pdfocr.ParseRegionKinds(args[i+1])
+				if err != nil {
+					fatalf("Error: invalid -only value: %v\n", err)
+				}
+				config.OnlyRegions = kinds
+				i++
+			}
+		case "-stats":
+			config.Stats = true
+		case "-spot":
+			if i+1 < len(args) {
+				config.Keywords = pdfocr.ParseKeywords(args[i+1])
+				i++
+			}
+		case "-force":
+			force = true
+		default:
+			specs = append(specs, args[i])
+		}
+	}
+	if len(specs) == 0 || outDir == "" {
+		printLine("Usage: pdf-ocr-tool batch <dir-or-glob>... -out <dir> [-jobs <n>] [-format text|json|tsv] [-manifest <file>]")
+		printLine("                          [-force] [-lang <language>] [-dpi <dpi>] [-fast|-best] [-ocr-mode <m>] [-engine <name>]")
+		printLine("                          [-tess-clients <n>] [-renderer <name>] [-tessdata <dir>] [-max-open-docs <n>] [-max-memory <MB>]")
+		printLine("                          [-onnx-models <det,rec,charset>] [-model-cache <dir>]")
+		printLine("                          [-psm <n>] [-oem <n>] [-tess-param <name=value>]... [-cloud-batch <n>]")
+		printLine("                          [-progress text|json] [-min-confidence <c>] [-two-pass] [-only <kinds>] [-stats]")
+		printLine("                          [-spot <terms>] [-on-error collect|skip|fail-fast] [-quiet|-v] [-log-format text|json]")
+		printLine("                          [-sandbox] [-sandbox-memory <MB>] [-sandbox-timeout <d>] [-offline-queue <dir>]")
+		os.Exit(1)
+	}
+	if err := pdfocr.ValidateProgress(progress); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if progress == pdfocr.ProgressJSON {
+		config.Progress = pdfocr.JSONProgress(os.Stderr)
+	}
+	if fast && best {
+		fatalf("Error: -fast and -best cannot be combined\n")
+	}
+	if fast {
+		pdfocr.ApplyFast(&config, dpiGiven)
+	}
+	if best {
+		pdfocr.ApplyBest(&config, dpiGiven)
+	}
+	if jobs > 1 {
+		// Files run in parallel instead of Tesseract's threads
+		config.OCRThreads = 1
+	}
+	if config.TessClients == 0 {
+		// One Tesseract client per file in progress
+		config.TessClients = max(jobs, 1)
+	}
+	if err := pdfocr.SetOCRThreads(config.OCRThreads); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if manifestFile == "" {
+		manifestFile = filepath.Join(outDir, "batch-manifest.json")
+	}
+	opts := pdfocr.BatchOptions{OutDir: outDir, Format: format, Jobs: jobs, Force: force, MaxOpenDocs: maxOpenDocs, MaxMemory: maxMemory}
+	if sandboxed {
+		sandbox, err := pdfocr.NewSandbox(pdfocr.SandboxOptions{Workers: jobs, MemoryMB: sandboxMemory, Timeout: sandboxTimeout,
+			Network: pdfocr.EngineIsRemote(config.Engine), Args: sandboxWorkerArgs(args), Stderr: logWriter{}})
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		defer sandbox.Close()
+		opts.Sandbox = sandbox
+		printf("Batch: documents processed by %d sandbox workers\n", jobs)
+	}
+
+	ctx, stop := interruptContext()
+	batch, err := pdfocr.RunBatch(ctx, specs, config, opts)
+	stop()
+	if err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	data, err := json.MarshalIndent(batch, "", "  ")
+	if err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(manifestFile), 0755); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if err := os.WriteFile(manifestFile, append(data, '\n'), 0644); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	printf("Batch: %d succeeded, %d failed, %d skipped (manifest: %s)\n", batch.Succeeded, batch.Failed, batch.Skipped, manifestFile)
+	if len(config.Keywords) > 0 {
+		printf("Batch: %d files mention one of the terms\n", batch.Matched)
+	}
+	syncOfflineQueue(config)
+	if batch.Failed > 0 {
+		os.Exit(1)
+	}
+}
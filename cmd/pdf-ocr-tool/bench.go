@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocr-tool/pdfocr"
+)
+
+// runBench implements the "bench" subcommand.
+func runBench(args []string) {
+	config := pdfocr.DefaultConfig()
+	pagesPerWorker := 2
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-lang":
+			if i+1 < len(args) {
+				config.Language = args[i+1]
+				i++
+			}
+		case "-renderer":
+			if i+1 < len(args) {
+				config.Renderer = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-engine":
+			if i+1 < len(args) {
+				config.Engine = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-ocr-threads":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					log.Fatalf("Error: invalid -ocr-threads value %q\n", args[i+1])
+				}
+				config.OCRThreads = n
+				i++
+			}
+		case "-pages":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					log.Fatalf("Error: invalid -pages value %q\n", args[i+1])
+				}
+				pagesPerWorker = n
+				i++
+			}
+		}
+	}
+	if err := pdfocr.SetOCRThreads(config.OCRThreads); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	fmt.Printf("Benchmarking on %d CPUs with the built-in sample page, Tesseract threads %s\n\n",
+		runtime.NumCPU(), pdfocr.OCRThreadsDescription())
+	fmt.Printf("%6s %8s %6s %10s %10s %9s\n", "DPI", "workers", "pages", "time", "pages/s", "accuracy")
+
+	results, err := pdfocr.Bench(config, pagesPerWorker, func(r pdfocr.BenchResult) {
+		if r.Err != nil {
+			fmt.Printf("%6g %8d %6d  failed: %v\n", r.DPI, r.Workers, r.Pages, r.Err)
+			return
+		}
+		fmt.Printf("%6g %8d %6d %10s %10.2f %8.0f%%\n", r.DPI, r.Workers, r.Pages,
+			r.Elapsed.Round(time.Millisecond), r.PagesPerSecond(), r.Accuracy*100)
+	})
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	best, ok := pdfocr.RecommendBench(results)
+	if !ok {
+		log.Fatalf("Error: every bench setting failed; run \"pdf-ocr-tool doctor\" to diagnose\n")
+	}
+	fmt.Printf("\nRecommended: %d worker(s) at %g DPI (%.2f pages/s, %.0f%% accuracy)\n",
+		best.Workers, best.DPI, best.PagesPerSecond(), best.Accuracy*100)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"ocr-tool/pdfocr"
+)
+
+// runCalibrate implements the "calibrate" subcommand. Every document needs
+// its ground truth in a .txt file of the same name; the learned curve is
+// stored under the engine and language key of the calibration file, which
+// keeps the curves of other backends.
+func runCalibrate(args []string) {
+	config := pdfocr.DefaultConfig()
+	output := "calibration.json"
+	var docs []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-lang":
+			if i+1 < len(args) {
+				config.Language = args[i+1]
+				i++
+			}
+		case "-renderer":
+			if i+1 < len(args) {
+				config.Renderer = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-engine":
+			if i+1 < len(args) {
+				config.Engine = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-o":
+			if i+1 < len(args) {
+				output = args[i+1]
+				i++
+			}
+		default:
+			docs = append(docs, args[i])
+		}
+	}
+	if len(docs) == 0 {
+		fmt.Println("Usage: pdf-ocr-tool calibrate [-lang <language>] [-engine <name>] [-renderer <name>] [-o calibration.json] <doc.pdf>...")
+		fmt.Println("Each document needs its ground truth text next to it (doc.pdf -> doc.txt).")
+		os.Exit(1)
+	}
+	key, words, err := pdfocr.Calibrate(output, docs, config)
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	calibration, err := pdfocr.LoadCalibration(output)
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+
+	fmt.Printf("Calibrated %s from %d words:\n", key, words)
+	for _, p := range calibration[key] {
+		fmt.Printf("  raw %5.1f -> %5.1f\n", p[0], p[1])
+	}
+	fmt.Printf("Saved to %s; use it with -calibration %s\n", output, output)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"ocr-tool/pdfocr"
+)
+
+// runDecrypt implements the "decrypt" subcommand, which restores a file
+// written with -encrypt-key to stdout or the -o file.
+func runDecrypt(args []string) {
+	var keyFile, output, input string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-key":
+			if i+1 < len(args) {
+				keyFile = args[i+1]
+				i++
+			}
+		case "-o":
+			if i+1 < len(args) {
+				output = args[i+1]
+				i++
+			}
+		default:
+			input = args[i]
+		}
+	}
+	if keyFile == "" || input == "" {
+		fmt.Println("Usage: pdf-ocr-tool decrypt -key <keyfile> <file> [-o <output>]")
+		os.Exit(1)
+	}
+	key, err := pdfocr.LoadEncryptionKey(keyFile)
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	data, err := os.ReadFile(input)
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	plain, err := pdfocr.DecryptData(key, data)
+	if err != nil {
+		log.Fatalf("Error: %s: %v\n", input, err)
+	}
+	if output == "" {
+		os.Stdout.Write(plain)
+		return
+	}
+	if err := os.WriteFile(output, plain, 0600); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+}
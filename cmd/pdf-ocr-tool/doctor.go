@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"ocr-tool/pdfocr"
+)
+
+// doctorLabels are the labels of the check statuses of the doctor command.
+var doctorLabels = map[string]string{
+	pdfocr.DoctorOK:   "[ OK ]",
+	pdfocr.DoctorWarn: "[WARN]",
+	pdfocr.DoctorFail: "[FAIL]",
+}
+
+// runDoctor implements the "doctor" subcommand: it runs a synthetic page
+// through rendering, OCR and output encoding with the selected backends and
+// reports what is broken and how to fix it.
+func runDoctor(args []string) {
+	config := pdfocr.DefaultConfig()
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-lang":
+			if i+1 < len(args) {
+				config.Language = args[i+1]
+				i++
+			}
+		case "-renderer":
+			if i+1 < len(args) {
+				config.Renderer = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-engine":
+			if i+1 < len(args) {
+				config.Engine = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-encoding":
+			if i+1 < len(args) {
+				config.Encoding = args[i+1]
+				i++
+			}
+		}
+	}
+
+	fmt.Printf("pdf-ocr-tool %s self-test\n\n", pdfocr.Version())
+	report := pdfocr.Doctor(config)
+	for _, c := range report.Checks {
+		fmt.Printf("%s %s\n", doctorLabels[c.Status], c.Message)
+		if c.Fix != "" {
+			fmt.Printf("       fix: %s\n", c.Fix)
+		}
+	}
+	if report.Failed() {
+		fmt.Println("\nSelf-test failed.")
+		os.Exit(1)
+	}
+	fmt.Println("\nAll checks passed.")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ocr-tool/pdfocr"
+)
+
+// runEval implements the "eval" subcommand: it OCRs the corpus with every
+// engine in every language and fails when the character error rate of
+// one regressed beyond -max-regression from the baseline file. A baseline
+// is recorded for the keys it does not hold yet, and for all of them with
+// -update-baseline.
+func runEval(args []string) {
+	config := pdfocr.DefaultConfig()
+	var corpus, baselineFile string
+	engineNames := []string{pdfocr.DefaultEngine}
+	var langs []string
+	maxRegression := pdfocr.DefaultMaxRegression
+	update := false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-corpus":
+			if i+1 < len(args) {
+				corpus = args[i+1]
+				i++
+			}
+		case "-engines":
+			if i+1 < len(args) {
+				engineNames = strings.Split(strings.ToLower(args[i+1]), ",")
+				i++
+			}
+		case "-langs":
+			if i+1 < len(args) {
+				langs = strings.Split(args[i+1], ",")
+				i++
+			}
+		case "-renderer":
+			if i+1 < len(args) {
+				config.Renderer = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-dpi":
+			if i+1 < len(args) {
+				dpi, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || dpi <= 0 {
+					log.Fatalf("Error: invalid -dpi value %q\n", args[i+1])
+				}
+				config.DPI = dpi
+				i++
+			}
+		case "-baseline":
+			if i+1 < len(args) {
+				baselineFile = args[i+1]
+				i++
+			}
+		case "-max-regression":
+			if i+1 < len(args) {
+				v, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || v < 0 {
+					log.Fatalf("Error: invalid -max-regression value %q\n", args[i+1])
+				}
+				maxRegression = v
+				i++
+			}
+		case "-update-baseline":
+			update = true
+		default:
+			fmt.Println("Usage: pdf-ocr-tool eval [-corpus <dir>] [-engines <name,...>] [-langs <lang,...>] [-renderer <name>]")
+			fmt.Println("                         [-dpi <dpi>] [-baseline <file>] [-max-regression <cer>] [-update-baseline]")
+			fmt.Println("The corpus holds one directory per language of documents with their ground truth next to them")
+			fmt.Println("(eng/doc.pdf -> eng/doc.txt); the built-in sample scan is always part of it.")
+			os.Exit(1)
+		}
+	}
+	for _, name := range engineNames {
+		c := config
+		c.Engine = name
+		if err := c.Validate(); err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+	}
+	docs, err := pdfocr.LoadEvalCorpus(corpus, langs)
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	byLang := map[string][]pdfocr.EvalDocument{}
+	for _, doc := range docs {
+		byLang[doc.Lang] = append(byLang[doc.Lang], doc)
+	}
+	if len(byLang) == 0 {
+		log.Fatalf("Error: no documents to evaluate\n")
+	}
+	corpusLangs := make([]string, 0, len(byLang))
+	for lang := range byLang {
+		corpusLangs = append(corpusLangs, lang)
+	}
+	sort.Strings(corpusLangs)
+
+	baseline := pdfocr.EvalBaseline{}
+	if baselineFile != "" {
+		if baseline, err = pdfocr.LoadEvalBaseline(baselineFile); err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+	}
+
+	fmt.Printf("%-24s %5s %8s %8s %9s\n", "engine/language", "docs", "chars", "CER", "baseline")
+	var results []pdfocr.EvalResult
+	failed := false
+	for _, name := range engineNames {
+		for _, lang := range corpusLangs {
+			config.Engine, config.Language = name, lang
+			r := pdfocr.EvalEngine(config, byLang[lang])
+			results = append(results, r)
+			if r.Err != nil {
+				fmt.Printf("%-24s  failed: %v\n", r.Key, r.Err)
+				failed = true
+				continue
+			}
+			base := "-"
+			if b, ok := baseline[r.Key]; ok {
+				base = fmt.Sprintf("%.2f%%", b*100)
+			}
+			fmt.Printf("%-24s %5d %8d %7.2f%% %9s\n", r.Key, r.Documents, r.Chars, r.CER()*100, base)
+		}
+	}
+
+	regressions := pdfocr.EvalRegressions(results, baseline, maxRegression)
+	if baselineFile != "" {
+		recorded := 0
+		for _, r := range results {
+			if _, ok := baseline[r.Key]; r.Err == nil && (update || !ok) {
+				baseline[r.Key] = r.CER()
+				recorded++
+			}
+		}
+		if recorded > 0 {
+			data, err := json.MarshalIndent(baseline, "", "  ")
+			if err != nil {
+				log.Fatalf("Error: %v\n", err)
+			}
+			if err := os.WriteFile(baselineFile, append(data, '\n'), 0644); err != nil {
+				log.Fatalf("Error: %v\n", err)
+			}
+			fmt.Printf("\nRecorded %d baseline(s) in %s\n", recorded, baselineFile)
+		}
+	}
+	if len(regressions) > 0 && !update {
+		fmt.Printf("\nAccuracy regressed by more than %.2f%%:\n", maxRegression*100)
+		for _, r := range regressions {
+			fmt.Println("  " + r)
+		}
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,867 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"ocr-tool/pdfocr"
+)
+
+// runExtract implements the main command, which extracts the text of the
+// document args[1] with the options after it.
+func runExtract(args []string) {
+	pdfPath := args[1]
+
+	// Check if file exists
+	if _, err := os.Stat(pdfPath); os.IsNotExist(err) {
+		fatalf("Error: File %s does not exist\n", pdfPath)
+	}
+
+	// Parse command line options
+	config := pdfocr.DefaultConfig()
+
+	extractImages := false
+	var ignoreFile, ignoreTemplate string
+	var ignoreSpecs []string
+	var calibrationFile string
+	previewPages := 0
+	fast, best, dpiGiven := false, false, false
+	dictFile := ""
+	maxCPUs := 0
+	var niceLevel *int
+	bundleFile := ""
+	format := pdfocr.FormatText
+	progress := pdfocr.ProgressText
+	tocFile := ""
+	splitDocs := false
+	keyFile := ""
+	var audit pdfocr.AuditLog
+
+	for i := 2; i < len(args); i++ {
+		switch args[i] {
+		case "-o":
+			if i+1 < len(args) {
+				config.OutputFile = args[i+1]
+				i++
+			}
+		case "-page-labels":
+			config.PageLabels = true
+		case "-stats":
+			config.Stats = true
+		case "-spot":
+			if i+1 < len(args) {
+				config.Keywords = pdfocr.ParseKeywords(args[i+1])
+				i++
+			}
+		case "-recollate":
+			config.Recollate = true
+		case "-detect-docs":
+			config.DetectDocuments = true
+		case "-split-docs":
+			config.DetectDocuments = true
+			splitDocs = true
+		case "-toc":
+			if i+1 < len(args) {
+				tocFile = args[i+1]
+				config.Outline = true
+				i++
+			}
+		case "-format":
+			if i+1 < len(args) {
+				format = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-progress":
+			if i+1 < len(args) {
+				progress = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-manifest":
+			if i+1 < len(args) {
+				config.ManifestFile = args[i+1]
+				i++
+			}
+		case "-bundle":
+			if i+1 < len(args) {
+				bundleFile = args[i+1]
+				i++
+			}
+		case "-mets":
+			if i+1 < len(args) {
+				config.METSDir = args[i+1]
+				i++
+			}
+		case "-tables":
+			if i+1 < len(args) {
+				config.TablesDir = args[i+1]
+				config.Tables = true
+				i++
+			}
+		case "-iiif":
+			if i+1 < len(args) {
+				config.IIIFDir = args[i+1]
+				i++
+			}
+		case "-iiif-base":
+			if i+1 < len(args) {
+				config.IIIFBaseURL = args[i+1]
+				i++
+			}
+		case "-pages":
+			if i+1 < len(args) {
+				set, err := pdfocr.ParsePageSet(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				config.PageSelection = set
+				i++
+			}
+		case "-pages-dir":
+			if i+1 < len(args) {
+				config.PagesDir = args[i+1]
+				i++
+			}
+		case "-audit-log":
+			if i+1 < len(args) {
+				audit.File = args[i+1]
+				i++
+			}
+		case "-audit-syslog":
+			audit.Syslog = true
+		case "-no-disk":
+			config.NoDisk = true
+		case "-encrypt-key":
+			if i+1 < len(args) {
+				keyFile = args[i+1]
+				i++
+			}
+		case "-xfa-out":
+			if i+1 < len(args) {
+				config.XFAOutputFile = args[i+1]
+				i++
+			}
+		case "-lang":
+			if i+1 < len(args) {
+				config.Language = args[i+1]
+				i++
+			}
+		case "-encoding":
+			if i+1 < len(args) {
+				config.Encoding = args[i+1]
+				i++
+			}
+		case "-newline":
+			if i+1 < len(args) {
+				config.Newline = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-expand-tabs":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -expand-tabs value %q\n", args[i+1])
+				}
+				config.TabWidth = n
+				i++
+			}
+		case "-collapse-blank":
+			config.CollapseBlankLines = true
+		case "-trim-trailing":
+			config.TrimTrailingSpace = true
+		case "-ocr-mode":
+			if i+1 < len(args) {
+				config.OCRMode = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-vector-pages":
+			if i+1 < len(args) {
+				config.VectorPages = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-dpi":
+			if i+1 < len(args) {
+				dpi, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || dpi <= 0 {
+					fatalf("Error: invalid -dpi value %q\n", args[i+1])
+				}
+				config.DPI, dpiGiven = dpi, true
+				i++
+			}
+		case "-vector-dpi":
+			if i+1 < len(args) {
+				dpi, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || dpi <= 0 {
+					fatalf("Error: invalid -vector-dpi value %q\n", args[i+1])
+				}
+				config.VectorDPI = dpi
+				i++
+			}
+		case "-attachments":
+			if i+1 < len(args) {
+				config.Attachments = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-on-error":
+			if i+1 < len(args) {
+				config.ErrorPolicy = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-layout":
+			config.PreserveLayout = true
+		case "-renderer":
+			if i+1 < len(args) {
+				config.Renderer = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-engine":
+			if i+1 < len(args) {
+				config.Engine = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-offline-queue":
+			if i+1 < len(args) {
+				config.OfflineQueue = args[i+1]
+				i++
+			}
+		case "-cloud-batch":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -cloud-batch value %q\n", args[i+1])
+				}
+				config.CloudBatch = n
+				i++
+			}
+		case "-preset":
+			if i+1 < len(args) {
+				config.Preset = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-normalize-locale":
+			if i+1 < len(args) {
+				config.NormalizeLocale = args[i+1]
+				i++
+			}
+		case "-merge-native":
+			config.MergeNative = true
+		case "-debug-overlay":
+			if i+1 < len(args) {
+				config.DebugOverlayDir = args[i+1]
+				i++
+			}
+		case "-annotations":
+			config.Annotations = true
+		case "-fast":
+			fast = true
+		case "-best":
+			best = true
+		case "-two-pass":
+			config.TwoPass = true
+		case "-only":
+			if i+1 < len(args) {
+				kinds, err := pdfocr.ParseRegionKinds(args[i+1])
+				if err != nil {
+					fatalf("Error: invalid -only value: %v\n", err)
+				}
+				config.OnlyRegions = kinds
+				i++
+			}
+		case "-dict":
+			if i+1 < len(args) {
+				dictFile = args[i+1]
+				i++
+			}
+		case "-preview":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -preview value %q\n", args[i+1])
+				}
+				previewPages = n
+				i++
+			}
+		case "-workers":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -workers value %q\n", args[i+1])
+				}
+				config.Workers = n
+				i++
+			}
+		case "-ocr-threads":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -ocr-threads value %q\n", args[i+1])
+				}
+				config.OCRThreads = n
+				i++
+			}
+		case "-tess-clients":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -tess-clients value %q\n", args[i+1])
+				}
+				config.TessClients = n
+				i++
+			}
+		case "-psm":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 || n > pdfocr.MaxPSM {
+					fatalf("Error: invalid -psm value %q\n", args[i+1])
+				}
+				config.PSM = n
+				i++
+			}
+		case "-oem":
+			if i+1 < len(args) {
+				oem, err := pdfocr.ParseOEM(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				config.OEM = oem
+				i++
+			}
+		case "-tess-param":
+			if i+1 < len(args) {
+				name, value, err := pdfocr.ParseTessParam(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				if config.TessParams == nil {
+					config.TessParams = map[string]string{}
+				}
+				config.TessParams[name] = value
+				i++
+			}
+		case "-nice":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < -20 || n > 19 {
+					fatalf("Error: invalid -nice value %q\n", args[i+1])
+				}
+				niceLevel = &n
+				i++
+			}
+		case "-max-cpu":
+			if i+1 < len(args) {
+				cpus, err := pdfocr.ParseCPULimit(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				maxCPUs = cpus
+				i++
+			}
+		case "-tessdata":
+			if i+1 < len(args) {
+				config.TessdataDir = args[i+1]
+				i++
+			}
+		case "-onnx-models":
+			if i+1 < len(args) {
+				models, err := pdfocr.ParseONNXModels(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				config.ONNXModels = models
+				i++
+			}
+		case "-model-cache":
+			if i+1 < len(args) {
+				config.ModelCache = args[i+1]
+				i++
+			}
+		case "-max-pages":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -max-pages value %q\n", args[i+1])
+				}
+				config.MaxPages = n
+				i++
+			}
+		case "-min-confidence":
+			if i+1 < len(args) {
+				threshold, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || pdfocr.ValidateMinConfidence(threshold) != nil {
+					fatalf("Error: invalid -min-confidence value %q\n", args[i+1])
+				}
+				config.MinConfidence = threshold
+				i++
+			}
+		case "-max-duration":
+			if i+1 < len(args) {
+				d, err := time.ParseDuration(args[i+1])
+				if err != nil || d <= 0 {
+					fatalf("Error: invalid -max-duration value %q (e.g. 90s, 5m)\n", args[i+1])
+				}
+				config.MaxDuration = d
+				i++
+			}
+		case "-flush-every":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -flush-every value %q\n", args[i+1])
+				}
+				config.FlushEvery = n
+				i++
+			}
+		case "-calibration":
+			if i+1 < len(args) {
+				calibrationFile = args[i+1]
+				i++
+			}
+		case "-ignore":
+			if i+1 < len(args) {
+				ignoreSpecs = append(ignoreSpecs, args[i+1])
+				i++
+			}
+		case "-ignore-regions":
+			if i+1 < len(args) {
+				ignoreFile = args[i+1]
+				i++
+			}
+		case "-ignore-template":
+			if i+1 < len(args) {
+				ignoreTemplate = args[i+1]
+				i++
+			}
+		case "-links":
+			config.Links = true
+		case "-ui-lang":
+			// Applied by uiLanguage
+			if i+1 < len(args) {
+				i++
+			}
+		case "-quiet", "-v", "-verbose":
+			// Applied by configureLogging
+		case "-log-format":
+			if i+1 < len(args) {
+				i++
+			}
+		case "-auto-lang":
+			config.AutoLanguage = true
+		case "-auto-rotate":
+			config.AutoRotate = true
+		case "-preprocess":
+			if i+1 < len(args) {
+				config.Preprocess = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-robust-decode":
+			config.RobustDecode = true
+		case "-extract-images":
+			extractImages = true
+		case "-render-images":
+			config.RenderPageImages = true
+		case "-image-format":
+			if i+1 < len(args) {
+				config.ImageFormat = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-min-image-size":
+			if i+1 < len(args) {
+				w, h, err := pdfocr.ParseImageSize(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				config.MinImageWidth, config.MinImageHeight = w, h
+				i++
+			}
+		}
+	}
+
+	if err := pdfocr.ValidateFormat(format); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if err := pdfocr.ValidateProgress(progress); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if progress == pdfocr.ProgressJSON {
+		config.Progress = pdfocr.JSONProgress(os.Stderr)
+	}
+	switch format {
+	case pdfocr.FormatJSON, pdfocr.FormatALTO, pdfocr.FormatTSV:
+		config.WordBoxes = true
+	case pdfocr.FormatHOCR:
+		config.HOCR = true
+	case pdfocr.FormatMarkdown:
+		config.Markdown = true
+	}
+	// Flushes write partial documents in the format of the output
+	config.OutputFormat = format
+	if err := config.Validate(); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if ignoreFile != "" && ignoreTemplate == "" {
+		// Without a named template, pages pick their template by fingerprint
+		templates, err := pdfocr.LoadRegionTemplates(ignoreFile, config.Renderer)
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		switch {
+		case len(templates) == 1 && !templates[0].Matchable():
+			config.IgnoreRegions = append(config.IgnoreRegions, templates[0].Regions...)
+		default:
+			for _, t := range templates {
+				if !t.Matchable() {
+					fatalf("Error: template %s in %s has no reference page; register one with \"pdf-ocr-tool template\" or choose a template with -ignore-template (available: %s)\n",
+						t.Name, ignoreFile, templateNames(templates))
+				}
+			}
+			config.RegionTemplates = templates
+		}
+	} else if ignoreFile != "" {
+		regions, err := pdfocr.LoadIgnoreTemplate(ignoreFile, ignoreTemplate)
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		config.IgnoreRegions = append(config.IgnoreRegions, regions...)
+	} else if ignoreTemplate != "" {
+		fatalf("Error: -ignore-template requires -ignore-regions\n")
+	}
+	if fast && best {
+		fatalf("Error: -fast and -best cannot be combined\n")
+	}
+	if fast {
+		pdfocr.ApplyFast(&config, dpiGiven)
+	}
+	if best {
+		pdfocr.ApplyBest(&config, dpiGiven)
+	}
+	if dictFile != "" {
+		dict, err := pdfocr.LoadDictionary(dictFile)
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		config.Dictionary = dict
+	}
+	if previewPages > 0 {
+		pdfocr.ApplyPreview(&config, previewPages)
+	}
+	if maxCPUs > 0 {
+		pdfocr.ApplyCPULimit(&config, maxCPUs)
+	}
+	if config.Workers > 1 && config.OCRThreads == 0 {
+		// Parallel pages already use the cores; Tesseract's own threads
+		// would only compete with them
+		config.OCRThreads = 1
+	}
+	if err := pdfocr.SetOCRThreads(config.OCRThreads); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if niceLevel != nil {
+		if err := pdfocr.SetNice(*niceLevel); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+	}
+	if keyFile != "" {
+		key, err := pdfocr.LoadEncryptionKey(keyFile)
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		if err := pdfocr.CheckEncryptedRun(config, extractImages); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		config.EncryptKey = key
+	}
+	if config.NoDisk {
+		if err := pdfocr.CheckNoDisk(config, extractImages, bundleFile); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+	}
+	if calibrationFile != "" {
+		calibration, err := pdfocr.LoadCalibration(calibrationFile)
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		config.Calibration = calibration
+	}
+	for _, spec := range ignoreSpecs {
+		region, err := pdfocr.ParseIgnoreRegion(spec)
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		config.IgnoreRegions = append(config.IgnoreRegions, region)
+	}
+
+	if audit.File != "" && config.NoDisk {
+		fatalf("Error: -no-disk cannot be combined with -audit-log; use -audit-syslog\n")
+	}
+	if format == pdfocr.FormatPDF && config.NoDisk {
+		fatalf("Error: -no-disk cannot be combined with -format pdf\n")
+	}
+	if tocFile != "" && config.NoDisk {
+		fatalf("Error: -no-disk cannot be combined with -toc\n")
+	}
+	if err := pdfocr.CheckCompressor(tocFile); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	if splitDocs && (config.OutputFile == "" || (format != pdfocr.FormatText && format != "")) {
+		fatalf("Error: -split-docs writes text files next to -o and needs -o with -format text\n")
+	}
+	if format == pdfocr.FormatPDF && bundleFile != "" {
+		fatalf("Error: -bundle packages text outputs and cannot be combined with -format pdf\n")
+	}
+
+	var bundle *pdfocr.Bundle
+	if bundleFile != "" {
+		bundle = pdfocr.NewBundle()
+		teeLog(bundle.Log())
+	}
+
+	// Extract images if requested
+	if extractImages {
+		rec := audit.Record("extract-images", pdfPath, args[2:], config)
+		outputDir := strings.TrimSuffix(pdfPath, filepath.Ext(pdfPath)) + "_images"
+		printf("Extracting images to: %s\n", outputDir)
+		if err := pdfocr.ExtractImagesFromPDF(pdfPath, outputDir, config); err != nil {
+			rec.Error = err.Error()
+			writeAudit(&audit, rec)
+			fatalf("Error extracting images: %v\n", err)
+		}
+		rec.AddOutput(filepath.Join(outputDir, pdfocr.ChecksumsFile))
+		if bundle != nil {
+			if err := bundle.AddDir("images", outputDir); err != nil {
+				fatalf("Error: %v\n", err)
+			}
+			writeBundle(bundle, bundleFile, config.EncryptKey)
+			rec.AddOutput(bundleFile)
+		}
+		writeAudit(&audit, rec)
+		return
+	}
+
+	if format == pdfocr.FormatPDF {
+		outPath := config.OutputFile
+		if outPath == "" {
+			outPath = pdfocr.SearchablePDFPath(pdfPath)
+		}
+		rec := audit.Record("searchable-pdf", pdfPath, args[2:], config)
+		ctx, stop := interruptContext()
+		outline, err := pdfocr.WriteSearchablePDF(ctx, pdfPath, outPath, config)
+		stop()
+		if err != nil {
+			rec.Error = err.Error()
+			writeAudit(&audit, rec)
+			fatalf("Error: %v\n", err)
+		}
+		rec.AddOutput(outPath)
+		if tocFile != "" {
+			writeTOC(tocFile, outline, config.EncryptKey)
+			rec.AddOutput(tocFile)
+		}
+		writeAudit(&audit, rec)
+		printf("Searchable PDF saved to: %s\n", outPath)
+		return
+	}
+
+	// Extract text from PDF
+	rec := audit.Record("extract", pdfPath, args[2:], config)
+	ctx, stop := interruptContext()
+	started := time.Now()
+	text, manifest, err := pdfocr.ExtractDocument(ctx, pdfPath, config)
+	stop()
+	if fast && err == nil {
+		elapsed := time.Since(started)
+		printf("Fast mode: %d pages in %s (%.1f pages/s)\n", len(manifest.PageResults), elapsed.Round(time.Millisecond),
+			float64(len(manifest.PageResults))/elapsed.Seconds())
+	}
+	if errors.Is(err, context.Canceled) {
+		// Interrupted runs output the pages done, like -max-pages
+		rec.Error = err.Error()
+		warnf("Warning: %v\n", err)
+	} else if err != nil {
+		rec.Error = err.Error()
+		writeAudit(&audit, rec)
+		fatalf("Error extracting text: %v\n", err)
+	}
+	rec.Pages, rec.Truncated = manifest.Pages, manifest.Truncated
+
+	if manifest.Truncated {
+		warnf("Warning: output is truncated, not every page was processed\n")
+	}
+	if manifest.Stats != nil {
+		printStats(manifest.Stats)
+	}
+	if len(config.Keywords) > 0 {
+		if m := manifest.Keyword; m != nil {
+			printf("Found %q on page %d of %s\n", m.Term, m.Page, manifest.Name)
+		} else {
+			printf("None of the terms found in %s\n", manifest.Name)
+		}
+	}
+
+	if config.ManifestFile != "" {
+		if err := pdfocr.WriteManifest(config.ManifestFile, manifest, config.EncryptKey); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		rec.AddOutput(config.ManifestFile)
+	}
+	if tocFile != "" {
+		writeTOC(tocFile, manifest.Outline, config.EncryptKey)
+		rec.AddOutput(tocFile)
+	}
+	if config.PagesDir != "" {
+		if err := pdfocr.WritePagesDir(config.PagesDir, manifest, config); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		rec.AddOutput(filepath.Join(config.PagesDir, "index.json"))
+	}
+	if config.TablesDir != "" {
+		if err := pdfocr.WriteTablesDir(config.TablesDir, manifest); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		tables := 0
+		for _, p := range manifest.PageResults {
+			for k := range p.Tables {
+				rec.AddOutput(filepath.Join(config.TablesDir, pdfocr.TableFileName(p.Page, k+1)))
+				tables++
+			}
+		}
+		printf("%d tables saved to: %s\n", tables, config.TablesDir)
+	}
+	if config.METSDir != "" {
+		if err := pdfocr.WriteMETSPackage(config.METSDir, pdfPath, manifest, config); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		rec.AddOutput(filepath.Join(config.METSDir, "mets.xml"))
+		printf("METS package saved to: %s\n", config.METSDir)
+	}
+	if config.IIIFDir != "" {
+		if err := pdfocr.WriteIIIFPackage(config.IIIFDir, config.IIIFBaseURL, pdfPath, manifest, config); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		rec.AddOutput(filepath.Join(config.IIIFDir, "manifest.json"))
+		printf("IIIF manifest saved to: %s\n", filepath.Join(config.IIIFDir, "manifest.json"))
+	}
+
+	formatted, err := pdfocr.FormatOutput(text, config)
+	if err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	data, err := pdfocr.DocumentOutput(format, text, manifest, config)
+	if err != nil {
+		fatalf("Error: %v\n", err)
+	}
+
+	// Output the result
+	if config.OutputFile != "" {
+		if data, err = pdfocr.SealOutput(config.OutputFile, data, config.EncryptKey); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		if err := os.WriteFile(config.OutputFile, data, 0644); err != nil {
+			fatalf("Error writing to file: %v\n", err)
+		}
+		rec.AddOutput(config.OutputFile)
+		printf("Text extracted successfully and saved to: %s\n", config.OutputFile)
+		if splitDocs {
+			files, err := pdfocr.WriteDocumentParts(config.OutputFile, manifest, config)
+			if err != nil {
+				fatalf("Error: %v\n", err)
+			}
+			for i, f := range files {
+				rec.AddOutput(f)
+				printf("Document %d saved to: %s\n", i+1, f)
+			}
+		}
+	} else if format == pdfocr.FormatJSON || format == pdfocr.FormatHOCR || format == pdfocr.FormatALTO || format == pdfocr.FormatMarkdown || format == pdfocr.FormatTSV {
+		// The document alone, without the text banner
+		os.Stdout.Write(data)
+		rec.AddOutputData("-", data)
+	} else {
+		if _, ok := uiLog.Handler().(uiHandler); ok {
+			printf("\n=== Extracted Text ===\n\n")
+		}
+		os.Stdout.Write(data)
+		fmt.Println()
+		rec.AddOutputData("-", data)
+	}
+	syncOfflineQueue(config)
+
+	if bundle != nil {
+		if err := bundle.AddRunArtifacts(pdfPath, formatted, manifest, config); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		writeBundle(bundle, bundleFile, config.EncryptKey)
+		rec.AddOutput(bundleFile)
+	}
+	writeAudit(&audit, rec)
+}
+
+// interruptContext returns a context canceled by the first interrupt or
+// termination signal. A second interrupt ends the process as usual.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+			printLine("Interrupted: stopping after the current page (interrupt again to quit now)")
+			signal.Stop(sigs)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel()
+	}
+}
+
+// writeAudit appends a record to the audit log, if one is enabled, or exits.
+func writeAudit(audit *pdfocr.AuditLog, rec pdfocr.AuditRecord) {
+	if !audit.Enabled() {
+		return
+	}
+	if err := audit.Write(rec); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+}
+
+// printStats prints the text statistics of a document.
+func printStats(s *pdfocr.TextStats) {
+	printf("Statistics: %d words, %d characters, %d sentences\n", s.Words, s.Characters, s.Sentences)
+	if s.Language != "" {
+		printf("Estimated language: %s\n", s.Language)
+	}
+	if r := s.Readability; r != nil {
+		printf("Readability: Flesch reading ease %.1f, grade level %.1f\n", r.ReadingEase, r.Grade)
+	}
+	if len(s.TopTerms) > 0 {
+		terms := make([]string, len(s.TopTerms))
+		for i, t := range s.TopTerms {
+			terms[i] = fmt.Sprintf("%s (%d)", t.Term, t.Count)
+		}
+		printf("Top terms: %s\n", strings.Join(terms, ", "))
+	}
+}
+
+// writeTOC saves the -toc outline, or exits.
+func writeTOC(file string, outline []pdfocr.OutlineEntry, key []byte) {
+	if err := pdfocr.WriteOutline(file, outline, key); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	printf("Table of contents (%d headings) saved to: %s\n", len(outline), file)
+}
+
+// writeBundle saves the -bundle archive, encrypted if key is set, or exits.
+func writeBundle(bundle *pdfocr.Bundle, file string, key []byte) {
+	if err := bundle.Save(file, key); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	printf("Bundle saved to: %s\n", file)
+}
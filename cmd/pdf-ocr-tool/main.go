@@ -0,0 +1,207 @@
+// Command pdf-ocr-tool extracts the text of PDF documents, running OCR on
+// pages without a usable text layer. The work is done by package pdfocr.
+package main
+
+import (
+	"log"
+	"os"
+
+	"ocr-tool/pdfocr"
+)
+
+func main() {
+	args := os.Args
+	if err := pdfocr.SetUILanguage(uiLanguage(args)); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	if err := configureLogging(args); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	if len(args) < 2 {
+		printLine("PDF OCR Text Extraction Tool")
+		printLine("\nUsage:")
+		printLine("  pdf-ocr-tool <pdf-or-image-file> [options]  (images: PNG, JPEG, and TIFF with all its pages)")
+		printLine("  pdf-ocr-tool version [--verbose]")
+		printLine("  pdf-ocr-tool bench [-pages <n>] [-ocr-threads <n>] [-lang <language>] [-renderer <name>] [-engine <name>]")
+		printLine("  pdf-ocr-tool demo [-renderer <name>] [-engine <name>]")
+		printLine("  pdf-ocr-tool doctor [-lang <language>] [-renderer <name>] [-engine <name>]")
+		printLine("  pdf-ocr-tool calibrate [-lang <language>] [-engine <name>] [-o <file>] <doc.pdf>...")
+		printLine("  pdf-ocr-tool eval [-corpus <dir>] [-engines <name,...>] [-baseline <file>]  (character error rate per engine and language)")
+		printLine("  pdf-ocr-tool verify <images-dir>  (check extracted images against their SHA256SUMS)")
+		printLine("  pdf-ocr-tool decrypt -key <keyfile> <file> [-o <output>]  (restore a file written with -encrypt-key)")
+		printLine("  pdf-ocr-tool sync <queue-dir> [-watch <interval>]  (send the pages of an -offline-queue to their cloud engine)")
+		printLine("  pdf-ocr-tool batch <dir-or-glob>... -out <dir> [-jobs <n>] [-format text|json|tsv] [-force]  (run 'batch' for all options)")
+		printLine("  pdf-ocr-tool serve [-addr <host:port>] [-jobs <n>] [-lang <language>]  (OCR REST API: POST /ocr, /jobs, GET /healthz)")
+		printLine("  pdf-ocr-tool schema [<name>] [-o <dir>]  (JSON Schemas of the manifest, json, pages, audit, batch and progress outputs)")
+		printLine("  pdf-ocr-tool template <templates.json> <name> <reference.pdf|image> [-page n]")
+		printLine("\nOptions:")
+		printLine("  -o <output-file>    Save extracted text to file (.gz or .zst compresses it; .zst needs zstd)")
+		printLine("  -format <f>         Output: text (default), json (pages with their method, text and OCR'd words")
+		printLine("                      with boxes and confidences), hocr or alto (layout XML positioned in pixels")
+		printLine("                      of the pages as rendered for OCR), md (Markdown with headings from the")
+		printLine("                      font size or line height, lists and a rule between pages), pdf (a")
+		printLine("                      searchable copy with an invisible text layer, saved to -o or <name>_ocr.pdf")
+		printLine("                      next to the original) or tsv (Tesseract's TSV rows of words with boxes)")
+		printLine("  -lang <language>    OCR language, or several joined with + (e.g. eng+swa) (default: eng)")
+		printLine("  -auto-lang          Pick the languages of every OCR'd page from its script and common words")
+		printLine("                      (Tesseract OSD; with several -lang languages, only those)")
+		printLine("  -dpi <dpi>          Render resolution of pages for OCR (default: 300; higher helps small print)")
+		printLine("  -layout             Preserve layout during OCR")
+		printLine("  -extract-images     Extract all images to a directory, with a SHA256SUMS file")
+		printLine("                      (the embedded images in their own format and resolution)")
+		printLine("  -render-images      With -extract-images, write one render of each page instead")
+		printLine("  -image-format <f>   With -extract-images, convert images to png or jpeg")
+		printLine("  -min-image-size <WxH> With -extract-images, skip images smaller than this, e.g. 200x200")
+		printLine("  -encoding <name>    Text output encoding: utf-8, utf-8-bom, utf-16le, windows-1252")
+		printLine("  -newline <style>    Line endings in text output: lf (default) or crlf")
+		printLine("  -expand-tabs <n>    Replace tabs with spaces using tab stops every n columns")
+		printLine("  -collapse-blank     Collapse runs of blank lines into one")
+		printLine("  -trim-trailing      Trim trailing whitespace from every line")
+		printLine("  -ocr-mode <m>       Pages to OCR: auto (default; pages whose text layer is missing, too short, or")
+		printLine("                      covers little of a scanned page), force (every page) or never (text layer only)")
+		printLine("  -vector-pages <m>   Vector-only pages: ocr (default), drawing (high-DPI, drawing charset), skip")
+		printLine("  -vector-dpi <dpi>   Render resolution for drawing pages (default: 600)")
+		printLine("  -attachments <m>    Embedded files: list, or process (extract text recursively)")
+		printLine("  -on-error <policy>  Failed pages: collect (default; listed as failed with their error), skip (left")
+		printLine("                      out with a warning) or fail-fast (stop the document at the first one)")
+		printLine("  -manifest <file>    Write a JSON manifest of the document and its sub-documents (.gz/.zst compress)")
+		printLine("  -page-labels        Read the printed page numbers and warn about missing, out-of-order or repeated pages")
+		printLine("  -stats              Count words, characters and sentences, estimate the language, readability and")
+		printLine("                      most frequent terms, and add them to the manifest and -format json")
+		printLine("  -spot <terms>       Stop at the first page mentioning one of these comma-separated terms (whole")
+		printLine("                      words, any case) and report it, for sweeps of archives (batch: no transcripts)")
+		printLine("  -recollate          Put pages of double-sided documents scanned out of order back in reading order")
+		printLine("                      (detected from the text flow, and the page numbers with -page-labels)")
+		printLine("  -detect-docs        Find the documents of a batch scan: after blank separator pages, where page")
+		printLine("                      numbers restart or where the letterhead changes (listed in the manifest)")
+		printLine("  -split-docs         Also write the text of each document to <output>_docN next to -o")
+		printLine("  -toc <file>         Detect headings on OCR'd pages and write a table of contents (Markdown, or JSON")
+		printLine("                      for .json); searchable PDFs also get them as bookmarks")
+		printLine("  -bundle <file.zip>  Package the text, manifest, page files, side outputs and log into a ZIP")
+		printLine("  -encrypt-key <file> Encrypt -o, -manifest and -bundle with AES-256-GCM (32-byte key file)")
+		printLine("  -no-disk            Process in memory only and print the text; refuses options that write files")
+		printLine("  -audit-log <file>   Append a JSON record of the run (user, settings, input and output hashes)")
+		printLine("  -audit-syslog       Also send the audit record to syslog")
+		printLine("  -mets <dir>         Write a METS package: page images, page texts and mets.xml with checksums")
+		printLine("  -iiif <dir>         Write page images and a IIIF Presentation 3 manifest with the text as annotations")
+		printLine("  -iiif-base <url>    URL the -iiif directory will be served from (default: a file:// URL)")
+		printLine("  -pages <list>       Process only these pages, e.g. 1-5,10,20- (also for -extract-images)")
+		printLine("  -pages-dir <dir>    Write index.json and one JSON file per page (pages/000001.json, ...)")
+		printLine("  -tables <dir>       Find tables (aligned columns of words or text lines) and write each as")
+		printLine("                      page_<n>_table_<k>.csv; also listed per page in -format json and -pages-dir")
+		printLine("  -xfa-out <file>     Save the XFA form XML of XFA-based forms")
+		printLine("  -auto-rotate        Turn sideways and upside-down pages upright before OCR (Tesseract OSD,")
+		printLine("                      needs osd.traineddata)")
+		printLine("  -preprocess <steps> Clean up page images before OCR: auto (per page from noise, skew and")
+		printLine("                      contrast), or a list of grayscale, contrast, denoise, deskew, binarize for")
+		printLine("                      every page, e.g. deskew,binarize")
+		printLine("  -robust-decode      Re-render JBIG2/CCITT pages that MuPDF renders blank (uses pdftoppm if installed)")
+		printLine("  -renderer <name>    Page rendering backend: mupdf (default) or poppler")
+		printLine("  -engine <name>      OCR engine: tesseract (default), tesseract-cli, a cloud service uploading the")
+		printLine("                      page images: google-vision, aws-textract or azure-read, or onnx (CPU) or gpu")
+		printLine("                      (CUDA) for ONNX Runtime models in builds with the onnx tag (see the README)")
+		printLine("  -cloud-batch <n>    Keep 2n pages in flight to a cloud engine, sent n per request to google-vision")
+		printLine("  -offline-queue <dir> Queue the pages a cloud engine cannot be reached for in dir, recognizing them")
+		printLine("                      locally meanwhile (marked provisional); the run ends by sending them and updating -o")
+		printLine("  -links              Append the links found in the document (annotations and OCR'd URLs)")
+		printLine("  -normalize-locale <l> Rewrite amounts and dates written in locale l (e.g. de-DE) to ISO formats")
+		printLine("  -merge-native       Also OCR pages with a text layer and keep the better source per line (tags lines)")
+		printLine("  -debug-overlay <dir> Save OCR'd page images with word boxes colored by confidence (red < 50 < yellow < green)")
+		printLine("                      as <name>_page_<n>_<run>.png, the run ID keeping runs sharing <dir> apart")
+		printLine("  -annotations        Add the text of stamp and free-text annotations (OCRs their appearance)")
+		printLine("  -ignore <region>    Leave a page area out of OCR and text: [pages:]x0,y0,x1,y1 from the top-left,")
+		printLine("                      fractions of the page or points with a pt suffix (repeatable)")
+		printLine("  -ignore-regions <f> JSON file of ignore region templates by document type")
+		printLine("  -ignore-template <n> Template to use from -ignore-regions; by default each page uses the")
+		printLine("                      template whose reference page it resembles (see the template command)")
+		printLine("  -workers <n>        OCR n pages in parallel (default: 1; Tesseract then uses one thread per page)")
+		printLine("  -ocr-threads <n>    Cap Tesseract's OpenMP threads per page (sets OMP_THREAD_LIMIT)")
+		printLine("  -tess-clients <n>   Keep n loaded Tesseract clients for reuse (default: one per worker)")
+		printLine("  -psm <n>            Tesseract page segmentation mode, e.g. 6 for a single block of text (1-13)")
+		printLine("  -oem <n>            Tesseract OCR engine mode: 0 legacy, 1 LSTM, 2 both or 3 the traineddata's default")
+		printLine("  -tess-param <n=v>   Set a Tesseract variable, e.g. tessedit_char_whitelist=0123456789 (repeatable)")
+		printLine("  -max-cpu <pct>%     Use at most this share of the CPUs (e.g. 50%)")
+		printLine("  -nice <level>       Run at a lower scheduling priority (0-19, like nice)")
+		printLine("  -tessdata <dir>     Directory of the traineddata files (default: the engine's)")
+		printLine("  -onnx-models <d,r,c> Detection model, recognition model and charset of the onnx and gpu engines:")
+		printLine("                      paths, or URLs fetched once (optional #sha256=<hex>) into -model-cache")
+		printLine("  -model-cache <dir>  Directory of the models fetched by URL (default: pdf-ocr-tool/models in the")
+		printLine("                      user cache directory)")
+		printLine("  -fast               Triage speed: 150 DPI unless -dpi, tessdata_fast models if installed, no")
+		printLine("                      preprocessing, and no OCR of blank or duplicate pages")
+		printLine("  -best               Archival quality: 400 DPI unless -dpi, tessdata_best models if installed,")
+		printLine("                      per-page preprocessing, three voting passes and retries of poor pages")
+		printLine("  -two-pass           Find the text, table and sparse regions of OCR'd pages first, then recognize")
+		printLine("                      each with settings for its kind (tables one row per line, cells tab-separated)")
+		printLine("  -only <kinds>       With -two-pass, keep only the text of these regions: body-text, table, sparse")
+		printLine("                      (comma-separated), on text layer pages too")
+		printLine("  -dict <file>        Word list (one per line) for correcting doubtful words in -best runs")
+		printLine("  -preview <n>        Quick look at the first n pages: 150 DPI and tessdata_fast models if installed")
+		printLine("  -min-confidence <c> Recognize OCR'd pages with a mean word confidence below c (0-100) again, at a")
+		printLine("                      higher DPI and as sparse text, and flag those still below for review (low_confidence")
+		printLine("                      in -format json and -pages-dir, low_confidence_pages in the manifest)")
+		printLine("  -max-pages <n>      Stop after n pages (attachments included) and output what was done")
+		printLine("  -max-duration <d>   Stop starting new pages after duration d (e.g. 90s, 5m)")
+		printLine("  -flush-every <n>    Rewrite the -o file with the pages done so far every n pages, in -format")
+		printLine("  -calibration <file> Map word confidences per engine and language (see the calibrate command)")
+		printLine("  -ui-lang <lang>     Language of the messages: en or sw (default: from LANG)")
+		printLine("  -quiet              Log only warnings and errors (messages go to stderr, the text to stdout)")
+		printLine("  -v                  Log page-level details as well, such as preprocessing and rotation")
+		printLine("  -log-format <f>     Messages as text (default) or json, one object per line on stderr")
+		printLine("  -progress <mode>    Page progress: text messages (default), or json events on stderr, one per line")
+		printLine("  -preset <name>      Settings preset: chart (charts/diagrams: 400 DPI, sparse text, one label per line)")
+		printLine("\nExamples:")
+		printLine("  pdf-ocr-tool document.pdf")
+		printLine("  pdf-ocr-tool scanned.pdf -o output.txt -lang eng")
+		printLine("  pdf-ocr-tool document.pdf -extract-images")
+		os.Exit(1)
+	}
+
+	switch args[1] {
+	case "version", "-version", "--version":
+		runVersion(args[2:])
+		return
+	case "bench":
+		runBench(args[2:])
+		return
+	case "demo":
+		runDemo(args[2:])
+		return
+	case "doctor":
+		runDoctor(args[2:])
+		return
+	case "template":
+		runTemplate(args[2:])
+		return
+	case "calibrate":
+		runCalibrate(args[2:])
+		return
+	case "eval":
+		runEval(args[2:])
+		return
+	case "verify":
+		runVerify(args[2:])
+		return
+	case "decrypt":
+		runDecrypt(args[2:])
+		return
+	case "sync":
+		runSync(args[2:])
+		return
+	case "schema":
+		runSchema(args[2:])
+		return
+	case "batch":
+		runBatch(args[2:])
+		return
+	case "serve":
+		runServe(args[2:])
+		return
+	case pdfocr.SandboxWorkerCommand:
+		if err := pdfocr.RunSandboxWorker(args[2:]); err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		return
+	}
+	runExtract(args)
+}
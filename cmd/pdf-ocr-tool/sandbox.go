@@ -0,0 +1,23 @@
+package main
+
+// defaultSandboxMemory is the address space limit of sandbox workers in MB,
+// unless -sandbox-memory sets another.
+const defaultSandboxMemory = 4096
+
+// sandboxWorkerArgs returns the options of a command line that workers
+// share with it: the UI language, the logging options and -progress.
+func sandboxWorkerArgs(args []string) []string {
+	var shared []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-quiet", "-v", "-verbose":
+			shared = append(shared, args[i])
+		case "-ui-lang", "-log-format", "-progress":
+			if i+1 < len(args) {
+				shared = append(shared, args[i], args[i+1])
+				i++
+			}
+		}
+	}
+	return shared
+}
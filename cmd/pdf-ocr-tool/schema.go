@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"ocr-tool/pdfocr"
+)
+
+// runSchema prints the schema named by args, lists the schemas without
+// one, and writes them all to a directory with -o.
+func runSchema(args []string) {
+	var name, dir string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-o":
+			if i+1 < len(args) {
+				dir = args[i+1]
+				i++
+			}
+		default:
+			name = args[i]
+		}
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatalf("Error: %v\n", err)
+		}
+		for _, n := range pdfocr.SchemaNames() {
+			data, _ := pdfocr.Schema(n)
+			if err := os.WriteFile(filepath.Join(dir, n+".schema.json"), data, 0644); err != nil {
+				log.Fatalf("Error: %v\n", err)
+			}
+		}
+		return
+	}
+	if name == "" {
+		fmt.Printf("Schemas (version %s; print one with pdf-ocr-tool schema <name>):\n", pdfocr.SchemaVersion)
+		for _, n := range pdfocr.SchemaNames() {
+			fmt.Printf("  %s\n", n)
+		}
+		return
+	}
+	data, err := pdfocr.Schema(name)
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	os.Stdout.Write(data)
+}
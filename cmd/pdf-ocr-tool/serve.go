@@ -0,0 +1,299 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"ocr-tool/pdfocr"
+)
+
+// runServe implements the "serve" subcommand: it serves the OCR REST API
+// on -addr until interrupted, then lets running extractions stop after
+// their current page and answers them as retryable.
+func runServe(args []string) {
+	config := pdfocr.DefaultConfig()
+	addr := ":8080"
+	jobs := runtime.NumCPU()
+	var opts pdfocr.ServerOptions
+	fast, best, dpiGiven := false, false, false
+	sandboxed, sandboxMemory, sandboxTimeout := false, defaultSandboxMemory, time.Duration(0)
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-addr":
+			if i+1 < len(args) {
+				addr = args[i+1]
+				i++
+			}
+		case "-max-upload":
+			if i+1 < len(args) {
+				n, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -max-upload value %q\n", args[i+1])
+				}
+				opts.MaxUpload = n << 20
+				i++
+			}
+		case "-jobs":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -jobs value %q\n", args[i+1])
+				}
+				jobs = n
+				i++
+			}
+		case "-allow-types":
+			if i+1 < len(args) {
+				opts.AllowTypes = args[i+1]
+				i++
+			}
+		case "-clamd":
+			if i+1 < len(args) {
+				opts.Clamd = append(opts.Clamd, args[i+1])
+				i++
+			}
+		case "-icap":
+			if i+1 < len(args) {
+				opts.ICAP = append(opts.ICAP, args[i+1])
+				i++
+			}
+		case "-sandbox":
+			sandboxed = true
+		case "-sandbox-memory":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 0 {
+					fatalf("Error: invalid -sandbox-memory value %q\n", args[i+1])
+				}
+				sandboxMemory = n
+				i++
+			}
+		case "-sandbox-timeout":
+			if i+1 < len(args) {
+				d, err := time.ParseDuration(args[i+1])
+				if err != nil || d < 0 {
+					fatalf("Error: invalid -sandbox-timeout value %q\n", args[i+1])
+				}
+				sandboxTimeout = d
+				i++
+			}
+		case "-job-ttl", "-scratch-ttl":
+			if i+1 < len(args) {
+				d, err := time.ParseDuration(args[i+1])
+				if err != nil || d < 0 {
+					fatalf("Error: invalid %s value %q\n", args[i], args[i+1])
+				}
+				if d == 0 {
+					// Keep no jobs, or leave the scratch files alone
+					d = -1
+				}
+				if args[i] == "-job-ttl" {
+					opts.JobTTL = d
+				} else {
+					opts.ScratchTTL = d
+				}
+				i++
+			}
+		case "-job-memory":
+			if i+1 < len(args) {
+				n, err := strconv.ParseInt(args[i+1], 10, 64)
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -job-memory value %q\n", args[i+1])
+				}
+				opts.JobMemory = n << 20
+				i++
+			}
+		case "-s3":
+			if i+1 < len(args) {
+				opts.S3 = append(opts.S3, args[i+1])
+				i++
+			}
+		case "-lang":
+			if i+1 < len(args) {
+				config.Language = args[i+1]
+				i++
+			}
+		case "-dpi":
+			if i+1 < len(args) {
+				dpi, err := strconv.ParseFloat(args[i+1], 64)
+				if err != nil || dpi <= 0 {
+					fatalf("Error: invalid -dpi value %q\n", args[i+1])
+				}
+				config.DPI, dpiGiven = dpi, true
+				i++
+			}
+		case "-ocr-mode":
+			if i+1 < len(args) {
+				config.OCRMode = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-renderer":
+			if i+1 < len(args) {
+				config.Renderer = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-engine":
+			if i+1 < len(args) {
+				config.Engine = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-tess-clients":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 {
+					fatalf("Error: invalid -tess-clients value %q\n", args[i+1])
+				}
+				config.TessClients = n
+				i++
+			}
+		case "-psm":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 1 || n > pdfocr.MaxPSM {
+					fatalf("Error: invalid -psm value %q\n", args[i+1])
+				}
+				config.PSM = n
+				i++
+			}
+		case "-oem":
+			if i+1 < len(args) {
+				oem, err := pdfocr.ParseOEM(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				config.OEM = oem
+				i++
+			}
+		case "-tess-param":
+			if i+1 < len(args) {
+				name, value, err := pdfocr.ParseTessParam(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				if config.TessParams == nil {
+					config.TessParams = map[string]string{}
+				}
+				config.TessParams[name] = value
+				i++
+			}
+		case "-tessdata":
+			if i+1 < len(args) {
+				config.TessdataDir = args[i+1]
+				i++
+			}
+		case "-onnx-models":
+			if i+1 < len(args) {
+				models, err := pdfocr.ParseONNXModels(args[i+1])
+				if err != nil {
+					fatalf("Error: %v\n", err)
+				}
+				config.ONNXModels = models
+				i++
+			}
+		case "-model-cache":
+			if i+1 < len(args) {
+				config.ModelCache = args[i+1]
+				i++
+			}
+		case "-quiet", "-v", "-verbose":
+			// Applied by configureLogging
+		case "-log-format":
+			if i+1 < len(args) {
+				i++
+			}
+		case "-fast":
+			fast = true
+		case "-best":
+			best = true
+		default:
+			printLine("Usage: pdf-ocr-tool serve [-addr <host:port>] [-jobs <n>] [-max-upload <MB>] [-lang <language>]")
+			printLine("                          [-dpi <dpi>] [-fast|-best] [-ocr-mode <m>] [-engine <name>] [-renderer <name>] [-tessdata <dir>]")
+			printLine("                          [-s3 <s3://bucket/prefix,...>] [-tess-clients <n>] [-quiet|-v] [-log-format text|json]")
+			printLine("                          [-psm <n>] [-oem <n>] [-tess-param <name=value>]...")
+			printLine("                          [-onnx-models <det,rec,charset>] [-model-cache <dir>]")
+			printLine("                          [-allow-types <pdf,png,jpeg,tiff>] [-clamd <host:port|socket>] [-icap <icap://host/service>]")
+			printLine("                          [-sandbox] [-sandbox-memory <MB>] [-sandbox-timeout <d>]")
+			printLine("                          [-job-ttl <d>] [-job-memory <MB>] [-scratch-ttl <d>]")
+			printLine("\n  POST /ocr     multipart/form-data with the PDF in field \"file\"; query: lang, format")
+			printLine("                (text, json, hocr, alto, md or tsv), pages (e.g. 1-5,10) and deadline (e.g. 90s or an")
+			printLine("                RFC 3339 time; jobs run earliest deadline first, degraded to meet it: X-Degraded),")
+			printLine("                s3 (an s3:// prefix under -s3 to upload the result to) and presign (e.g. 1h,")
+			printLine("                for a download URL; alone, uploads under the first -s3 prefix), and boxes=true")
+			printLine("                to record the word boxes of the job; X-Job-ID names the job, kept for -job-ttl")
+			printLine("                (default 1h, 0 keeps none) while the jobs take under -job-memory (default 256 MB;")
+			printLine("                the oldest are dropped sooner beyond it).")
+			printLine("                The result is compressed with gzip or zstd as Accept-Encoding allows")
+			printLine("  GET /jobs/{id}/pages/{n}  page n of a job: text, confidence, boxes and a PNG thumbnail")
+			printLine("  DELETE /jobs/{id}         drops a job before it expires")
+			printLine("\n  A janitor removes expired jobs, and scratch files that killed runs left in the")
+			printLine("  temporary directory once they are -scratch-ttl old (default 24h, 0 leaves them)")
+			printLine("  GET /healthz  {\"status\":\"ok\"} while the server is up")
+			os.Exit(1)
+		}
+	}
+	if fast && best {
+		fatalf("Error: -fast and -best cannot be combined\n")
+	}
+	if fast {
+		pdfocr.ApplyFast(&config, dpiGiven)
+	}
+	if best {
+		pdfocr.ApplyBest(&config, dpiGiven)
+	}
+	if jobs > 1 {
+		// Documents run in parallel instead of Tesseract's threads
+		config.OCRThreads = 1
+	}
+	if config.TessClients == 0 {
+		// One Tesseract client per document in progress
+		config.TessClients = max(jobs, 1)
+	}
+	if err := pdfocr.SetOCRThreads(config.OCRThreads); err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	opts.Jobs = jobs
+	if sandboxed {
+		sandbox, err := pdfocr.NewSandbox(pdfocr.SandboxOptions{Workers: jobs, MemoryMB: sandboxMemory, Timeout: sandboxTimeout,
+			Network: pdfocr.EngineIsRemote(config.Engine), Args: sandboxWorkerArgs(args), Stderr: logWriter{}})
+		if err != nil {
+			fatalf("Error: %v\n", err)
+		}
+		defer sandbox.Close()
+		opts.Sandbox = sandbox
+	}
+	handler, err := pdfocr.NewServer(config, opts)
+	if err != nil {
+		fatalf("Error: %v\n", err)
+	}
+	defer handler.Close()
+	ctx, stop := interruptContext()
+	defer stop()
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	errs := make(chan error, 1)
+	go func() { errs <- server.ListenAndServe() }()
+	printf("Serving the OCR API on %s (POST /ocr, /jobs, GET /healthz), %d documents at a time\n", addr, jobs)
+	if opts.Sandbox != nil {
+		printf("Documents are processed by %d sandbox workers\n", jobs)
+	}
+	select {
+	case err := <-errs:
+		fatalf("Error: %v\n", err)
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := server.Shutdown(shutdown); err != nil {
+		warnf("Warning: %v\n", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"ocr-tool/pdfocr"
+)
+
+// runSync implements the "sync" subcommand, which sends the pages waiting
+// in an -offline-queue to their cloud engine, once or every -watch
+// interval until interrupted.
+func runSync(args []string) {
+	config := pdfocr.DefaultConfig()
+	var dir string
+	var watch time.Duration
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-watch":
+			if i+1 < len(args) {
+				d, err := time.ParseDuration(args[i+1])
+				if err != nil || d <= 0 {
+					fatalf("Error: invalid -watch value %q\n", args[i+1])
+				}
+				watch = d
+				i++
+			}
+		default:
+			dir = args[i]
+		}
+	}
+	if dir == "" {
+		printLine("Usage: pdf-ocr-tool sync <queue-dir> [-watch <interval>]")
+		printLine("  Recognize the pages an extraction with -offline-queue queued while its cloud engine was")
+		printLine("  unreachable, and replace their provisional text in the text or JSON output they went to;")
+		printLine("  every synced page is also written to <queue-dir>/done. With -watch, try again every")
+		printLine("  interval (e.g. 5m) until interrupted, picking up pages queued meanwhile.")
+		os.Exit(1)
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	for {
+		synced, err := pdfocr.SyncQueue(ctx, dir, config)
+		printSynced(synced)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, pdfocr.ErrCloudUnreachable) && watch > 0:
+			printf("Still offline: %v\n", err)
+		case err != nil && watch == 0:
+			fatalf("Error: %v\n", err)
+		case err != nil:
+			warnf("Warning: %v\n", err)
+		}
+		if watch == 0 {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watch):
+		}
+	}
+}
+
+// syncOfflineQueue sends the pages waiting in config.OfflineQueue once a
+// run has written its outputs, so the pages it or an earlier run queued
+// are updated as soon as the cloud engine is back.
+func syncOfflineQueue(config pdfocr.OCRConfig) {
+	if config.OfflineQueue == "" {
+		return
+	}
+	ctx, stop := interruptContext()
+	defer stop()
+	synced, err := pdfocr.SyncQueue(ctx, config.OfflineQueue, config)
+	printSynced(synced)
+	if err != nil && ctx.Err() == nil {
+		printf("Pages stay queued in %s (%v); pdf-ocr-tool sync sends them later\n", config.OfflineQueue, err)
+	}
+}
+
+func printSynced(synced []pdfocr.QueuedPage) {
+	for _, p := range synced {
+		if p.Output != "" {
+			printf("Synced page %d of %s (%s) into %s\n", p.Page, p.Document, p.ID, p.Output)
+		} else {
+			printf("Synced page %d of %s (%s)\n", p.Page, p.Document, p.ID)
+		}
+	}
+}
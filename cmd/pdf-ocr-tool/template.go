@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"ocr-tool/pdfocr"
+)
+
+// runTemplate implements the "template" subcommand, which registers the
+// reference page of a document type in a template file:
+//
+//	pdf-ocr-tool template <templates.json> <name> <reference.pdf|image> [-page n] [-renderer name]
+//
+// The file and template are created if needed; existing regions are kept.
+func runTemplate(args []string) {
+	if len(args) < 3 {
+		fmt.Println("Usage: pdf-ocr-tool template <templates.json> <name> <reference.pdf|image> [-page n] [-renderer name]")
+		os.Exit(1)
+	}
+	path, name, ref := args[0], args[1], args[2]
+	page := 1
+	renderer := ""
+	for i := 3; i < len(args); i++ {
+		switch args[i] {
+		case "-page":
+			if i+1 < len(args) {
+				p, err := strconv.Atoi(args[i+1])
+				if err != nil || p < 1 {
+					log.Fatalf("Error: invalid page: %s\n", args[i+1])
+				}
+				page = p
+				i++
+			}
+		case "-renderer":
+			if i+1 < len(args) {
+				renderer = strings.ToLower(args[i+1])
+				i++
+			}
+		}
+	}
+
+	if err := pdfocr.RegisterTemplate(path, name, ref, page, renderer); err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	fmt.Printf("Registered template %s in %s\n", name, path)
+}
+
+// templateNames lists the names of templates.
+func templateNames(templates []pdfocr.RegionTemplate) string {
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		names[i] = t.Name
+	}
+	return strings.Join(names, ", ")
+}
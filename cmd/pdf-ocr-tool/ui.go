@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"ocr-tool/pdfocr"
+)
+
+// uiLanguage returns the UI language requested on the command line with
+// -ui-lang, or else by the locale environment (sw_TZ.UTF-8 giving "sw")
+// when a catalog exists for it.
+func uiLanguage(args []string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-ui-lang" {
+			return args[i+1]
+		}
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			lang, _, _ := strings.Cut(strings.ToLower(value), "_")
+			lang, _, _ = strings.Cut(lang, ".")
+			for _, l := range pdfocr.UILanguages() {
+				if l == lang {
+					return lang
+				}
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+// printf, printLine, warnf and fatalf are fmt.Printf, fmt.Println,
+// log.Printf and log.Fatalf for messages translated with pdfocr.Translate.
+// Once configureLogging has run, printf, warnf and fatalf log to uiLog at
+// the levels Info, Warn and Error instead, keeping stdout for the results;
+// printLine, for usage texts, still prints.
+func printf(format string, a ...any) {
+	if uiLog != nil {
+		logMessage(slog.LevelInfo, fmt.Sprintf(pdfocr.Translate(format), a...))
+		return
+	}
+	fmt.Printf(pdfocr.Translate(format), a...)
+}
+
+func printLine(message string) {
+	fmt.Println(pdfocr.Translate(message))
+}
+
+func warnf(format string, a ...any) {
+	if uiLog != nil {
+		logMessage(slog.LevelWarn, fmt.Sprintf(pdfocr.Translate(format), a...))
+		return
+	}
+	log.Printf(pdfocr.Translate(format), a...)
+}
+
+func fatalf(format string, a ...any) {
+	if uiLog != nil {
+		logMessage(slog.LevelError, fmt.Sprintf(pdfocr.Translate(format), a...))
+		os.Exit(1)
+	}
+	log.Fatalf(pdfocr.Translate(format), a...)
+}
+
+// logMessage logs a message to uiLog at level without its surrounding
+// newlines.
+func logMessage(level slog.Level, message string) {
+	ctx := context.Background()
+	if uiLog.Enabled(ctx, level) {
+		uiLog.Log(ctx, level, strings.Trim(message, "\n"))
+	}
+}
+
+// Log formats of the command line (-log-format).
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// uiLog is the logger of the command line, set by configureLogging, which
+// also makes it the logger of the pipeline. Before it is set, printf writes
+// to stdout and warnf to the standard logger.
+var uiLog *slog.Logger
+
+// logOutput is where the command line logs go, stderr unless a run bundle
+// also keeps a copy.
+var (
+	logMu     sync.Mutex
+	logOutput io.Writer = os.Stderr
+)
+
+// logWriter writes to logOutput, one log line at a time.
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	return logOutput.Write(p)
+}
+
+// teeLog copies the command line logs written from now on to w.
+func teeLog(w io.Writer) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logOutput = io.MultiWriter(logOutput, w)
+}
+
+// configureLogging sets up uiLog from the -quiet, -v (or -verbose) and
+// -log-format options anywhere on the command line: warnings and errors
+// only, debugging details as well, and json for one JSON object per
+// message instead of the plain messages.
+func configureLogging(args []string) error {
+	level, format := slog.LevelInfo, logFormatText
+	quiet, verbose := false, false
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-quiet":
+			quiet, level = true, slog.LevelWarn
+		case "-v", "-verbose":
+			verbose, level = true, slog.LevelDebug
+		case "-log-format":
+			if i+1 < len(args) {
+				format = strings.ToLower(args[i+1])
+				i++
+			}
+		}
+	}
+	if quiet && verbose {
+		return fmt.Errorf("-quiet and -v cannot be combined")
+	}
+	switch format {
+	case logFormatText:
+		uiLog = slog.New(uiHandler{level: level})
+	case logFormatJSON:
+		uiLog = slog.New(slog.NewJSONHandler(logWriter{}, &slog.HandlerOptions{Level: level}))
+	default:
+		return fmt.Errorf("unsupported log format %q (use text or json)", format)
+	}
+	pdfocr.SetLogger(uiLog)
+	return nil
+}
+
+// uiHandler is the slog.Handler of the text log format: every message on a
+// line of its own, attributes aside, and warnings and errors with the
+// time as the standard logger writes it.
+type uiHandler struct {
+	level slog.Level
+}
+
+func (h uiHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h uiHandler) Handle(_ context.Context, r slog.Record) error {
+	line := r.Message + "\n"
+	if r.Level >= slog.LevelWarn {
+		line = r.Time.Format("2006/01/02 15:04:05 ") + line
+	}
+	_, err := io.WriteString(logWriter{}, line)
+	return err
+}
+
+func (h uiHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h uiHandler) WithGroup(string) slog.Handler      { return h }
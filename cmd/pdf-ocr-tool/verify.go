@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"ocr-tool/pdfocr"
+)
+
+// runVerify implements the "verify" subcommand.
+func runVerify(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: pdf-ocr-tool verify <images-dir>")
+		os.Exit(1)
+	}
+	problems, err := pdfocr.VerifyChecksums(args[0])
+	if err != nil {
+		log.Fatalf("Error: %v\n", err)
+	}
+	for _, p := range problems {
+		fmt.Println("FAILED", p)
+	}
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("All files in %s match %s\n", args[0], pdfocr.ChecksumsFile)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"ocr-tool/pdfocr"
+)
+
+// outputFormats lists the output formats compiled into this binary.
+var outputFormats = []string{"text", "pages (JSON)", "METS", "IIIF"}
+
+// languageLister is implemented by engines that can report their installed
+// recognition languages.
+type languageLister interface {
+	Languages() ([]string, error)
+}
+
+// buildTags returns the build tags recorded in the binary, if any.
+func buildTags() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "-tags" {
+				return s.Value
+			}
+		}
+	}
+	return ""
+}
+
+// cpuFeatures lists SIMD features relevant to OCR throughput (Tesseract's
+// LSTM uses AVX2/FMA/SSE4.1 on x86 and NEON on ARM).
+func cpuFeatures() []string {
+	data, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return nil
+	}
+	interesting := map[string]bool{
+		"sse4_1": true, "sse4_2": true, "avx": true, "avx2": true, "fma": true,
+		"avx512f": true, "avx512bw": true, "neon": true, "asimd": true,
+	}
+	found := map[string]bool{}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key != "flags" && key != "Features" {
+			continue
+		}
+		for _, f := range strings.Fields(value) {
+			if interesting[f] {
+				found[f] = true
+			}
+		}
+		break
+	}
+	out := make([]string, 0, len(found))
+	for f := range found {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// runVersion implements the "version" subcommand.
+func runVersion(args []string) {
+	verbose := false
+	for _, arg := range args {
+		if arg == "-v" || arg == "-verbose" || arg == "--verbose" {
+			verbose = true
+		}
+	}
+
+	fmt.Printf("pdf-ocr-tool %s\n", pdfocr.Version())
+	if !verbose {
+		return
+	}
+
+	fmt.Printf("Go:          %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	if tags := buildTags(); tags != "" {
+		fmt.Printf("Build tags:  %s\n", tags)
+	}
+	fmt.Printf("CPUs:        %d\n", runtime.NumCPU())
+	if features := cpuFeatures(); len(features) > 0 {
+		fmt.Printf("CPU features: %s\n", strings.Join(features, " "))
+	}
+	fmt.Printf("OCR threads: %s\n", pdfocr.OCRThreadsDescription())
+
+	fmt.Println("\nRenderers:")
+	for _, r := range pdfocr.Renderers() {
+		marker := " "
+		if r.Name() == pdfocr.DefaultRenderer {
+			marker = "*"
+		}
+		fmt.Printf("  %s %-14s %s\n", marker, r.Name(), r.Version())
+	}
+
+	fmt.Println("\nOCR engines:")
+	for _, e := range pdfocr.Engines() {
+		marker := " "
+		if e.Name() == pdfocr.DefaultEngine {
+			marker = "*"
+		}
+		fmt.Printf("  %s %-14s %s\n", marker, e.Name(), e.Version())
+		if lister, ok := e.(languageLister); ok {
+			langs, err := lister.Languages()
+			switch {
+			case err != nil:
+				fmt.Printf("      languages: unavailable (%v)\n", err)
+			case len(langs) == 0:
+				fmt.Println("      languages: none installed")
+			default:
+				fmt.Printf("      languages: %s\n", strings.Join(langs, " "))
+			}
+		}
+	}
+
+	fmt.Printf("\nOutput formats: %s\n", strings.Join(outputFormats, ", "))
+	fmt.Printf("Encodings:      %s\n", strings.Join([]string{pdfocr.EncodingUTF8, pdfocr.EncodingUTF8BOM, pdfocr.EncodingUTF16LE, pdfocr.EncodingWindows1252}, ", "))
+
+	fallback := "not installed"
+	if path, err := exec.LookPath("pdftoppm"); err == nil {
+		fallback = path
+	}
+	fmt.Printf("pdftoppm:       %s\n", fallback)
+}
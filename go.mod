@@ -5,4 +5,5 @@ go 1.21.0
 require (
 	github.com/gen2brain/go-fitz v1.23.7
 	github.com/otiai10/gosseract/v2 v2.4.1
+	github.com/yalue/onnxruntime_go v1.36.0
 )
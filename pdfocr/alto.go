@@ -0,0 +1,206 @@
+package pdfocr
+
+import (
+	"encoding/xml"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// ALTO v4 structures, limited to the text of a page: its blocks, lines and
+// words with their positions in pixels of the page image.
+type altoDocument struct {
+	XMLName        xml.Name        `xml:"alto"`
+	XMLNS          string          `xml:"xmlns,attr"`
+	XMLNSXSI       string          `xml:"xmlns:xsi,attr"`
+	SchemaLocation string          `xml:"xsi:schemaLocation,attr"`
+	Description    altoDescription `xml:"Description"`
+	Pages          []altoPage      `xml:"Layout>Page"`
+}
+
+type altoDescription struct {
+	MeasurementUnit string         `xml:"MeasurementUnit"`
+	FileName        string         `xml:"sourceImageInformation>fileName"`
+	Processing      altoProcessing `xml:"Processing"`
+}
+
+type altoProcessing struct {
+	ID       string `xml:"ID,attr"`
+	Software string `xml:"processingSoftware>softwareName"`
+	Version  string `xml:"processingSoftware>softwareVersion"`
+}
+
+type altoPage struct {
+	ID         string         `xml:"ID,attr"`
+	PhysicalNr int            `xml:"PHYSICAL_IMG_NR,attr"`
+	PrintedNr  string         `xml:"PRINTED_IMG_NR,attr,omitempty"`
+	Width      int            `xml:"WIDTH,attr"`
+	Height     int            `xml:"HEIGHT,attr"`
+	PC         string         `xml:"PC,attr,omitempty"` // page confidence, 0-1
+	PrintSpace altoPrintSpace `xml:"PrintSpace"`
+}
+
+type altoPrintSpace struct {
+	HPos   int             `xml:"HPOS,attr"`
+	VPos   int             `xml:"VPOS,attr"`
+	Width  int             `xml:"WIDTH,attr"`
+	Height int             `xml:"HEIGHT,attr"`
+	Blocks []altoTextBlock `xml:"TextBlock"`
+}
+
+type altoTextBlock struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	Lines []altoTextLine `xml:"TextLine"`
+}
+
+type altoTextLine struct {
+	ID string `xml:"ID,attr"`
+	altoBox
+	BaseDirection string       `xml:"BASEDIRECTION,attr,omitempty"` // ltr or rtl
+	Strings       []altoString `xml:"String"`
+}
+
+type altoString struct {
+	ID      string `xml:"ID,attr"`
+	Content string `xml:"CONTENT,attr"`
+	altoBox
+	WC string `xml:"WC,attr,omitempty"` // word confidence, 0-1
+}
+
+type altoBox struct {
+	HPos   int `xml:"HPOS,attr"`
+	VPos   int `xml:"VPOS,attr"`
+	Width  int `xml:"WIDTH,attr"`
+	Height int `xml:"HEIGHT,attr"`
+}
+
+func newAltoBox(r image.Rectangle) altoBox {
+	return altoBox{HPos: r.Min.X, VPos: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
+
+// DocumentALTO returns a processed document as one ALTO file with a Page per
+// processed page. Positions are pixels of the page rendered at config.DPI
+// (the resolution OCR ran at): OCR'd pages carry their words with
+// OCRConfig.WordBoxes, and pages from the text layer their lines, split into
+// words by character count.
+func DocumentALTO(manifest DocumentManifest, config OCRConfig) ([]byte, error) {
+	dpi := config.DPI
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	doc := altoDocument{
+		XMLNS:          "http://www.loc.gov/standards/alto/ns-v4#",
+		XMLNSXSI:       "http://www.w3.org/2001/XMLSchema-instance",
+		SchemaLocation: "http://www.loc.gov/standards/alto/ns-v4# http://www.loc.gov/alto/v4/alto-4-2.xsd",
+		Description: altoDescription{
+			MeasurementUnit: "pixel",
+			FileName:        manifest.Name,
+			Processing:      altoProcessing{ID: "OCR_0", Software: "pdf-ocr-tool", Version: Version()},
+		},
+	}
+	for _, p := range manifest.PageResults {
+		if page, ok := altoPageOf(p, dpi); ok {
+			doc.Pages = append(doc.Pages, page)
+		}
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding ALTO: %w", err)
+	}
+	return append(append([]byte(xml.Header), out...), '\n'), nil
+}
+
+// altoPageOf describes a page in pixels at dpi, or returns false for pages
+// whose size is unknown.
+func altoPageOf(p PageResult, dpi float64) (altoPage, bool) {
+	if p.Width <= 0 || p.Height <= 0 {
+		return altoPage{}, false
+	}
+	px := func(pt float64) int {
+		return int(math.Round(pt * dpi / 72))
+	}
+	n := p.Page
+	page := altoPage{
+		ID: fmt.Sprintf("page_%d", n), PhysicalNr: n, PrintedNr: p.Label,
+		Width: px(p.Width), Height: px(p.Height),
+	}
+	if p.Confidence > 0 {
+		page.PC = fmt.Sprintf("%.2f", math.Min(p.Confidence/100, 1))
+	}
+	page.PrintSpace = altoPrintSpace{Width: page.Width, Height: page.Height}
+
+	lines := pixelLines(p, px)
+	if len(lines) == 0 {
+		return page, true
+	}
+
+	block := altoTextBlock{ID: fmt.Sprintf("block_%d_1", n)}
+	var blockBox image.Rectangle
+	for i, l := range lines {
+		line := altoTextLine{
+			ID: fmt.Sprintf("line_%d_%d", n, i+1), altoBox: newAltoBox(l.Box),
+			BaseDirection: TextDirection(l.Text),
+		}
+		for _, w := range l.Words {
+			s := altoString{
+				ID:      fmt.Sprintf("word_%d_%d_%d", n, i+1, len(line.Strings)+1),
+				Content: w.Text, altoBox: newAltoBox(w.Box),
+			}
+			if len(p.Words) > 0 {
+				s.WC = fmt.Sprintf("%.2f", math.Min(math.Max(w.Confidence/100, 0), 1))
+			}
+			line.Strings = append(line.Strings, s)
+		}
+		block.Lines = append(block.Lines, line)
+		blockBox = blockBox.Union(l.Box)
+	}
+	block.altoBox = newAltoBox(blockBox)
+	page.PrintSpace.Blocks = []altoTextBlock{block}
+	return page, true
+}
+
+// pixelLines returns the lines of a processed page with their words, in
+// pixels as px converts points: the OCR'd words with OCRConfig.WordBoxes,
+// or else the text layer lines, split into words by character count.
+func pixelLines(p PageResult, px func(pt float64) int) []ocrLine {
+	if len(p.Words) > 0 {
+		words := make([]OCRWord, len(p.Words))
+		for i, w := range p.Words {
+			words[i] = OCRWord{
+				Text:       w.Text,
+				Box:        image.Rect(px(w.Box[0]), px(w.Box[1]), px(w.Box[2]), px(w.Box[3])),
+				Confidence: w.Confidence,
+			}
+		}
+		return wordLines(words)
+	}
+	var lines []ocrLine
+	for _, l := range p.layout {
+		box := image.Rect(px(l.X0), px(l.Y0), px(l.X1), px(l.Y1))
+		lines = append(lines, ocrLine{Text: l.Text, Box: box, Words: splitLineWords(l.Text, box)})
+	}
+	return lines
+}
+
+// splitLineWords divides the box of a text layer line among its words in
+// proportion to their length, the text layer giving no word positions.
+func splitLineWords(text string, box image.Rectangle) []OCRWord {
+	fields := strings.Fields(text)
+	chars := len([]rune(strings.Join(fields, " ")))
+	if chars == 0 {
+		return nil
+	}
+	width := float64(box.Dx()) / float64(chars)
+	var words []OCRWord
+	offset := 0
+	for _, f := range fields {
+		n := len([]rune(f))
+		x0 := box.Min.X + int(math.Round(float64(offset)*width))
+		x1 := box.Min.X + int(math.Round(float64(offset+n)*width))
+		words = append(words, OCRWord{Text: f, Box: image.Rect(x0, box.Min.Y, x1, box.Max.Y)})
+		offset += n + 1
+	}
+	return words
+}
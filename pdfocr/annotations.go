@@ -0,0 +1,147 @@
+package pdfocr
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// annotationDPI is the render resolution for annotation appearances; stamps
+// are small, so they get more pixels than a full page.
+const annotationDPI = 400
+
+// pageAnnotation is a stamp or free-text annotation whose content may exist
+// only in its appearance.
+type pageAnnotation struct {
+	Subtype    string   // "Stamp" or "FreeText"
+	Contents   string   // /Contents text, often empty or stale
+	Name       string   // stamp icon name, e.g. "Approved"
+	Appearance pdfValue // normal appearance stream, or nil
+}
+
+// pageAnnotations returns the stamp and free-text annotations of a page.
+func (pdf *rawPDF) pageAnnotations(pageNum int) []pageAnnotation {
+	page := pdf.page(pageNum)
+	if page == nil {
+		return nil
+	}
+	var out []pageAnnotation
+	for _, ref := range pdf.array(page["Annots"]) {
+		annot := pdf.dict(ref)
+		subtype := pdf.name(annot["Subtype"])
+		if subtype != "Stamp" && subtype != "FreeText" {
+			continue
+		}
+		a := pageAnnotation{
+			Subtype:  subtype,
+			Contents: strings.TrimSpace(pdf.text(annot["Contents"])),
+			Name:     pdf.name(annot["Name"]),
+		}
+
+		// /N is a stream, or a dictionary of streams keyed by appearance state
+		normal := pdf.dict(annot["AP"])["N"]
+		if states := pdf.dict(normal); states != nil && pdf.object(normal) == nil {
+			normal = states[pdf.name(annot["AS"])]
+			if normal == nil {
+				keys := make([]string, 0, len(states))
+				for k := range states {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				if len(keys) > 0 {
+					normal = states[keys[0]]
+				}
+			}
+		}
+		if o := pdf.object(normal); o != nil && o.Stream != nil {
+			a.Appearance = normal
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// stampNameText turns a standard stamp icon name such as "NotApproved" into
+// the words the viewer would draw ("NOT APPROVED").
+func stampNameText(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte(' ')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// ocrAppearance renders an appearance stream on its own page and OCRs it.
+func ocrAppearance(src *pdfSource, appearance pdfValue, config OCRConfig) (string, error) {
+	data, err := src.raw.formXObjectPDF(appearance)
+	if err != nil {
+		return "", err
+	}
+	ap, err := openPDFSource(data, src.name+" (annotation)", config.Renderer)
+	if err != nil {
+		return "", err
+	}
+	defer ap.Close()
+	ap.noScratch = src.noScratch
+	img, err := ap.renderPage(0, annotationDPI, config.RobustDecode)
+	if err != nil {
+		return "", fmt.Errorf("error rendering annotation: %w", err)
+	}
+	engine, err := lookupEngine(config.Engine)
+	if err != nil {
+		return "", err
+	}
+	text, err := engine.Text(img, config, PageOCROptions{DPI: annotationDPI, SparseText: true})
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(strings.Fields(text), " "), nil
+}
+
+// annotationText returns the text of one annotation. Free-text /Contents is
+// used when present; otherwise the appearance is OCR'd, since it is what
+// readers see, falling back to /Contents and then to the icon name of a
+// stamp.
+func annotationText(src *pdfSource, pageNum int, a pageAnnotation, config OCRConfig) string {
+	if a.Subtype == "FreeText" && a.Contents != "" {
+		return a.Contents
+	}
+	if a.Appearance != nil {
+		text, err := ocrAppearance(src, a.Appearance, config)
+		if err != nil {
+			src.warnings.warnf(pageNum, "Warning: could not OCR %s annotation on page %d: %v\n", a.Subtype, pageNum+1, err)
+		}
+		if text != "" {
+			return text
+		}
+	}
+	if a.Contents != "" {
+		return a.Contents
+	}
+	if a.Subtype == "Stamp" && a.Name != "" {
+		return stampNameText(a.Name)
+	}
+	return ""
+}
+
+// annotationSection returns the annotation text of a page as lines tagged
+// with their annotation type, or "" if the page has none.
+func annotationSection(src *pdfSource, pageNum int, config OCRConfig) string {
+	if src.raw == nil {
+		return ""
+	}
+	var lines []string
+	for _, a := range src.raw.pageAnnotations(pageNum) {
+		if text := annotationText(src, pageNum, a, config); text != "" {
+			lines = append(lines, fmt.Sprintf("[annotation %s] %s", a.Subtype, text))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("--- Page %d annotations ---\n%s\n\n", pageNum+1, strings.Join(lines, "\n"))
+}
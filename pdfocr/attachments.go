@@ -0,0 +1,251 @@
+package pdfocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Modes for embedded attachments.
+const (
+	AttachmentsList    = "list"    // report attachments without processing them
+	AttachmentsProcess = "process" // extract text from attachments recursively
+)
+
+// maxAttachmentDepth limits how deeply nested attachments are followed.
+const maxAttachmentDepth = 4
+
+// Attachment is a file embedded in a PDF, either through the document's
+// EmbeddedFiles name tree or a file attachment annotation.
+type Attachment struct {
+	Name        string
+	Description string
+	MIMEType    string
+	Folder      string // portfolio folder path, "" for the root
+	Data        []byte
+}
+
+// validateAttachments checks an -attachments mode given on the command line.
+func validateAttachments(mode string) error {
+	switch mode {
+	case "", AttachmentsList, AttachmentsProcess:
+		return nil
+	}
+	return fmt.Errorf("unsupported attachments mode %q (use list or process)", mode)
+}
+
+// attachments returns all embedded files, in name tree order followed by
+// attachments that are only referenced from annotations. Files that cannot
+// be decoded are left out with a warning.
+func (pdf *rawPDF) attachments(warnings *warningLog) []Attachment {
+	var out []Attachment
+	seen := map[int]bool{}
+	folders := pdf.portfolioFolders()
+
+	add := func(key string, spec pdfDict) {
+		ef := pdf.dict(spec["EF"])
+		if ef == nil {
+			return
+		}
+		ref := ef["UF"]
+		if ref == nil {
+			ref = ef["F"]
+		}
+		obj := pdf.object(ref)
+		if obj == nil || seen[obj.Num] {
+			return
+		}
+		seen[obj.Num] = true
+
+		data, err := pdf.decodeStream(obj)
+		if err != nil {
+			warnings.warnf(-1, "Warning: could not decode attachment %q: %v\n", key, err)
+			return
+		}
+		name := pdf.text(spec["UF"])
+		if name == "" {
+			name = pdf.text(spec["F"])
+		}
+		if name == "" {
+			name = key
+		}
+		if name == "" {
+			name = fmt.Sprintf("attachment-%d", obj.Num)
+		}
+		folder := ""
+		if id, rest, ok := splitFolderID(key); ok {
+			folder = folders[id]
+			if name == key {
+				name = rest
+			}
+		}
+		stream, _ := obj.Value.(pdfDict)
+		out = append(out, Attachment{
+			Name:        name,
+			Folder:      folder,
+			Description: pdf.text(spec["Desc"]),
+			MIMEType:    pdf.name(stream["Subtype"]),
+			Data:        data,
+		})
+	}
+
+	names := pdf.dict(pdf.catalog()["Names"])
+	for _, entry := range pdf.nameTree(names["EmbeddedFiles"]) {
+		if spec := pdf.dict(entry.Value); spec != nil {
+			add(entry.Key, spec)
+		}
+	}
+
+	for _, num := range pdf.sortedObjectNumbers() {
+		if dict, ok := pdf.objects[num].Value.(pdfDict); ok {
+			if _, ok := dict["EF"]; ok {
+				add("", dict)
+			}
+		}
+	}
+	return out
+}
+
+// isPDFData reports whether data looks like a PDF file.
+func isPDFData(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("%PDF"))
+}
+
+// isTextAttachment reports whether an attachment holds plain text.
+func isTextAttachment(a Attachment) bool {
+	if strings.HasPrefix(a.MIMEType, "text/") {
+		return true
+	}
+	switch strings.ToLower(path.Ext(a.Name)) {
+	case ".txt", ".csv", ".md", ".xml", ".json":
+		return true
+	}
+	return false
+}
+
+// extractSubDocuments reports the attachments embedded in a PDF as
+// sub-documents. Embedded PDFs are run through the normal extraction
+// pipeline (including their own attachments) and plain-text attachments are
+// included verbatim when process is set; otherwise they are only listed.
+// Processing stops when ctx is canceled.
+func extractSubDocuments(ctx context.Context, pdf *rawPDF, parent string, config OCRConfig, process bool, depth int) (string, []DocumentManifest) {
+	attachments := pdf.attachments(config.warnings)
+	if len(attachments) == 0 {
+		return "", nil
+	}
+	config.logf("Found %d embedded attachment(s) in %s\n", len(attachments), parent)
+	// The page selection is of the parent document
+	config.PageSelection = PageSet{}
+
+	var out strings.Builder
+	members := make([]DocumentManifest, 0, len(attachments))
+	for _, a := range attachments {
+		if ctx.Err() != nil {
+			break
+		}
+		name := parent + "/" + path.Join(a.Folder, a.Name)
+		member := DocumentManifest{
+			Name:        a.Name,
+			Folder:      a.Folder,
+			Description: a.Description,
+			MIMEType:    a.MIMEType,
+			Size:        len(a.Data),
+		}
+
+		out.WriteString(fmt.Sprintf("=== Attachment: %s (%d bytes) ===\n", name, len(a.Data)))
+		if a.Description != "" {
+			out.WriteString(fmt.Sprintf("Description: %s\n", a.Description))
+		}
+
+		switch {
+		case !process:
+			out.WriteString("\n")
+		case isPDFData(a.Data):
+			text, sub, err := extractPDFData(ctx, a.Data, name, config, depth+1)
+			if err != nil {
+				config.warnings.warnf(-1, "Warning: could not process attachment %s: %v\n", name, err)
+				out.WriteString(fmt.Sprintf("(not processed: %v)\n\n", err))
+				member.Error = err.Error()
+				break
+			}
+			member.Pages, member.Portfolio, member.Members = sub.Pages, sub.Portfolio, sub.Members
+			member.Warnings = sub.Warnings
+			member.Processed = true
+			out.WriteString("\n")
+			out.WriteString(text)
+		case isTextAttachment(a):
+			member.Processed = true
+			out.WriteString("\n")
+			out.WriteString(strings.TrimSpace(string(a.Data)))
+			out.WriteString("\n\n")
+		default:
+			mime := a.MIMEType
+			if mime == "" {
+				mime = "unknown type"
+			}
+			out.WriteString(fmt.Sprintf("(not processed: %s)\n\n", mime))
+		}
+		members = append(members, member)
+	}
+	return out.String(), members
+}
+
+// isPortfolio reports whether the document is a PDF portfolio (collection),
+// whose pages are usually just a cover sheet for the embedded members.
+func (pdf *rawPDF) isPortfolio() bool {
+	return pdf.dict(pdf.catalog()["Collection"]) != nil
+}
+
+// portfolioFolders maps portfolio folder IDs to slash-separated folder paths.
+// Members of a folder carry a "<ID>" prefix on their name tree key.
+func (pdf *rawPDF) portfolioFolders() map[int]string {
+	folders := map[int]string{}
+	seen := map[int]bool{}
+
+	// Sibling folders are chained through /Next, children start at /Child.
+	var walk func(first pdfValue, parent string, depth int)
+	walk = func(first pdfValue, parent string, depth int) {
+		for v := first; v != nil && depth < 32; {
+			if ref, ok := v.(pdfRef); ok {
+				if seen[ref.Num] {
+					return
+				}
+				seen[ref.Num] = true
+			}
+			folder := pdf.dict(v)
+			if folder == nil {
+				return
+			}
+			p := path.Join(parent, pdf.text(folder["Name"]))
+			folders[pdf.int(folder["ID"])] = p
+			walk(folder["Child"], p, depth+1)
+			v = folder["Next"]
+		}
+	}
+
+	// The root folder's name is not part of member paths.
+	if root := pdf.dict(pdf.dict(pdf.catalog()["Collection"])["Folders"]); root != nil {
+		folders[pdf.int(root["ID"])] = ""
+		walk(root["Child"], "", 1)
+	}
+	return folders
+}
+
+// splitFolderID splits a portfolio name tree key of the form "<ID>name".
+func splitFolderID(key string) (int, string, bool) {
+	if !strings.HasPrefix(key, "<") {
+		return 0, "", false
+	}
+	end := strings.IndexByte(key, '>')
+	if end < 0 {
+		return 0, "", false
+	}
+	id, err := strconv.Atoi(key[1:end])
+	if err != nil {
+		return 0, "", false
+	}
+	return id, key[end+1:], true
+}
@@ -0,0 +1,140 @@
+package pdfocr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+)
+
+// AuditRecord is one line of the audit log: who processed which input with
+// which settings, and what came out of it.
+type AuditRecord struct {
+	SchemaVersion string        `json:"schema_version"`
+	Time          string        `json:"time"`
+	User          string        `json:"user"`
+	Host          string        `json:"host"`
+	RunID         string        `json:"run_id"` // names the run's scratch and debug files
+	Action        string        `json:"action"`
+	Input         string        `json:"input"`
+	InputSHA256   string        `json:"input_sha256,omitempty"`
+	Settings      []string      `json:"settings"`
+	Engine        string        `json:"engine"`
+	Renderer      string        `json:"renderer"`
+	Pages         int           `json:"pages,omitempty"`
+	Truncated     bool          `json:"truncated,omitempty"`
+	Outputs       []AuditOutput `json:"outputs,omitempty"`
+	Error         string        `json:"error,omitempty"`
+
+	enabled bool // false when no audit log is kept; nothing is hashed then
+}
+
+// AuditOutput identifies an output by path ("-" for stdout) and content hash.
+type AuditOutput struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// AuditLog appends records as JSON lines to a file, and to syslog if
+// requested. Records are never rewritten: the file is only opened for
+// appending.
+type AuditLog struct {
+	File   string // appended to, unless ""
+	Syslog bool   // also send records to syslog
+}
+
+// Enabled reports whether records are kept anywhere.
+func (a *AuditLog) Enabled() bool {
+	return a.File != "" || a.Syslog
+}
+
+// Record starts the record of an action on input, with the command line
+// options as the settings.
+func (a *AuditLog) Record(action, input string, args []string, config OCRConfig) AuditRecord {
+	if !a.Enabled() {
+		return AuditRecord{}
+	}
+	rec := AuditRecord{
+		SchemaVersion: SchemaVersion,
+		Time:          time.Now().UTC().Format(time.RFC3339),
+		Action:        action,
+		Input:         input,
+		Settings:      args,
+		RunID:         runID,
+		enabled:       true,
+	}
+	if u, err := user.Current(); err == nil {
+		rec.User = u.Username
+	}
+	rec.Host, _ = os.Hostname()
+	if data, err := os.ReadFile(input); err == nil {
+		rec.InputSHA256 = sha256Hex(data)
+	}
+	if e, err := lookupEngine(config.Engine); err == nil {
+		rec.Engine = e.Name()
+	}
+	if r, err := lookupRenderer(config.Renderer); err == nil {
+		rec.Renderer = r.Name()
+	}
+	return rec
+}
+
+// AddOutput records an output file by hashing it as written.
+func (rec *AuditRecord) AddOutput(path string) {
+	if !rec.enabled {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	rec.AddOutputData(path, data)
+}
+
+// AddOutputData records an output by hashing data, with "-" as the path of
+// stdout.
+func (rec *AuditRecord) AddOutputData(path string, data []byte) {
+	if rec.enabled {
+		rec.Outputs = append(rec.Outputs, AuditOutput{Path: path, SHA256: sha256Hex(data)})
+	}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// append adds an encoded record to the log file.
+func (a *AuditLog) append(line []byte) (err error) {
+	f, err := os.OpenFile(a.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %w", err)
+	}
+	defer closeChecked(&err, f, "audit log")
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+	return nil
+}
+
+// Write appends a record to the log.
+func (a *AuditLog) Write(rec AuditRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("error encoding audit record: %w", err)
+	}
+	if a.File != "" {
+		if err := a.append(line); err != nil {
+			return err
+		}
+	}
+	if a.Syslog {
+		if err := writeSyslog(string(line)); err != nil {
+			return fmt.Errorf("error sending audit record to syslog: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build windows || plan9
+
+package pdfocr
+
+import "fmt"
+
+// writeSyslog is not available: Go's syslog package does not support this
+// platform.
+func writeSyslog(msg string) error {
+	return fmt.Errorf("syslog is not supported on this platform")
+}
@@ -0,0 +1,311 @@
+package pdfocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of the files of a batch run.
+const (
+	BatchSucceeded = "succeeded"
+	BatchFailed    = "failed"
+	BatchSkipped   = "skipped" // output up to date, or the run was interrupted first
+)
+
+// BatchFile is the outcome of one input file of a batch run.
+type BatchFile struct {
+	Input    string        `json:"input"`
+	Output   string        `json:"output"`
+	Status   string        `json:"status"` // BatchSucceeded, BatchFailed or BatchSkipped
+	Reason   string        `json:"reason,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Pages    int           `json:"pages,omitempty"`
+	Duration int64         `json:"duration_ms,omitempty"`
+	Memory   int64         `json:"memory_estimate,omitempty"`      // bytes accounted for the document, see documentMemory
+	Review   []int         `json:"low_confidence_pages,omitempty"` // pages below -min-confidence
+	Stats    *TextStats    `json:"stats,omitempty"`                // with -stats
+	Keyword  *KeywordMatch `json:"keyword_match,omitempty"`        // with -spot, when found
+}
+
+// BatchManifest is the machine-readable record of a batch run.
+type BatchManifest struct {
+	SchemaVersion string      `json:"schema_version"`
+	Started       string      `json:"started"`
+	Finished      string      `json:"finished"`
+	Succeeded     int         `json:"succeeded"`
+	Failed        int         `json:"failed"`
+	Skipped       int         `json:"skipped"`
+	Matched       int         `json:"matched,omitempty"` // files mentioning one of the -spot terms
+	Files         []BatchFile `json:"files"`
+}
+
+// batchInput is a PDF or image found for a batch run, with its path relative to the
+// directory or glob it was found under, which the output mirrors.
+type batchInput struct {
+	path, rel string
+	size      int64
+}
+
+// collectBatchInputs finds the PDFs and images named by specs:
+// directories, walked recursively, files and glob patterns.
+func collectBatchInputs(specs []string) ([]batchInput, error) {
+	var inputs []batchInput
+	seen := map[string]bool{}
+	add := func(path, base string) {
+		if seen[path] || !isInputFile(path) {
+			return
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			rel = filepath.Base(path)
+		}
+		seen[path] = true
+		in := batchInput{path: path, rel: rel}
+		if info, err := os.Stat(path); err == nil {
+			in.size = info.Size()
+		}
+		inputs = append(inputs, in)
+	}
+	for _, spec := range specs {
+		if info, err := os.Stat(spec); err == nil && info.IsDir() {
+			err := filepath.WalkDir(spec, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.Type().IsRegular() {
+					add(path, spec)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", spec, err)
+			}
+			continue
+		}
+		matches, err := filepath.Glob(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", spec, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match %s", spec)
+		}
+		base := globBase(spec)
+		for _, path := range matches {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				add(path, base)
+			}
+		}
+	}
+	return inputs, nil
+}
+
+// globBase returns the directory of a glob pattern before its first
+// component with wildcards.
+func globBase(pattern string) string {
+	dir := filepath.Dir(pattern)
+	for strings.ContainsAny(dir, "*?[") {
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// batchOutputPath is where the output of a batch input is written: its
+// relative path under outDir, with the extension of the format.
+func batchOutputPath(outDir, rel, format string) string {
+	ext := ".txt"
+	switch format {
+	case FormatJSON:
+		ext = ".json"
+	case FormatTSV:
+		ext = ".tsv"
+	}
+	return filepath.Join(outDir, strings.TrimSuffix(rel, filepath.Ext(rel))+ext)
+}
+
+// upToDate reports whether output exists and is newer than input.
+func upToDate(input, output string) bool {
+	in, err := os.Stat(input)
+	if err != nil {
+		return false
+	}
+	out, err := os.Stat(output)
+	return err == nil && !out.ModTime().Before(in.ModTime())
+}
+
+// processBatchFile extracts one input of a batch run to its output file,
+// or only spots config.Keywords when output is "", in sandbox unless it is
+// nil.
+func processBatchFile(ctx context.Context, in batchInput, output, format string, config OCRConfig, sandbox *Sandbox) BatchFile {
+	file := BatchFile{Input: in.path, Output: output}
+	started := time.Now()
+	var text string
+	var manifest DocumentManifest
+	var err error
+	// Pages queued offline record the output SyncQueue updates
+	config.OutputFile, config.OutputFormat = output, format
+	if sandbox == nil {
+		text, manifest, err = ExtractDocument(ctx, in.path, config)
+	} else if data, rerr := os.ReadFile(in.path); rerr != nil {
+		err = fmt.Errorf("error reading PDF: %w", rerr)
+	} else {
+		text, manifest, err = sandbox.ExtractData(ctx, data, filepath.Base(in.path), config)
+	}
+	file.Pages, file.Review, file.Stats = manifest.Pages, manifest.ReviewPages, manifest.Stats
+	file.Keyword = manifest.Keyword
+	if errors.Is(err, context.Canceled) {
+		file.Status, file.Reason = BatchSkipped, "interrupted"
+		return file
+	}
+	var data []byte
+	if err == nil && output != "" {
+		switch format {
+		case FormatJSON:
+			data, err = DocumentJSON(manifest, config)
+		case FormatTSV:
+			data = DocumentTSV(manifest, config)
+		default:
+			data, err = FormatOutput(text, config)
+		}
+	}
+	if err == nil && output != "" {
+		err = os.MkdirAll(filepath.Dir(output), 0755)
+	}
+	if err == nil && output != "" {
+		err = os.WriteFile(output, data, 0644)
+	}
+	file.Duration = time.Since(started).Milliseconds()
+	if err != nil {
+		file.Status, file.Error = BatchFailed, err.Error()
+		return file
+	}
+	file.Status = BatchSucceeded
+	return file
+}
+
+// BatchOptions configures RunBatch.
+type BatchOptions struct {
+	OutDir      string   // where the outputs go, mirroring the directories of the inputs
+	Format      string   // FormatText (the default), FormatJSON or FormatTSV
+	Jobs        int      // files extracted at a time (0 for one per CPU)
+	Force       bool     // extract files whose output is newer than them too
+	MaxOpenDocs int      // documents open at a time (0 for no limit besides Jobs)
+	MaxMemory   int64    // estimated bytes of the documents in memory (0 for no limit)
+	Sandbox     *Sandbox // extracts the documents, unless nil
+}
+
+// RunBatch extracts the text of every PDF and image under specs,
+// directories walked recursively, files and glob patterns, into
+// opts.OutDir, opts.Jobs files at a time, and logs the outcome of each.
+// With config.Keywords it only spots them and writes no transcripts.
+// Canceling ctx skips the files not started yet and lets the running ones
+// stop after their current page. The returned manifest lists every file;
+// RunBatch fails only when it cannot start.
+func RunBatch(ctx context.Context, specs []string, config OCRConfig, opts BatchOptions) (BatchManifest, error) {
+	format := opts.Format
+	switch format {
+	case "":
+		format = FormatText
+	case FormatText, FormatJSON, FormatTSV:
+	default:
+		return BatchManifest{}, fmt.Errorf("batch writes -format text, json or tsv, not %s", format)
+	}
+	if format == FormatTSV {
+		config.WordBoxes = true
+	}
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	if err := config.Validate(); err != nil {
+		return BatchManifest{}, err
+	}
+	inputs, err := collectBatchInputs(specs)
+	if err != nil {
+		return BatchManifest{}, err
+	}
+	config.logf("Batch: %d files, %d at a time\n", len(inputs), jobs)
+	if opts.MaxOpenDocs > 0 {
+		config.logf("Batch: at most %d documents open at a time\n", opts.MaxOpenDocs)
+	}
+	if opts.MaxMemory > 0 {
+		config.logf("Batch: at most %d MB of documents in memory (estimated)\n", opts.MaxMemory>>20)
+	}
+	limiter := newDocLimiter(opts.MaxOpenDocs, opts.MaxMemory)
+
+	batch := BatchManifest{SchemaVersion: SchemaVersion, Started: time.Now().UTC().Format(time.RFC3339)}
+	batch.Files = make([]BatchFile, len(inputs))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				in := inputs[i]
+				output := batchOutputPath(opts.OutDir, in.rel, format)
+				if len(config.Keywords) > 0 {
+					// Spotting sweeps write no transcripts
+					output = ""
+				}
+				var file BatchFile
+				switch {
+				case ctx.Err() != nil:
+					file = BatchFile{Input: in.path, Output: output, Status: BatchSkipped, Reason: "interrupted"}
+				case !opts.Force && output != "" && upToDate(in.path, output):
+					file = BatchFile{Input: in.path, Output: output, Status: BatchSkipped, Reason: "up to date"}
+				default:
+					memory := documentMemory(in.size, config)
+					limiter.acquire(memory)
+					file = processBatchFile(ctx, in, output, format, config, opts.Sandbox)
+					file.Memory = memory
+					limiter.release(memory)
+				}
+				batch.Files[i] = file
+				mu.Lock()
+				done++
+				switch {
+				case file.Status == BatchFailed:
+					config.logf("[%d/%d] failed: %s: %s\n", done, len(inputs), in.path, file.Error)
+				case file.Status == BatchSkipped:
+					config.logf("[%d/%d] skipped (%s): %s\n", done, len(inputs), file.Reason, in.path)
+				case file.Keyword != nil:
+					config.logf("[%d/%d] found %q on page %d: %s\n", done, len(inputs), file.Keyword.Term, file.Keyword.Page, in.path)
+				default:
+					config.logf("[%d/%d] done: %s (%d pages)\n", done, len(inputs), in.path, file.Pages)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range inputs {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	batch.Finished = time.Now().UTC().Format(time.RFC3339)
+	for _, f := range batch.Files {
+		switch f.Status {
+		case BatchSucceeded:
+			batch.Succeeded++
+			if f.Keyword != nil {
+				batch.Matched++
+			}
+		case BatchFailed:
+			batch.Failed++
+		default:
+			batch.Skipped++
+		}
+	}
+	return batch, nil
+}
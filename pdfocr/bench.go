@@ -0,0 +1,150 @@
+package pdfocr
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// benchDPIs are the render resolutions compared by the bench command.
+var benchDPIs = []float64{150, 200, 300}
+
+// BenchResult is the outcome of one bench setting.
+type BenchResult struct {
+	DPI      float64
+	Workers  int
+	Pages    int
+	Elapsed  time.Duration
+	Accuracy float64 // mean fraction of sample words recognized
+	Err      error
+}
+
+// PagesPerSecond returns the measured throughput.
+func (r BenchResult) PagesPerSecond() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Pages) / r.Elapsed.Seconds()
+}
+
+// benchWorkerCounts returns 1, 2, 4, ... up to the number of CPUs, always
+// including the CPU count itself.
+func benchWorkerCounts() []int {
+	cpus := runtime.NumCPU()
+	var counts []int
+	for n := 1; n < cpus; n *= 2 {
+		counts = append(counts, n)
+	}
+	return append(counts, cpus)
+}
+
+// benchSetting renders and OCRs the demo sample pages times, spread over
+// workers goroutines. Each worker opens its own copy of the document, since
+// renderers serialize calls on one document.
+func benchSetting(config OCRConfig, dpi float64, workers, pages int) BenchResult {
+	result := BenchResult{DPI: dpi, Workers: workers, Pages: pages}
+	engine, err := lookupEngine(config.Engine)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	sources := make([]*pdfSource, workers)
+	for i := range sources {
+		src, err := openPDFSource(demoPDF, "sample-scanned.pdf", config.Renderer)
+		if err != nil {
+			result.Err = err
+			for _, s := range sources[:i] {
+				s.Close()
+			}
+			return result
+		}
+		sources[i] = src
+	}
+	defer func() {
+		for _, s := range sources {
+			s.Close()
+		}
+	}()
+
+	jobs := make(chan int)
+	var mu sync.Mutex
+	var total float64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, src := range sources {
+		wg.Add(1)
+		go func(src *pdfSource) {
+			defer wg.Done()
+			for range jobs {
+				img, err := src.renderPage(0, dpi, false)
+				var text string
+				if err == nil {
+					text, err = engine.Text(img, config, PageOCROptions{DPI: dpi})
+				}
+				mu.Lock()
+				if err != nil && result.Err == nil {
+					result.Err = err
+				}
+				total += wordMatch(demoSampleLines, text)
+				mu.Unlock()
+			}
+		}(src)
+	}
+	for i := 0; i < pages; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	result.Elapsed = time.Since(start)
+	result.Accuracy = total / float64(pages)
+	return result
+}
+
+// RecommendBench picks the fastest setting whose accuracy is within a few
+// points of the best accuracy measured, so speed is not bought with
+// unusable output.
+func RecommendBench(results []BenchResult) (BenchResult, bool) {
+	bestAccuracy := 0.0
+	for _, r := range results {
+		if r.Err == nil && r.Accuracy > bestAccuracy {
+			bestAccuracy = r.Accuracy
+		}
+	}
+	var best BenchResult
+	found := false
+	for _, r := range results {
+		if r.Err != nil || r.Accuracy < bestAccuracy-0.05 {
+			continue
+		}
+		if !found || r.PagesPerSecond() > best.PagesPerSecond() {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+// Bench measures the throughput and accuracy of config's renderer and
+// engine on the built-in sample page at several resolutions and worker
+// counts, OCRing pagesPerWorker pages per worker in each setting; report,
+// unless nil, receives every result as soon as it is measured.
+func Bench(config OCRConfig, pagesPerWorker int, report func(BenchResult)) ([]BenchResult, error) {
+	if _, err := lookupRenderer(config.Renderer); err != nil {
+		return nil, err
+	}
+	if _, err := lookupEngine(config.Engine); err != nil {
+		return nil, err
+	}
+	var results []BenchResult
+	for _, dpi := range benchDPIs {
+		for _, workers := range benchWorkerCounts() {
+			r := benchSetting(config, dpi, workers, workers*pagesPerWorker)
+			results = append(results, r)
+			if report != nil {
+				report(r)
+			}
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,289 @@
+package pdfocr
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// bestDPI is the render resolution of best-quality runs, and
+	// bestRetryDPI that of the retry of pages recognized with a mean word
+	// confidence below bestRetryConfidence.
+	bestDPI             = 400
+	bestRetryDPI        = 600
+	bestRetryConfidence = 75
+
+	// correctConfidence is the confidence below which a word missing from
+	// the dictionary is replaced by a dictionary word one edit away, and
+	// lexiconConfidence the confidence from which recognized words count as
+	// spelled correctly for the rest of their page.
+	correctConfidence = 60
+	lexiconConfidence = 90
+
+	// voteOverlap is the share of the smaller box two words of different
+	// passes must have in common to be votes for the same word.
+	voteOverlap = 0.5
+)
+
+// bestTessdataDirs are the usual install locations of the tessdata_best
+// models, the most accurate and slowest.
+var bestTessdataDirs = []string{
+	"/usr/share/tesseract-ocr/5/tessdata_best",
+	"/usr/share/tesseract-ocr/4.00/tessdata_best",
+	"/usr/share/tessdata_best",
+	"/usr/local/share/tessdata_best",
+	"/opt/homebrew/share/tessdata_best",
+}
+
+// ApplyBest configures an accuracy-oriented run: 400 DPI (unless keepDPI),
+// the best models when they are installed, per-page preprocessing unless
+// set otherwise, and multi-pass recognition.
+func ApplyBest(config *OCRConfig, keepDPI bool) {
+	if !keepDPI {
+		config.DPI = bestDPI
+	}
+	if config.Preprocess == "" {
+		config.Preprocess = PreprocessAuto
+	}
+	config.Best = true
+	if config.TessdataDir == "" {
+		if dir := findTessdataVariant("tessdata_best", bestTessdataDirs, config.Language); dir != "" {
+			config.TessdataDir = dir
+		} else {
+			config.logf("Best mode: no tessdata_best models for %s found, using the default models\n", config.Language)
+		}
+	}
+	config.logf("Best mode: %g DPI, three recognition passes per page, retries at %d DPI\n", config.DPI, bestRetryDPI)
+}
+
+// LoadDictionary reads a word list, one word per line, for correcting
+// low-confidence words in best mode. Words are matched case-insensitively.
+func LoadDictionary(path string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading dictionary: %w", err)
+	}
+	defer f.Close()
+	dict := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" {
+			dict[strings.ToLower(w)] = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading dictionary: %w", err)
+	}
+	return dict, nil
+}
+
+// bestText recognizes a page image in three passes: as given, with its
+// contrast stretched and speckle removed, and with sparse text
+// segmentation. The passes vote on every word, weighted by confidence. A
+// page whose voted words stay below bestRetryConfidence is rendered again at
+// bestRetryDPI and recognized the same way, keeping the better result.
+// Low-confidence words are then corrected against config.Dictionary.
+func (src *pdfSource) bestText(ctx context.Context, img image.Image, pageNum int, we wordEngine, engine Engine, config OCRConfig, opts PageOCROptions) (string, error) {
+	words, err := voteText(img, we, engine, config, opts)
+	if err != nil {
+		return "", err
+	}
+	if mean := meanConfidence(words); mean < bestRetryConfidence && opts.DPI > 0 && opts.DPI < bestRetryDPI && ctx.Err() == nil {
+		config.debugf("Page %d: mean confidence %.0f, retrying at %d DPI\n", pageNum+1, mean, bestRetryDPI)
+		hi, err := src.renderForOCR(pageNum, bestRetryDPI, config)
+		if err == nil {
+			hi = src.maskIgnored(hi, pageNum, config, bestRetryDPI)
+			hi = rotateImage(hi, opts.Rotation)
+			hi, _ = preprocessPage(hi, pageNum, config, !config.WordBoxes && !config.HOCR)
+			retryOpts := opts
+			retryOpts.DPI = bestRetryDPI
+			if retry, err := voteText(hi, we, engine, config, retryOpts); err == nil && meanConfidence(retry) > mean {
+				words = retry
+			}
+		}
+	}
+	words = correctWords(words, config.Dictionary)
+	lines := wordLines(words)
+	text := make([]string, len(lines))
+	for i, l := range lines {
+		text[i] = l.Text
+	}
+	return strings.Join(text, "\n"), nil
+}
+
+// voteText runs the three recognition passes of bestText over img and
+// returns the voted words.
+func voteText(img image.Image, we wordEngine, engine Engine, config OCRConfig, opts PageOCROptions) ([]OCRWord, error) {
+	gray := toGray(img)
+	m := measurePage(gray)
+	cleaned := medianFilter(stretchContrast(gray, m.lo, m.hi))
+	sparse := opts
+	sparse.SparseText = true
+
+	var passes [][]OCRWord
+	for _, pass := range []struct {
+		img  image.Image
+		opts PageOCROptions
+	}{{img, opts}, {cleaned, opts}, {img, sparse}} {
+		words, err := we.Words(pass.img, config, pass.opts)
+		if err != nil {
+			if len(passes) == 0 {
+				return nil, err
+			}
+			continue
+		}
+		calibrateWords(words, engine.Name(), config)
+		passes = append(passes, words)
+	}
+	return voteWords(passes), nil
+}
+
+// voteWords combines recognition passes of one image. The pass with the
+// most confident words lays out the page; each of its words takes the
+// reading with the highest summed confidence among the words of all passes
+// at its place.
+func voteWords(passes [][]OCRWord) []OCRWord {
+	if len(passes) == 0 {
+		return nil
+	}
+	ref := 0
+	for i, p := range passes {
+		if totalConfidence(p) > totalConfidence(passes[ref]) {
+			ref = i
+		}
+	}
+	out := make([]OCRWord, 0, len(passes[ref]))
+	for _, w := range passes[ref] {
+		votes := map[string]float64{}
+		best := map[string]float64{}
+		for i, p := range passes {
+			if i == ref {
+				votes[w.Text] += w.Confidence
+				best[w.Text] = max(best[w.Text], w.Confidence)
+				continue
+			}
+			for _, o := range p {
+				if boxesOverlap(w.Box, o.Box) {
+					votes[o.Text] += o.Confidence
+					best[o.Text] = max(best[o.Text], o.Confidence)
+					break
+				}
+			}
+		}
+		readings := make([]string, 0, len(votes))
+		for text := range votes {
+			readings = append(readings, text)
+		}
+		sort.Strings(readings)
+		winner := w.Text
+		for _, text := range readings {
+			if votes[text] > votes[winner] {
+				winner = text
+			}
+		}
+		out = append(out, OCRWord{Text: winner, Box: w.Box, Confidence: best[winner]})
+	}
+	return out
+}
+
+// boxesOverlap reports whether two word boxes share at least voteOverlap of
+// the smaller one.
+func boxesOverlap(a, b image.Rectangle) bool {
+	in := a.Intersect(b)
+	if in.Empty() {
+		return false
+	}
+	smaller := min(a.Dx()*a.Dy(), b.Dx()*b.Dy())
+	return float64(in.Dx()*in.Dy()) >= voteOverlap*float64(smaller)
+}
+
+func totalConfidence(words []OCRWord) float64 {
+	total := 0.0
+	for _, w := range words {
+		total += w.Confidence
+	}
+	return total
+}
+
+// meanConfidence returns the mean confidence of words, 0 for none.
+func meanConfidence(words []OCRWord) float64 {
+	if len(words) == 0 {
+		return 0
+	}
+	return totalConfidence(words) / float64(len(words))
+}
+
+// correctWords replaces low-confidence words that are neither in dict nor
+// recognized confidently elsewhere on the page by the only word of either
+// one edit away, keeping surrounding punctuation and the case of the first
+// letter.
+func correctWords(words []OCRWord, dict map[string]bool) []OCRWord {
+	lexicon := map[string]bool{}
+	for _, w := range words {
+		if core := strings.ToLower(wordCore(w.Text)); w.Confidence >= lexiconConfidence && len([]rune(core)) >= 3 {
+			lexicon[core] = true
+		}
+	}
+	known := func(s string) bool { return dict[s] || lexicon[s] }
+	for i, w := range words {
+		core := wordCore(w.Text)
+		lower := strings.ToLower(core)
+		if w.Confidence >= correctConfidence || len([]rune(core)) < 3 || known(lower) {
+			continue
+		}
+		var candidates []string
+		for _, source := range []map[string]bool{dict, lexicon} {
+			for word := range source {
+				if oneEditApart(lower, word) && !slices.Contains(candidates, word) {
+					candidates = append(candidates, word)
+				}
+			}
+		}
+		if len(candidates) != 1 {
+			continue
+		}
+		fixed := candidates[0]
+		if r := []rune(core); unicode.IsUpper(r[0]) {
+			fr := []rune(fixed)
+			fr[0] = unicode.ToUpper(fr[0])
+			fixed = string(fr)
+		}
+		words[i].Text = strings.Replace(w.Text, core, fixed, 1)
+	}
+	return words
+}
+
+// wordCore returns a word without leading and trailing punctuation.
+func wordCore(s string) string {
+	return strings.TrimFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// oneEditApart reports whether b is a, with one character inserted,
+// deleted or replaced, or two neighbours swapped.
+func oneEditApart(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(ra)-len(rb) > 1 || a == b {
+		return false
+	}
+	i := 0
+	for i < len(rb) && ra[i] == rb[i] {
+		i++
+	}
+	if len(ra) != len(rb) {
+		return string(ra[i+1:]) == string(rb[i:])
+	}
+	if string(ra[i+1:]) == string(rb[i+1:]) {
+		return true
+	}
+	return i+1 < len(ra) && ra[i] == rb[i+1] && ra[i+1] == rb[i] && string(ra[i+2:]) == string(rb[i+2:])
+}
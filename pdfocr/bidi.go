@@ -0,0 +1,131 @@
+package pdfocr
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Text directions reported for paragraphs.
+const (
+	DirectionLTR = "ltr"
+	DirectionRTL = "rtl"
+)
+
+// rtlScripts are the scripts whose letters have strong right-to-left bidi class.
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Arabic,
+	unicode.Hebrew,
+	unicode.Syriac,
+	unicode.Thaana,
+	unicode.Nko,
+	unicode.Samaritan,
+	unicode.Mandaic,
+}
+
+// rtlMark is U+200F RIGHT-TO-LEFT MARK, a strong RTL character with no glyph.
+const rtlMark = "\u200f"
+
+// isStrongRTL reports whether r has a strong right-to-left bidi class.
+func isStrongRTL(r rune) bool {
+	return unicode.In(r, rtlScripts...)
+}
+
+// isStrongLTR reports whether r has a strong left-to-right bidi class.
+func isStrongLTR(r rune) bool {
+	return unicode.IsLetter(r) && !isStrongRTL(r)
+}
+
+// TextDirection returns the dominant direction of a paragraph, decided by
+// the majority of strongly directional characters. Text without any strong
+// characters (digits, punctuation) is treated as left-to-right.
+func TextDirection(text string) string {
+	rtl, ltr := 0, 0
+	for _, r := range text {
+		switch {
+		case isStrongRTL(r):
+			rtl++
+		case isStrongLTR(r):
+			ltr++
+		}
+	}
+	if rtl > ltr {
+		return DirectionRTL
+	}
+	return DirectionLTR
+}
+
+// firstStrongDirection applies rules P2/P3 of the Unicode bidi algorithm and
+// returns the direction a viewer will pick for the paragraph, or "" if the
+// text has no strong characters.
+func firstStrongDirection(text string) string {
+	for _, r := range text {
+		switch {
+		case isStrongRTL(r):
+			return DirectionRTL
+		case isStrongLTR(r):
+			return DirectionLTR
+		}
+	}
+	return ""
+}
+
+// applyBidiMarks prepares text for plain-text output. Every line is its own
+// bidi paragraph in plain text, so a right-to-left line that starts with a
+// Latin word or a number would be laid out left-to-right by viewers. Such
+// lines get a leading RIGHT-TO-LEFT MARK so the paragraph level matches the
+// dominant direction of the line.
+func applyBidiMarks(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, rtlMark) {
+			continue
+		}
+		if TextDirection(line) == DirectionRTL && firstStrongDirection(line) != DirectionRTL {
+			lines[i] = rtlMark + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PageParagraph is a paragraph of the text of a page, with the direction of
+// the paragraph and of each of its lines.
+type PageParagraph struct {
+	Direction string     `json:"direction"` // DirectionLTR or DirectionRTL
+	Lines     []PageLine `json:"lines"`
+}
+
+// PageLine is a line of a PageParagraph.
+type PageLine struct {
+	Text      string `json:"text"`
+	Direction string `json:"direction"`
+}
+
+// textParagraphs splits the text of a page into its paragraphs, which blank
+// lines and form feeds separate, and gives each and each line its dominant
+// direction. The marks applyBidiMarks added are dropped, the direction
+// saying as much.
+func textParagraphs(text string) []PageParagraph {
+	var paragraphs []PageParagraph
+	var cur []string
+	flush := func() {
+		if len(cur) == 0 {
+			return
+		}
+		p := PageParagraph{Direction: TextDirection(strings.Join(cur, "\n"))}
+		for _, l := range cur {
+			p.Lines = append(p.Lines, PageLine{Text: l, Direction: TextDirection(l)})
+		}
+		paragraphs = append(paragraphs, p)
+		cur = nil
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\f", "\n"), "\n") {
+		line = strings.TrimPrefix(line, rtlMark)
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		cur = append(cur, line)
+	}
+	flush()
+	return paragraphs
+}
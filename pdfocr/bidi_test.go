@@ -0,0 +1,74 @@
+package pdfocr
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTextParagraphs(t *testing.T) {
+	text := applyBidiMarks("Invoice 2024\nTotal 1250\n\nPDF مرحبا بالعالم\nشكرا\f\nEnd")
+	want := []PageParagraph{
+		{Direction: DirectionLTR, Lines: []PageLine{{"Invoice 2024", DirectionLTR}, {"Total 1250", DirectionLTR}}},
+		{Direction: DirectionRTL, Lines: []PageLine{{"PDF مرحبا بالعالم", DirectionRTL}, {"شكرا", DirectionRTL}}},
+		{Direction: DirectionLTR, Lines: []PageLine{{"End", DirectionLTR}}},
+	}
+	if got := textParagraphs(text); !reflect.DeepEqual(got, want) {
+		t.Errorf("textParagraphs = %+v, want %+v", got, want)
+	}
+
+	manifest := DocumentManifest{PageResults: []PageResult{{Page: 1, Method: MethodNative, Text: text}}}
+	data, err := DocumentJSON(manifest, OCRConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc jsonDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.Pages[0].Paragraphs, want) {
+		t.Errorf("DocumentJSON paragraphs = %+v, want %+v", doc.Pages[0].Paragraphs, want)
+	}
+}
+
+func TestDirectionMarkup(t *testing.T) {
+	p := PageResult{Page: 1, Width: 72, Height: 72, layout: []TextLine{
+		{Text: "שלום עולם", X0: 10, Y0: 10, X1: 60, Y1: 20},
+		{Text: "ID 42", X0: 10, Y0: 30, X1: 40, Y1: 40},
+		{Text: "תודה רבה", X0: 10, Y0: 50, X1: 60, Y1: 60},
+	}}
+	hocr := hocrTextPage(p, 72)
+	for _, want := range []string{
+		"<p class='ocr_par' id='par_1_1' dir='rtl'>",
+		"id='line_1_1' dir='rtl'",
+		"id='line_1_2' dir='ltr'",
+	} {
+		if !strings.Contains(hocr, want) {
+			t.Errorf("hOCR lacks %q:\n%s", want, hocr)
+		}
+	}
+
+	raw := "<div class='ocr_page' id='page_1' title='bbox 0 0 10 10'>" +
+		"<p class='ocr_par' id='par_1_1'><span class='ocrx_word'>مرحبا</span></p>" +
+		"<p class='ocr_par' id='par_1_2' dir='ltr'><span class='ocrx_word'>Hello</span></p></div>"
+	page, err := hocrPage(raw, 0, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page, "id='par_1_1' dir='rtl'>") || strings.Count(page, "dir=") != 2 {
+		t.Errorf("hocrPage directions:\n%s", page)
+	}
+
+	alto, ok := altoPageOf(p, 72)
+	if !ok {
+		t.Fatal("altoPageOf failed")
+	}
+	var dirs []string
+	for _, l := range alto.PrintSpace.Blocks[0].Lines {
+		dirs = append(dirs, l.BaseDirection)
+	}
+	if want := []string{DirectionRTL, DirectionLTR, DirectionRTL}; !reflect.DeepEqual(dirs, want) {
+		t.Errorf("ALTO line directions %v, want %v", dirs, want)
+	}
+}
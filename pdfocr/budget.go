@@ -0,0 +1,55 @@
+package pdfocr
+
+import (
+	"fmt"
+	"time"
+)
+
+// processingBudget bounds the pages and time spent on one run. The
+// top-level document and its sub-documents share one budget, and a page in
+// progress is always finished.
+type processingBudget struct {
+	maxPages int
+	deadline time.Time // zero for no time limit
+	pages    int
+	reason   string // why the budget ran out, "" while it lasts
+}
+
+// newProcessingBudget returns a budget for -max-pages and -max-duration, or
+// nil when neither is set.
+func newProcessingBudget(maxPages int, maxDuration time.Duration) *processingBudget {
+	if maxPages <= 0 && maxDuration <= 0 {
+		return nil
+	}
+	b := &processingBudget{maxPages: maxPages}
+	if maxDuration > 0 {
+		b.deadline = time.Now().Add(maxDuration)
+	}
+	return b
+}
+
+// take reports whether another page may be processed, and counts it. A nil
+// budget is unlimited.
+func (b *processingBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	if b.reason == "" {
+		switch {
+		case b.maxPages > 0 && b.pages >= b.maxPages:
+			b.reason = fmt.Sprintf("page limit of %d reached", b.maxPages)
+		case !b.deadline.IsZero() && !time.Now().Before(b.deadline):
+			b.reason = "time limit reached"
+		}
+	}
+	if b.reason != "" {
+		return false
+	}
+	b.pages++
+	return true
+}
+
+// truncatedNotice marks where the text of a document stops early, and why.
+func truncatedNotice(reason string, pagesDone, numPages int) string {
+	return fmt.Sprintf("=== Truncated: %s after %d of %d pages ===\n\n", reason, pagesDone, numPages)
+}
@@ -0,0 +1,157 @@
+package pdfocr
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// outputFile is one artifact of a run, named by its slash-separated path
+// inside a bundle or output directory.
+type outputFile struct {
+	Name string
+	Data []byte
+}
+
+// Bundle collects the artifacts of a run into a ZIP archive for -bundle,
+// along with the run log written to its Log.
+type Bundle struct {
+	files []outputFile
+	log   bytes.Buffer
+}
+
+// NewBundle starts an empty bundle.
+func NewBundle() *Bundle {
+	return &Bundle{}
+}
+
+// Log returns the writer of the run log, which callers copy their log
+// output to; it is not safe for concurrent writes.
+func (b *Bundle) Log() io.Writer {
+	return &b.log
+}
+
+func (b *Bundle) add(name string, data []byte) {
+	b.files = append(b.files, outputFile{Name: name, Data: data})
+}
+
+// addFile adds a file written during the run, if it exists.
+func (b *Bundle) addFile(name, file string) error {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %s for the bundle: %w", file, err)
+	}
+	b.add(name, data)
+	return nil
+}
+
+// AddDir adds the files under dir with their relative paths below prefix.
+func (b *Bundle) AddDir(prefix, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == dir {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		return b.addFile(path.Join(prefix, filepath.ToSlash(rel)), p)
+	})
+}
+
+// Save saves the bundle, with the run log as run.log. With a key the
+// archive is encrypted as a whole.
+func (b *Bundle) Save(file string, key []byte) error {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	now := time.Now()
+	for _, of := range append(b.files, outputFile{Name: "run.log", Data: b.log.Bytes()}) {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: of.Name, Method: zip.Deflate, Modified: now})
+		if err == nil {
+			_, err = w.Write(of.Data)
+		}
+		if err != nil {
+			return fmt.Errorf("error writing bundle: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+	data := buf.Bytes()
+	if key != nil {
+		var err error
+		if data, err = encryptData(key, data); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		return fmt.Errorf("error writing bundle: %w", err)
+	}
+	return nil
+}
+
+// AddRunArtifacts adds the results of a text extraction: the text output,
+// the manifest, the page files and the side outputs written to disk.
+func (b *Bundle) AddRunArtifacts(pdfPath string, text []byte, manifest DocumentManifest, config OCRConfig) error {
+	base := strings.TrimSuffix(filepath.Base(pdfPath), filepath.Ext(pdfPath))
+	b.add(base+".txt", text)
+
+	data, err := manifestJSON(manifest)
+	if err != nil {
+		return err
+	}
+	b.add("manifest.json", data)
+
+	pages, err := pagesFiles(manifest, config)
+	if err != nil {
+		return err
+	}
+	b.files = append(b.files, pages...)
+
+	if config.Tables {
+		tables, err := tableFiles(manifest)
+		if err != nil {
+			return err
+		}
+		for _, f := range tables {
+			b.add("tables/"+f.Name, f.Data)
+		}
+	}
+	if config.XFAOutputFile != "" {
+		if err := b.addFile("xfa/"+filepath.Base(config.XFAOutputFile), config.XFAOutputFile); err != nil {
+			return err
+		}
+	}
+	if config.METSDir != "" {
+		if err := b.AddDir("mets", config.METSDir); err != nil {
+			return err
+		}
+	}
+	if config.IIIFDir != "" {
+		if err := b.AddDir("iiif", config.IIIFDir); err != nil {
+			return err
+		}
+	}
+	if config.DebugOverlayDir != "" {
+		if err := b.AddDir("overlays", config.DebugOverlayDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
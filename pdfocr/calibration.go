@@ -0,0 +1,217 @@
+package pdfocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// calibrationBinWidth groups raw confidences when learning a curve.
+const calibrationBinWidth = 10
+
+// calibrationCurve maps raw confidences to calibrated ones by linear
+// interpolation between points, each a [raw, calibrated] pair sorted by raw
+// confidence.
+type calibrationCurve [][2]float64
+
+// apply returns the calibrated value of a raw confidence.
+func (c calibrationCurve) apply(raw float64) float64 {
+	if len(c) == 0 {
+		return raw
+	}
+	if raw <= c[0][0] {
+		return c[0][1]
+	}
+	for i := 1; i < len(c); i++ {
+		if raw <= c[i][0] {
+			x0, y0, x1, y1 := c[i-1][0], c[i-1][1], c[i][0], c[i][1]
+			if x1 == x0 {
+				return y1
+			}
+			return y0 + (raw-x0)*(y1-y0)/(x1-x0)
+		}
+	}
+	return c[len(c)-1][1]
+}
+
+// Calibration holds a curve per "engine/language" key (e.g.
+// "tesseract/eng"), so that a calibrated confidence of 80 means about 80% of
+// such words are right whichever backend produced them. A key with only the
+// engine name applies to that engine's languages without their own curve.
+type Calibration map[string]calibrationCurve
+
+// LoadCalibration reads a calibration file as written by the calibrate
+// command.
+func LoadCalibration(path string) (Calibration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading calibration: %w", err)
+	}
+	var c Calibration
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("error parsing calibration %s: %w", path, err)
+	}
+	for key, curve := range c {
+		for i, p := range curve {
+			if p[1] < 0 || p[1] > 100 || (i > 0 && p[0] < curve[i-1][0]) {
+				return nil, fmt.Errorf("invalid calibration curve %q: points must be sorted and map to 0-100", key)
+			}
+		}
+	}
+	return c, nil
+}
+
+// curve returns the curve for an engine and language, or nil to keep raw
+// confidences.
+func (c Calibration) curve(engine, lang string) calibrationCurve {
+	if curve, ok := c[engine+"/"+lang]; ok {
+		return curve
+	}
+	return c[engine]
+}
+
+// calibrateWords rewrites word confidences in place with the configured
+// calibration for the engine that produced them.
+func calibrateWords(words []OCRWord, engine string, config OCRConfig) {
+	curve := config.Calibration.curve(engine, config.Language)
+	if curve == nil {
+		return
+	}
+	for i := range words {
+		words[i].Confidence = curve.apply(words[i].Confidence)
+	}
+}
+
+// calibrationSample is a recognized word scored against the ground truth.
+type calibrationSample struct {
+	Confidence float64
+	Correct    bool
+}
+
+// fitCalibration learns a curve from scored words: the share of correct
+// words in each band of raw confidence, made non-decreasing by pooling
+// adjacent bands that violate the order.
+func fitCalibration(samples []calibrationSample) calibrationCurve {
+	type band struct{ raw, correct, n float64 }
+	bins := map[int]*band{}
+	for _, s := range samples {
+		k := int(s.Confidence) / calibrationBinWidth
+		if bins[k] == nil {
+			bins[k] = &band{}
+		}
+		bins[k].raw += s.Confidence
+		bins[k].n++
+		if s.Correct {
+			bins[k].correct++
+		}
+	}
+	keys := make([]int, 0, len(bins))
+	for k := range bins {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	var pooled []band
+	for _, k := range keys {
+		pooled = append(pooled, *bins[k])
+		for len(pooled) > 1 {
+			a, b := pooled[len(pooled)-2], pooled[len(pooled)-1]
+			if a.correct/a.n <= b.correct/b.n {
+				break
+			}
+			pooled = append(pooled[:len(pooled)-2], band{a.raw + b.raw, a.correct + b.correct, a.n + b.n})
+		}
+	}
+	curve := make(calibrationCurve, len(pooled))
+	for i, b := range pooled {
+		curve[i] = [2]float64{b.raw / b.n, 100 * b.correct / b.n}
+	}
+	return curve
+}
+
+// scoreWords marks each recognized word as correct if it occurs in the
+// ground truth text, ignoring case and surrounding punctuation.
+func scoreWords(words []OCRWord, truth string) []calibrationSample {
+	known := map[string]bool{}
+	for _, w := range strings.Fields(truth) {
+		known[normalizeTruthWord(w)] = true
+	}
+	samples := make([]calibrationSample, 0, len(words))
+	for _, w := range words {
+		samples = append(samples, calibrationSample{Confidence: w.Confidence, Correct: known[normalizeTruthWord(w.Text)]})
+	}
+	return samples
+}
+
+func normalizeTruthWord(w string) string {
+	return strings.ToLower(strings.Trim(w, ".,;:!?\"'()[]"))
+}
+
+// Calibrate learns the confidence curve of the engine and language of
+// config from docs, whose ground truth is in a .txt file of the same name
+// next to each, and stores it in the calibration file under its
+// "engine/language" key, keeping the curves of other backends. It returns
+// the key and the number of words the curve was learned from.
+func Calibrate(file string, docs []string, config OCRConfig) (key string, words int, err error) {
+	engine, err := lookupEngine(config.Engine)
+	if err != nil {
+		return "", 0, err
+	}
+	we, ok := engine.(wordEngine)
+	if !ok {
+		return "", 0, fmt.Errorf("OCR engine %s does not report word confidences", engine.Name())
+	}
+
+	var samples []calibrationSample
+	for _, path := range docs {
+		truth, err := os.ReadFile(strings.TrimSuffix(path, filepath.Ext(path)) + ".txt")
+		if err != nil {
+			return "", 0, fmt.Errorf("ground truth for %s: %w", path, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", 0, fmt.Errorf("error reading PDF: %w", err)
+		}
+		src, err := openPDFSource(data, filepath.Base(path), config.Renderer)
+		if err != nil {
+			return "", 0, err
+		}
+		for pageNum := 0; pageNum < src.doc.NumPage(); pageNum++ {
+			img, err := src.renderPage(pageNum, config.DPI, false)
+			if err != nil {
+				warnf("Warning: could not render page %d of %s: %v\n", pageNum+1, path, err)
+				continue
+			}
+			words, err := we.Words(img, config, PageOCROptions{})
+			if err != nil {
+				warnf("Warning: OCR failed for page %d of %s: %v\n", pageNum+1, path, err)
+				continue
+			}
+			samples = append(samples, scoreWords(words, string(truth))...)
+		}
+		src.Close()
+	}
+	if len(samples) == 0 {
+		return "", 0, fmt.Errorf("no words recognized, nothing to calibrate")
+	}
+
+	calibration := Calibration{}
+	if _, err := os.Stat(file); err == nil {
+		if calibration, err = LoadCalibration(file); err != nil {
+			return "", 0, err
+		}
+	}
+	key = engine.Name() + "/" + config.Language
+	calibration[key] = fitCalibration(samples)
+	data, err := json.MarshalIndent(calibration, "", "  ")
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.WriteFile(file, append(data, '\n'), 0644); err != nil {
+		return "", 0, err
+	}
+	return key, len(samples), nil
+}
@@ -0,0 +1,160 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Presets bundle OCR settings for a kind of document.
+const (
+	PresetChart = "chart" // charts and diagrams: sparse, small labels
+)
+
+// chartDPI is the render resolution of the chart preset; axis labels and
+// legends are often set in 6-7pt type that 300 DPI renders too small.
+const chartDPI = 400
+
+// validatePreset checks a -preset name given on the command line.
+func validatePreset(preset string) error {
+	switch preset {
+	case "", PresetChart:
+		return nil
+	}
+	return fmt.Errorf("unknown preset %q (available: chart)", preset)
+}
+
+// chartOCROptions returns the per-page OCR settings of the chart preset.
+func chartOCROptions() PageOCROptions {
+	return PageOCROptions{
+		DPI:        chartDPI,
+		SparseText: true,
+		Labels:     true,
+	}
+}
+
+// ChartLabel is a group of words that sit close together on a chart, such
+// as an axis title, a tick label or a legend entry.
+type ChartLabel struct {
+	Text string
+	Box  image.Rectangle
+}
+
+// joinable reports whether two word boxes belong to the same label: either
+// on one line with less than a word height between them, or stacked with
+// overlapping columns and less than half a line between them.
+func joinable(a, b image.Rectangle) bool {
+	h := max(a.Dy(), b.Dy())
+	overlapY := min(a.Max.Y, b.Max.Y) - max(a.Min.Y, b.Min.Y)
+	gapX := max(a.Min.X, b.Min.X) - min(a.Max.X, b.Max.X)
+	if overlapY*2 >= min(a.Dy(), b.Dy()) && gapX <= h {
+		return true
+	}
+	overlapX := min(a.Max.X, b.Max.X) - max(a.Min.X, b.Min.X)
+	gapY := max(a.Min.Y, b.Min.Y) - min(a.Max.Y, b.Max.Y)
+	return overlapX*2 >= min(a.Dx(), b.Dx()) && gapY*2 <= h
+}
+
+// groupLabels clusters words by proximity into labels, ordered top to
+// bottom and left to right.
+func groupLabels(words []OCRWord) []ChartLabel {
+	parent := make([]int, len(words))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	for i := range words {
+		for j := i + 1; j < len(words); j++ {
+			if joinable(words[i].Box, words[j].Box) {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groups := map[int][]OCRWord{}
+	var roots []int
+	for i, w := range words {
+		r := find(i)
+		if _, ok := groups[r]; !ok {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], w)
+	}
+
+	labels := make([]ChartLabel, 0, len(roots))
+	for _, r := range roots {
+		labels = append(labels, newChartLabel(groups[r]))
+	}
+	sort.SliceStable(labels, func(i, j int) bool {
+		a, b := labels[i].Box, labels[j].Box
+		if a.Min.Y != b.Min.Y {
+			return a.Min.Y < b.Min.Y
+		}
+		return a.Min.X < b.Min.X
+	})
+	return labels
+}
+
+// newChartLabel joins the words of one group in reading order.
+func newChartLabel(words []OCRWord) ChartLabel {
+	sort.Slice(words, func(i, j int) bool {
+		a, b := words[i].Box, words[j].Box
+		if a.Max.Y <= b.Min.Y || b.Max.Y <= a.Min.Y {
+			return a.Min.Y < b.Min.Y
+		}
+		return a.Min.X < b.Min.X
+	})
+	label := ChartLabel{Box: words[0].Box}
+	parts := make([]string, len(words))
+	for i, w := range words {
+		parts[i] = w.Text
+		label.Box = label.Box.Union(w.Box)
+	}
+	label.Text = strings.Join(parts, " ")
+	return label
+}
+
+// chartLabelsText renders labels as plain text, one label per line.
+func chartLabelsText(labels []ChartLabel) string {
+	lines := make([]string, len(labels))
+	for i, l := range labels {
+		lines[i] = l.Text
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PageChartLabel is a label of a chart page in PageResult.
+type PageChartLabel struct {
+	Text string     `json:"text"`
+	Box  [4]float64 `json:"box"` // x0, y0, x1, y1 in points from the top-left corner of the page
+}
+
+// pageChartLabels converts the labels found on a page image rendered at dpi
+// (0 meaning defaultRenderDPI) to page coordinates.
+func pageChartLabels(labels []ChartLabel, dpi float64) []PageChartLabel {
+	if len(labels) == 0 {
+		return nil
+	}
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	points := func(px int) float64 {
+		return math.Round(float64(px)*72/dpi*100) / 100
+	}
+	out := make([]PageChartLabel, len(labels))
+	for i, l := range labels {
+		out[i] = PageChartLabel{
+			Text: l.Text,
+			Box:  [4]float64{points(l.Box.Min.X), points(l.Box.Min.Y), points(l.Box.Max.X), points(l.Box.Max.Y)},
+		}
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package pdfocr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumsFile lists the SHA-256 of every file written to an image output
+// directory, in the format of sha256sum, so "sha256sum -c" can check it too.
+const ChecksumsFile = "SHA256SUMS"
+
+// checksums records the digests of the files written to one directory.
+type checksums map[string]string // file name -> hex SHA-256
+
+func (c checksums) add(name string, data []byte) {
+	sum := sha256.Sum256(data)
+	c[name] = hex.EncodeToString(sum[:])
+}
+
+// write saves the checksums to dir/SHA256SUMS, sorted by file name.
+func (c checksums) write(dir string) error {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s  %s\n", c[name], name)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ChecksumsFile), buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("error writing checksums: %w", err)
+	}
+	return nil
+}
+
+// readChecksums parses dir/SHA256SUMS.
+func readChecksums(dir string) (checksums, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ChecksumsFile))
+	if err != nil {
+		return nil, fmt.Errorf("error reading checksums: %w", err)
+	}
+	c := checksums{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, " ")
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		if !ok || len(sum) != sha256.Size*2 || name == "" {
+			return nil, fmt.Errorf("invalid line %d in %s", n, ChecksumsFile)
+		}
+		c[name] = strings.ToLower(sum)
+	}
+	return c, nil
+}
+
+// VerifyChecksums checks the files of an image output directory against its
+// SHA256SUMS and returns a description of every missing or altered file.
+func VerifyChecksums(dir string) ([]string, error) {
+	c, err := readChecksums(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: missing", name))
+			continue
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != c[name] {
+			problems = append(problems, fmt.Sprintf("%s: checksum mismatch", name))
+		}
+	}
+	return problems, nil
+}
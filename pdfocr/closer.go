@@ -0,0 +1,16 @@
+package pdfocr
+
+import (
+	"fmt"
+	"io"
+)
+
+// closeChecked closes c when the function whose error result is *err
+// returns, and reports a failed close as that error unless the function
+// already failed. Writers only know their data reached the disk once Close
+// succeeds.
+func closeChecked(err *error, c io.Closer, what string) {
+	if cerr := c.Close(); cerr != nil && *err == nil {
+		*err = fmt.Errorf("error closing %s: %w", what, cerr)
+	}
+}
@@ -0,0 +1,181 @@
+package pdfocr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scanning mistakes of double-sided documents scanned one side at a time.
+const (
+	// CollationInterleave: the fronts pass was followed by the backs pass,
+	// so the backs come after all fronts.
+	CollationInterleave = "interleave"
+	// CollationReversedBacks: as CollationInterleave, with the stack flipped
+	// over for the backs pass so the backs come last to first.
+	CollationReversedBacks = "reversed-backs"
+	// CollationSwappedSides: every sheet was fed the wrong way up, so each
+	// back comes before its front.
+	CollationSwappedSides = "swapped-sides"
+)
+
+// minCollationPages is the smallest document whose order is checked.
+const minCollationPages = 4
+
+// Collation is a likely scanning mistake in the order of a document's pages.
+type Collation struct {
+	Error string `json:"error"` // CollationInterleave, CollationReversedBacks or CollationSwappedSides
+	Order []int  `json:"order"` // physical pages (1-based) in reading order
+	// Applied is set when the output follows Order (OCRConfig.Recollate)
+	Applied bool `json:"applied,omitempty"`
+}
+
+func (c Collation) String() string {
+	var what string
+	switch c.Error {
+	case CollationInterleave:
+		what = "scanned as all fronts, then all backs"
+	case CollationReversedBacks:
+		what = "scanned as all fronts, then all backs in reverse order"
+	default:
+		what = "scanned with every sheet's back before its front"
+	}
+	pages := make([]string, len(c.Order))
+	for i, p := range c.Order {
+		pages[i] = strconv.Itoa(p)
+	}
+	return fmt.Sprintf("pages look %s; the reading order is pages %s", what, strings.Join(pages, ", "))
+}
+
+// collationOrders returns the reading order of n scanned pages for each
+// scanning mistake, as indices into the scan.
+func collationOrders(n int) map[string][]int {
+	fronts := (n + 1) / 2
+	interleave := make([]int, 0, n)
+	reversed := make([]int, 0, n)
+	for i := 0; i < fronts; i++ {
+		interleave = append(interleave, i)
+		reversed = append(reversed, i)
+		if back := fronts + i; back < n {
+			interleave = append(interleave, back)
+			reversed = append(reversed, n-1-i)
+		}
+	}
+	swapped := make([]int, 0, n)
+	for i := 0; i+1 < n; i += 2 {
+		swapped = append(swapped, i+1, i)
+	}
+	if n%2 == 1 {
+		swapped = append(swapped, n-1)
+	}
+	return map[string][]int{
+		CollationInterleave:    interleave,
+		CollationReversedBacks: reversed,
+		CollationSwappedSides:  swapped,
+	}
+}
+
+// checkCollation looks for a scanning mistake that explains the order of
+// the pages better than the scan order does. Consecutive pages score when
+// their printed numbers (PageResult.Label) follow each other, and less when
+// the text of one runs on into the next, a sentence or a hyphenated word
+// being split between them. The mistake must score on at least half of the
+// pages to be reported.
+func checkCollation(pages []PageResult) *Collation {
+	n := len(pages)
+	if n < minCollationPages {
+		return nil
+	}
+	score := func(order []int) int {
+		total := 0
+		for i := 1; i < len(order); i++ {
+			total += pageSuccession(pages[order[i-1]], pages[order[i]])
+		}
+		return total
+	}
+	scan := make([]int, n)
+	for i := range scan {
+		scan[i] = i
+	}
+	best, bestScore := "", score(scan)
+	orders := collationOrders(n)
+	for _, mistake := range []string{CollationInterleave, CollationReversedBacks, CollationSwappedSides} {
+		if s := score(orders[mistake]); s > bestScore && s >= n/2 {
+			best, bestScore = mistake, s
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	c := &Collation{Error: best}
+	for _, i := range orders[best] {
+		c.Order = append(c.Order, pages[i].Page)
+	}
+	return c
+}
+
+// pageSuccession scores how clearly page b follows page a: 2 when b's
+// printed number is the next one, 1 when a's text runs on into b's.
+func pageSuccession(a, b PageResult) int {
+	la, errA := strconv.Atoi(a.Label)
+	lb, errB := strconv.Atoi(b.Label)
+	if errA == nil && errB == nil {
+		if lb == la+1 {
+			return 2
+		}
+		return 0
+	}
+	if textRunsOn(a.Text, b.Text) {
+		return 1
+	}
+	return 0
+}
+
+// textRunsOn reports whether the text of a page ends mid-sentence and the
+// text of the next begins in lower case, page numbers aside.
+func textRunsOn(a, b string) bool {
+	last := bodyLines(a)
+	next := bodyLines(b)
+	if len(last) == 0 || len(next) == 0 {
+		return false
+	}
+	end, _ := utf8.DecodeLastRuneInString(last[len(last)-1])
+	start, _ := utf8.DecodeRuneInString(next[0])
+	if !unicode.IsLower(start) {
+		return false
+	}
+	return unicode.IsLetter(end) || end == '-' || end == ','
+}
+
+// bodyLines returns the non-blank lines of a page's text without a page
+// number in the first or last line.
+func bodyLines(text string) []string {
+	var lines []string
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) > 0 && parsePageLabel(lines[len(lines)-1]) != "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > 0 && parsePageLabel(lines[0]) != "" {
+		lines = lines[1:]
+	}
+	return lines
+}
+
+// recollate returns the pages in the reading order of c.
+func recollate(pages []PageResult, c *Collation) []PageResult {
+	byPage := map[int]PageResult{}
+	for _, p := range pages {
+		byPage[p.Page] = p
+	}
+	out := make([]PageResult, 0, len(pages))
+	for _, n := range c.Order {
+		out = append(out, byPage[n])
+	}
+	return out
+}
@@ -0,0 +1,122 @@
+package pdfocr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// compressForPath compresses output data according to the extension of the
+// file it is written to: .gz with gzip, .zst by piping through the zstd
+// tool (keeping the binary free of a zstd dependency). Other files are
+// returned unchanged.
+func compressForPath(path string, data []byte) ([]byte, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	var encoding string
+	switch ext {
+	case ".gz":
+		encoding = encodingGzip
+	case ".zst":
+		encoding = encodingZstd
+	default:
+		return data, nil
+	}
+	compressed, err := compressData(data, encoding, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	if err != nil {
+		return nil, fmt.Errorf("error compressing %s: %w", path, err)
+	}
+	return compressed, nil
+}
+
+// Content codings the output can be compressed with, named as in HTTP.
+const (
+	encodingGzip = "gzip"
+	encodingZstd = "zstd"
+)
+
+// compressData compresses data with encoding, gzip or zstd; name is the
+// file of the data, if any, which gzip records.
+func compressData(data []byte, encoding, name string) ([]byte, error) {
+	if encoding == encodingZstd {
+		bin, err := exec.LookPath("zstd")
+		if err != nil {
+			return nil, errors.New("zstd not found in PATH")
+		}
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(bin, "-q", "-c")
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return stdout.Bytes(), nil
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Name = name
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// responseEncoding picks the content coding of a response from the
+// Accept-Encoding header of its request: the one of gzip and zstd the
+// client weighs highest, zstd on a tie, or "" for none. zstd is only
+// offered while the zstd tool is installed.
+func responseEncoding(accept string) string {
+	quality := map[string]float64{}
+	wildcard := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				q = 0
+			}
+		}
+		if coding == "*" {
+			wildcard = q
+		} else if coding != "" {
+			quality[coding] = q
+		}
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{encodingZstd, encodingGzip} {
+		q, ok := quality[encoding]
+		if !ok {
+			q = wildcard
+		}
+		if encoding == encodingZstd {
+			if _, err := exec.LookPath("zstd"); err != nil {
+				continue
+			}
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// CheckCompressor fails early if writing path needs a missing tool, rather
+// than after a long extraction.
+func CheckCompressor(path string) error {
+	if strings.EqualFold(filepath.Ext(path), ".zst") {
+		if _, err := exec.LookPath("zstd"); err != nil {
+			return fmt.Errorf("zstd not found in PATH, needed to write %s", path)
+		}
+	}
+	return nil
+}
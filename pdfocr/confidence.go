@@ -0,0 +1,89 @@
+package pdfocr
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// ValidateMinConfidence checks a -min-confidence threshold, a mean word
+// confidence on the engines' 0-100 scale.
+func ValidateMinConfidence(threshold float64) error {
+	if threshold < 0 || threshold > 100 {
+		return fmt.Errorf("invalid minimum confidence %g (use 0-100)", threshold)
+	}
+	return nil
+}
+
+// confidenceRetryDPI returns the resolution a page rendered at dpi is
+// recognized again at when it is below OCRConfig.MinConfidence: half as
+// much again, from bestDPI up to bestRetryDPI, or 0 when dpi is already
+// that high.
+func confidenceRetryDPI(dpi float64) float64 {
+	retry := math.Min(math.Max(dpi*1.5, bestDPI), bestRetryDPI)
+	if retry <= dpi {
+		return 0
+	}
+	return retry
+}
+
+// retryLowConfidence recognizes a page whose mean word confidence is below
+// config.MinConfidence again, at a higher resolution and then with sparse
+// text segmentation, which suits pages of scattered fields, and returns
+// the most confident reading with the options it was made with. The
+// returned flag is set when even that reading stays below the threshold.
+func (src *pdfSource) retryLowConfidence(ctx context.Context, pageNum int, config OCRConfig, opts PageOCROptions, recognized pageOCR) (pageOCR, PageOCROptions, bool) {
+	if config.MinConfidence <= 0 || recognized.confidence >= config.MinConfidence {
+		return recognized, opts, false
+	}
+	if opts.DPI == 0 {
+		opts.DPI = config.DPI
+	}
+	var retries []PageOCROptions
+	if dpi := confidenceRetryDPI(opts.DPI); dpi > 0 {
+		retry := opts
+		retry.DPI = dpi
+		retries = append(retries, retry)
+	}
+	if !opts.SparseText && !opts.SingleLine {
+		retry := opts
+		retry.SparseText = true
+		retries = append(retries, retry)
+	}
+
+	best, bestOpts := recognized, opts
+	for _, retry := range retries {
+		if ctx.Err() != nil {
+			break
+		}
+		if retry.DPI != opts.DPI {
+			config.logf("Page %d: mean confidence %.0f is below %g, recognizing it again at %g DPI\n", pageNum+1, best.confidence, config.MinConfidence, retry.DPI)
+		} else {
+			config.logf("Page %d: mean confidence %.0f is below %g, recognizing it again as sparse text\n", pageNum+1, best.confidence, config.MinConfidence)
+		}
+		out, err := ocrPage(ctx, src, pageNum, config, retry)
+		if err != nil {
+			src.warnings.warnf(pageNum, "Warning: recognizing page %d again failed: %v\n", pageNum+1, err)
+			continue
+		}
+		if out.confidence > best.confidence {
+			best, bestOpts = out, retry
+		}
+		if best.confidence >= config.MinConfidence {
+			return best, bestOpts, false
+		}
+	}
+	src.warnings.warnf(pageNum, "Warning: page %d mean confidence %.0f is below %g, flagged for review\n", pageNum+1, best.confidence, config.MinConfidence)
+	return best, bestOpts, true
+}
+
+// lowConfidencePages lists the pages flagged with PageResult.LowConfidence.
+func lowConfidencePages(pages []PageResult) []int {
+	var flagged []int
+	for _, p := range pages {
+		if p.LowConfidence {
+			flagged = append(flagged, p.Page)
+		}
+	}
+	return flagged
+}
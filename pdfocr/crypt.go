@@ -0,0 +1,90 @@
+package pdfocr
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// encryptedMagic starts every file written with -encrypt-key. It is also
+// authenticated with the ciphertext, followed by the GCM nonce.
+const encryptedMagic = "PDFOCR-AESGCM1\n"
+
+// LoadEncryptionKey reads an AES-256 key file holding either 32 raw bytes
+// or 64 hexadecimal characters, e.g. from "head -c 32 /dev/urandom".
+func LoadEncryptionKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading encryption key: %w", err)
+	}
+	if len(data) == 32 {
+		return data, nil
+	}
+	if key, err := hex.DecodeString(string(bytes.TrimSpace(data))); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("invalid encryption key %s: need 32 bytes or 64 hex characters", path)
+}
+
+// encryptData seals data with AES-256-GCM under a random nonce.
+func encryptData(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	out := append([]byte(encryptedMagic), nonce...)
+	return gcm.Seal(out, nonce, data, []byte(encryptedMagic)), nil
+}
+
+// DecryptData opens an output written with OCRConfig.EncryptKey, key.
+func DecryptData(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(encryptedMagic)) || len(data) < len(encryptedMagic)+gcm.NonceSize() {
+		return nil, fmt.Errorf("not an encrypted pdf-ocr-tool file")
+	}
+	data = data[len(encryptedMagic):]
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], []byte(encryptedMagic))
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed (wrong key or damaged file)")
+	}
+	return plain, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealOutput prepares the contents of an output file: compressed according
+// to its extension, then encrypted when a key is set.
+func SealOutput(path string, data, key []byte) ([]byte, error) {
+	data, err := compressForPath(path, data)
+	if err != nil || key == nil {
+		return data, err
+	}
+	return encryptData(key, data)
+}
+
+// CheckEncryptedRun rejects options that would leave plaintext files on
+// disk when outputs are encrypted: directory outputs (which -bundle
+// replaces) and renderers that need temporary files.
+func CheckEncryptedRun(config OCRConfig, extractImages bool) error {
+	if flags := directoryOutputFlags(config, extractImages); len(flags) > 0 {
+		return fmt.Errorf("%s writes plaintext files and cannot be combined with -encrypt-key; use -bundle instead", flags[0])
+	}
+	return checkNoTempFiles(config, "-encrypt-key")
+}
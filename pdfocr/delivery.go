@@ -0,0 +1,75 @@
+package pdfocr
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// maxPresignExpiry is the longest validity of a presigned S3 URL.
+const maxPresignExpiry = 7 * 24 * time.Hour
+
+// s3Location is an S3 bucket and key prefix, written s3://bucket/prefix.
+type s3Location struct {
+	Bucket string
+	Prefix string // "" or ending in "/"
+}
+
+// parseS3URL reads an s3://bucket/prefix location; the prefix names a
+// "directory", so a missing trailing slash is added.
+func parseS3URL(spec string) (s3Location, error) {
+	rest, ok := strings.CutPrefix(spec, "s3://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if !ok || bucket == "" || strings.Contains(prefix, "//") || strings.Contains("/"+prefix+"/", "/../") {
+		return s3Location{}, fmt.Errorf("invalid S3 location %q (use s3://bucket/prefix)", spec)
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return s3Location{Bucket: bucket, Prefix: prefix}, nil
+}
+
+// parseS3Locations reads a comma-separated list of s3://bucket/prefix
+// locations.
+func parseS3Locations(list string) ([]s3Location, error) {
+	var locs []s3Location
+	for _, spec := range strings.Split(list, ",") {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		loc, err := parseS3URL(spec)
+		if err != nil {
+			return nil, err
+		}
+		locs = append(locs, loc)
+	}
+	return locs, nil
+}
+
+func (l s3Location) String() string {
+	return "s3://" + l.Bucket + "/" + l.Prefix
+}
+
+// within reports whether l lies under root.
+func (l s3Location) within(root s3Location) bool {
+	return l.Bucket == root.Bucket && strings.HasPrefix(l.Prefix, root.Prefix)
+}
+
+// key returns the key of the object name under the prefix.
+func (l s3Location) key(name string) string {
+	return l.Prefix + path.Base(name)
+}
+
+// s3Delivery is the JSON body of the server's responses to requests whose
+// result went to S3 in place of the response.
+type s3Delivery struct {
+	Location    string     `json:"location"`          // s3://bucket/key of the result
+	URL         string     `json:"url,omitempty"`     // presigned download URL, with presign
+	Expires     *time.Time `json:"expires,omitempty"` // of URL
+	Bytes       int        `json:"bytes"`
+	ContentType string     `json:"content_type"`
+	Pages       int        `json:"pages"`
+	Degraded    []string   `json:"degraded,omitempty"`
+	Truncated   bool       `json:"truncated,omitempty"`
+}
@@ -0,0 +1,49 @@
+package pdfocr
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDeliveryTarget(t *testing.T) {
+	roots, err := parseS3Locations("s3://results/ocr, s3://archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ocrServer{s3Roots: roots}
+	for query, want := range map[string]string{
+		"s3=s3://results/ocr/case-7":       "s3://results/ocr/case-7/",
+		"s3=s3://archive/2026/":            "s3://archive/2026/",
+		"s3=s3://results/other":            "403",
+		"s3=s3://results/ocr/../other":     "400",
+		"s3=s3://elsewhere/ocr&presign=1h": "403",
+		"presign=10m":                      "s3://results/ocr/",
+		"presign=720h":                     "400",
+		"format=json":                      "",
+	} {
+		values, _ := url.ParseQuery(query)
+		w := httptest.NewRecorder()
+		dest, _, ok := s.deliveryTarget(w, values)
+		got := ""
+		switch {
+		case !ok:
+			got = strconv.Itoa(w.Code)
+		case dest != nil:
+			got = dest.String()
+		}
+		if query == "presign=10m" && strings.HasPrefix(got, want) {
+			// a new prefix per request
+			got = want
+		}
+		if got != want {
+			t.Errorf("deliveryTarget(%q) = %q, want %q", query, got, want)
+		}
+	}
+
+	if _, _, ok := (&ocrServer{}).deliveryTarget(httptest.NewRecorder(), url.Values{"presign": {"1h"}}); ok {
+		t.Error("a server without -s3 accepted presign")
+	}
+}
@@ -0,0 +1,53 @@
+package pdfocr
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+)
+
+//go:generate go run samples/generate.go
+
+// demoPDF is a one-page scan (a JPEG without a text layer) used by the demo
+// command, so every word of it has to come from OCR.
+//
+//go:embed samples/scanned.pdf
+var demoPDF []byte
+
+// demoSampleLines is the text shown in demoPDF.
+var demoSampleLines = []string{
+	"SAMPLE SCANNED DOCUMENT",
+	"This page is an image without a text layer.",
+	"If this sentence appears in the output,",
+	"OCR is working on your machine.",
+	"Invoice 2024-0417 Total 1250.00",
+}
+
+// DemoResult is the outcome of Demo: the text recognized on the sample
+// scan and the fraction of its words that came back.
+type DemoResult struct {
+	Text  string
+	Match float64
+}
+
+// Passed reports whether enough of the sample text was recognized for the
+// installation to be working.
+func (r DemoResult) Passed() bool {
+	return r.Match >= doctorMinMatch
+}
+
+// Demo OCRs a built-in sample scan with the regular pipeline and config,
+// and scores the text recognized against the text shown on it.
+func Demo(config OCRConfig) (DemoResult, error) {
+	if _, err := lookupRenderer(config.Renderer); err != nil {
+		return DemoResult{}, err
+	}
+	if _, err := lookupEngine(config.Engine); err != nil {
+		return DemoResult{}, err
+	}
+	text, _, err := extractPDFData(context.Background(), demoPDF, "sample-scanned.pdf", config, 0)
+	if err != nil {
+		return DemoResult{}, fmt.Errorf("error extracting text: %w", err)
+	}
+	return DemoResult{Text: text, Match: wordMatch(demoSampleLines, text)}, nil
+}
@@ -0,0 +1,37 @@
+// Package pdfocr extracts the text of PDF documents, using the text layer
+// of pages that have one and OCR for scanned pages, along with a manifest
+// of the document and its attachments.
+//
+// Programs embedding it create an Extractor from an OCRConfig:
+//
+//	config := pdfocr.DefaultConfig()
+//	config.Language = "deu"
+//	ex, err := pdfocr.NewExtractor(config)
+//	if err != nil {
+//		return err
+//	}
+//	text, err := ex.ExtractText(ctx, "scan.pdf")
+//
+// The renderer and OCR engine are chosen by name in the config; the build
+// tags nomupdf and nogosseract remove the cgo backends, and nocloud the
+// engines that upload page images to cloud OCR services. Progress messages
+// and warnings are logged to OCRConfig.Logger, a *slog.Logger, or else the
+// one given to SetLogger or slog.Default(), unless OCRConfig.Progress
+// receives the progress as events and OCRConfig.Warnings the warnings; the
+// manifest lists the warnings of every document. The package neither
+// prints nor exits: the command line in cmd/pdf-ocr-tool does both.
+//
+// Long documents can be streamed: Extractor.ExtractTo writes the text of
+// every page to an io.Writer as soon as it is done instead of returning the
+// whole text, and OCRConfig.Pages receives every finished page.
+//
+// Pages that fail are handled by OCRConfig.ErrorPolicy: by default they
+// are kept as failed pages and DocumentManifest.Report lists their
+// errors as *PageError values; ErrorPolicyFailFast stops the document at
+// the first one instead.
+//
+// Servers handling untrusted documents can extract them in a Sandbox of
+// worker processes instead, so a page that crashes MuPDF or Tesseract
+// fails on its own, with ErrWorkerCrashed; a program running its own executable as the worker
+// calls RunSandboxWorker when started with SandboxWorkerCommand.
+package pdfocr
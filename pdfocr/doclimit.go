@@ -0,0 +1,81 @@
+package pdfocr
+
+import "sync"
+
+// docLimiter bounds the documents a batch run holds open at a time, by
+// number (-max-open-docs) and by their estimated memory (-max-memory). Every
+// file of the run takes one slot, from reading the file to closing the
+// document; its attachments are opened within the slot of their parent. A
+// nil limiter is unlimited.
+type docLimiter struct {
+	mu       sync.Mutex
+	freed    *sync.Cond
+	maxDocs  int   // 0 for no limit
+	maxBytes int64 // 0 for no limit
+	docs     int
+	bytes    int64
+}
+
+// newDocLimiter returns a limiter for maxDocs documents and maxBytes of
+// memory, or nil when neither is set.
+func newDocLimiter(maxDocs int, maxBytes int64) *docLimiter {
+	if maxDocs <= 0 && maxBytes <= 0 {
+		return nil
+	}
+	l := &docLimiter{maxDocs: maxDocs, maxBytes: maxBytes}
+	l.freed = sync.NewCond(&l.mu)
+	return l
+}
+
+// fits reports whether a document needing bytes can be opened now. With
+// nothing open, any document fits, so that one larger than the whole memory
+// limit still runs, on its own.
+func (l *docLimiter) fits(bytes int64) bool {
+	if l.docs == 0 {
+		return true
+	}
+	return (l.maxDocs <= 0 || l.docs < l.maxDocs) && (l.maxBytes <= 0 || l.bytes+bytes <= l.maxBytes)
+}
+
+// acquire waits until a document needing bytes fits and accounts for it.
+func (l *docLimiter) acquire(bytes int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for !l.fits(bytes) {
+		l.freed.Wait()
+	}
+	l.docs++
+	l.bytes += bytes
+}
+
+// release returns the slot of a closed document that needed bytes.
+func (l *docLimiter) release(bytes int64) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.docs--
+	l.bytes -= bytes
+	l.mu.Unlock()
+	l.freed.Broadcast()
+}
+
+// pageImageMemory estimates the memory of a page rendered at dpi (0 means
+// defaultRenderDPI): a US Letter page in RGBA, with the copies made by
+// preprocessing.
+func pageImageMemory(dpi float64) int64 {
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	return 2 * int64(8.5*dpi) * int64(11*dpi) * 4
+}
+
+// documentMemory estimates the working set of a document of size bytes
+// processed with config: the file held in memory, which the renderer reads
+// in place, and a page image being recognized.
+func documentMemory(size int64, config OCRConfig) int64 {
+	return size + pageImageMemory(config.DPI)
+}
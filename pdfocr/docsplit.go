@@ -0,0 +1,178 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Why a new document starts at a page of a batch scan.
+const (
+	BoundarySeparator  = "blank separator"      // after one or more blank pages
+	BoundaryLetterhead = "letterhead change"    // a different letterhead than the document's first
+	BoundaryNumbering  = "page numbers restart" // the printed page number is 1 again
+)
+
+const (
+	// letterheadZone is the height of the strip compared for letterheads,
+	// as a fraction of the page height.
+	letterheadZone = 0.15
+
+	// letterheadInk is the mean ink density (0-255) of the fingerprint of a
+	// strip holding a letterhead; the plain header of a continuation page,
+	// such as "Page 2 of 3", stays below it.
+	letterheadInk = 6
+
+	// letterheadMatchMin is the fingerprint similarity of two strips showing
+	// the same letterhead.
+	letterheadMatchMin = 0.6
+)
+
+// DocumentPart is a document found within a batch scan.
+type DocumentPart struct {
+	First  int    `json:"first"` // first and last physical page, 1-based
+	Last   int    `json:"last"`
+	Pages  int    `json:"pages"`            // pages of the document, separators left out
+	Reason string `json:"reason,omitempty"` // BoundarySeparator, BoundaryLetterhead or BoundaryNumbering; "" for the first
+}
+
+func (d DocumentPart) String() string {
+	pages := fmt.Sprintf("page %d", d.First)
+	if d.Last != d.First {
+		pages = fmt.Sprintf("pages %d-%d", d.First, d.Last)
+	}
+	if d.Reason == "" {
+		return pages
+	}
+	return fmt.Sprintf("%s (%s)", pages, d.Reason)
+}
+
+// pageLook is what document detection needs from the image of a page.
+type pageLook struct {
+	blank  bool
+	header pageFingerprint // of the letterhead strip
+	inked  bool            // the strip holds a letterhead
+}
+
+// lookAtPage renders a page at fingerprintDPI and describes it.
+func (src *pdfSource) lookAtPage(pageNum int, config OCRConfig) (pageLook, error) {
+	img, err := src.renderPage(pageNum, fingerprintDPI, config.RobustDecode)
+	if err != nil {
+		return pageLook{}, err
+	}
+	b := img.Bounds()
+	strip := image.NewRGBA(image.Rect(0, 0, b.Dx(), max(int(float64(b.Dy())*letterheadZone), 1)))
+	draw.Draw(strip, strip.Bounds(), img, b.Min, draw.Src)
+	look := pageLook{blank: isBlankImage(img), header: fingerprintImage(strip)}
+	ink := 0
+	for _, v := range look.header {
+		ink += int(v)
+	}
+	look.inked = ink >= letterheadInk*len(look.header)
+	return look, nil
+}
+
+// detectDocuments splits the pages of a batch scan into the documents they
+// hold. A document ends at blank pages without text, and a new one starts
+// where the printed page number (PageResult.Label, or the text's first or
+// last line) is 1 again or the letterhead differs from the letterhead of
+// the document's first page.
+func detectDocuments(src *pdfSource, pages []PageResult, config OCRConfig) []DocumentPart {
+	var parts []DocumentPart
+	var letterhead *pageFingerprint // of the current document
+	inDocument := false
+	reason := ""   // of the next document
+	lastLabel := 0 // printed number of the previous page of the document
+	for _, p := range pages {
+		look, err := src.lookAtPage(p.Page-1, config)
+		if err != nil {
+			config.logf("Page %d could not be rendered for document detection: %v\n", p.Page, err)
+		}
+		if err == nil && look.blank && strings.TrimSpace(p.Text) == "" {
+			if inDocument {
+				inDocument, reason = false, BoundarySeparator
+			}
+			continue
+		}
+
+		label := p.Label
+		if label == "" && p.Text != "" {
+			label = textPageLabel(p.Text)
+		}
+		n, _ := strconv.Atoi(label)
+		if inDocument {
+			switch {
+			case n == 1 && lastLabel > 0:
+				reason = BoundaryNumbering
+			case err == nil && look.inked && letterhead != nil && look.header.similarity(*letterhead) < letterheadMatchMin:
+				reason = BoundaryLetterhead
+			}
+		}
+		if !inDocument || reason != "" {
+			parts = append(parts, DocumentPart{First: p.Page, Reason: reason})
+			inDocument, reason, letterhead, lastLabel = true, "", nil, 0
+		}
+		if err == nil && look.inked && letterhead == nil {
+			letterhead = &look.header
+		}
+		if n > 0 {
+			lastLabel = n
+		}
+		parts[len(parts)-1].Last = p.Page
+		parts[len(parts)-1].Pages++
+	}
+	return parts
+}
+
+// documentPath is where the text of the nth detected document of an output
+// file is written: "out.txt" gives "out_doc1.txt", "out.txt.gz"
+// "out_doc1.txt.gz".
+func documentPath(path string, n int) string {
+	compression := ""
+	for _, ext := range []string{".gz", ".zst"} {
+		if strings.HasSuffix(path, ext) {
+			compression, path = ext, strings.TrimSuffix(path, ext)
+		}
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s_doc%d%s%s", strings.TrimSuffix(path, ext), n, ext, compression)
+}
+
+// documentText returns the text output of the pages of a detected document.
+func documentText(pages []PageResult, part DocumentPart) string {
+	var b strings.Builder
+	for _, p := range pages {
+		if p.Page >= part.First && p.Page <= part.Last {
+			b.WriteString(p.section())
+			b.WriteString(p.annotations)
+		}
+	}
+	return b.String()
+}
+
+// WriteDocumentParts writes the text of every document detected in a batch
+// scan (DocumentManifest.Documents) to its own file next to path, formatted,
+// compressed and encrypted like the text output. It returns the files
+// written.
+func WriteDocumentParts(path string, manifest DocumentManifest, config OCRConfig) ([]string, error) {
+	var written []string
+	for i, part := range manifest.Documents {
+		data, err := FormatOutput(documentText(manifest.PageResults, part), config)
+		if err != nil {
+			return written, err
+		}
+		out := documentPath(path, i+1)
+		if data, err = SealOutput(out, data, config.EncryptKey); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(out, data, 0644); err != nil {
+			return written, fmt.Errorf("error writing document text: %w", err)
+		}
+		written = append(written, out)
+	}
+	return written, nil
+}
@@ -0,0 +1,249 @@
+package pdfocr
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// doctorLines is the text of the synthetic self-test page: a pangram and the
+// digits, so every letter the default engine is expected to know appears.
+var doctorLines = []string{
+	"PACK MY BOX WITH FIVE",
+	"DOZEN LIQUOR JUGS",
+	"The quick brown fox",
+	"0123456789",
+}
+
+// doctorMinMatch is the fraction of expected words OCR must recover for the
+// self-test to pass; a healthy installation gets all of them.
+const doctorMinMatch = 0.8
+
+// buildTextPDF writes a one-page PDF that shows lines in Helvetica at size
+// points.
+func buildTextPDF(lines []string, size float64) []byte {
+	var content strings.Builder
+	fmt.Fprintf(&content, "BT /F1 %g Tf %g TL 72 720 Td\n", size, size*1.4)
+	escape := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+	for _, line := range lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", escape.Replace(line))
+	}
+	content.WriteString("ET\n")
+
+	return buildPDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	})
+}
+
+// wordMatch returns the fraction of the words in want that occur in got,
+// ignoring case and surrounding punctuation.
+func wordMatch(want []string, got string) float64 {
+	found := map[string]bool{}
+	for _, w := range strings.Fields(strings.ToUpper(got)) {
+		found[strings.Trim(w, ".,;:")] = true
+	}
+	total, hits := 0, 0
+	for _, line := range want {
+		for _, w := range strings.Fields(strings.ToUpper(line)) {
+			total++
+			if found[strings.Trim(w, ".,;:")] {
+				hits++
+			}
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(hits) / float64(total)
+}
+
+// Statuses of the checks of a DoctorReport.
+const (
+	DoctorOK   = "ok"
+	DoctorWarn = "warn"
+	DoctorFail = "fail"
+)
+
+// DoctorCheck is one check of a DoctorReport: its status, what was found,
+// and for problems how to fix them, if known.
+type DoctorCheck struct {
+	Status  string
+	Message string
+	Fix     string
+}
+
+// DoctorReport lists the checks of Doctor in pipeline order.
+type DoctorReport struct {
+	Checks []DoctorCheck
+}
+
+// Failed reports whether a check failed.
+func (r *DoctorReport) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == DoctorFail {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *DoctorReport) ok(format string, args ...interface{}) {
+	r.Checks = append(r.Checks, DoctorCheck{Status: DoctorOK, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *DoctorReport) warn(fix, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, DoctorCheck{Status: DoctorWarn, Message: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+func (r *DoctorReport) fail(fix, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, DoctorCheck{Status: DoctorFail, Message: fmt.Sprintf(format, args...), Fix: fix})
+}
+
+// rendererFix suggests how to repair a renderer that could not open the
+// synthetic page.
+func rendererFix(name string) string {
+	if name == "poppler" {
+		return "install poppler-utils (pdfinfo, pdftotext, pdftoppm), e.g. apt-get install poppler-utils"
+	}
+	return "rebuild with CGO_ENABLED=1 and without the nomupdf tag, or use -renderer poppler"
+}
+
+// engineFix suggests how to repair an OCR failure, judged on the error text.
+func engineFix(engine, lang string, err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "executable file not found"):
+		return "install the tesseract executable, e.g. apt-get install tesseract-ocr"
+	case strings.Contains(msg, "traineddata") || strings.Contains(msg, "loading language"):
+		return languageFix(lang)
+	case engine == "tesseract":
+		return "check that libtesseract and leptonica are installed, or try -engine tesseract-cli"
+	}
+	return ""
+}
+
+// languageFix explains how to install missing traineddata for lang.
+func languageFix(lang string) string {
+	return fmt.Sprintf("install the %s traineddata (e.g. apt-get install tesseract-ocr-%s) "+
+		"or download it from https://github.com/tesseract-ocr/tessdata_fast into the directory named by TESSDATA_PREFIX",
+		lang, strings.ToLower(lang))
+}
+
+// Doctor runs a synthetic page through rendering, OCR and output encoding
+// with the backends of config, stopping at the first failure that makes
+// the remaining checks pointless, and reports what is broken and how to
+// fix it.
+func Doctor(config OCRConfig) DoctorReport {
+	var report DoctorReport
+	doctorPipeline(config, &report)
+	return report
+}
+
+// doctorPipeline runs the checks in pipeline order and returns false when a
+// failure makes the remaining checks pointless.
+func doctorPipeline(config OCRConfig, report *DoctorReport) bool {
+	// Temporary files: the poppler renderer and the gosseract engine need them
+	f, err := os.CreateTemp("", scratchPattern("doctor", -1, ""))
+	if err == nil {
+		err = f.Close()
+		os.Remove(f.Name())
+	}
+	if err != nil {
+		report.fail("set TMPDIR to a writable directory", "temporary directory %s is not writable: %v", os.TempDir(), err)
+	} else {
+		report.ok("temporary directory %s is writable", os.TempDir())
+	}
+
+	// Rendering
+	renderer, err := lookupRenderer(config.Renderer)
+	if err != nil {
+		report.fail("", "%v", err)
+		return false
+	}
+	src, err := openPDFSource(buildTextPDF(doctorLines, 28), "self-test.pdf", renderer.Name())
+	if err != nil {
+		report.fail(rendererFix(renderer.Name()), "renderer %s cannot open a PDF: %v", renderer.Name(), err)
+		return false
+	}
+	defer src.Close()
+	report.ok("renderer %s (%s) opened the test document", renderer.Name(), renderer.Version())
+
+	native, err := src.doc.Text(0)
+	if err != nil || wordMatch(doctorLines, native) < 1 {
+		report.warn("", "renderer %s did not return the page's text layer (native text extraction is broken)", renderer.Name())
+	} else {
+		report.ok("native text extraction")
+	}
+
+	img, err := src.renderPage(0, config.DPI, false)
+	if err != nil {
+		report.fail(rendererFix(renderer.Name()), "renderer %s cannot rasterize pages: %v", renderer.Name(), err)
+		return false
+	}
+	if isBlankImage(img) {
+		report.fail(rendererFix(renderer.Name()), "renderer %s produced a blank page image (missing fonts?)", renderer.Name())
+		return false
+	}
+	b := img.Bounds()
+	report.ok("rendered page at %g DPI (%dx%d)", config.DPI, b.Dx(), b.Dy())
+
+	// OCR
+	engine, err := lookupEngine(config.Engine)
+	if err != nil {
+		report.fail("", "%v", err)
+		return false
+	}
+	report.ok("OCR engine %s (%s)", engine.Name(), engine.Version())
+	if lister, ok := engine.(languageLister); ok {
+		installed, err := lister.Languages()
+		if err != nil {
+			report.warn("", "could not list installed languages: %v", err)
+		} else {
+			have := map[string]bool{}
+			for _, l := range installed {
+				have[l] = true
+			}
+			missing := false
+			for _, l := range strings.Split(config.Language, "+") {
+				if !have[l] {
+					missing = true
+					report.fail(languageFix(l), "traineddata for language %q is not installed (installed: %s)", l, strings.Join(installed, " "))
+				}
+			}
+			if missing {
+				return false
+			}
+			report.ok("traineddata installed for %s", config.Language)
+		}
+	}
+
+	text, err := engine.Text(img, config, PageOCROptions{})
+	if err != nil {
+		report.fail(engineFix(engine.Name(), config.Language, err), "OCR failed: %v", err)
+		return false
+	}
+	score := wordMatch(doctorLines, text)
+	if score < doctorMinMatch {
+		report.fail("check that the traineddata for "+config.Language+" is not truncated or from an incompatible Tesseract version",
+			"OCR recognized only %.0f%% of the test words: %q", score*100, strings.Join(strings.Fields(text), " "))
+		return false
+	}
+	report.ok("OCR recognized %.0f%% of the test words", score*100)
+
+	// Output
+	encoding, err := normalizeEncoding(config.Encoding)
+	if err != nil {
+		report.fail("", "%v", err)
+		return false
+	}
+	if _, err := EncodeText(NormalizeWhitespace(text, config), encoding); err != nil {
+		report.fail("choose another -encoding", "encoding the result as %s failed: %v", encoding, err)
+		return false
+	}
+	report.ok("output encoding %s", encoding)
+	return true
+}
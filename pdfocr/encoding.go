@@ -0,0 +1,78 @@
+package pdfocr
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// Supported output encodings for text output.
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF8BOM     = "utf-8-bom"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingWindows1252 = "windows-1252"
+)
+
+// cp1252High maps the Unicode code points that Windows-1252 places in the
+// 0x80-0x9F range. Everything in 0xA0-0xFF matches Latin-1 directly.
+var cp1252High = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B, 'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// normalizeEncoding maps the accepted spellings of an encoding name to one of
+// the Encoding constants.
+func normalizeEncoding(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "utf8", "utf-8":
+		return EncodingUTF8, nil
+	case "utf8bom", "utf-8-bom", "utf8-bom":
+		return EncodingUTF8BOM, nil
+	case "utf16le", "utf-16le", "utf-16":
+		return EncodingUTF16LE, nil
+	case "windows-1252", "cp1252", "win1252":
+		return EncodingWindows1252, nil
+	}
+	return "", fmt.Errorf("unsupported encoding %q (use utf-8, utf-8-bom, utf-16le or windows-1252)", name)
+}
+
+// EncodeText converts UTF-8 text into the requested output encoding.
+// UTF-16LE output starts with a byte order mark, as most Windows tools expect.
+// Windows-1252 is best-effort: characters outside the code page become '?'.
+func EncodeText(text, encoding string) ([]byte, error) {
+	enc, err := normalizeEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	switch enc {
+	case EncodingUTF8BOM:
+		return append([]byte{0xEF, 0xBB, 0xBF}, text...), nil
+	case EncodingUTF16LE:
+		units := utf16.Encode([]rune(text))
+		out := make([]byte, 2, 2+2*len(units))
+		out[0], out[1] = 0xFF, 0xFE
+		for _, u := range units {
+			out = binary.LittleEndian.AppendUint16(out, u)
+		}
+		return out, nil
+	case EncodingWindows1252:
+		out := make([]byte, 0, len(text))
+		for _, r := range text {
+			switch {
+			case r < 0x80 || (r >= 0xA0 && r <= 0xFF):
+				out = append(out, byte(r))
+			case cp1252High[r] != 0:
+				out = append(out, cp1252High[r])
+			default:
+				out = append(out, '?')
+			}
+		}
+		return out, nil
+	}
+	return []byte(text), nil
+}
@@ -0,0 +1,145 @@
+package pdfocr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// Engine is a text recognition backend for rendered page images.
+type Engine interface {
+	// Name identifies the engine on the command line, e.g. "tesseract".
+	Name() string
+	// Version describes the engine library or tool version.
+	Version() string
+	// Text recognizes the text in img.
+	Text(img image.Image, config OCRConfig, opts PageOCROptions) (string, error)
+}
+
+// OCRWord is a recognized word and its position in the page image.
+type OCRWord struct {
+	Text       string
+	Box        image.Rectangle
+	Confidence float64 // 0-100
+}
+
+// linesText returns the text of words grouped into lines, for engines that
+// recognize lines of words.
+func linesText(lines [][]OCRWord) string {
+	var b strings.Builder
+	for _, line := range lines {
+		for i, w := range line {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteString(w.Text)
+		}
+		b.WriteByte('\n')
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// wordEngine is implemented by engines that can report word positions.
+type wordEngine interface {
+	Words(img image.Image, config OCRConfig, opts PageOCROptions) ([]OCRWord, error)
+}
+
+// hocrEngine is implemented by engines that can describe the layout of a
+// page as hOCR.
+type hocrEngine interface {
+	// HOCR returns the hOCR of img: its ocr_page element, or a complete
+	// document holding one.
+	HOCR(img image.Image, config OCRConfig, opts PageOCROptions) (string, error)
+}
+
+// osdEngine is implemented by engines that can detect the orientation and
+// script of a page image.
+type osdEngine interface {
+	OSD(img image.Image, config OCRConfig) (pageOSD, error)
+}
+
+// checkedEngine is implemented by engines that need settings outside
+// OCRConfig, such as the credentials of a cloud service; Check reports what
+// is missing before the first page is recognized.
+type checkedEngine interface {
+	Check() error
+}
+
+// modelEngine is implemented by engines that load the model files named
+// in OCRConfig; CheckModels reports the ones missing before the first page
+// is recognized.
+type modelEngine interface {
+	CheckModels(config OCRConfig) error
+}
+
+// ErrCloudUnreachable is wrapped by the errors of cloud engines that could
+// not reach their service, as opposed to errors the service returned.
+var ErrCloudUnreachable = errors.New("cloud OCR service unreachable")
+
+// remoteEngine is implemented by engines that send page images over the
+// network, which sandbox workers may otherwise not use.
+type remoteEngine interface {
+	remote()
+}
+
+// EngineIsRemote reports whether the engine registered under name is a
+// remoteEngine.
+func EngineIsRemote(name string) bool {
+	e, err := lookupEngine(name)
+	if err != nil {
+		return false
+	}
+	_, ok := e.(remoteEngine)
+	return ok
+}
+
+// pageOSD is the orientation and script of a page image.
+type pageOSD struct {
+	// Rotation is the clockwise rotation, a multiple of 90 degrees, that
+	// turns the image upright
+	Rotation              int
+	OrientationConfidence float64
+	Script                string // e.g. "Latin", "Cyrillic", "Han"
+	ScriptConfidence      float64
+}
+
+var engines = map[string]Engine{}
+
+// RegisterEngine makes an OCR engine selectable by name.
+func RegisterEngine(e Engine) {
+	engines[e.Name()] = e
+}
+
+// lookupEngine returns the engine registered under name ("" selects
+// DefaultEngine, which depends on the build profile).
+func lookupEngine(name string) (Engine, error) {
+	if name == "" {
+		name = DefaultEngine
+	}
+	e, ok := engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown OCR engine %q (available: %s)", name, strings.Join(engineNames(), ", "))
+	}
+	return e, nil
+}
+
+// engineNames lists the registered engines in alphabetical order.
+func engineNames() []string {
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Engines returns the registered engines in alphabetical order.
+func Engines() []Engine {
+	var list []Engine
+	for _, name := range engineNames() {
+		list = append(list, engines[name])
+	}
+	return list
+}
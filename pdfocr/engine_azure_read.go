@@ -0,0 +1,104 @@
+//go:build !nocloud
+
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// azureReadPoll is how often the result of a Read operation is asked for.
+const azureReadPoll = 500 * time.Millisecond
+
+func init() {
+	RegisterEngine(cloudEngine{
+		name:      "azure-read",
+		service:   "Azure AI Vision Read API v3.2",
+		env:       []string{"AZURE_VISION_ENDPOINT", "AZURE_VISION_KEY"},
+		recognize: azureReadRecognize,
+	})
+}
+
+// azureReadResult is the result of a Read operation.
+type azureReadResult struct {
+	Status        string `json:"status"` // notStarted, running, succeeded or failed
+	AnalyzeResult struct {
+		ReadResults []struct {
+			Lines []struct {
+				Words []struct {
+					BoundingBox []float64 `json:"boundingBox"`
+					Text        string    `json:"text"`
+					Confidence  float64   `json:"confidence"`
+				} `json:"words"`
+			} `json:"lines"`
+		} `json:"readResults"`
+	} `json:"analyzeResult"`
+}
+
+// azureReadRecognize submits a page image to the Read API of the Azure
+// resource at AZURE_VISION_ENDPOINT and waits for the operation to finish.
+func azureReadRecognize(img []byte, size image.Point, config OCRConfig) ([][]OCRWord, error) {
+	key := cloudEnv("AZURE_VISION_KEY")
+	target := strings.TrimRight(cloudEnv("AZURE_VISION_ENDPOINT"), "/") + "/vision/v3.2/read/analyze"
+	if hints := languageHints(config.Language); len(hints) == 1 {
+		// Read takes one language; without it, it detects them per line
+		target += "?language=" + url.QueryEscape(hints[0])
+	}
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(img))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Ocp-Apim-Subscription-Key", key)
+	_, header, err := cloudDo(req)
+	if err != nil {
+		return nil, err
+	}
+	operation := header.Get("Operation-Location")
+	if operation == "" {
+		return nil, fmt.Errorf("no Operation-Location in the response")
+	}
+
+	deadline := time.Now().Add(cloudClient.Timeout)
+	for {
+		time.Sleep(azureReadPoll)
+		req, err := http.NewRequest(http.MethodGet, operation, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Ocp-Apim-Subscription-Key", key)
+		data, _, err := cloudDo(req)
+		if err != nil {
+			return nil, err
+		}
+		var result azureReadResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return nil, fmt.Errorf("error reading response: %w", err)
+		}
+		switch result.Status {
+		case "succeeded":
+			var lines [][]OCRWord
+			for _, page := range result.AnalyzeResult.ReadResults {
+				for _, l := range page.Lines {
+					var line []OCRWord
+					for _, w := range l.Words {
+						line = append(line, OCRWord{Text: w.Text, Box: boundingBox(w.BoundingBox), Confidence: w.Confidence * 100})
+					}
+					lines = append(lines, line)
+				}
+			}
+			return lines, nil
+		case "failed":
+			return nil, fmt.Errorf("read operation failed")
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("read operation still %s after %s", result.Status, cloudClient.Timeout)
+		}
+	}
+}
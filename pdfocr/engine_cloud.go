@@ -0,0 +1,297 @@
+//go:build !nocloud
+
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// cloudClient sends the requests of the cloud OCR engines.
+var cloudClient = &http.Client{Timeout: 2 * time.Minute}
+
+// cloudBatchLinger is how long a batch of pages waits for more before it
+// is sent short, e.g. with the last pages of a document.
+const cloudBatchLinger = 500 * time.Millisecond
+
+// cloudEngine adapts a cloud OCR service to Engine. Every page image is
+// uploaded once per call and comes back as words grouped into lines, in
+// pixels of the image; the credentials are read from the environment.
+type cloudEngine struct {
+	name    string
+	service string
+	// env lists the environment variables the service needs; an entry
+	// "A|B" is satisfied by either
+	env       []string
+	recognize func(img []byte, size image.Point, config OCRConfig) ([][]OCRWord, error)
+	// recognizeBatch replaces recognize for services that take up to
+	// maxBatch images per request, filling in the result of every page;
+	// its error fails the pages without one
+	recognizeBatch func(pages []*cloudPage, config OCRConfig) error
+	maxBatch       int
+	// maxBatchBytes caps the encoded images of a batch request when above
+	// 0; a page over it on its own is sent alone
+	maxBatchBytes int
+	batcher       *cloudBatcher
+}
+
+// cloudPage is a page image sent to a cloud service, with its result.
+type cloudPage struct {
+	img   []byte // PNG
+	size  image.Point
+	lines [][]OCRWord
+	err   error
+}
+
+func (e cloudEngine) Name() string { return e.name }
+
+func (cloudEngine) remote() {}
+
+func (e cloudEngine) Version() string {
+	if err := e.Check(); err != nil {
+		return e.service + " (not configured)"
+	}
+	return e.service
+}
+
+// Check reports missing credentials before the first page is uploaded.
+func (e cloudEngine) Check() error {
+	var missing []string
+	for _, names := range e.env {
+		if cloudEnv(names) == "" {
+			missing = append(missing, strings.ReplaceAll(names, "|", " or "))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("OCR engine %s needs %s set in the environment", e.name, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func (e cloudEngine) lines(img image.Image, config OCRConfig) ([][]OCRWord, error) {
+	var data bytes.Buffer
+	if err := png.Encode(&data, img); err != nil {
+		return nil, fmt.Errorf("error encoding image: %w", err)
+	}
+	page := &cloudPage{img: data.Bytes(), size: img.Bounds().Size()}
+	if e.recognizeBatch != nil {
+		e.batcher.recognize(e, page, config)
+	} else {
+		page.lines, page.err = e.recognize(page.img, page.size, config)
+	}
+	if page.err != nil {
+		return nil, fmt.Errorf("error performing OCR with %s: %w", e.service, page.err)
+	}
+	return page.lines, nil
+}
+
+// cloudBatcher gathers the pages the workers of a run upload to a service
+// into batches of up to config.CloudBatch pages, by language since every
+// request has one set of hints. A batch is sent once full, or
+// cloudBatchLinger after its first page; a page that would take it past
+// the request size limit of the service sends it and starts the next.
+type cloudBatcher struct {
+	mu      sync.Mutex
+	pending map[string]*cloudBatch
+}
+
+// cloudBatch is a batch of pages being gathered or recognized; done is
+// closed once their results are in.
+type cloudBatch struct {
+	pages  []*cloudPage
+	bytes  int // base64 of the images
+	config OCRConfig
+	done   chan struct{}
+}
+
+func newCloudBatcher() *cloudBatcher {
+	return &cloudBatcher{pending: map[string]*cloudBatch{}}
+}
+
+// recognize adds page to the batch being gathered and waits for its
+// result.
+func (b *cloudBatcher) recognize(e cloudEngine, page *cloudPage, config OCRConfig) {
+	size := min(max(config.CloudBatch, 1), e.maxBatch)
+	key := config.Language + "/" + strconv.Itoa(size)
+	encoded := base64.StdEncoding.EncodedLen(len(page.img))
+	b.mu.Lock()
+	batch := b.pending[key]
+	var overflow *cloudBatch
+	if batch != nil && e.maxBatchBytes > 0 && batch.bytes+encoded > e.maxBatchBytes {
+		overflow, batch = batch, nil
+		delete(b.pending, key)
+	}
+	if batch == nil {
+		batch = &cloudBatch{config: config, done: make(chan struct{})}
+		b.pending[key] = batch
+		if size > 1 {
+			time.AfterFunc(cloudBatchLinger, func() { b.send(e, key, batch) })
+		}
+	}
+	batch.pages = append(batch.pages, page)
+	batch.bytes += encoded
+	full := len(batch.pages) >= size || (e.maxBatchBytes > 0 && batch.bytes >= e.maxBatchBytes)
+	b.mu.Unlock()
+	if overflow != nil {
+		go b.flush(e, overflow)
+	}
+	if full {
+		b.send(e, key, batch)
+	}
+	<-batch.done
+}
+
+// send recognizes the pages of batch unless it has been sent already.
+func (b *cloudBatcher) send(e cloudEngine, key string, batch *cloudBatch) {
+	b.mu.Lock()
+	if b.pending[key] != batch {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.pending, key)
+	b.mu.Unlock()
+	b.flush(e, batch)
+}
+
+// flush recognizes the pages of a batch taken off pending.
+func (b *cloudBatcher) flush(e cloudEngine, batch *cloudBatch) {
+	if err := e.recognizeBatch(batch.pages, batch.config); err != nil {
+		for _, p := range batch.pages {
+			if p.err == nil {
+				p.err = err
+			}
+		}
+	}
+	close(batch.done)
+}
+
+func (e cloudEngine) Text(img image.Image, config OCRConfig, opts PageOCROptions) (string, error) {
+	lines, err := e.lines(img, config)
+	if err != nil {
+		return "", err
+	}
+	return linesText(lines), nil
+}
+
+func (e cloudEngine) Words(img image.Image, config OCRConfig, opts PageOCROptions) ([]OCRWord, error) {
+	lines, err := e.lines(img, config)
+	if err != nil {
+		return nil, err
+	}
+	var words []OCRWord
+	for _, line := range lines {
+		words = append(words, line...)
+	}
+	return words, nil
+}
+
+// cloudEnv returns the first of the "|"-separated environment variables
+// that is set.
+func cloudEnv(names string) string {
+	for _, name := range strings.Split(names, "|") {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// cloudDo sends a request and returns the response body, or an error
+// quoting the start of the body for unsuccessful statuses. Requests that
+// cannot connect to the service fail with ErrCloudUnreachable; the
+// cancellation of the request context is returned as is.
+func cloudDo(req *http.Request) ([]byte, http.Header, error) {
+	resp, err := cloudClient.Do(req)
+	if err != nil {
+		if cerr := req.Context().Err(); cerr != nil {
+			return nil, nil, cerr
+		}
+		if cloudUnreachable(err) {
+			return nil, nil, fmt.Errorf("%w: %v", ErrCloudUnreachable, err)
+		}
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 300 {
+			msg = msg[:300] + "..."
+		}
+		return nil, nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+	}
+	return body, resp.Header, nil
+}
+
+// cloudUnreachable reports whether a request failed to connect: the name
+// of the service did not resolve, or dialing it failed or was refused,
+// reset or unroutable. Timeouts of a connected request are not, since the
+// service may have got the images.
+func cloudUnreachable(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	for _, errno := range []syscall.Errno{syscall.ECONNREFUSED, syscall.ECONNRESET, syscall.ENETUNREACH, syscall.EHOSTUNREACH} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
+
+// isoLanguages maps traineddata names to the ISO 639-1 codes the cloud
+// services take as language hints.
+var isoLanguages = map[string]string{
+	"eng": "en", "swa": "sw", "fra": "fr", "deu": "de", "spa": "es", "por": "pt", "ita": "it", "nld": "nl",
+	"pol": "pl", "ces": "cs", "tur": "tr", "swe": "sv", "dan": "da", "nor": "no", "fin": "fi", "hun": "hu",
+	"ron": "ro", "rus": "ru", "ukr": "uk", "bul": "bg", "ell": "el", "ara": "ar", "fas": "fa", "heb": "he",
+	"hin": "hi", "ben": "bn", "tha": "th", "vie": "vi", "ind": "id", "chi_sim": "zh", "chi_tra": "zh-Hant",
+	"jpn": "ja", "kor": "ko",
+}
+
+// languageHints returns the ISO codes of the languages of config.Language
+// that have one.
+func languageHints(language string) []string {
+	var hints []string
+	for _, l := range strings.Split(language, "+") {
+		if code, ok := isoLanguages[l]; ok {
+			hints = append(hints, code)
+		}
+	}
+	return hints
+}
+
+// boundingBox returns the rectangle around polygon points given as x, y
+// pairs.
+func boundingBox(points []float64) image.Rectangle {
+	if len(points) < 2 {
+		return image.Rectangle{}
+	}
+	minX, minY, maxX, maxY := points[0], points[1], points[0], points[1]
+	for i := 2; i+1 < len(points); i += 2 {
+		minX, maxX = min(minX, points[i]), max(maxX, points[i])
+		minY, maxY = min(minY, points[i+1]), max(maxY, points[i+1])
+	}
+	return image.Rect(int(minX), int(minY), int(maxX+0.5), int(maxY+0.5))
+}
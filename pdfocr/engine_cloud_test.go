@@ -0,0 +1,193 @@
+//go:build !nocloud
+
+package pdfocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCloudBatcher(t *testing.T) {
+	var mu sync.Mutex
+	var sizes []int
+	e := cloudEngine{
+		name:     "batch-test",
+		maxBatch: 3,
+		batcher:  newCloudBatcher(),
+		recognizeBatch: func(pages []*cloudPage, config OCRConfig) error {
+			mu.Lock()
+			sizes = append(sizes, len(pages))
+			mu.Unlock()
+			for _, p := range pages {
+				if string(p.img) == "bad" {
+					return errors.New("quota exceeded")
+				}
+				p.lines = [][]OCRWord{{{Text: string(p.img)}}}
+			}
+			return nil
+		},
+	}
+	recognize := func(images []string, config OCRConfig) []*cloudPage {
+		pages := make([]*cloudPage, len(images))
+		var wg sync.WaitGroup
+		for i, img := range images {
+			pages[i] = &cloudPage{img: []byte(img)}
+			wg.Add(1)
+			go func(p *cloudPage) {
+				defer wg.Done()
+				e.batcher.recognize(e, p, config)
+			}(pages[i])
+		}
+		wg.Wait()
+		return pages
+	}
+
+	config := OCRConfig{Language: "eng", CloudBatch: 5}
+	images := []string{"p1", "p2", "p3", "p4"}
+	for i, p := range recognize(images, config) {
+		if p.err != nil || len(p.lines) != 1 || p.lines[0][0].Text != images[i] {
+			t.Errorf("page %s: lines %v, error %v", images[i], p.lines, p.err)
+		}
+	}
+	// Batches are capped at the service's maximum; the last one is sent
+	// short after cloudBatchLinger
+	if fmt.Sprint(sizes) != "[3 1]" {
+		t.Errorf("batch sizes %v, want [3 1]", sizes)
+	}
+
+	sizes = nil
+	config.CloudBatch = 2
+	for _, p := range recognize([]string{"ok", "bad"}, config) {
+		if p.err == nil || p.err.Error() != "quota exceeded" {
+			t.Errorf("page %s of a failed batch: error %v", p.img, p.err)
+		}
+	}
+
+	sizes = nil
+	config.CloudBatch = 0
+	recognize([]string{"a", "b"}, config)
+	if fmt.Sprint(sizes) != "[1 1]" {
+		t.Errorf("without batching: batch sizes %v, want [1 1]", sizes)
+	}
+
+	// Requests stay under the size limit of the service: a page that would
+	// cross it starts the next batch, and a page over it goes alone
+	e.maxBatchBytes = 8 // two 4-byte images base64-encoded
+	sizes = nil
+	config.CloudBatch = 3
+	recognize([]string{"abc", "def", "ghi", "jkl"}, config)
+	if fmt.Sprint(sizes) != "[2 2]" {
+		t.Errorf("size-limited batch sizes %v, want [2 2]", sizes)
+	}
+	sizes = nil
+	started := time.Now()
+	recognize([]string{"oversized"}, config)
+	if fmt.Sprint(sizes) != "[1]" || time.Since(started) >= cloudBatchLinger {
+		t.Errorf("oversized page: batch sizes %v after %v, want [1] sent at once", sizes, time.Since(started))
+	}
+}
+
+func TestCloudUnreachable(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	req, _ := http.NewRequest(http.MethodGet, failing.URL, nil)
+	if _, _, err := cloudDo(req); err == nil || errors.Is(err, ErrCloudUnreachable) {
+		t.Errorf("HTTP 500: error %v, want one the service returned", err)
+	}
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	req, _ = http.NewRequest(http.MethodGet, down.URL, nil)
+	if _, _, err := cloudDo(req); !errors.Is(err, ErrCloudUnreachable) {
+		t.Errorf("closed server: error %v, want ErrCloudUnreachable", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := cloudDo(req.WithContext(ctx)); err != context.Canceled {
+		t.Errorf("canceled request: error %v, want context.Canceled", err)
+	}
+}
+
+func TestSyncQueue(t *testing.T) {
+	online := false
+	RegisterEngine(cloudEngine{
+		name:    "sync-test",
+		service: "sync test service",
+		recognize: func(img []byte, size image.Point, config OCRConfig) ([][]OCRWord, error) {
+			if !online {
+				return nil, fmt.Errorf("%w: connection refused", ErrCloudUnreachable)
+			}
+			return [][]OCRWord{{{Text: config.Language}, {Text: fmt.Sprint(size.X)}}}, nil
+		},
+	})
+	defer delete(engines, "sync-test")
+
+	dir := t.TempDir()
+	out := t.TempDir()
+	textOut, jsonOut := filepath.Join(out, "scan.txt"), filepath.Join(out, "scan.json")
+	os.WriteFile(textOut, []byte("--- Page 1 (OCR) ---\nlocal\n\n--- Page 2 ---\nnative\n\n"), 0644)
+	doc, _ := json.Marshal(jsonDocument{Pages: []PageResult{{Page: 1, Text: "native"}, {Page: 2, Method: MethodOCR, Text: "local", Provisional: true, QueueID: "run-p0002-b", Confidence: 80}}})
+	os.WriteFile(jsonOut, doc, 0644)
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 40, 20)))
+	for _, entry := range []QueuedPage{
+		{ID: "run-p0001-a", Page: 1, Method: MethodOCR, Output: textOut, OutputFormat: FormatText, ProvisionalText: "local"},
+		{ID: "run-p0002-b", Page: 2, Method: MethodOCR, Output: jsonOut, OutputFormat: FormatJSON, ProvisionalText: "local"},
+	} {
+		entry.Document, entry.Engine, entry.Language = "scan.pdf", "sync-test", "swa"
+		os.WriteFile(filepath.Join(dir, entry.ID+".png"), buf.Bytes(), 0644)
+		writeQueueEntry(filepath.Join(dir, entry.ID+".json"), entry)
+	}
+
+	synced, err := SyncQueue(context.Background(), dir, OCRConfig{})
+	if !errors.Is(err, ErrCloudUnreachable) || len(synced) != 0 {
+		t.Fatalf("offline: synced %v, error %v", synced, err)
+	}
+	online = true
+	synced, err = SyncQueue(context.Background(), dir, OCRConfig{})
+	if err != nil || len(synced) != 2 || synced[0].ID != "run-p0001-a" || synced[0].Text != "swa 40" || synced[0].Synced == nil {
+		t.Fatalf("online: synced %+v, error %v", synced, err)
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, "run-*"))
+	done, _ := filepath.Glob(filepath.Join(dir, queueDoneDir, "*.json"))
+	if len(left) != 0 || len(done) != 2 {
+		t.Errorf("queue holds %v and done %v, want nothing and both entries", left, done)
+	}
+
+	if data, _ := os.ReadFile(textOut); string(data) != "--- Page 1 (OCR) ---\nswa 40\n\n--- Page 2 ---\nnative\n\n" {
+		t.Errorf("text output = %q", data)
+	}
+	var merged jsonDocument
+	data, _ := os.ReadFile(jsonOut)
+	if err := json.Unmarshal(data, &merged); err != nil {
+		t.Fatal(err)
+	}
+	if p := merged.Pages[1]; p.Text != "swa 40" || p.Provisional || p.Confidence != 0 || merged.Pages[0].Text != "native" {
+		t.Errorf("JSON output pages = %+v", merged.Pages)
+	}
+}
+
+func TestOfflineQueueValidate(t *testing.T) {
+	t.Setenv("GOOGLE_VISION_API_KEY", "test")
+	config := OCRConfig{Language: "eng", DPI: defaultRenderDPI, Engine: "google-vision", OfflineQueue: t.TempDir()}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	config.EncryptKey = make([]byte, 32)
+	if err := config.Validate(); err == nil {
+		t.Error("offline queue accepted with an encryption key")
+	}
+}
@@ -0,0 +1,159 @@
+//go:build !nocloud
+
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// googleVisionURL is the images:annotate endpoint of the Cloud Vision API.
+const googleVisionURL = "https://vision.googleapis.com/v1/images:annotate"
+
+// googleVisionMaxBatch is the most images an annotate request may hold.
+const googleVisionMaxBatch = 16
+
+// googleVisionMaxBatchBytes is the most base64 image data a batch puts in
+// one request, leaving room for the JSON around it under the 10 MB limit
+// of the API.
+const googleVisionMaxBatchBytes = 9 << 20
+
+func init() {
+	RegisterEngine(cloudEngine{
+		name:           "google-vision",
+		service:        "Google Cloud Vision API v1",
+		env:            []string{"GOOGLE_VISION_API_KEY"},
+		recognizeBatch: googleVisionRecognize,
+		maxBatch:       googleVisionMaxBatch,
+		maxBatchBytes:  googleVisionMaxBatchBytes,
+		batcher:        newCloudBatcher(),
+	})
+}
+
+// googleVisionResponse holds the parts of an annotate response that are
+// read: for every image, the words of the dense text detection, with the
+// break after each symbol telling where lines end.
+type googleVisionResponse struct {
+	Responses []googleVisionAnnotation `json:"responses"`
+}
+
+// googleVisionAnnotation is the response for one image.
+type googleVisionAnnotation struct {
+	FullTextAnnotation struct {
+		Pages []struct {
+			Blocks []struct {
+				Paragraphs []struct {
+					Words []struct {
+						BoundingBox struct {
+							Vertices []struct {
+								X, Y float64
+							} `json:"vertices"`
+						} `json:"boundingBox"`
+						Symbols []struct {
+							Text     string `json:"text"`
+							Property struct {
+								DetectedBreak struct {
+									Type string `json:"type"`
+								} `json:"detectedBreak"`
+							} `json:"property"`
+						} `json:"symbols"`
+						Confidence float64 `json:"confidence"`
+					} `json:"words"`
+				} `json:"paragraphs"`
+			} `json:"blocks"`
+		} `json:"pages"`
+	} `json:"fullTextAnnotation"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// googleVisionRecognize runs DOCUMENT_TEXT_DETECTION on a batch of page
+// images in one request, authenticating with the API key in
+// GOOGLE_VISION_API_KEY.
+func googleVisionRecognize(pages []*cloudPage, config OCRConfig) error {
+	var imageContext map[string]any
+	if hints := languageHints(config.Language); len(hints) > 0 {
+		imageContext = map[string]any{"languageHints": hints}
+	}
+	requests := make([]any, len(pages))
+	for i, p := range pages {
+		request := map[string]any{
+			"image":    map[string]any{"content": p.img},
+			"features": []map[string]string{{"type": "DOCUMENT_TEXT_DETECTION"}},
+		}
+		if imageContext != nil {
+			request["imageContext"] = imageContext
+		}
+		requests[i] = request
+	}
+	body, err := json.Marshal(map[string]any{"requests": requests})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, googleVisionURL+"?key="+url.QueryEscape(cloudEnv("GOOGLE_VISION_API_KEY")), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	data, _, err := cloudDo(req)
+	if err != nil {
+		return err
+	}
+	var resp googleVisionResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+	// The responses are in the order of the requests; an image without
+	// text may have none
+	for i, p := range pages {
+		if i >= len(resp.Responses) {
+			break
+		}
+		if e := resp.Responses[i].Error; e != nil {
+			p.err = fmt.Errorf("%s", e.Message)
+			continue
+		}
+		p.lines = resp.Responses[i].lines()
+	}
+	return nil
+}
+
+// lines returns the words of an annotation grouped into lines.
+func (a googleVisionAnnotation) lines() [][]OCRWord {
+	var lines [][]OCRWord
+	var line []OCRWord
+	for _, page := range a.FullTextAnnotation.Pages {
+		for _, block := range page.Blocks {
+			for _, para := range block.Paragraphs {
+				for _, w := range para.Words {
+					word := OCRWord{Confidence: w.Confidence * 100}
+					var points []float64
+					for _, v := range w.BoundingBox.Vertices {
+						points = append(points, v.X, v.Y)
+					}
+					word.Box = boundingBox(points)
+					lineBreak := false
+					for _, s := range w.Symbols {
+						word.Text += s.Text
+						switch s.Property.DetectedBreak.Type {
+						case "EOL_SURE_SPACE", "LINE_BREAK":
+							lineBreak = true
+						}
+					}
+					line = append(line, word)
+					if lineBreak {
+						lines, line = append(lines, line), nil
+					}
+				}
+				if len(line) > 0 {
+					lines, line = append(lines, line), nil
+				}
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,205 @@
+//go:build !nogosseract
+
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/otiai10/gosseract/v2"
+)
+
+// DefaultEngine is the OCR engine used when OCRConfig.Engine is empty.
+const DefaultEngine = "tesseract"
+
+// gosseractEngine runs Tesseract in-process through gosseract (cgo).
+type gosseractEngine struct{}
+
+func init() {
+	RegisterEngine(gosseractEngine{})
+}
+
+func (gosseractEngine) Name() string { return "tesseract" }
+
+func (gosseractEngine) Version() string {
+	return "tesseract " + gosseract.Version()
+}
+
+// Languages lists the traineddata files in the default tessdata directory.
+func (gosseractEngine) Languages() ([]string, error) {
+	langs, err := gosseract.GetAvailableLanguages()
+	sort.Strings(langs)
+	return langs, err
+}
+
+// gosseractClients keeps the clients of finished recognitions for reuse,
+// by tessdata directory, language, page segmentation mode and Tesseract
+// variables: loading traineddata is the costly part of a client, done on
+// its first recognition only.
+var gosseractClients = struct {
+	sync.Mutex
+	idle map[string][]*gosseract.Client
+}{idle: map[string][]*gosseract.Client{}}
+
+// pageSegMode returns the page segmentation mode of a recognition and
+// whether one is set, for the Tesseract default otherwise.
+func pageSegMode(config OCRConfig, opts PageOCROptions) (gosseract.PageSegMode, bool) {
+	switch {
+	case opts.SingleBlock:
+		return gosseract.PSM_SINGLE_BLOCK, true
+	case opts.SingleLine:
+		return gosseract.PSM_SINGLE_LINE, true
+	case opts.SparseText:
+		return gosseract.PSM_SPARSE_TEXT, true
+	case config.PSM > 0:
+		return gosseract.PageSegMode(config.PSM), true
+	case config.PreserveLayout:
+		return gosseract.PSM_AUTO, true
+	}
+	return 0, false
+}
+
+// client prepares a Tesseract client for img, reusing an idle one with the
+// same settings when there is one. The image is handed over in memory, so
+// page images never reach the disk. The returned release function keeps
+// the client for reuse, up to config.TessClients idle clients per
+// settings (one per worker by default), or closes it after a failed
+// recognition.
+func (gosseractEngine) client(img image.Image, config OCRConfig, opts PageOCROptions) (*gosseract.Client, func(error), error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, nil, fmt.Errorf("error encoding image: %w", err)
+	}
+
+	mode, hasMode := pageSegMode(config, opts)
+	params := tessParamNames(config)
+	key := fmt.Sprintf("%s\x00%s\x00%d\x00%t", config.TessdataDir, config.Language, mode, hasMode)
+	for _, name := range params {
+		key += "\x00" + name + "=" + config.TessParams[name]
+	}
+	gosseractClients.Lock()
+	var client *gosseract.Client
+	if idle := gosseractClients.idle[key]; len(idle) > 0 {
+		client = idle[len(idle)-1]
+		gosseractClients.idle[key] = idle[:len(idle)-1]
+	}
+	gosseractClients.Unlock()
+
+	if client == nil {
+		client = gosseract.NewClient()
+		if config.TessdataDir != "" {
+			client.SetTessdataPrefix(config.TessdataDir)
+		}
+		client.SetLanguage(config.Language)
+		if hasMode {
+			client.SetPageSegMode(mode)
+		}
+		for _, name := range params {
+			client.SetVariable(gosseract.SettableVariable(name), config.TessParams[name])
+		}
+	}
+	release := func(err error) {
+		capacity := config.TessClients
+		if capacity <= 0 {
+			capacity = max(config.Workers, 1)
+		}
+		gosseractClients.Lock()
+		defer gosseractClients.Unlock()
+		if err != nil || len(gosseractClients.idle[key]) >= capacity {
+			client.Close()
+			return
+		}
+		gosseractClients.idle[key] = append(gosseractClients.idle[key], client)
+	}
+
+	if err := client.SetImageFromBytes(buf.Bytes()); err != nil {
+		client.Close()
+		return nil, nil, fmt.Errorf("error loading image: %w", err)
+	}
+	// The whitelist is a variable of the client, so a reused client would
+	// keep the previous one
+	whitelist := opts.Whitelist
+	if whitelist == "" {
+		whitelist = config.TessParams["tessedit_char_whitelist"]
+	}
+	client.SetWhitelist(whitelist)
+	return client, release, nil
+}
+
+// otherEngineMode reports whether config asks for an OCR engine mode other
+// than the traineddata's default. gosseract loads every client in that
+// mode, so such recognitions run the tesseract executable instead.
+func otherEngineMode(config OCRConfig) bool {
+	return config.OEM != "" && config.OEM != OEMDefault
+}
+
+func (e gosseractEngine) Text(img image.Image, config OCRConfig, opts PageOCROptions) (string, error) {
+	if otherEngineMode(config) {
+		return tesseractCLIEngine{}.Text(img, config, opts)
+	}
+	// Perform OCR using Tesseract
+	client, release, err := e.client(img, config, opts)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := client.Text()
+	release(err)
+	if err != nil {
+		return "", fmt.Errorf("error performing OCR: %w", err)
+	}
+
+	return text, nil
+}
+
+func (e gosseractEngine) HOCR(img image.Image, config OCRConfig, opts PageOCROptions) (string, error) {
+	if otherEngineMode(config) {
+		return tesseractCLIEngine{}.HOCR(img, config, opts)
+	}
+	client, release, err := e.client(img, config, opts)
+	if err != nil {
+		return "", err
+	}
+
+	hocr, err := client.HOCRText()
+	release(err)
+	if err != nil {
+		return "", fmt.Errorf("error performing OCR: %w", err)
+	}
+	return hocr, nil
+}
+
+// OSD runs the orientation and script detection of the tesseract
+// executable, which gosseract does not expose.
+func (gosseractEngine) OSD(img image.Image, config OCRConfig) (pageOSD, error) {
+	return tesseractCLIEngine{}.OSD(img, config)
+}
+
+func (e gosseractEngine) Words(img image.Image, config OCRConfig, opts PageOCROptions) ([]OCRWord, error) {
+	if otherEngineMode(config) {
+		return tesseractCLIEngine{}.Words(img, config, opts)
+	}
+	client, release, err := e.client(img, config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	boxes, err := client.GetBoundingBoxes(gosseract.RIL_WORD)
+	release(err)
+	if err != nil {
+		return nil, fmt.Errorf("error performing OCR: %w", err)
+	}
+	words := make([]OCRWord, 0, len(boxes))
+	for _, b := range boxes {
+		if strings.TrimSpace(b.Word) == "" {
+			continue
+		}
+		words = append(words, OCRWord{Text: b.Word, Box: b.Box, Confidence: b.Confidence})
+	}
+	return words, nil
+}
@@ -0,0 +1,8 @@
+//go:build nogosseract
+
+package pdfocr
+
+// DefaultEngine is the OCR engine used when OCRConfig.Engine is empty. Builds
+// tagged nogosseract do not link libtesseract and run the tesseract
+// executable instead.
+const DefaultEngine = "tesseract-cli"
@@ -0,0 +1,288 @@
+//go:build onnx
+
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// onnxEngine recognizes page images with ONNX Runtime the way PP-OCR does:
+// a detection model finds the text boxes of the page and a CTC recognition
+// model reads each one (see textBoxes and ctcDecode). The models are
+// OCRConfig.ONNXModels, fetched into the model cache when given by URL;
+// the ONNX Runtime shared library is loaded when the engine is first used.
+// Engine gpu runs them with CUDA, engine onnx on the CPU.
+type onnxEngine struct {
+	name string
+	gpu  bool // run the models with the CUDA execution provider
+}
+
+func init() {
+	RegisterEngine(onnxEngine{name: "gpu", gpu: true})
+	RegisterEngine(onnxEngine{name: "onnx"})
+}
+
+// onnxRuntime is the ONNX Runtime environment of the process and the
+// models loaded in it, by execution provider and path.
+var onnxRuntime struct {
+	once sync.Once
+	err  error
+
+	mu       sync.Mutex
+	sessions map[string]*onnxSession
+	charsets map[string][]string
+}
+
+// onnxRuntimeLib is the ONNX Runtime shared library to load: the one
+// ONNXRUNTIME_LIB names, or the platform's from the library path.
+func onnxRuntimeLib() string {
+	if lib := os.Getenv("ONNXRUNTIME_LIB"); lib != "" {
+		return lib
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return "onnxruntime.dll"
+	case "darwin":
+		return "libonnxruntime.dylib"
+	}
+	return "libonnxruntime.so"
+}
+
+// initONNXRuntime loads ONNX Runtime once per process.
+func initONNXRuntime() error {
+	onnxRuntime.once.Do(func() {
+		ort.SetSharedLibraryPath(onnxRuntimeLib())
+		if err := ort.InitializeEnvironment(); err != nil {
+			onnxRuntime.err = fmt.Errorf("could not load ONNX Runtime from %s (set ONNXRUNTIME_LIB to the library): %w", onnxRuntimeLib(), err)
+		}
+	})
+	return onnxRuntime.err
+}
+
+// onnxSession is a model loaded for an execution provider. ONNX Runtime
+// runs a session from several goroutines at once.
+type onnxSession struct {
+	session *ort.DynamicAdvancedSession
+	input   ort.InputOutputInfo
+}
+
+// loadONNXSession returns the model at path, loading it the first time.
+func loadONNXSession(path string, gpu bool) (*onnxSession, error) {
+	if err := initONNXRuntime(); err != nil {
+		return nil, err
+	}
+	key := "cpu:" + path
+	if gpu {
+		key = "cuda:" + path
+	}
+	onnxRuntime.mu.Lock()
+	defer onnxRuntime.mu.Unlock()
+	if s := onnxRuntime.sessions[key]; s != nil {
+		return s, nil
+	}
+	inputs, outputs, err := ort.GetInputOutputInfo(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading model %s: %w", path, err)
+	}
+	if len(inputs) != 1 || len(outputs) == 0 {
+		return nil, fmt.Errorf("model %s has %d inputs and %d outputs, want one input", path, len(inputs), len(outputs))
+	}
+	opts, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, err
+	}
+	defer opts.Destroy()
+	if gpu {
+		cuda, err := ort.NewCUDAProviderOptions()
+		if err == nil {
+			err = opts.AppendExecutionProviderCUDA(cuda)
+			cuda.Destroy()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error enabling CUDA for model %s: %w", path, err)
+		}
+	}
+	session, err := ort.NewDynamicAdvancedSession(path, []string{inputs[0].Name}, []string{outputs[0].Name}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error loading model %s: %w", path, err)
+	}
+	s := &onnxSession{session: session, input: inputs[0]}
+	if onnxRuntime.sessions == nil {
+		onnxRuntime.sessions = map[string]*onnxSession{}
+	}
+	onnxRuntime.sessions[key] = s
+	return s, nil
+}
+
+// run runs the model on a float32 tensor of shape and returns its first
+// output with the shape of that.
+func (s *onnxSession) run(shape []int64, data []float32) ([]float32, []int64, error) {
+	input, err := ort.NewTensor(ort.NewShape(shape...), data)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer input.Destroy()
+	outputs := []ort.Value{nil}
+	if err := s.session.Run([]ort.Value{input}, outputs); err != nil {
+		return nil, nil, err
+	}
+	defer outputs[0].Destroy()
+	out, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, nil, fmt.Errorf("model output is not a float32 tensor")
+	}
+	return slices.Clone(out.GetData()), slices.Clone([]int64(out.GetShape())), nil
+}
+
+// inputDim returns dimension i of the model input, or 0 when it is
+// dynamic.
+func (s *onnxSession) inputDim(i int) int {
+	if i >= len(s.input.Dimensions) || s.input.Dimensions[i] <= 0 {
+		return 0
+	}
+	return int(s.input.Dimensions[i])
+}
+
+// loadCharset returns the characters of a recognition model's classes,
+// one per line of the file at path.
+func loadCharset(path string) ([]string, error) {
+	onnxRuntime.mu.Lock()
+	defer onnxRuntime.mu.Unlock()
+	if c, ok := onnxRuntime.charsets[path]; ok {
+		return c, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading charset: %w", err)
+	}
+	charset := strings.Split(strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+	if onnxRuntime.charsets == nil {
+		onnxRuntime.charsets = map[string][]string{}
+	}
+	onnxRuntime.charsets[path] = charset
+	return charset, nil
+}
+
+func (e onnxEngine) Name() string { return e.name }
+
+func (e onnxEngine) Version() string {
+	if err := initONNXRuntime(); err != nil {
+		return "ONNX Runtime (library not found)"
+	}
+	if e.gpu {
+		return "ONNX Runtime " + ort.GetVersion() + " (CUDA)"
+	}
+	return "ONNX Runtime " + ort.GetVersion()
+}
+
+// Check loads ONNX Runtime before the first page.
+func (e onnxEngine) Check() error {
+	if err := initONNXRuntime(); err != nil {
+		return fmt.Errorf("OCR engine %s: %w", e.name, err)
+	}
+	return nil
+}
+
+// CheckModels verifies that the models of config are set and readable,
+// fetching those given by URL into the model cache before the first page.
+func (e onnxEngine) CheckModels(config OCRConfig) error {
+	m := config.ONNXModels
+	if m.Detector == "" || m.Recognizer == "" || m.Charset == "" {
+		return fmt.Errorf("OCR engine %s needs -onnx-models <detector.onnx>,<recognizer.onnx>,<charset.txt>", e.name)
+	}
+	for _, model := range []string{m.Detector, m.Recognizer, m.Charset} {
+		path, err := FetchModel(model, config)
+		if err == nil {
+			_, err = os.Stat(path)
+		}
+		if err != nil {
+			return fmt.Errorf("OCR engine %s: %w", e.name, err)
+		}
+	}
+	return nil
+}
+
+func (e onnxEngine) Text(img image.Image, config OCRConfig, opts PageOCROptions) (string, error) {
+	lines, err := e.lines(img, config)
+	if err != nil {
+		return "", err
+	}
+	return linesText(lines), nil
+}
+
+func (e onnxEngine) Words(img image.Image, config OCRConfig, opts PageOCROptions) ([]OCRWord, error) {
+	lines, err := e.lines(img, config)
+	if err != nil {
+		return nil, err
+	}
+	var words []OCRWord
+	for _, line := range lines {
+		words = append(words, line...)
+	}
+	return words, nil
+}
+
+// lines detects the text boxes of img and recognizes each, returning the
+// words grouped into lines.
+func (e onnxEngine) lines(img image.Image, config OCRConfig) ([][]OCRWord, error) {
+	m := config.ONNXModels
+	var paths [3]string
+	for i, model := range []string{m.Detector, m.Recognizer, m.Charset} {
+		path, err := FetchModel(model, config)
+		if err != nil {
+			return nil, err
+		}
+		paths[i] = path
+	}
+	det, err := loadONNXSession(paths[0], e.gpu)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := loadONNXSession(paths[1], e.gpu)
+	if err != nil {
+		return nil, err
+	}
+	charset, err := loadCharset(paths[2])
+	if err != nil {
+		return nil, err
+	}
+
+	gray := toGray(img)
+	data, w, h := detectorInput(gray)
+	probs, shape, err := det.run([]int64{1, 3, int64(h), int64(w)}, data)
+	if err != nil {
+		return nil, fmt.Errorf("error detecting text: %w", err)
+	}
+	if len(shape) < 2 || int(shape[len(shape)-1]) != w || int(shape[len(shape)-2]) != h {
+		return nil, fmt.Errorf("detection model returned a %v map for a %dx%d image", shape, w, h)
+	}
+	boxes := textBoxes(probs, w, h, gray.Rect.Size())
+
+	height := rec.inputDim(2)
+	if height == 0 {
+		height = onnxLineHeight
+	}
+	var words []OCRWord
+	for _, box := range boxes {
+		data, width := recognizerInput(gray, box, height, rec.inputDim(3))
+		probs, shape, err := rec.run([]int64{1, 3, int64(height), int64(width)}, data)
+		if err != nil {
+			return nil, fmt.Errorf("error recognizing text: %w", err)
+		}
+		if len(shape) != 3 {
+			return nil, fmt.Errorf("recognition model returned a %v tensor, want steps by classes", shape)
+		}
+		steps, classes := int(shape[1]), int(shape[2])
+		chars := ctcDecode(probs, steps, classes, ctcCharset(charset, classes))
+		words = append(words, lineWords(chars, box, steps)...)
+	}
+	return groupLines(words), nil
+}
@@ -0,0 +1,99 @@
+//go:build onnx
+
+package pdfocr
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestTextBoxes(t *testing.T) {
+	// Two confident regions of a 20x10 map and a faint one, for a 40x20 page
+	w, h := 20, 10
+	probs := make([]float32, w*h)
+	fill := func(r image.Rectangle, p float32) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				probs[y*w+x] = p
+			}
+		}
+	}
+	fill(image.Rect(2, 6, 8, 9), 0.9)
+	fill(image.Rect(10, 1, 18, 4), 0.8)
+	fill(image.Rect(2, 1, 6, 4), 0.4)
+	boxes := textBoxes(probs, w, h, image.Pt(40, 20))
+	if len(boxes) != 2 {
+		t.Fatalf("got boxes %v, want 2", boxes)
+	}
+	if !boxes[0].In(image.Rect(0, 0, 40, 20)) || boxes[0].Min.Y > boxes[1].Min.Y {
+		t.Errorf("boxes %v not within the page top to bottom", boxes)
+	}
+	if core := image.Rect(20, 2, 36, 8); !core.In(boxes[0]) || boxes[0] == core {
+		t.Errorf("first box %v does not grow %v", boxes[0], core)
+	}
+}
+
+func TestCTCDecode(t *testing.T) {
+	charset := ctcCharset([]string{"a", "b"}, 4)
+	if !reflect.DeepEqual(charset, []string{"a", "b", " "}) {
+		t.Fatalf("charset %q", charset)
+	}
+	// Steps: a a blank a space b, over classes blank, a, b, space
+	probs := []float32{
+		0.1, 0.8, 0.05, 0.05,
+		0.1, 0.7, 0.1, 0.1,
+		0.9, 0.05, 0.05, 0,
+		0.2, 0.6, 0.1, 0.1,
+		0, 0, 0.1, 0.9,
+		0, 0.1, 0.5, 0.4,
+	}
+	chars := ctcDecode(probs, 6, 4, charset)
+	var text string
+	for _, c := range chars {
+		text += c.text
+	}
+	if text != "aa b" {
+		t.Fatalf("decoded %q, want %q", text, "aa b")
+	}
+
+	words := lineWords(chars, image.Rect(100, 50, 160, 70), 6)
+	if len(words) != 2 || words[0].Text != "aa" || words[1].Text != "b" {
+		t.Fatalf("words %+v", words)
+	}
+	if words[0].Box != image.Rect(100, 50, 140, 70) || words[1].Box != image.Rect(150, 50, 160, 70) {
+		t.Errorf("boxes %v and %v", words[0].Box, words[1].Box)
+	}
+	if c := words[0].Confidence; c < 69 || c > 71 {
+		t.Errorf("confidence %v, want 70", c)
+	}
+}
+
+func TestGroupLines(t *testing.T) {
+	words := []OCRWord{
+		{Text: "world", Box: image.Rect(60, 12, 100, 30)},
+		{Text: "next", Box: image.Rect(0, 40, 40, 60)},
+		{Text: "hello", Box: image.Rect(0, 10, 50, 30)},
+	}
+	if got, want := linesText(groupLines(words)), "hello world\nnext"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRecognizerInput(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 40, 20))
+	for i := range gray.Pix {
+		gray.Pix[i] = 255
+	}
+	data, width := recognizerInput(gray, image.Rect(0, 0, 40, 10), 24, 0)
+	if width != 96 || len(data) != 3*24*96 {
+		t.Fatalf("width %d, %d values", width, len(data))
+	}
+	if data[0] != 1 {
+		t.Errorf("white is %v, want 1", data[0])
+	}
+	data, width = recognizerInput(gray, image.Rect(0, 0, 40, 10), 24, 320)
+	if width != 320 || data[24*320-1] != 0 {
+		t.Errorf("width %d, padding %v", width, data[24*320-1])
+	}
+}
@@ -0,0 +1,172 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// tesseractCLIEngine runs the tesseract executable as a subprocess, feeding
+// the page image on stdin. It needs no cgo and no libtesseract at build time.
+type tesseractCLIEngine struct{}
+
+func init() {
+	RegisterEngine(tesseractCLIEngine{})
+}
+
+func (tesseractCLIEngine) Name() string { return "tesseract-cli" }
+
+func (tesseractCLIEngine) Version() string {
+	out, err := exec.Command("tesseract", "--version").CombinedOutput()
+	if err != nil && len(out) == 0 {
+		return "tesseract (executable not found)"
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(line)
+}
+
+// Languages parses the output of "tesseract --list-langs".
+func (tesseractCLIEngine) Languages() ([]string, error) {
+	out, err := exec.Command("tesseract", "--list-langs").Output()
+	if err != nil {
+		return nil, fmt.Errorf("tesseract --list-langs failed: %w", err)
+	}
+	var langs []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "List of available languages") {
+			continue
+		}
+		langs = append(langs, line)
+	}
+	sort.Strings(langs)
+	return langs, nil
+}
+
+// tesseractArgs builds the command line for one recognition run. The
+// segmentation modes and whitelist of opts, which the pipeline picks for
+// regions and drawings, take precedence over those of config.
+func tesseractArgs(config OCRConfig, opts PageOCROptions) []string {
+	args := []string{"stdin", "stdout"}
+	if config.TessdataDir != "" {
+		args = append(args, "--tessdata-dir", config.TessdataDir)
+	}
+	if config.Language != "" {
+		args = append(args, "-l", config.Language)
+	}
+	switch {
+	case opts.SparseText:
+		args = append(args, "--psm", "11")
+	case opts.SingleLine:
+		args = append(args, "--psm", "7")
+	case opts.SingleBlock:
+		args = append(args, "--psm", "6")
+	case config.PSM > 0:
+		args = append(args, "--psm", strconv.Itoa(config.PSM))
+	case config.PreserveLayout:
+		args = append(args, "--psm", "3")
+	}
+	if n, ok := oemNumbers[config.OEM]; ok {
+		args = append(args, "--oem", strconv.Itoa(n))
+	}
+	for _, name := range tessParamNames(config) {
+		args = append(args, "-c", name+"="+config.TessParams[name])
+	}
+	// Set last, as the later -c wins
+	if opts.Whitelist != "" {
+		args = append(args, "-c", "tessedit_char_whitelist="+opts.Whitelist)
+	}
+	return args
+}
+
+// run feeds img to tesseract and returns its standard output.
+func (tesseractCLIEngine) run(img image.Image, args []string) (string, error) {
+	var input bytes.Buffer
+	if err := png.Encode(&input, img); err != nil {
+		return "", fmt.Errorf("error encoding image: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("tesseract", args...)
+	cmd.Stdin = &input
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("error performing OCR: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func (e tesseractCLIEngine) Text(img image.Image, config OCRConfig, opts PageOCROptions) (string, error) {
+	out, err := e.run(img, tesseractArgs(config, opts))
+	if err != nil {
+		return "", err
+	}
+	return strings.Trim(out, "\n\f"), nil
+}
+
+// Words runs tesseract with its "tsv" output config and keeps the word-level
+// rows (level 5).
+func (e tesseractCLIEngine) Words(img image.Image, config OCRConfig, opts PageOCROptions) ([]OCRWord, error) {
+	out, err := e.run(img, append(tesseractArgs(config, opts), "tsv"))
+	if err != nil {
+		return nil, err
+	}
+	return parseTesseractTSV(out), nil
+}
+
+// HOCR runs tesseract with its "hocr" output config.
+func (e tesseractCLIEngine) HOCR(img image.Image, config OCRConfig, opts PageOCROptions) (string, error) {
+	return e.run(img, append(tesseractArgs(config, opts), "hocr"))
+}
+
+// OSD runs tesseract's orientation and script detection (--psm 0), which
+// needs osd.traineddata. Pages with too little text to judge are reported
+// upright, in no script.
+func (e tesseractCLIEngine) OSD(img image.Image, config OCRConfig) (pageOSD, error) {
+	args := []string{"stdin", "stdout", "--psm", "0", "-l", "osd"}
+	if config.TessdataDir != "" {
+		args = append(args, "--tessdata-dir", config.TessdataDir)
+	}
+	out, err := e.run(img, args)
+	if err != nil {
+		if strings.Contains(err.Error(), "Too few characters") {
+			return pageOSD{}, nil
+		}
+		return pageOSD{}, err
+	}
+	return parseTesseractOSD(out)
+}
+
+// parseTesseractTSV extracts the words from tesseract TSV output, whose
+// columns are level, page_num, block_num, par_num, line_num, word_num, left,
+// top, width, height, conf and text.
+func parseTesseractTSV(tsv string) []OCRWord {
+	var words []OCRWord
+	for _, line := range strings.Split(tsv, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) < 12 || fields[0] != "5" {
+			continue
+		}
+		text := strings.TrimSpace(fields[11])
+		if text == "" {
+			continue
+		}
+		var n [4]int
+		for i := range n {
+			n[i], _ = strconv.Atoi(fields[6+i])
+		}
+		conf, _ := strconv.ParseFloat(fields[10], 64)
+		words = append(words, OCRWord{
+			Text:       text,
+			Box:        image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]),
+			Confidence: conf,
+		})
+	}
+	return words
+}
@@ -0,0 +1,152 @@
+//go:build !nocloud
+
+package pdfocr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterEngine(cloudEngine{
+		name:      "aws-textract",
+		service:   "Amazon Textract DetectDocumentText",
+		env:       []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_REGION|AWS_DEFAULT_REGION"},
+		recognize: textractRecognize,
+	})
+}
+
+// textractBlock is a block of a DetectDocumentText response. LINE blocks
+// list their WORD blocks as children; boxes are fractions of the image.
+type textractBlock struct {
+	BlockType  string  `json:"BlockType"`
+	ID         string  `json:"Id"`
+	Text       string  `json:"Text"`
+	Confidence float64 `json:"Confidence"`
+	Geometry   struct {
+		BoundingBox struct {
+			Width, Height, Left, Top float64
+		} `json:"BoundingBox"`
+	} `json:"Geometry"`
+	Relationships []struct {
+		Type string   `json:"Type"`
+		IDs  []string `json:"Ids"`
+	} `json:"Relationships"`
+}
+
+// textractRecognize runs DetectDocumentText on a page image with the AWS
+// credentials and region of the environment.
+func textractRecognize(img []byte, size image.Point, config OCRConfig) ([][]OCRWord, error) {
+	region := cloudEnv("AWS_REGION|AWS_DEFAULT_REGION")
+	body, err := json.Marshal(map[string]any{"Document": map[string]any{"Bytes": img}})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, "https://textract."+region+".amazonaws.com/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Textract.DetectDocumentText")
+	signAWSRequest(req, body, "textract", region, time.Now())
+	data, _, err := cloudDo(req)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Blocks []textractBlock `json:"Blocks"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+
+	blocks := map[string]textractBlock{}
+	for _, b := range resp.Blocks {
+		blocks[b.ID] = b
+	}
+	var lines [][]OCRWord
+	for _, b := range resp.Blocks {
+		if b.BlockType != "LINE" {
+			continue
+		}
+		var line []OCRWord
+		for _, rel := range b.Relationships {
+			if rel.Type != "CHILD" {
+				continue
+			}
+			for _, id := range rel.IDs {
+				w, ok := blocks[id]
+				if !ok || w.BlockType != "WORD" {
+					continue
+				}
+				bb := w.Geometry.BoundingBox
+				x0, y0 := bb.Left*float64(size.X), bb.Top*float64(size.Y)
+				x1, y1 := x0+bb.Width*float64(size.X), y0+bb.Height*float64(size.Y)
+				line = append(line, OCRWord{Text: w.Text, Box: boundingBox([]float64{x0, y0, x1, y1}), Confidence: w.Confidence})
+			}
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// signAWSRequest adds the Signature Version 4 authorization of the
+// credentials in the environment to req, whose body is body.
+func signAWSRequest(req *http.Request, body []byte, service, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if token := cloudEnv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, sha256Hex(body)}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cloudEnv("AWS_ACCESS_KEY_ID"), scope, signedHeaders, hex.EncodeToString(hmacSHA256(awsSigningKey(date, region, service), toSign))))
+}
+
+// awsSigningKey derives the Signature Version 4 key of a day, region and
+// service from the secret key in the environment.
+func awsSigningKey(date, region, service string) []byte {
+	key := []byte("AWS4" + cloudEnv("AWS_SECRET_ACCESS_KEY"))
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return key
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,199 @@
+package pdfocr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultMaxRegression is the rise in character error rate over the
+// baseline the eval command tolerates, one character in a hundred.
+const DefaultMaxRegression = 0.01
+
+// EvalDocument is a document of the eval corpus and its ground truth.
+type EvalDocument struct {
+	Name  string
+	Lang  string
+	Truth string
+	data  []byte
+}
+
+// EvalResult is the accuracy of one engine in one language, over the
+// documents of the corpus in that language.
+type EvalResult struct {
+	Key       string // "engine/language", as in Calibration
+	Documents int
+	Chars     int // ground truth characters
+	Edits     int // edit distance of the recognized text to the ground truth
+	Err       error
+}
+
+// CER returns the character error rate: edits per ground truth character.
+func (r EvalResult) CER() float64 {
+	if r.Chars == 0 {
+		return 0
+	}
+	return float64(r.Edits) / float64(r.Chars)
+}
+
+// normalizeEvalText collapses runs of whitespace, which are layout rather
+// than recognition, into single spaces.
+func normalizeEvalText(s string) []rune {
+	return []rune(strings.Join(strings.Fields(s), " "))
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// scoreEvalText returns the ground truth characters of truth and the edits
+// turning them into the pages recognized. A ground truth with one form
+// feed separated section per page is compared page by page, which keeps
+// long documents fast; any other is compared with the whole text.
+func scoreEvalText(truth string, pages []string) (chars, edits int) {
+	sections := strings.Split(strings.TrimRight(truth, "\f\n"), "\f")
+	if len(sections) != len(pages) {
+		sections, pages = []string{truth}, []string{strings.Join(pages, "\n")}
+	}
+	for i, section := range sections {
+		want := normalizeEvalText(section)
+		chars += len(want)
+		edits += editDistance(want, normalizeEvalText(pages[i]))
+	}
+	return chars, edits
+}
+
+// LoadEvalCorpus reads the documents of dir, one subdirectory per
+// language (e.g. eng/invoice.pdf with its ground truth in
+// eng/invoice.txt), along with the built-in sample scan in English.
+// Only the languages in langs are read, unless langs is empty.
+func LoadEvalCorpus(dir string, langs []string) ([]EvalDocument, error) {
+	wanted := func(lang string) bool {
+		if len(langs) == 0 {
+			return true
+		}
+		for _, l := range langs {
+			if l == lang {
+				return true
+			}
+		}
+		return false
+	}
+
+	var docs []EvalDocument
+	if wanted("eng") {
+		docs = append(docs, EvalDocument{
+			Name:  "sample-scanned.pdf",
+			Lang:  "eng",
+			Truth: strings.Join(demoSampleLines, "\n"),
+			data:  demoPDF,
+		})
+	}
+	if dir == "" {
+		return docs, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the corpus: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !wanted(entry.Name()) {
+			continue
+		}
+		paths, _ := filepath.Glob(filepath.Join(dir, entry.Name(), "*.pdf"))
+		for _, path := range paths {
+			truth, err := os.ReadFile(strings.TrimSuffix(path, filepath.Ext(path)) + ".txt")
+			if err != nil {
+				return nil, fmt.Errorf("ground truth for %s: %w", path, err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("error reading PDF: %w", err)
+			}
+			docs = append(docs, EvalDocument{
+				Name:  filepath.Join(entry.Name(), filepath.Base(path)),
+				Lang:  entry.Name(),
+				Truth: string(truth),
+				data:  data,
+			})
+		}
+	}
+	return docs, nil
+}
+
+// EvalEngine runs the documents of one language through the regular
+// pipeline with config and scores the text of their pages.
+func EvalEngine(config OCRConfig, docs []EvalDocument) EvalResult {
+	result := EvalResult{Key: config.Engine + "/" + config.Language}
+	for _, doc := range docs {
+		_, manifest, err := extractPDFData(context.Background(), doc.data, doc.Name, config, 0)
+		if err != nil {
+			result.Err = fmt.Errorf("%s: %w", doc.Name, err)
+			return result
+		}
+		pages := make([]string, len(manifest.PageResults))
+		for i, p := range manifest.PageResults {
+			pages[i] = p.Text
+		}
+		chars, edits := scoreEvalText(doc.Truth, pages)
+		result.Documents++
+		result.Chars += chars
+		result.Edits += edits
+	}
+	return result
+}
+
+// EvalBaseline holds the character error rate of every "engine/language"
+// key of a previous eval run.
+type EvalBaseline map[string]float64
+
+// LoadEvalBaseline reads a baseline file written by the eval command; a
+// missing file is an empty baseline.
+func LoadEvalBaseline(path string) (EvalBaseline, error) {
+	baseline := EvalBaseline{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return baseline, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("error reading baseline %s: %w", path, err)
+	}
+	return baseline, nil
+}
+
+// EvalRegressions lists the results whose character error rate is more
+// than maxRegression above their baseline. Keys without a baseline pass.
+func EvalRegressions(results []EvalResult, baseline EvalBaseline, maxRegression float64) []string {
+	var regressions []string
+	for _, r := range results {
+		base, ok := baseline[r.Key]
+		if r.Err != nil || !ok || r.CER() <= base+maxRegression {
+			continue
+		}
+		regressions = append(regressions, fmt.Sprintf("%s: CER %.2f%%, baseline %.2f%%", r.Key, r.CER()*100, base*100))
+	}
+	return regressions
+}
@@ -0,0 +1,34 @@
+package pdfocr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEvalScoring(t *testing.T) {
+	if d := editDistance([]rune("Total 1250.00"), []rune("Tota1 1250,0")); d != 3 {
+		t.Errorf("editDistance = %d, want 3", d)
+	}
+	// Whitespace is layout, and form feeds split the truth into pages
+	chars, edits := scoreEvalText("Invoice  2024\n\fTotal 1250.00\n", []string{"Invoice 2024", "Tota1\n1250.00"})
+	if chars != 25 || edits != 1 {
+		t.Errorf("scoreEvalText = %d chars, %d edits, want 25, 1", chars, edits)
+	}
+	// A truth whose pages do not match is compared with the whole text
+	if chars, edits := scoreEvalText("one two three", []string{"one", "two three"}); chars != 13 || edits != 0 {
+		t.Errorf("scoreEvalText without page breaks = %d chars, %d edits, want 13, 0", chars, edits)
+	}
+
+	results := []EvalResult{
+		{Key: "tesseract/eng", Chars: 1000, Edits: 30},
+		{Key: "tesseract/deu", Chars: 1000, Edits: 50},
+		{Key: "tesseract-cli/eng", Chars: 1000, Edits: 90},
+		{Key: "google-vision/eng", Err: errors.New("no credentials")},
+	}
+	baseline := EvalBaseline{"tesseract/eng": 0.025, "tesseract/deu": 0.02, "google-vision/eng": 0.01}
+	got := EvalRegressions(results, baseline, DefaultMaxRegression)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "tesseract/deu:") {
+		t.Errorf("EvalRegressions = %q, want tesseract/deu only", got)
+	}
+}
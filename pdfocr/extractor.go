@@ -0,0 +1,188 @@
+package pdfocr
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultConfig returns the settings the command line starts from: English
+// OCR at defaultRenderDPI with the build's default renderer and engine.
+func DefaultConfig() OCRConfig {
+	return OCRConfig{Language: "eng", DPI: defaultRenderDPI}
+}
+
+// Validate checks the settings that name encodings, locales, backends and
+// modes, and the combinations that need other settings.
+func (config OCRConfig) Validate() error {
+	if _, err := normalizeEncoding(config.Encoding); err != nil {
+		return err
+	}
+	if err := validateNewline(config.Newline); err != nil {
+		return err
+	}
+	if err := validateVectorPages(config.VectorPages); err != nil {
+		return err
+	}
+	if err := validateOCRMode(config.OCRMode); err != nil {
+		return err
+	}
+	if err := validateTessSettings(config); err != nil {
+		return err
+	}
+	if err := validatePreprocess(config.Preprocess); err != nil {
+		return err
+	}
+	if err := validateAttachments(config.Attachments); err != nil {
+		return err
+	}
+	if err := validateErrorPolicy(config.ErrorPolicy); err != nil {
+		return err
+	}
+	if err := validatePreset(config.Preset); err != nil {
+		return err
+	}
+	if config.NormalizeLocale != "" {
+		if _, err := lookupNumberLocale(config.NormalizeLocale); err != nil {
+			return err
+		}
+	}
+	if _, err := lookupRenderer(config.Renderer); err != nil {
+		return err
+	}
+	engine, err := lookupEngine(config.Engine)
+	if err != nil {
+		return err
+	}
+	if _, ok := engine.(remoteEngine); config.CloudBatch != 0 && !ok {
+		return fmt.Errorf("OCR engine %s is not a cloud service and takes no batches", engine.Name())
+	}
+	if _, ok := engine.(remoteEngine); config.OfflineQueue != "" && !ok {
+		return fmt.Errorf("OCR engine %s is not a cloud service and needs no offline queue", engine.Name())
+	}
+	if config.OfflineQueue != "" && config.NoDisk {
+		return fmt.Errorf("the offline queue is kept on disk and cannot be used with -no-disk")
+	}
+	if config.OfflineQueue != "" && config.EncryptKey != nil {
+		return fmt.Errorf("the offline queue keeps page images in plaintext and cannot be used with -encrypt-key")
+	}
+	if config.CloudBatch < 0 {
+		return fmt.Errorf("invalid cloud batch size %d", config.CloudBatch)
+	}
+	if c, ok := engine.(checkedEngine); ok {
+		if err := c.Check(); err != nil {
+			return err
+		}
+	}
+	if m, ok := engine.(modelEngine); ok {
+		if err := m.CheckModels(config); err != nil {
+			return err
+		}
+	} else if config.ONNXModels != (ONNXModels{}) {
+		return fmt.Errorf("OCR engine %s takes no -onnx-models", engine.Name())
+	}
+	if _, ok := engine.(hocrEngine); config.HOCR && !ok {
+		return fmt.Errorf("OCR engine %s does not produce hOCR", engine.Name())
+	}
+	if _, ok := engine.(osdEngine); config.AutoRotate && !ok {
+		return fmt.Errorf("OCR engine %s does not detect page orientation", engine.Name())
+	}
+	if err := ValidateMinConfidence(config.MinConfidence); err != nil {
+		return err
+	}
+	if _, ok := engine.(wordEngine); config.MinConfidence > 0 && !ok {
+		return fmt.Errorf("OCR engine %s does not report word confidences", engine.Name())
+	}
+	if _, ok := engine.(wordEngine); config.TwoPass && !ok {
+		return fmt.Errorf("OCR engine %s does not report word positions for two-pass recognition", engine.Name())
+	}
+	if len(config.Keywords) > 0 && (config.Recollate || config.DetectDocuments) {
+		return fmt.Errorf("keyword spotting stops at the first match and cannot recollate or detect documents")
+	}
+	if len(config.OnlyRegions) > 0 && !config.TwoPass {
+		return fmt.Errorf("keeping only some regions needs two-pass recognition to find them")
+	}
+	if _, err := ParseRegionKinds(strings.Join(config.OnlyRegions, ",")); err != nil {
+		return err
+	}
+	if config.TwoPass && config.Best {
+		return fmt.Errorf("two-pass recognition and best mode both choose the recognition settings and cannot be combined")
+	}
+	if _, ok := engine.(wordEngine); config.Tables && !ok {
+		return fmt.Errorf("OCR engine %s does not report word positions", engine.Name())
+	}
+	if err := validateLanguages(config, engine); err != nil {
+		return err
+	}
+	for _, path := range []string{config.OutputFile, config.ManifestFile} {
+		if err := CheckCompressor(path); err != nil {
+			return err
+		}
+	}
+	if config.Workers < 0 {
+		return fmt.Errorf("invalid worker count %d", config.Workers)
+	}
+	if config.FlushEvery > 0 && config.OutputFile == "" {
+		return fmt.Errorf("-flush-every requires -o")
+	}
+	return nil
+}
+
+// Extractor extracts the text and images of PDF documents with fixed
+// settings, for programs that embed the tool. Every call processes its
+// document independently, so an Extractor can be shared between goroutines
+// as long as the settings name no output files.
+type Extractor struct {
+	config OCRConfig
+}
+
+// NewExtractor validates config and returns an Extractor using it.
+func NewExtractor(config OCRConfig) (*Extractor, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return &Extractor{config: config}, nil
+}
+
+// Config returns the settings of the Extractor.
+func (e *Extractor) Config() OCRConfig {
+	return e.config
+}
+
+// ExtractText returns the text of the PDF file at pdfPath. Canceling ctx
+// stops it as described for ExtractTextFromPDF.
+func (e *Extractor) ExtractText(ctx context.Context, pdfPath string) (string, error) {
+	return ExtractTextFromPDF(ctx, pdfPath, e.config)
+}
+
+// ExtractDocument returns the text of the PDF file at pdfPath and its
+// manifest.
+func (e *Extractor) ExtractDocument(ctx context.Context, pdfPath string) (string, DocumentManifest, error) {
+	return ExtractDocument(ctx, pdfPath, e.config)
+}
+
+// ExtractData is ExtractDocument for a PDF held in memory; name is used in
+// messages and the manifest.
+func (e *Extractor) ExtractData(ctx context.Context, data []byte, name string) (string, DocumentManifest, error) {
+	return extractPDFData(ctx, data, filepath.Base(name), e.config, 0)
+}
+
+// ExtractWithReport returns the text of the PDF file at pdfPath and the
+// report of its failed pages.
+func (e *Extractor) ExtractWithReport(ctx context.Context, pdfPath string) (string, ExtractionReport, error) {
+	return ExtractWithReport(ctx, pdfPath, e.config)
+}
+
+// ExtractTo writes the text of the PDF file at pdfPath to w page by page,
+// as the ExtractTo function does, and returns its manifest.
+func (e *Extractor) ExtractTo(ctx context.Context, w io.Writer, pdfPath string) (DocumentManifest, error) {
+	return ExtractTo(ctx, w, pdfPath, e.config)
+}
+
+// ExtractImages writes the images of the PDF file at pdfPath to
+// outputDir, as ExtractImagesFromPDF does.
+func (e *Extractor) ExtractImages(pdfPath, outputDir string) error {
+	return ExtractImagesFromPDF(pdfPath, outputDir, e.config)
+}
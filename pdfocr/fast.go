@@ -0,0 +1,83 @@
+package pdfocr
+
+import "sync"
+
+const (
+	// fastDPI is the render resolution of fast runs, enough for body text
+	// at triage quality.
+	fastDPI = 150
+
+	// duplicateWindow is how many recent pages a page is compared with to
+	// skip it as a duplicate, and duplicateMatch the fingerprint similarity
+	// of a scanned duplicate.
+	duplicateWindow = 32
+	duplicateMatch  = 0.99
+)
+
+// ApplyFast configures a throughput-oriented run: 150 DPI (unless keepDPI),
+// the fast models when they are installed, no preprocessing, and skipping
+// OCR of blank and duplicate pages.
+func ApplyFast(config *OCRConfig, keepDPI bool) {
+	if !keepDPI {
+		config.DPI = fastDPI
+	}
+	config.Preprocess = PreprocessNone
+	config.Fast = true
+	if config.TessdataDir == "" {
+		if dir := findFastTessdata(config.Language); dir != "" {
+			config.TessdataDir = dir
+		} else {
+			config.logf("Fast mode: no tessdata_fast models for %s found, using the default models\n", config.Language)
+		}
+	}
+	config.logf("Fast mode: %g DPI, no preprocessing, blank and duplicate pages skipped\n", config.DPI)
+}
+
+// pageDeduper remembers the fingerprints of the recent pages of a document,
+// shared by its workers.
+type pageDeduper struct {
+	mu     sync.Mutex
+	recent []dedupedPage
+}
+
+type dedupedPage struct {
+	page int
+	fp   pageFingerprint
+}
+
+// duplicateOf returns the earlier page a page looks identical to, or 0 after
+// remembering the page.
+func (d *pageDeduper) duplicateOf(page int, fp pageFingerprint) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range d.recent {
+		if fp.similarity(r.fp) >= duplicateMatch {
+			return r.page
+		}
+	}
+	d.recent = append(d.recent, dedupedPage{page, fp})
+	if len(d.recent) > duplicateWindow {
+		d.recent = d.recent[1:]
+	}
+	return 0
+}
+
+// fastSkip checks a page about to be OCR'd in a fast run, rendered at
+// fingerprintDPI, and returns the method of a skipped page (MethodSkippedBlank
+// or MethodSkippedDuplicate, with the page duplicated) or "".
+func (src *pdfSource) fastSkip(pageNum int, config OCRConfig) (string, int) {
+	img, err := src.renderPage(pageNum, fingerprintDPI, config.RobustDecode)
+	if err != nil {
+		return "", 0
+	}
+	if isBlankImage(img) {
+		return MethodSkippedBlank, 0
+	}
+	if config.dedupe == nil {
+		return "", 0
+	}
+	if page := config.dedupe.duplicateOf(pageNum+1, fingerprintImage(img)); page > 0 {
+		return MethodSkippedDuplicate, page
+	}
+	return "", 0
+}
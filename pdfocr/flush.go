@@ -0,0 +1,91 @@
+package pdfocr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FormatOutput applies the output post-processing (locale normalization,
+// whitespace normalization and encoding) to extracted text.
+func FormatOutput(text string, config OCRConfig) ([]byte, error) {
+	if config.NormalizeLocale != "" {
+		var err error
+		if text, err = NormalizeLocaleFormats(text, config.NormalizeLocale); err != nil {
+			return nil, err
+		}
+	}
+	data, err := EncodeText(NormalizeWhitespace(text, config), config.Encoding)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding output: %w", err)
+	}
+	return data, nil
+}
+
+// DocumentOutput returns the output of a document in format: its text
+// post-processed by FormatOutput for FormatText or "", else the document
+// as DocumentJSON, DocumentHOCR, DocumentALTO, DocumentMarkdown or
+// DocumentTSV writes it.
+func DocumentOutput(format, text string, manifest DocumentManifest, config OCRConfig) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		return DocumentJSON(manifest, config)
+	case FormatHOCR:
+		return DocumentHOCR(manifest, config), nil
+	case FormatALTO:
+		return DocumentALTO(manifest, config)
+	case FormatMarkdown:
+		return FormatOutput(DocumentMarkdown(manifest), config)
+	case FormatTSV:
+		return DocumentTSV(manifest, config), nil
+	}
+	return FormatOutput(text, config)
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the
+// same directory, so a crash never leaves a half-written file behind.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// flushPartialOutput writes the pages of src done so far to the output file
+// when -flush-every is due, bounding what a crash can lose on long runs:
+// their text, or in config.OutputFormat a document marked partial. The
+// complete output replaces it at the end.
+func flushPartialOutput(config OCRConfig, src *pdfSource, pages []PageResult, text string) {
+	pagesDone := len(pages)
+	if config.FlushEvery <= 0 || config.OutputFile == "" || pagesDone%config.FlushEvery != 0 {
+		return
+	}
+	manifest := DocumentManifest{Name: src.name, Size: len(src.data), MIMEType: src.mediaType, Pages: src.doc.NumPage(),
+		Partial: true, PageResults: pages}
+	data, err := DocumentOutput(config.OutputFormat, text, manifest, config)
+	if err == nil {
+		data, err = SealOutput(config.OutputFile, data, config.EncryptKey)
+	}
+	if err == nil {
+		err = writeFileAtomic(config.OutputFile, data)
+	}
+	if err != nil {
+		config.warnings.warnf(-1, "Warning: could not flush partial output after page %d: %v\n", pagesDone, err)
+		return
+	}
+	config.logf("Flushed %d pages to %s\n", pagesDone, config.OutputFile)
+}
@@ -0,0 +1,47 @@
+package pdfocr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minNativeText is the length in bytes a page's text layer must exceed,
+// surrounding whitespace aside, to be used instead of OCR.
+const minNativeText = 50
+
+// cleanNativeText prepares the text layer of a page for the output.
+func cleanNativeText(text string) string {
+	return strings.TrimSpace(text)
+}
+
+// needsOCR reports whether a page whose cleaned text layer is text counts
+// as scanned: its text is too short to be the page's content, or it is the
+// "Please wait..." placeholder of an XFA form.
+func needsOCR(text string) bool {
+	return len(text) <= minNativeText || isXFAPlaceholderText(text)
+}
+
+// pageSeparator returns the line heading a page in the text output: its
+// number and, for anything but the text layer, how its text was obtained.
+func pageSeparator(page int, method string, duplicateOf int) string {
+	switch method {
+	case MethodNative:
+		return fmt.Sprintf("--- Page %d ---\n", page)
+	case MethodSkippedDuplicate:
+		return fmt.Sprintf("--- Page %d (duplicate of page %d, skipped) ---\n", page, duplicateOf)
+	}
+	return fmt.Sprintf("--- Page %d (%s) ---\n", page, method)
+}
+
+// section returns the page as it appears in the text output, headed by
+// pageSeparator. Skipped pages have no text and failed pages leave no
+// section.
+func (p PageResult) section() string {
+	switch p.Method {
+	case MethodFailed:
+		return ""
+	case MethodSkippedDrawing, MethodSkippedBlank, MethodSkippedDuplicate:
+		return pageSeparator(p.Page, p.Method, p.DuplicateOf) + "\n"
+	}
+	return pageSeparator(p.Page, p.Method, p.DuplicateOf) + p.Text + "\n\n"
+}
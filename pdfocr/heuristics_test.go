@@ -0,0 +1,93 @@
+package pdfocr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanNativeText(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"empty", "", ""},
+		{"whitespace only", " \n\t\n ", ""},
+		{"surrounding whitespace", "\n\n  Invoice 42  \n", "Invoice 42"},
+		{"inner whitespace kept", "Line one\n\nLine two", "Line one\n\nLine two"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanNativeText(tt.in); got != tt.want {
+				t.Errorf("cleanNativeText(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedsOCR(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want bool
+	}{
+		{"no text layer", "", true},
+		{"page number only", "12", true},
+		{"at threshold", strings.Repeat("a", minNativeText), true},
+		{"above threshold", strings.Repeat("a", minNativeText+1), false},
+		{"paragraph", "The quick brown fox jumps over the lazy dog, twice over and over.", false},
+		{"XFA placeholder", "Please wait... If this message is not eventually replaced by the proper contents of the document, your PDF viewer may not be able to display this type of document.", true},
+		{"XFA placeholder, other viewer", "Please wait while the form loads. Your viewer does not support XFA forms.", true},
+		{"please wait in real text", "Please wait for the bell before entering the examination room, then sit down.", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsOCR(tt.text); got != tt.want {
+				t.Errorf("needsOCR(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageSeparator(t *testing.T) {
+	tests := []struct {
+		name        string
+		page        int
+		method      string
+		duplicateOf int
+		want        string
+	}{
+		{"native", 1, MethodNative, 0, "--- Page 1 ---\n"},
+		{"OCR", 2, MethodOCR, 0, "--- Page 2 (OCR) ---\n"},
+		{"merged", 3, MethodMerged, 0, "--- Page 3 (native+OCR) ---\n"},
+		{"chart", 4, MethodOCRChart, 0, "--- Page 4 (OCR, chart) ---\n"},
+		{"blank", 5, MethodSkippedBlank, 0, "--- Page 5 (blank, skipped) ---\n"},
+		{"duplicate", 7, MethodSkippedDuplicate, 3, "--- Page 7 (duplicate of page 3, skipped) ---\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pageSeparator(tt.page, tt.method, tt.duplicateOf); got != tt.want {
+				t.Errorf("pageSeparator(%d, %q, %d) = %q, want %q", tt.page, tt.method, tt.duplicateOf, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageResultSection(t *testing.T) {
+	tests := []struct {
+		name string
+		page PageResult
+		want string
+	}{
+		{"native", PageResult{Page: 1, Method: MethodNative, Text: "Hello"}, "--- Page 1 ---\nHello\n\n"},
+		{"OCR", PageResult{Page: 2, Method: MethodOCR, Text: "Scanned"}, "--- Page 2 (OCR) ---\nScanned\n\n"},
+		{"drawing skipped", PageResult{Page: 3, Method: MethodSkippedDrawing}, "--- Page 3 (vector drawing, skipped) ---\n\n"},
+		{"duplicate skipped", PageResult{Page: 4, Method: MethodSkippedDuplicate, DuplicateOf: 2}, "--- Page 4 (duplicate of page 2, skipped) ---\n\n"},
+		{"failed", PageResult{Page: 5, Method: MethodFailed, Error: "render failed"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.page.section(); got != tt.want {
+				t.Errorf("section() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
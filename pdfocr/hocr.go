@@ -0,0 +1,190 @@
+package pdfocr
+
+import (
+	"fmt"
+	"html"
+	"image"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// hocrID matches the ids of hOCR elements, which start with the page
+	// number: page_1, block_1_2, word_1_15 and so on.
+	hocrID = regexp.MustCompile(`id='([a-z]+)_\d+(_\d+)?'`)
+
+	// hocrPageTitle matches the properties of the ocr_page element.
+	hocrPageTitle = regexp.MustCompile(`(class='ocr_page'[^>]*?title=')([^']*)'`)
+
+	// hocrPar matches an ocr_par element, its attributes and its content.
+	hocrPar = regexp.MustCompile(`(?s)<p class=['"]ocr_par['"]([^>]*)>(.*?)</p>`)
+
+	// hocrTag matches the tags within an element, leaving its text.
+	hocrTag = regexp.MustCompile(`<[^>]*>`)
+
+	// hocrLine matches the start of a line element, hocrWord a word element
+	// with its box and its content.
+	hocrLine = regexp.MustCompile(`<span class=['"]ocr_(?:line|caption|header|textfloat)['"]`)
+	hocrWord = regexp.MustCompile(`(?s)(<span class=['"]ocrx_word['"][^>]*?title=['"]bbox (\d+) (\d+) (\d+) (\d+)[^>]*>)(.*?)(</span>)`)
+)
+
+// hocrPage turns the hOCR an engine produced for one page image, rendered at
+// dpi (0 meaning defaultRenderDPI), into the ocr_page element of page pageNum (zero-based) of a document:
+// ids are numbered by page and the title gets the page number and the scan
+// resolution, so consumers can scale the pixel boxes to the page.
+func hocrPage(raw string, pageNum int, dpi float64) (string, error) {
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	if i := strings.Index(raw, "<body>"); i >= 0 {
+		raw = raw[i+len("<body>"):]
+		raw = raw[:max(strings.Index(raw, "</body>"), 0)]
+	}
+	raw = strings.TrimSpace(raw)
+	if !hocrPageTitle.MatchString(raw) {
+		return "", fmt.Errorf("engine output has no ocr_page element")
+	}
+	raw = hocrID.ReplaceAllString(raw, fmt.Sprintf("id='${1}_%d${2}'", pageNum+1))
+	raw = hocrParDirections(raw)
+	raw = hocrScripts(raw)
+	return hocrPageTitle.ReplaceAllStringFunc(raw, func(m string) string {
+		parts := hocrPageTitle.FindStringSubmatch(m)
+		var props []string
+		for _, prop := range strings.Split(parts[2], ";") {
+			prop = strings.TrimSpace(prop)
+			name, _, _ := strings.Cut(prop, " ")
+			if prop != "" && name != "image" && name != "ppageno" && name != "scan_res" {
+				props = append(props, prop)
+			}
+		}
+		res := math.Round(dpi)
+		props = append(props, fmt.Sprintf("ppageno %d", pageNum), fmt.Sprintf("scan_res %g %g", res, res))
+		return parts[1] + strings.Join(props, "; ") + "'"
+	}), nil
+}
+
+// hocrParDirections gives the ocr_par elements of engine hOCR without a
+// dir attribute the dominant direction of their text. Tesseract sets it
+// itself; the cloud engines do not.
+func hocrParDirections(raw string) string {
+	return hocrPar.ReplaceAllStringFunc(raw, func(m string) string {
+		parts := hocrPar.FindStringSubmatch(m)
+		if strings.Contains(parts[1], " dir=") {
+			return m
+		}
+		dir := TextDirection(html.UnescapeString(hocrTag.ReplaceAllString(parts[2], " ")))
+		end := strings.Index(m, ">")
+		return m[:end] + fmt.Sprintf(" dir='%s'", dir) + m[end:]
+	})
+}
+
+// hocrScripts wraps the content of the words of engine hOCR that are
+// superscripts or subscripts of their line (see wordScripts) in <sup> and
+// <sub> elements.
+func hocrScripts(raw string) string {
+	lineStarts := hocrLine.FindAllStringIndex(raw, -1)
+	words := hocrWord.FindAllStringSubmatchIndex(raw, -1)
+	var b strings.Builder
+	last := 0
+	for len(words) > 0 {
+		// The words up to the start of the next line share one
+		end := len(words)
+		for _, ls := range lineStarts {
+			if ls[0] > words[0][0] {
+				end = sort.Search(len(words), func(i int) bool { return words[i][0] > ls[0] })
+				break
+			}
+		}
+		line := words[:end]
+		words = words[end:]
+		texts := make([]string, len(line))
+		boxes := make([]image.Rectangle, len(line))
+		for i, m := range line {
+			var c [4]int
+			for j := range c {
+				c[j], _ = strconv.Atoi(raw[m[2*j+4]:m[2*j+5]])
+			}
+			boxes[i] = image.Rect(c[0], c[1], c[2], c[3])
+			texts[i] = html.UnescapeString(hocrTag.ReplaceAllString(raw[m[12]:m[13]], ""))
+		}
+		for i, script := range wordScripts(texts, boxes) {
+			if script == scriptNone {
+				continue
+			}
+			m := line[i]
+			b.WriteString(raw[last:m[12]])
+			b.WriteString(scriptElement(raw[m[12]:m[13]], script))
+			last = m[13]
+		}
+	}
+	b.WriteString(raw[last:])
+	return b.String()
+}
+
+// hocrTextPage describes a page taken from its text layer as hOCR, with the
+// boxes of its lines in pixels at dpi. Pages without a recorded layout give
+// "".
+func hocrTextPage(p PageResult, dpi float64) string {
+	if p.Width <= 0 || p.Height <= 0 {
+		return ""
+	}
+	px := func(pt float64) int {
+		return int(math.Round(pt * dpi / 72))
+	}
+	n := p.Page
+	var b strings.Builder
+	fmt.Fprintf(&b, "<div class='ocr_page' id='page_%d' title='bbox 0 0 %d %d; ppageno %d; scan_res %g %g'>\n",
+		n, px(p.Width), px(p.Height), n-1, math.Round(dpi), math.Round(dpi))
+	if len(p.layout) > 0 {
+		texts := make([]string, len(p.layout))
+		for i, l := range p.layout {
+			texts[i] = l.Text
+		}
+		fmt.Fprintf(&b, " <div class='ocr_carea' id='block_%d_1'>\n  <p class='ocr_par' id='par_%d_1' dir='%s'>\n",
+			n, n, TextDirection(strings.Join(texts, "\n")))
+		for i, l := range p.layout {
+			fmt.Fprintf(&b, "   <span class='ocr_line' id='line_%d_%d' dir='%s' title='bbox %d %d %d %d'>%s</span>\n",
+				n, i+1, TextDirection(l.Text), px(l.X0), px(l.Y0), px(l.X1), px(l.Y1), html.EscapeString(l.Text))
+		}
+		b.WriteString("  </p>\n </div>\n")
+	}
+	b.WriteString("</div>")
+	return b.String()
+}
+
+// DocumentHOCR returns a processed document as one hOCR file. OCR'd pages
+// carry the engine's hOCR (with OCRConfig.HOCR) and pages from the text
+// layer their lines; pages that were not processed are left out.
+func DocumentHOCR(manifest DocumentManifest, config OCRConfig) []byte {
+	dpi := config.DPI
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	lang := ""
+	if tag := iiifLanguages[strings.SplitN(config.Language, "+", 2)[0]]; tag != "" {
+		lang = fmt.Sprintf(` xml:lang="%s" lang="%s"`, tag, tag)
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Transitional//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-transitional.dtd">
+`)
+	fmt.Fprintf(&b, "<html xmlns=\"http://www.w3.org/1999/xhtml\"%s>\n <head>\n  <title>%s</title>\n", lang, html.EscapeString(manifest.Name))
+	b.WriteString("  <meta http-equiv=\"Content-Type\" content=\"text/html;charset=utf-8\"/>\n")
+	fmt.Fprintf(&b, "  <meta name=\"ocr-system\" content=\"pdf-ocr-tool %s\"/>\n", html.EscapeString(Version()))
+	b.WriteString("  <meta name=\"ocr-capabilities\" content=\"ocr_page ocr_carea ocr_par ocr_line ocrx_word ocrp_wconf\"/>\n </head>\n <body>\n")
+	for _, p := range manifest.PageResults {
+		page := p.hocr
+		if page == "" {
+			page = hocrTextPage(p, dpi)
+		}
+		if page != "" {
+			b.WriteString(page + "\n")
+		}
+	}
+	b.WriteString(" </body>\n</html>\n")
+	return []byte(b.String())
+}
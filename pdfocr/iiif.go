@@ -0,0 +1,159 @@
+package pdfocr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IIIF Presentation 3 structures: a manifest of canvases, each painted with
+// its page image and supplemented with the page text.
+type iiifManifest struct {
+	Context string       `json:"@context"`
+	ID      string       `json:"id"`
+	Type    string       `json:"type"`
+	Label   iiifLabel    `json:"label"`
+	Items   []iiifCanvas `json:"items"`
+}
+
+type iiifLabel map[string][]string
+
+type iiifCanvas struct {
+	ID          string               `json:"id"`
+	Type        string               `json:"type"`
+	Label       iiifLabel            `json:"label"`
+	Width       int                  `json:"width"`
+	Height      int                  `json:"height"`
+	Items       []iiifAnnotationPage `json:"items"`
+	Annotations []iiifAnnotationPage `json:"annotations,omitempty"`
+}
+
+type iiifAnnotationPage struct {
+	ID    string           `json:"id"`
+	Type  string           `json:"type"`
+	Items []iiifAnnotation `json:"items"`
+}
+
+type iiifAnnotation struct {
+	ID         string   `json:"id"`
+	Type       string   `json:"type"`
+	Motivation string   `json:"motivation"`
+	Body       iiifBody `json:"body"`
+	Target     string   `json:"target"`
+}
+
+type iiifBody struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Format   string `json:"format"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Language string `json:"language,omitempty"`
+}
+
+// iiifLanguages maps common Tesseract language codes to the BCP 47 tags
+// IIIF text bodies use; others are left untagged.
+var iiifLanguages = map[string]string{
+	"eng": "en", "deu": "de", "fra": "fr", "spa": "es", "ita": "it", "nld": "nl",
+	"por": "pt", "swa": "sw", "ara": "ar", "heb": "he", "rus": "ru", "jpn": "ja",
+}
+
+// WriteIIIFPackage writes the page images of a processed document and a
+// IIIF Presentation 3 manifest.json into dir. Each page is a canvas with
+// its image and a supplementing text annotation holding the extracted text.
+// IIIF ids must be absolute URLs: baseURL is where dir will be served,
+// defaulting to a file URL of dir.
+func WriteIIIFPackage(dir, baseURL, pdfPath string, manifest DocumentManifest, config OCRConfig) error {
+	if baseURL == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		baseURL = "file://" + filepath.ToSlash(abs)
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	imageDir := filepath.Join(dir, "images")
+	if err := ExtractImagesFromPDF(pdfPath, imageDir, config); err != nil {
+		return fmt.Errorf("error writing page images: %w", err)
+	}
+
+	m := iiifManifest{
+		Context: "http://iiif.io/api/presentation/3/context.json",
+		ID:      baseURL + "/manifest.json",
+		Type:    "Manifest",
+		Label:   iiifLabel{"none": {manifest.Name}},
+		Items:   []iiifCanvas{},
+	}
+	lang := iiifLanguages[strings.SplitN(config.Language, "+", 2)[0]]
+	for _, p := range manifest.PageResults {
+		imageName := fmt.Sprintf("page_%d.jpg", p.Page)
+		cfg, err := readImageConfig(filepath.Join(imageDir, imageName))
+		if errors.Is(err, fs.ErrNotExist) {
+			// Pages that could not be rendered have no canvas
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading page image %s: %w", imageName, err)
+		}
+
+		canvasID := fmt.Sprintf("%s/canvas/%d", baseURL, p.Page)
+		canvas := iiifCanvas{
+			ID: canvasID, Type: "Canvas", Label: iiifLabel{"none": {fmt.Sprint(p.Page)}},
+			Width: cfg.Width, Height: cfg.Height,
+			Items: []iiifAnnotationPage{{
+				ID: canvasID + "/paint", Type: "AnnotationPage",
+				Items: []iiifAnnotation{{
+					ID: canvasID + "/paint/image", Type: "Annotation", Motivation: "painting", Target: canvasID,
+					Body: iiifBody{
+						ID: baseURL + "/images/" + imageName, Type: "Image", Format: "image/jpeg",
+						Width: cfg.Width, Height: cfg.Height,
+					},
+				}},
+			}},
+		}
+
+		text := p.Text
+		if config.NormalizeLocale != "" {
+			if text, err = NormalizeLocaleFormats(text, config.NormalizeLocale); err != nil {
+				return err
+			}
+		}
+		if strings.TrimSpace(text) != "" {
+			canvas.Annotations = []iiifAnnotationPage{{
+				ID: canvasID + "/text", Type: "AnnotationPage",
+				Items: []iiifAnnotation{{
+					ID: canvasID + "/text/1", Type: "Annotation", Motivation: "supplementing", Target: canvasID,
+					Body: iiifBody{Type: "TextualBody", Format: "text/plain", Value: text, Language: lang},
+				}},
+			}}
+		}
+		m.Items = append(m.Items, canvas)
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding IIIF manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing IIIF manifest: %w", err)
+	}
+	return nil
+}
+
+// readImageConfig reads the dimensions of an image file.
+func readImageConfig(path string) (image.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	return cfg, err
+}
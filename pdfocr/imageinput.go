@@ -0,0 +1,205 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+)
+
+// defaultImageDPI is the resolution assumed for image files that do not
+// record one, as scanners usually produce; at the default -dpi their pixels
+// are recognized as they are.
+const defaultImageDPI = 300
+
+// mupdfImageDPI is the resolution MuPDF assumes for TIFF frames that do not
+// record one.
+const mupdfImageDPI = 96
+
+// Media types of the image files accepted in place of a PDF.
+const (
+	mediaPNG  = "image/png"
+	mediaJPEG = "image/jpeg"
+	mediaTIFF = "image/tiff"
+)
+
+// imageExtensions are the file extensions of the image inputs, for finding
+// inputs in directories.
+var imageExtensions = []string{".png", ".jpg", ".jpeg", ".tif", ".tiff"}
+
+// inputMediaType returns the media type of an image input from its magic
+// bytes, or "" for anything else, which is opened as a PDF.
+func inputMediaType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return mediaPNG
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return mediaJPEG
+	case bytes.HasPrefix(data, []byte("II*\x00")), bytes.HasPrefix(data, []byte("MM\x00*")):
+		return mediaTIFF
+	}
+	return ""
+}
+
+// isInputFile reports whether a file name has the extension of a PDF or an
+// image input.
+func isInputFile(name string) bool {
+	lower := strings.ToLower(name)
+	if strings.HasSuffix(lower, ".pdf") {
+		return true
+	}
+	for _, ext := range imageExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// openImageDocument opens an image input as a document of one page per
+// frame. PNG and JPEG files are decoded here, in every build; the frames of
+// TIFF files, whose compressions include the CCITT codecs of fax and
+// document scanners, are rendered by MuPDF.
+func openImageDocument(data []byte, mediaType string) (RenderDocument, error) {
+	var img image.Image
+	var dpi float64
+	var err error
+	switch mediaType {
+	case mediaTIFF:
+		r, lerr := lookupRenderer("mupdf")
+		if lerr != nil {
+			return nil, fmt.Errorf("TIFF input needs the MuPDF renderer, which this build leaves out")
+		}
+		doc, err := r.Open(data)
+		if err != nil {
+			return nil, err
+		}
+		return &tiffDocument{RenderDocument: doc, noResolution: tiffFramesWithoutResolution(data)}, nil
+	case mediaPNG:
+		img, err = png.Decode(bytes.NewReader(data))
+		dpi = pngDPI(data)
+	default:
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		dpi = jpegDPI(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", mediaType, err)
+	}
+	if dpi <= 0 {
+		dpi = defaultImageDPI
+	}
+	return &imageDocument{img: img, dpi: dpi}, nil
+}
+
+// imageDocument is a single image opened as a one-page document with no
+// text layer, the page being the image at its resolution.
+type imageDocument struct {
+	img image.Image
+	dpi float64
+}
+
+func (d *imageDocument) NumPage() int { return 1 }
+
+func (d *imageDocument) Text(pageNumber int) (string, error) {
+	return "", nil
+}
+
+// ImageDPI returns the image resampled from its own resolution to dpi, or
+// as it is when they match.
+func (d *imageDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	if pageNumber != 0 {
+		return nil, fmt.Errorf("page %d out of range (1 page)", pageNumber+1)
+	}
+	if math.Abs(dpi/d.dpi-1) < 0.01 {
+		return d.img, nil
+	}
+	return upscaleBicubic(d.img, dpi/d.dpi)
+}
+
+func (d *imageDocument) Close() error { return nil }
+
+// tiffDocument is a TIFF file rendered by MuPDF, with the frames that do not
+// record their resolution taken for defaultImageDPI like other images.
+type tiffDocument struct {
+	RenderDocument
+	noResolution []bool // by frame
+}
+
+func (d *tiffDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	if pageNumber < len(d.noResolution) && d.noResolution[pageNumber] {
+		dpi *= mupdfImageDPI / float64(defaultImageDPI)
+	}
+	return d.RenderDocument.ImageDPI(pageNumber, dpi)
+}
+
+// tiffFramesWithoutResolution walks the image file directories of a TIFF
+// file and reports for each frame whether it lacks an XResolution tag.
+func tiffFramesWithoutResolution(data []byte) []bool {
+	if len(data) < 8 {
+		return nil
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if data[0] == 'M' {
+		order = binary.BigEndian
+	}
+	var frames []bool
+	seen := map[uint32]bool{}
+	for off := order.Uint32(data[4:]); off != 0 && !seen[off] && int(off)+2 <= len(data); {
+		seen[off] = true
+		n := int(order.Uint16(data[off:]))
+		entries := int(off) + 2
+		if entries+12*n+4 > len(data) {
+			break
+		}
+		missing := true
+		for i := 0; i < n; i++ {
+			if order.Uint16(data[entries+12*i:]) == 282 {
+				missing = false
+			}
+		}
+		frames = append(frames, missing)
+		off = order.Uint32(data[entries+12*n:])
+	}
+	return frames
+}
+
+// pngDPI reads the resolution of a PNG file from its pHYs chunk, or returns
+// 0.
+func pngDPI(data []byte) float64 {
+	for i := 8; i+12 <= len(data); {
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		kind := string(data[i+4 : i+8])
+		if kind == "IDAT" || n < 0 || i+12+n > len(data) {
+			break
+		}
+		if kind == "pHYs" && n == 9 && data[i+16] == 1 {
+			// Pixels per metre
+			ppm := binary.BigEndian.Uint32(data[i+8:])
+			return math.Round(float64(ppm) * 0.0254)
+		}
+		i += 12 + n
+	}
+	return 0
+}
+
+// jpegDPI reads the resolution of a JPEG file from its JFIF header, or
+// returns 0.
+func jpegDPI(data []byte) float64 {
+	// FF D8, then the APP0 segment: FF E0, length, "JFIF\0", version,
+	// units, X density, Y density
+	if len(data) < 18 || data[2] != 0xFF || data[3] != 0xE0 || string(data[6:11]) != "JFIF\x00" {
+		return 0
+	}
+	density := float64(binary.BigEndian.Uint16(data[14:]))
+	switch data[13] {
+	case 1:
+		return density
+	case 2:
+		return math.Round(density * 2.54)
+	}
+	return 0
+}
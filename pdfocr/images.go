@@ -0,0 +1,384 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Formats of the embedded images written by ExtractImagesFromPDF, in
+// OCRConfig.ImageFormat.
+const (
+	ImageFormatPNG  = "png"
+	ImageFormatJPEG = "jpeg"
+)
+
+// validateImageFormat checks an -image-format given on the command line.
+func validateImageFormat(format string) error {
+	switch format {
+	case "", ImageFormatPNG, ImageFormatJPEG:
+		return nil
+	}
+	return fmt.Errorf("unsupported image format %q (use png or jpeg)", format)
+}
+
+// ParseImageSize reads a minimum image size, WxH in pixels or a single
+// number for both.
+func ParseImageSize(spec string) (int, int, error) {
+	ws, hs, ok := strings.Cut(strings.ToLower(spec), "x")
+	if !ok {
+		hs = ws
+	}
+	w, werr := strconv.Atoi(ws)
+	h, herr := strconv.Atoi(hs)
+	if werr != nil || herr != nil || w < 0 || h < 0 {
+		return 0, 0, fmt.Errorf("invalid image size %q (use WxH in pixels, e.g. 200x200)", spec)
+	}
+	return w, h, nil
+}
+
+// extractEmbeddedImages writes the image XObjects drawn by the pages of
+// config.PageSelection to outputDir as page_<n>_image_<k>.<ext>, each
+// object once, and returns their checksums. Images smaller than
+// config.MinImageWidth x config.MinImageHeight are left out.
+func extractEmbeddedImages(src *pdfSource, outputDir string, config OCRConfig) (checksums, error) {
+	sums := checksums{}
+	written := map[int]bool{}
+	for _, pageNum := range config.PageSelection.pageNums(src.doc.NumPage()) {
+		k := 0
+		for _, obj := range src.raw.pageImages(pageNum) {
+			dict, _ := obj.Value.(pdfDict)
+			w, h := src.raw.int(dict["Width"]), src.raw.int(dict["Height"])
+			if written[obj.Num] || w < config.MinImageWidth || h < config.MinImageHeight {
+				continue
+			}
+			written[obj.Num] = true
+			data, ext, err := src.raw.exportImage(obj, config.ImageFormat)
+			if err != nil {
+				src.warnings.warnf(pageNum, "Warning: could not extract an image of page %d: %v\n", pageNum+1, err)
+				continue
+			}
+			k++
+			name := fmt.Sprintf("page_%d_image_%d.%s", pageNum+1, k, ext)
+			filename := filepath.Join(outputDir, name)
+			if err := os.WriteFile(filename, data, 0644); err != nil {
+				return nil, fmt.Errorf("error writing %s: %w", filename, err)
+			}
+			sums.add(name, data)
+			config.logf("Extracted %dx%d image from page %d to %s\n", w, h, pageNum+1, filename)
+		}
+	}
+	return sums, nil
+}
+
+// exportImage returns an image XObject as a file of its own and the file
+// extension. JPEG and JPEG 2000 streams are copied unchanged, CCITT fax
+// data is wrapped in a TIFF and JBIG2 data in a JBIG2 file, and images of
+// raw samples become PNG. A format other than "" converts the images Go
+// can decode (JPEG and raw samples) to PNG or JPEG; the others keep their
+// own format.
+func (pdf *rawPDF) exportImage(obj *rawObject, format string) ([]byte, string, error) {
+	dict, _ := obj.Value.(pdfDict)
+	data, err := pdf.decodeStream(obj)
+	if err != nil {
+		return nil, "", err
+	}
+	codec, parms := pdf.imageCodec(dict)
+	var img image.Image
+	switch codec {
+	case "DCTDecode", "DCT":
+		if format == "" || format == ImageFormatJPEG {
+			return data, "jpg", nil
+		}
+		if img, err = jpeg.Decode(bytes.NewReader(data)); err != nil {
+			return nil, "", fmt.Errorf("error decoding JPEG image: %w", err)
+		}
+	case "JPXDecode":
+		return data, "jp2", nil
+	case "CCITTFaxDecode", "CCF":
+		return pdf.ccittTIFF(dict, parms, data), "tif", nil
+	case "JBIG2Decode":
+		return pdf.jbig2File(parms, data), "jb2", nil
+	default:
+		if img, err = pdf.sampledImage(dict, data); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	if format == ImageFormatJPEG {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+		return buf.Bytes(), "jpg", err
+	}
+	err = png.Encode(&buf, img)
+	return buf.Bytes(), "png", err
+}
+
+// imageCodec returns the image compression filter of a stream, which
+// decodeStream leaves in place, and its DecodeParms, or "" for raw samples.
+func (pdf *rawPDF) imageCodec(dict pdfDict) (string, pdfDict) {
+	for i, filter := range pdf.streamFilters(dict) {
+		switch filter {
+		case "DCTDecode", "DCT", "JPXDecode", "JBIG2Decode", "CCITTFaxDecode", "CCF":
+			if a, ok := pdf.resolve(dict["DecodeParms"]).(pdfArray); ok {
+				if i < len(a) {
+					return filter, pdf.dict(a[i])
+				}
+				return filter, nil
+			}
+			return filter, pdf.dict(dict["DecodeParms"])
+		}
+	}
+	return "", nil
+}
+
+// imageColorSpace describes the color space of an image: its number of
+// components and the color of a set of component values scaled to 0-1,
+// or the palette of an indexed space.
+type imageColorSpace struct {
+	components int
+	palette    color.Palette
+	cmyk       bool
+}
+
+// colorSpace returns the imageColorSpace of a ColorSpace value. Spaces
+// other than the device, calibrated, ICC-based and indexed ones are
+// unsupported.
+func (pdf *rawPDF) colorSpace(v pdfValue, depth int) (imageColorSpace, error) {
+	v = pdf.resolve(v)
+	name := pdf.name(v)
+	var args pdfArray
+	if a, ok := v.(pdfArray); ok && len(a) > 0 {
+		name, args = pdf.name(a[0]), a[1:]
+	}
+	switch name {
+	case "DeviceGray", "G", "CalGray":
+		return imageColorSpace{components: 1}, nil
+	case "DeviceRGB", "RGB", "CalRGB":
+		return imageColorSpace{components: 3}, nil
+	case "DeviceCMYK", "CMYK":
+		return imageColorSpace{components: 4, cmyk: true}, nil
+	case "ICCBased":
+		if len(args) > 0 {
+			if obj := pdf.object(args[0]); obj != nil {
+				stream, _ := obj.Value.(pdfDict)
+				switch pdf.int(stream["N"]) {
+				case 1:
+					return imageColorSpace{components: 1}, nil
+				case 3:
+					return imageColorSpace{components: 3}, nil
+				case 4:
+					return imageColorSpace{components: 4, cmyk: true}, nil
+				}
+			}
+		}
+	case "Indexed", "I":
+		if len(args) < 3 || depth > 0 {
+			break
+		}
+		base, err := pdf.colorSpace(args[0], depth+1)
+		if err != nil {
+			return base, err
+		}
+		var lookup []byte
+		switch l := pdf.resolve(args[2]).(type) {
+		case pdfString:
+			lookup = []byte(l)
+		default:
+			if obj := pdf.object(args[2]); obj != nil {
+				if lookup, err = pdf.decodeStream(obj); err != nil {
+					return base, err
+				}
+			}
+		}
+		cs := imageColorSpace{components: 1}
+		for i := 0; i <= pdf.int(args[1]) && (i+1)*base.components <= len(lookup); i++ {
+			entry := lookup[i*base.components : (i+1)*base.components]
+			values := make([]float64, len(entry))
+			for j, b := range entry {
+				values[j] = float64(b) / 255
+			}
+			cs.palette = append(cs.palette, base.color(values))
+		}
+		return cs, nil
+	}
+	return imageColorSpace{}, fmt.Errorf("unsupported color space %q", name)
+}
+
+// color returns the color of component values scaled to 0-1.
+func (cs imageColorSpace) color(values []float64) color.Color {
+	b := func(v float64) uint8 { return uint8(min(max(v, 0), 1)*255 + 0.5) }
+	switch {
+	case cs.components == 1:
+		return color.Gray{b(values[0])}
+	case cs.cmyk:
+		return color.CMYK{b(values[0]), b(values[1]), b(values[2]), b(values[3])}
+	}
+	return color.RGBA{b(values[0]), b(values[1]), b(values[2]), 255}
+}
+
+// sampledImage decodes the raw samples of an image XObject, as laid out by
+// its Width, Height, BitsPerComponent, ColorSpace and Decode entries;
+// stencil masks (ImageMask) paint their 0 samples black.
+func (pdf *rawPDF) sampledImage(dict pdfDict, data []byte) (image.Image, error) {
+	w, h := pdf.int(dict["Width"]), pdf.int(dict["Height"])
+	bpc := pdf.int(dict["BitsPerComponent"])
+	var cs imageColorSpace
+	var err error
+	if mask, _ := pdf.resolve(dict["ImageMask"]).(bool); mask {
+		cs, bpc = imageColorSpace{components: 1}, 1
+	} else if cs, err = pdf.colorSpace(dict["ColorSpace"], 0); err != nil {
+		return nil, err
+	}
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("unsupported %d bits per component", bpc)
+	}
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", w, h)
+	}
+	stride := (w*cs.components*bpc + 7) / 8
+	if len(data) < stride*h {
+		return nil, fmt.Errorf("image data holds %d of %d bytes", len(data), stride*h)
+	}
+
+	// Decode maps every component from 0-maxSample to a range
+	maxSample := float64(int(1)<<bpc - 1)
+	ranges := make([][2]float64, cs.components)
+	for i := range ranges {
+		ranges[i] = [2]float64{0, 1}
+		if cs.palette != nil {
+			ranges[i] = [2]float64{0, maxSample}
+		}
+	}
+	if decode := pdf.array(dict["Decode"]); len(decode) >= 2*cs.components {
+		for i := range ranges {
+			lo, _ := pdf.resolve(decode[2*i]).(float64)
+			hi, _ := pdf.resolve(decode[2*i+1]).(float64)
+			ranges[i] = [2]float64{lo, hi}
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	values := make([]float64, cs.components)
+	for y := 0; y < h; y++ {
+		row := data[y*stride : (y+1)*stride]
+		for x := 0; x < w; x++ {
+			for c := range values {
+				bit := (x*cs.components + c) * bpc
+				var s int
+				if bpc == 16 {
+					s = int(binary.BigEndian.Uint16(row[bit/8:]))
+				} else {
+					s = int(row[bit/8]>>(8-bpc-bit%8)) & (1<<bpc - 1)
+				}
+				values[c] = ranges[c][0] + float64(s)*(ranges[c][1]-ranges[c][0])/maxSample
+			}
+			var col color.Color
+			if cs.palette != nil {
+				i := int(values[0] + 0.5)
+				if i < 0 || i >= len(cs.palette) {
+					i = 0
+				}
+				col = cs.palette[i]
+			} else {
+				col = cs.color(values)
+			}
+			img.Set(x, y, col)
+		}
+	}
+	return img, nil
+}
+
+// ccittTIFF wraps CCITT fax data in a single-strip TIFF file, Group 4 for
+// a negative K and Group 3 otherwise.
+func (pdf *rawPDF) ccittTIFF(dict, parms pdfDict, data []byte) []byte {
+	width, height := pdf.int(parms["Columns"]), pdf.int(parms["Rows"])
+	if width == 0 {
+		width = 1728
+	}
+	if height == 0 {
+		height = pdf.int(dict["Height"])
+	}
+	k := pdf.int(parms["K"])
+	compression, optionsTag, options := 3, uint16(292), uint32(0) // T4Options
+	if k < 0 {
+		compression, optionsTag = 4, 293 // T6Options
+	} else if k > 0 {
+		options = 1 // two-dimensional coding
+	}
+	// Black runs decode to 1 bits, which WhiteIsZero shows black as PDF
+	// does by default
+	photometric := 0
+	blackIs1, _ := pdf.resolve(parms["BlackIs1"]).(bool)
+	if decode := pdf.array(dict["Decode"]); len(decode) == 2 && pdf.int(decode[0]) == 1 {
+		blackIs1 = !blackIs1
+	}
+	if blackIs1 {
+		photometric = 1
+	}
+
+	type entry struct {
+		tag, kind uint16
+		value     uint32
+	}
+	const short, long = 3, 4
+	entries := []entry{
+		{256, long, uint32(width)},
+		{257, long, uint32(height)},
+		{258, short, 1},
+		{259, short, uint32(compression)},
+		{262, short, uint32(photometric)},
+		{273, long, 0}, // strip offset, set below
+		{278, long, uint32(height)},
+		{279, long, uint32(len(data))},
+		{optionsTag, long, options},
+	}
+	var buf bytes.Buffer
+	buf.WriteString("II*\x00")
+	binary.Write(&buf, binary.LittleEndian, uint32(8))
+	binary.Write(&buf, binary.LittleEndian, uint16(len(entries)))
+	offset := uint32(8 + 2 + 12*len(entries) + 4)
+	for _, e := range entries {
+		if e.tag == 273 {
+			e.value = offset
+		}
+		binary.Write(&buf, binary.LittleEndian, e.tag)
+		binary.Write(&buf, binary.LittleEndian, e.kind)
+		binary.Write(&buf, binary.LittleEndian, uint32(1))
+		if e.kind == short {
+			binary.Write(&buf, binary.LittleEndian, uint16(e.value))
+			binary.Write(&buf, binary.LittleEndian, uint16(0))
+		} else {
+			binary.Write(&buf, binary.LittleEndian, e.value)
+		}
+	}
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // no further IFD
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// jbig2File turns the embedded JBIG2 data of an image, and its global
+// segments, into a single-page JBIG2 file in sequential organization.
+func (pdf *rawPDF) jbig2File(parms pdfDict, data []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x97JB2\r\n\x1a\n")
+	buf.WriteByte(0x01) // sequential, with a page count
+	binary.Write(&buf, binary.BigEndian, uint32(1))
+	if globals := pdf.object(parms["JBIG2Globals"]); globals != nil {
+		if g, err := pdf.decodeStream(globals); err == nil {
+			buf.Write(g)
+		}
+	}
+	buf.Write(data)
+	return buf.Bytes()
+}
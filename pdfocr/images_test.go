@@ -0,0 +1,61 @@
+package pdfocr
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestExportImage(t *testing.T) {
+	data := []byte("%PDF-1.4\n" +
+		"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n" +
+		"2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n" +
+		"3 0 obj << /Type /Page /Parent 2 0 R /Resources << /XObject << /Im1 4 0 R /Im2 5 0 R /Im3 6 0 R >> >> >> endobj\n" +
+		"4 0 obj << /Type /XObject /Subtype /Image /Width 2 /Height 1 /BitsPerComponent 8" +
+		" /ColorSpace [/Indexed /DeviceRGB 1 <FF000000FF00>] /Length 2 >>\nstream\n\x01\x00\nendstream endobj\n" +
+		"5 0 obj << /Type /XObject /Subtype /Image /Width 640 /Height 480 /Filter /DCTDecode /Length 4 >>\nstream\n\xff\xd8\xff\xd9\nendstream endobj\n" +
+		"6 0 obj << /Type /XObject /Subtype /Image /Width 8 /Height 2 /Filter /CCITTFaxDecode" +
+		" /DecodeParms << /K -1 /Columns 8 /Rows 2 >> /Length 3 >>\nstream\nabc\nendstream endobj\n" +
+		"trailer << /Root 1 0 R >>\n%%EOF\n")
+	pdf, err := parseRawPDF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	images := pdf.pageImages(0)
+	if len(images) != 3 {
+		t.Fatalf("pageImages found %d images, want 3", len(images))
+	}
+
+	out, ext, err := pdf.exportImage(images[0], "")
+	if err != nil || ext != "png" {
+		t.Fatalf("indexed image: %q, %v, want png", ext, err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, _, _ := img.At(0, 0).RGBA(); r != 0 || g != 0xffff {
+		t.Errorf("pixel 0 of the indexed image is %v, want green", img.At(0, 0))
+	}
+	if r, _, _, _ := img.At(1, 0).RGBA(); r != 0xffff {
+		t.Errorf("pixel 1 of the indexed image is %v, want red", img.At(1, 0))
+	}
+
+	if out, ext, err := pdf.exportImage(images[1], ""); err != nil || ext != "jpg" || string(out) != "\xff\xd8\xff\xd9" {
+		t.Errorf("JPEG image: %q, %q, %v, want the stream as jpg", out, ext, err)
+	}
+
+	out, ext, err = pdf.exportImage(images[2], ImageFormatPNG)
+	if err != nil || ext != "tif" || !bytes.HasPrefix(out, []byte("II*\x00")) || !bytes.HasSuffix(out, []byte("abc")) {
+		t.Errorf("CCITT image: %q, %q, %v, want a TIFF holding the stream", out, ext, err)
+	}
+
+	for spec, want := range map[string][2]int{"200x100": {200, 100}, "50": {50, 50}} {
+		if w, h, err := ParseImageSize(spec); err != nil || w != want[0] || h != want[1] {
+			t.Errorf("ParseImageSize(%q) = %d, %d, %v, want %v", spec, w, h, err, want)
+		}
+	}
+	if _, _, err := ParseImageSize("big"); err == nil {
+		t.Error("ParseImageSize(\"big\") succeeded")
+	}
+}
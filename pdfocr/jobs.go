@@ -0,0 +1,205 @@
+package pdfocr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Retention of the server's data, unless ServerOptions say otherwise: the
+// pages of a document are kept for the job endpoints for defaultJobTTL,
+// as long as the jobs take less than defaultJobMemory, and scratch files
+// left in the temporary directory are removed once they are
+// defaultScratchTTL old. The janitor looks for both every janitorInterval.
+const (
+	defaultJobTTL     = time.Hour
+	defaultJobMemory  = 256 << 20
+	defaultScratchTTL = 24 * time.Hour
+	janitorInterval   = time.Minute
+)
+
+// serverJob is a document extracted by the server, kept for the job
+// endpoints under a random ID.
+type serverJob struct {
+	expires time.Time
+	pages   []PageResult
+	size    int64 // estimated bytes, see jobSize
+}
+
+// jobStore holds the jobs of the server for ttl, dropping the oldest ones
+// early while they take more than maxBytes.
+type jobStore struct {
+	ttl      time.Duration
+	maxBytes int64
+	mu       sync.Mutex
+	jobs     map[string]*serverJob
+	bytes    int64
+}
+
+func newJobStore(ttl time.Duration, maxBytes int64) *jobStore {
+	return &jobStore{ttl: ttl, maxBytes: maxBytes, jobs: map[string]*serverJob{}}
+}
+
+// jobSize estimates the memory the pages of a job take: their text,
+// thumbnails and word boxes, each of which takes about 64 bytes besides its
+// text.
+func jobSize(pages []PageResult) int64 {
+	var n int64
+	for _, p := range pages {
+		n += int64(len(p.Text) + len(p.Thumbnail) + 64*len(p.Words))
+		for _, w := range p.Words {
+			n += int64(len(w.Text))
+		}
+	}
+	return n
+}
+
+// add keeps the pages of a document and returns the ID of its job. The
+// oldest jobs are dropped until the store fits in maxBytes again; the new
+// job is kept even when it is larger on its own.
+func (s *jobStore) add(pages []PageResult, now time.Time) string {
+	var b [16]byte
+	rand.Read(b[:])
+	id := hex.EncodeToString(b[:])
+	job := &serverJob{expires: now.Add(s.ttl), pages: pages, size: jobSize(pages)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.maxBytes > 0 && len(s.jobs) > 0 && s.bytes+job.size > s.maxBytes {
+		oldest := ""
+		for id, job := range s.jobs {
+			if oldest == "" || job.expires.Before(s.jobs[oldest].expires) {
+				oldest = id
+			}
+		}
+		s.drop(oldest)
+	}
+	s.jobs[id] = job
+	s.bytes += job.size
+	return id
+}
+
+// drop removes the job id; s.mu is held.
+func (s *jobStore) drop(id string) {
+	if job := s.jobs[id]; job != nil {
+		s.bytes -= job.size
+		delete(s.jobs, id)
+	}
+}
+
+// get returns the job id, or nil if there is none or it has expired.
+func (s *jobStore) get(id string, now time.Time) *serverJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	if job == nil || !now.Before(job.expires) {
+		return nil
+	}
+	return job
+}
+
+// remove drops the job id, reporting whether there was one.
+func (s *jobStore) remove(id string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := s.jobs[id]
+	s.drop(id)
+	return job != nil && now.Before(job.expires)
+}
+
+// expire drops the jobs that have expired by now.
+func (s *jobStore) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, job := range s.jobs {
+		if !now.Before(job.expires) {
+			s.drop(id)
+		}
+	}
+}
+
+// janitor expires the jobs and, unless scratchTTL is negative, removes the
+// stale scratch files every janitorInterval until stop is closed.
+func (s *ocrServer) janitor(scratchTTL time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			s.jobs.expire(now)
+			if scratchTTL >= 0 {
+				removeStaleScratch(scratchTTL, now)
+			}
+		}
+	}
+}
+
+// jobPage is the JSON body of GET /jobs/{id}/pages/{n}: the page's result
+// as in -format json, with its thumbnail.
+type jobPage struct {
+	PageResult
+	Thumbnail []byte `json:"thumbnail,omitempty"` // PNG, base64 encoded in JSON
+}
+
+// handleJob serves GET /jobs/{id}/pages/{n}, the result of page n of the
+// document of job id (see handleOCR): its text, method and confidence,
+// its word boxes when the job recorded them, and a PNG thumbnail. DELETE
+// /jobs/{id} drops the job before it expires.
+func (s *ocrServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/")
+	switch {
+	case len(parts) == 1:
+		if r.Method != http.MethodDelete {
+			w.Header().Set("Allow", http.MethodDelete)
+			writeServeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed, errors.New("use DELETE"), false)
+			return
+		}
+		if !s.jobs.remove(parts[0], time.Now()) {
+			writeServeError(w, http.StatusNotFound, errNotFound, fmt.Errorf("no job %q", parts[0]), false)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case len(parts) != 3 || parts[1] != "pages":
+		writeServeError(w, http.StatusNotFound, errNotFound, errors.New("use /jobs/{id} or /jobs/{id}/pages/{n}"), false)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeServeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed, errors.New("use GET"), false)
+		return
+	}
+	job := s.jobs.get(parts[0], time.Now())
+	if job == nil {
+		writeServeError(w, http.StatusNotFound, errNotFound, fmt.Errorf("no job %q (jobs are kept for %v)", parts[0], s.jobs.ttl), false)
+		return
+	}
+	n, err := strconv.Atoi(parts[2])
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, errInvalidRequest, fmt.Errorf("invalid page number %q", parts[2]), false)
+		return
+	}
+	for _, p := range job.pages {
+		if p.Page != n {
+			continue
+		}
+		body, err := json.Marshal(jobPage{PageResult: p, Thumbnail: p.Thumbnail})
+		if err != nil {
+			writeServeError(w, http.StatusInternalServerError, errExtractionFailed, err, false)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Pages", strconv.Itoa(len(job.pages)))
+		writeResult(w, r, body)
+		return
+	}
+	writeServeError(w, http.StatusNotFound, errNotFound, fmt.Errorf("page %d is not part of the job", n), false)
+}
@@ -0,0 +1,78 @@
+package pdfocr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobStore(t *testing.T) {
+	now := time.Now()
+	jobs := newJobStore(time.Hour, 0)
+	early := jobs.add([]PageResult{{Page: 1}}, now.Add(-2*time.Hour))
+	late := jobs.add([]PageResult{{Page: 1}}, now)
+	if jobs.get(early, now) != nil || jobs.get(late, now) == nil {
+		t.Error("get did not tell the expired job from the live one")
+	}
+	jobs.expire(now)
+	if _, ok := jobs.jobs[early]; ok || len(jobs.jobs) != 1 {
+		t.Errorf("expire left %d jobs, want the live one", len(jobs.jobs))
+	}
+	if !jobs.remove(late, now) || jobs.remove(late, now) || len(jobs.jobs) != 0 {
+		t.Error("remove did not drop the job once")
+	}
+}
+
+func TestJobStoreMemory(t *testing.T) {
+	now := time.Now()
+	jobs := newJobStore(time.Hour, 100)
+	page := []PageResult{{Page: 1, Text: strings.Repeat("x", 40)}}
+	first := jobs.add(page, now)
+	second := jobs.add(page, now.Add(time.Second))
+	third := jobs.add(page, now.Add(2*time.Second))
+	if jobs.get(first, now) != nil || jobs.get(second, now) == nil || jobs.get(third, now) == nil {
+		t.Error("the oldest job was not dropped to make room")
+	}
+	huge := jobs.add([]PageResult{{Page: 1, Text: strings.Repeat("x", 200)}}, now.Add(3*time.Second))
+	if len(jobs.jobs) != 1 || jobs.get(huge, now) == nil || jobs.bytes != 200 {
+		t.Errorf("store holds %d jobs of %d bytes, want the oversized new one alone", len(jobs.jobs), jobs.bytes)
+	}
+	jobs.remove(huge, now)
+	if jobs.bytes != 0 {
+		t.Errorf("empty store counts %d bytes", jobs.bytes)
+	}
+}
+
+func TestRemoveStaleScratch(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"pdf-ocr-20261001T080000-42-0badf00d-render-p0001-1.png": 48 * time.Hour,
+		"pdf-ocr-20261001T080000-42-0badf00d-poppler-2":          time.Minute,
+		"pdf-ocr-cache.db": 48 * time.Hour,
+		"other.png":        48 * time.Hour,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if name := strings.Replace(scratchPattern("render", 2, ".png"), "*", "1", 1); !scratchName.MatchString(name) {
+		t.Fatalf("scratch file %s of this run would be left", name)
+	}
+	removeStaleScratch(24*time.Hour, now)
+	entries, _ := os.ReadDir(dir)
+	var left []string
+	for _, e := range entries {
+		left = append(left, e.Name())
+	}
+	if len(left) != 3 || left[0] != "other.png" || left[1] != "pdf-ocr-20261001T080000-42-0badf00d-poppler-2" || left[2] != "pdf-ocr-cache.db" {
+		t.Errorf("left %v, want the files of other programs and the new scratch file", left)
+	}
+}
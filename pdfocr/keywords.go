@@ -0,0 +1,68 @@
+package pdfocr
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KeywordMatch is the first page of a document found to mention one of
+// OCRConfig.Keywords.
+type KeywordMatch struct {
+	Term string `json:"term"` // as given in OCRConfig.Keywords
+	Page int    `json:"page"` // 1-based
+}
+
+// ParseKeywords splits a comma-separated list of terms, dropping blank
+// ones.
+func ParseKeywords(list string) []string {
+	var terms []string
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// findKeyword returns the first of terms that text mentions, or "". Terms
+// match whole words in any case, and the words of a phrase may be split
+// over lines.
+func findKeyword(text string, terms []string) string {
+	text = " " + strings.Join(strings.Fields(strings.ToLower(text)), " ") + " "
+	for _, term := range terms {
+		needle := strings.Join(strings.Fields(strings.ToLower(term)), " ")
+		if needle == "" {
+			continue
+		}
+		for from := 0; ; {
+			i := strings.Index(text[from:], needle)
+			if i < 0 {
+				break
+			}
+			i += from
+			before, _ := utf8.DecodeLastRuneInString(text[:i])
+			after, _ := utf8.DecodeRuneInString(text[i+len(needle):])
+			if !isWordRune(before) && !isWordRune(after) {
+				return term
+			}
+			from = i + 1
+		}
+	}
+	return ""
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// keywordMatch returns the page of a document where keyword spotting
+// stopped, or nil.
+func keywordMatch(pages []PageResult) *KeywordMatch {
+	for _, p := range pages {
+		if p.Keyword != "" {
+			return &KeywordMatch{Term: p.Keyword, Page: p.Page}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// minLanguageHits is how many common words of a language the first
+// recognition of a page must hold for the language to be picked.
+const minLanguageHits = 3
+
+// scriptLanguages are the traineddata languages written in each script
+// reported by Tesseract's OSD.
+var scriptLanguages = map[string][]string{
+	"Latin": {"eng", "fra", "deu", "spa", "por", "ita", "nld", "swa", "pol", "ces", "slk", "hun", "ron", "swe",
+		"dan", "nor", "fin", "tur", "ind", "msa", "vie", "cat", "hrv", "slv", "lit", "lav", "est", "afr", "lat"},
+	"Cyrillic":   {"rus", "ukr", "bul", "srp", "bel", "mkd", "kaz"},
+	"Greek":      {"ell"},
+	"Arabic":     {"ara", "fas", "urd", "pus"},
+	"Hebrew":     {"heb", "yid"},
+	"Devanagari": {"hin", "mar", "nep", "san"},
+	"Bengali":    {"ben", "asm"},
+	"Tamil":      {"tam"},
+	"Telugu":     {"tel"},
+	"Kannada":    {"kan"},
+	"Malayalam":  {"mal"},
+	"Gujarati":   {"guj"},
+	"Gurmukhi":   {"pan"},
+	"Thai":       {"tha"},
+	"Han":        {"chi_sim", "chi_tra"},
+	"Japanese":   {"jpn"},
+	"Korean":     {"kor"},
+	"Hangul":     {"kor"},
+	"Georgian":   {"kat"},
+	"Armenian":   {"hye"},
+	"Ethiopic":   {"amh"},
+}
+
+// commonWords are frequent short words of the languages that share a
+// script, telling them apart in recognized text.
+var commonWords = map[string][]string{
+	"eng": {"the", "and", "of", "to", "in", "is", "that", "for", "it", "with", "as", "was", "on", "are", "this", "be", "by", "not"},
+	"swa": {"na", "ya", "wa", "kwa", "katika", "ni", "kuwa", "za", "cha", "hiyo", "pia", "hii", "kama", "lakini", "au", "vya"},
+	"fra": {"le", "les", "de", "des", "et", "est", "pour", "dans", "une", "que", "qui", "pas", "sur", "au", "du"},
+	"deu": {"der", "die", "und", "das", "ist", "nicht", "ein", "eine", "mit", "den", "von", "zu", "sich", "auf", "dem"},
+	"spa": {"el", "los", "las", "de", "que", "y", "en", "es", "por", "una", "para", "con", "del", "se"},
+	"por": {"o", "os", "as", "de", "que", "e", "do", "da", "em", "para", "com", "uma", "não", "dos"},
+	"ita": {"il", "che", "di", "le", "per", "una", "sono", "non", "gli", "del", "della", "con", "è"},
+	"nld": {"de", "het", "een", "en", "van", "is", "dat", "niet", "op", "te", "voor", "met", "zijn"},
+	"rus": {"и", "в", "не", "на", "что", "с", "по", "как", "это", "он", "к", "из"},
+	"ukr": {"і", "в", "не", "на", "що", "з", "та", "як", "це", "до", "від", "у"},
+}
+
+// validateLanguages checks config.Language, one language or several joined
+// with "+" as in "eng+swa", against the installed traineddata when the
+// engine can list it.
+func validateLanguages(config OCRConfig, engine Engine) error {
+	_, osd := engine.(osdEngine)
+	if config.AutoLanguage && !osd {
+		return fmt.Errorf("OCR engine %s does not detect the script of pages", engine.Name())
+	}
+	if config.Language == "" {
+		return nil
+	}
+	langs := strings.Split(config.Language, "+")
+	for _, l := range langs {
+		if l == "" {
+			return fmt.Errorf("invalid language %q (use traineddata names joined by +, e.g. eng+swa)", config.Language)
+		}
+	}
+	installed, err := installedLanguages(engine, config)
+	if err != nil {
+		if config.AutoLanguage {
+			return fmt.Errorf("automatic language detection needs the installed languages: %w", err)
+		}
+		// Recognition reports a missing language
+		return nil
+	}
+	for _, l := range langs {
+		if !slices.Contains(installed, l) {
+			return fmt.Errorf("traineddata for language %q is not installed (installed: %s)", l, strings.Join(installed, " "))
+		}
+	}
+	return nil
+}
+
+// installedLanguages lists the languages of the traineddata files in
+// config.TessdataDir, or those the engine reports without one.
+func installedLanguages(engine Engine, config OCRConfig) ([]string, error) {
+	if config.TessdataDir != "" {
+		paths, err := filepath.Glob(filepath.Join(config.TessdataDir, "*.traineddata"))
+		if err != nil || len(paths) == 0 {
+			return nil, fmt.Errorf("no traineddata files in %s", config.TessdataDir)
+		}
+		langs := make([]string, len(paths))
+		for i, p := range paths {
+			langs[i] = strings.TrimSuffix(filepath.Base(p), ".traineddata")
+		}
+		sort.Strings(langs)
+		return langs, nil
+	}
+	lister, ok := engine.(languageLister)
+	if !ok {
+		return nil, fmt.Errorf("OCR engine %s cannot list its languages", engine.Name())
+	}
+	langs, err := lister.Languages()
+	if err == nil && len(langs) == 0 {
+		err = fmt.Errorf("no traineddata files in %s", os.Getenv("TESSDATA_PREFIX"))
+	}
+	return langs, err
+}
+
+// pageLanguage picks the languages to recognize a page in with
+// config.AutoLanguage, from the script found by OSD: the only installed
+// language written in it, or else the language whose common words are
+// frequent in a first recognition of the page, together with a second one
+// close behind on mixed pages. With several languages in config.Language,
+// only those are candidates. It returns "" to keep config.Language.
+func pageLanguage(img image.Image, pageNum int, osd pageOSD, engine Engine, config OCRConfig) string {
+	if !config.AutoLanguage || osd.Script == "" {
+		return ""
+	}
+	var candidates []string
+	listed := strings.Split(config.Language, "+")
+	for _, l := range scriptLanguages[osd.Script] {
+		if slices.Contains(config.languages, l) && (len(listed) < 2 || slices.Contains(listed, l)) {
+			candidates = append(candidates, l)
+		}
+	}
+	lang := ""
+	switch len(candidates) {
+	case 0:
+		return ""
+	case 1:
+		lang = candidates[0]
+	default:
+		first := config
+		if !slices.ContainsFunc(listed, func(l string) bool { return slices.Contains(candidates, l) }) {
+			first.Language = candidates[0]
+		}
+		text, err := engine.Text(img, first, PageOCROptions{})
+		if err != nil {
+			return ""
+		}
+		if lang = detectLanguage(text, candidates); lang == "" {
+			lang = first.Language
+		}
+	}
+	if lang != config.Language {
+		config.debugf("Page %d: %s script, recognizing as %s\n", pageNum+1, osd.Script, lang)
+	}
+	return lang
+}
+
+// detectLanguage returns the candidate language with the most common words
+// in text, joined with the runner-up when it has at least half as many, or
+// "" when none has minLanguageHits.
+func detectLanguage(text string, candidates []string) string {
+	counts := map[string]int{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		counts[w]++
+	}
+	return rankLanguages(counts, candidates)
+}
+
+// rankLanguages is detectLanguage for the lower-case words of a text with
+// the number of times each occurs.
+func rankLanguages(counts map[string]int, candidates []string) string {
+	hits := map[string]int{}
+	for w, n := range counts {
+		for _, l := range candidates {
+			if slices.Contains(commonWords[l], w) {
+				hits[l] += n
+			}
+		}
+	}
+	ranked := slices.Clone(candidates)
+	sort.SliceStable(ranked, func(i, j int) bool { return hits[ranked[i]] > hits[ranked[j]] })
+	if hits[ranked[0]] < minLanguageHits {
+		return ""
+	}
+	if len(ranked) > 1 && hits[ranked[1]] >= minLanguageHits && 2*hits[ranked[1]] >= hits[ranked[0]] {
+		return ranked[0] + "+" + ranked[1]
+	}
+	return ranked[0]
+}
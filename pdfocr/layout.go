@@ -0,0 +1,231 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Kinds of the regions of a page recognized with OCRConfig.TwoPass, which
+// OCRConfig.OnlyRegions selects.
+const (
+	RegionBodyText = "body-text" // paragraphs, recognized as a single block
+	RegionTable    = "table"     // rows of aligned cells, recognized word by word
+	RegionSparse   = "sparse"    // scattered words such as form fields
+)
+
+// ParseRegionKinds reads a comma-separated list of region kinds for -only.
+func ParseRegionKinds(list string) ([]string, error) {
+	var kinds []string
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		switch kind {
+		case "":
+			continue
+		case RegionBodyText, RegionTable, RegionSparse:
+			kinds = append(kinds, kind)
+		default:
+			return nil, fmt.Errorf("unknown region kind %q (use body-text, table or sparse)", kind)
+		}
+	}
+	return kinds, nil
+}
+
+// layoutSparseWords is the mean number of words per line below which a
+// block of lines is sparse text rather than prose.
+const layoutSparseWords = 3
+
+// layoutRegion is a region of a page image found by the layout pass.
+type layoutRegion struct {
+	kind string
+	box  image.Rectangle
+}
+
+// imageTablePieces returns words recognized on a page image as table
+// pieces, in pixels.
+func imageTablePieces(words []OCRWord) []tablePiece {
+	pieces := make([]tablePiece, 0, len(words))
+	for _, w := range words {
+		b := w.Box
+		pieces = append(pieces, tablePiece{w.Text, float64(b.Min.X), float64(b.Min.Y), float64(b.Max.X), float64(b.Max.Y)})
+	}
+	return pieces
+}
+
+// rowRegion is a region of a page as rows of pieces.
+type rowRegion struct {
+	kind string
+	rows []tableRow
+}
+
+// rowRegions segments the rows of a page, top to bottom: tables as
+// detectTables finds them, and between them blocks of rows separated by
+// wide vertical gaps, which are sparse when their rows hold few words or
+// several cells.
+func rowRegions(rows []tableRow) []rowRegion {
+	var regions []rowRegion
+	addBlock := func(block []tableRow) {
+		if len(block) == 0 {
+			return
+		}
+		cells, words := 0, 0
+		for _, r := range block {
+			cells += len(r.cells)
+			for _, c := range r.cells {
+				words += c.words
+			}
+		}
+		kind := RegionBodyText
+		if cells > len(block) || words < layoutSparseWords*len(block) {
+			kind = RegionSparse
+		}
+		regions = append(regions, rowRegion{kind, block})
+	}
+
+	runs := tableRuns(rows)
+	var block []tableRow
+	for i := 0; i < len(rows); {
+		if len(runs) > 0 && runs[0].start == i {
+			addBlock(block)
+			block = nil
+			regions = append(regions, rowRegion{RegionTable, rows[i:runs[0].end]})
+			i, runs = runs[0].end, runs[1:]
+			continue
+		}
+		if n := len(block); n > 0 && rows[i].y0-block[n-1].y1 > tableRowGap*math.Max(rows[i].height, block[n-1].height) {
+			addBlock(block)
+			block = nil
+		}
+		block = append(block, rows[i])
+		i++
+	}
+	addBlock(block)
+	return regions
+}
+
+// layoutRegions segments a page image from the words of a layout pass, as
+// rowRegions does, into boxes within bounds.
+func layoutRegions(words []OCRWord, bounds image.Rectangle) []layoutRegion {
+	var regions []layoutRegion
+	for _, r := range rowRegions(tableRows(imageTablePieces(words))) {
+		x0, x1, height := math.Inf(1), math.Inf(-1), 0.0
+		for _, row := range r.rows {
+			x0, x1 = math.Min(x0, row.cells[0].x0), math.Max(x1, row.cells[len(row.cells)-1].x1)
+			height = math.Max(height, row.height)
+		}
+		// A margin of half a line keeps the strokes at the edges
+		pad := height / 2
+		box := image.Rect(int(x0-pad), int(r.rows[0].y0-pad), int(math.Ceil(x1+pad)), int(math.Ceil(r.rows[len(r.rows)-1].y1+pad))).Intersect(bounds)
+		if !box.Empty() {
+			regions = append(regions, layoutRegion{r.kind, box})
+		}
+	}
+	return regions
+}
+
+// onlyRegionsText returns the text of the lines of a text layer that lie
+// in regions of the kinds, one row per line with the cells of tables
+// separated by tabs and the regions by blank lines.
+func onlyRegionsText(lines []TextLine, kinds []string) string {
+	var parts []string
+	for _, r := range rowRegions(tableRows(lineTablePieces(lines))) {
+		if !slices.Contains(kinds, r.kind) {
+			continue
+		}
+		sep := " "
+		if r.kind == RegionTable {
+			sep = "\t"
+		}
+		rows := make([]string, len(r.rows))
+		for i, row := range r.rows {
+			cells := make([]string, len(row.cells))
+			for j, c := range row.cells {
+				cells[j] = c.text
+			}
+			rows[i] = strings.Join(cells, sep)
+		}
+		parts = append(parts, strings.Join(rows, "\n"))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// cropImage copies the area r of img into an image of its own.
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	crop := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(crop, crop.Bounds(), img, r.Min, draw.Src)
+	return crop
+}
+
+// twoPassText recognizes a page image in two passes: a layout pass
+// locating its regions (layoutRegions), then a recognition of every region
+// with settings for its kind. Body text is read as a single block, sparse
+// regions as sparse text, and tables word by word into rows with their
+// cells separated by tabs. Regions are separated by blank lines; with
+// config.OnlyRegions those of other kinds are left out unread.
+func twoPassText(img image.Image, we wordEngine, engine Engine, config OCRConfig, opts PageOCROptions) (string, error) {
+	words, err := we.Words(img, config, opts)
+	if err != nil {
+		return "", err
+	}
+	regions := layoutRegions(words, img.Bounds())
+	if len(regions) == 0 && len(config.OnlyRegions) == 0 {
+		return engine.Text(img, config, opts)
+	}
+
+	var parts []string
+	for _, r := range regions {
+		if len(config.OnlyRegions) > 0 && !slices.Contains(config.OnlyRegions, r.kind) {
+			continue
+		}
+		crop := cropImage(img, r.box)
+		var text string
+		switch r.kind {
+		case RegionTable:
+			text, err = tableText(crop, we, config, opts)
+		case RegionSparse:
+			sparse := opts
+			sparse.SparseText = true
+			text, err = engine.Text(crop, config, sparse)
+		default:
+			block := opts
+			block.SingleBlock = true
+			text, err = engine.Text(crop, config, block)
+		}
+		if err != nil {
+			return "", err
+		}
+		if text = strings.TrimSpace(text); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// tableText recognizes the words of a table region as sparse text and lays
+// them out in rows, one line per row with tabs between the cells.
+func tableText(img image.Image, we wordEngine, config OCRConfig, opts PageOCROptions) (string, error) {
+	opts.SparseText = true
+	words, err := we.Words(img, config, opts)
+	if err != nil {
+		return "", err
+	}
+	rows := tableRows(imageTablePieces(words))
+	var lines []string
+	if t, ok := buildTable(rows); ok {
+		for _, row := range t.Rows {
+			lines = append(lines, strings.Join(row, "\t"))
+		}
+	} else {
+		for _, r := range rows {
+			cells := make([]string, len(r.cells))
+			for i, c := range r.cells {
+				cells[i] = c.text
+			}
+			lines = append(lines, strings.Join(cells, "\t"))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,98 @@
+package pdfocr_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"ocr-tool/pdfocr"
+	"ocr-tool/pdfocr/testsupport"
+)
+
+// openFDs returns the number of open file descriptors of the process.
+func openFDs(t *testing.T) int {
+	t.Helper()
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip("descriptor count needs /proc/self/fd")
+	}
+	return len(entries)
+}
+
+// TestNoLeakedHandles runs extractions that succeed and extractions that
+// fail on their error branches many times over, as a long batch run does,
+// and checks that every document and file they open is closed again.
+func TestNoLeakedHandles(t *testing.T) {
+	dir := t.TempDir()
+	pdfPath := filepath.Join(dir, "fixture.pdf")
+	// Small pages keep the renders cheap
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{Text: nativeText, Width: 144, Height: 144},
+		{OCR: "Scanned", Width: 144, Height: 144},
+		{OCR: "Unreadable", RenderError: "decoder failure"},
+	}}
+	if err := os.WriteFile(pdfPath, fixture.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	notDir := filepath.Join(dir, "not-a-directory")
+	if err := os.WriteFile(notDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	config := testsupport.Config()
+	config.Workers = 3
+	ex, err := pdfocr.NewExtractor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scenarios := []struct {
+		name    string
+		run     func() error
+		wantErr bool
+	}{
+		{"extract", func() error {
+			_, _, err := ex.ExtractDocument(context.Background(), pdfPath)
+			return err
+		}, false},
+		{"canceled", func() error {
+			_, _, err := ex.ExtractDocument(canceled, pdfPath)
+			return err
+		}, true},
+		{"unreadable document", func() error {
+			_, _, err := ex.ExtractData(context.Background(), []byte("not a document"), "broken.pdf")
+			return err
+		}, true},
+		{"missing file", func() error {
+			_, _, err := ex.ExtractDocument(context.Background(), filepath.Join(dir, "missing.pdf"))
+			return err
+		}, true},
+		{"images", func() error {
+			return ex.ExtractImages(pdfPath, filepath.Join(dir, "images"))
+		}, false},
+		{"images into a file", func() error {
+			return ex.ExtractImages(pdfPath, notDir)
+		}, true},
+	}
+
+	// The first round opens what the runtime keeps open once used
+	for _, s := range scenarios {
+		s.run()
+	}
+	before := openFDs(t)
+	for i := 0; i < 10; i++ {
+		for _, s := range scenarios {
+			if err := s.run(); (err != nil) != s.wantErr {
+				t.Fatalf("%s: error %v, want error %v", s.name, err, s.wantErr)
+			}
+			if n := testsupport.OpenDocuments(); n != 0 {
+				t.Fatalf("%s: %d documents left open", s.name, n)
+			}
+		}
+	}
+	if after := openFDs(t); after > before {
+		t.Errorf("%d file descriptors open after the runs, %d before", after, before)
+	}
+}
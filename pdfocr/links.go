@@ -0,0 +1,114 @@
+package pdfocr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Link is a hyperlink found in a document, either as a link annotation with
+// a URI action or as a URL printed in OCR text.
+type Link struct {
+	Page   int    `json:"page"` // 1-based
+	URI    string `json:"uri"`
+	Source string `json:"source"` // "annotation" or "text"
+}
+
+// pageLinks returns the URIs of the link annotations on a page.
+func (pdf *rawPDF) pageLinks(pageNum int) []string {
+	page := pdf.page(pageNum)
+	if page == nil {
+		return nil
+	}
+	var out []string
+	for _, ref := range pdf.array(page["Annots"]) {
+		annot := pdf.dict(ref)
+		if pdf.name(annot["Subtype"]) != "Link" {
+			continue
+		}
+		action := pdf.dict(annot["A"])
+		if pdf.name(action["S"]) != "URI" {
+			continue
+		}
+		// URIs are 7-bit ASCII (or UTF-8 in practice), not text strings
+		if uri, ok := pdf.resolve(action["URI"]).(pdfString); ok && len(uri) > 0 {
+			out = append(out, strings.TrimSpace(string(uri)))
+		}
+	}
+	return out
+}
+
+var (
+	// urlRe matches http(s) and www URLs in running text.
+	urlRe = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+	// urlAtLineEndRe matches a URL that runs to the end of a line.
+	urlAtLineEndRe = regexp.MustCompile(`(?i)(?:https?://|www\.)[^\s<>"]*$`)
+
+	// urlContinuationRe matches a following line that starts with something
+	// that can only be the rest of a URL: a path, query or fragment
+	// character, or a token with a slash, dot-separated host part or query
+	// inside it.
+	urlContinuationRe = regexp.MustCompile(`^(?:[/?#&=%~_.-][^\s<>"]*|[A-Za-z0-9-]*[/=&?#][^\s<>"]*|[A-Za-z0-9-]+\.[A-Za-z]{2,}[^\s<>"]*)`)
+)
+
+// urlTrailingPunct is stripped from detected URLs, since sentence
+// punctuation usually follows rather than belongs to them.
+const urlTrailingPunct = ".,;:!?)]}'\""
+
+// repairWrappedURLs rejoins URLs that OCR split across lines. A line break
+// is removed when a line ends inside a URL and the next line starts with
+// text that continues it.
+func repairWrappedURLs(text string) string {
+	lines := strings.Split(text, "\n")
+	out := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for i+1 < len(lines) && urlAtLineEndRe.MatchString(strings.TrimRight(line, " \t")) {
+			next := strings.TrimLeft(lines[i+1], " \t")
+			cont := urlContinuationRe.FindString(next)
+			if cont == "" {
+				break
+			}
+			url := urlAtLineEndRe.FindString(strings.TrimRight(line, " \t"))
+			// A URL that already ends like a finished sentence only continues
+			// if the next line is plainly a path or query
+			if strings.ContainsAny(url[len(url)-1:], ",;:!)") && !strings.ContainsAny(cont[:1], "/?#&=") {
+				break
+			}
+			line = strings.TrimRight(line, " \t") + next
+			i++
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// textLinks returns the distinct URLs printed in text.
+func textLinks(text string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, m := range urlRe.FindAllString(text, -1) {
+		m = strings.TrimRight(m, urlTrailingPunct)
+		if len(m) <= len("www.") || seen[m] {
+			continue
+		}
+		seen[m] = true
+		out = append(out, m)
+	}
+	return out
+}
+
+// linksSection formats links as a plain-text section appended to the output.
+func linksSection(links []Link) string {
+	if len(links) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("=== Links ===\n")
+	for _, l := range links {
+		fmt.Fprintf(&b, "page %d: %s\n", l.Page, l.URI)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
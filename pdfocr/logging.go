@@ -0,0 +1,57 @@
+package pdfocr
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// logger is the logger set by SetLogger, nil for slog.Default().
+var logger *slog.Logger
+
+// SetLogger sets the logger of the OCRConfigs without a Logger and of the
+// messages outside of a document, such as the warnings of sandboxes and
+// upload scanners; nil restores slog.Default(). Programs call it before
+// starting extractions.
+func SetLogger(l *slog.Logger) {
+	logger = l
+}
+
+// defaultLogger is the logger of OCRConfigs without a Logger.
+func defaultLogger() *slog.Logger {
+	if logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+func (config *OCRConfig) logger() *slog.Logger {
+	if config.Logger != nil {
+		return config.Logger
+	}
+	return defaultLogger()
+}
+
+// logf logs a progress message of the pipeline, translated with tr; debugf
+// logs details for -v.
+func (config *OCRConfig) logf(format string, a ...any) {
+	logMessage(config.logger(), slog.LevelInfo, fmt.Sprintf(tr(format), a...))
+}
+
+func (config *OCRConfig) debugf(format string, a ...any) {
+	logMessage(config.logger(), slog.LevelDebug, fmt.Sprintf(tr(format), a...))
+}
+
+// warnf logs a warning that concerns no document to the default logger.
+func warnf(format string, a ...any) {
+	logMessage(defaultLogger(), slog.LevelWarn, fmt.Sprintf(tr(format), a...))
+}
+
+// logMessage logs a message at level without its surrounding newlines.
+func logMessage(l *slog.Logger, level slog.Level, message string, attrs ...slog.Attr) {
+	ctx := context.Background()
+	if l.Enabled(ctx, level) {
+		l.LogAttrs(ctx, level, strings.Trim(message, "\n"), attrs...)
+	}
+}
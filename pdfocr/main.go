@@ -0,0 +1,924 @@
+package pdfocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type OCRConfig struct {
+	Language       string
+	DPI            float64
+	TessdataDir    string // traineddata directory ("" uses the engine default)
+	OCRThreads     int    // OpenMP threads per Tesseract recognition (0 leaves OMP_THREAD_LIMIT alone)
+	TessClients    int    // idle in-process Tesseract clients kept for reuse per language (0 for one per worker)
+	MaxCPUs        int    // CPUs a run may use (0 for all)
+	Workers        int    // pages processed in parallel (0 or 1 for one at a time)
+	OutputFile     string
+	ManifestFile   string
+	PagesDir       string // directory for index.json and one JSON file per page
+	METSDir        string // directory for a METS package of page images and texts
+	IIIFDir        string // directory for page images and a IIIF manifest
+	IIIFBaseURL    string // URL IIIFDir is served from
+	TablesDir      string // directory for one CSV file per table found, with Tables
+	XFAOutputFile  string
+	EncryptKey     []byte // AES-256 key for the output files (nil writes plaintext)
+	NoDisk         bool   // keep all data in memory and write only to stdout
+	PreserveLayout bool
+	Encoding       string
+
+	// Tesseract settings of every recognition: the page segmentation mode
+	// (1-13, 0 for the pipeline's choice), which the modes the pipeline
+	// picks for regions and single lines override, the OCR engine mode
+	// (OEMLegacy, OEMLSTM, OEMLegacyLSTM or OEMDefault; "" for the
+	// traineddata's default) and variables such as
+	// tessedit_char_whitelist=0123456789. Cloud engines ignore them.
+	PSM        int
+	OEM        string
+	TessParams map[string]string
+
+	// Output whitespace normalization
+	Newline            string
+	TabWidth           int
+	CollapseBlankLines bool
+	TrimTrailingSpace  bool
+
+	// Which pages are OCR'd: "" or OCRModeAuto, OCRModeForce or
+	// OCRModeNever
+	OCRMode string
+
+	// Handling of vector-only pages (e.g. CAD drawings) without a text layer
+	VectorPages string
+	VectorDPI   float64
+
+	// Embedded file attachments: "" (ignore), "list" or "process"
+	Attachments string
+
+	// Handling of pages that fail: "" or ErrorPolicyCollect,
+	// ErrorPolicySkip or ErrorPolicyFailFast
+	ErrorPolicy string
+
+	// Treat blank renders of pages with JBIG2/CCITT images as decode failures
+	RobustDecode bool
+
+	// ExtractImagesFromPDF output: the embedded images at least
+	// MinImageWidth x MinImageHeight pixels, in their own format ("") or
+	// converted to ImageFormatPNG or ImageFormatJPEG, or with
+	// RenderPageImages one render of each page
+	RenderPageImages bool
+	ImageFormat      string
+	MinImageWidth    int
+	MinImageHeight   int
+
+	// Record the positions and confidences of the words of OCR'd pages in
+	// PageResult.Words (costs a word recognition pass per page)
+	WordBoxes bool
+
+	// Keep the engine's hOCR of OCR'd pages for DocumentHOCR (costs a
+	// recognition pass per page)
+	HOCR bool
+
+	// Read printed page numbers into PageResult.Label and check them
+	// against the page order in DocumentManifest.LabelIssues
+	PageLabels bool
+
+	// Find where the documents of a batch scan begin, for
+	// DocumentManifest.Documents (renders every page at fingerprintDPI)
+	DetectDocuments bool
+
+	// Put the pages of documents scanned in the wrong order (see
+	// checkCollation) in reading order; without it the mistake is only
+	// reported in DocumentManifest.Collation
+	Recollate bool
+
+	// Detect headings on OCR'd pages for DocumentManifest.Outline (costs a
+	// word recognition pass per page)
+	Outline bool
+
+	// Recognize OCR'd pages in two passes: a layout pass locating their
+	// text, table and sparse regions, then a recognition of every region
+	// with settings for its kind (see twoPassText)
+	TwoPass bool
+
+	// With TwoPass, keep only the text of the regions of these kinds
+	// (RegionBodyText, RegionTable, RegionSparse), on text layer pages too
+	OnlyRegions []string
+
+	// Keep the line heights of every page for the headings of
+	// DocumentMarkdown (costs a word recognition pass per OCR'd page)
+	Markdown bool
+
+	// Detect the orientation of every page image and turn it upright
+	// before OCR (see orientPage)
+	AutoRotate bool
+
+	// Pick the languages of every OCR'd page from its script and a first
+	// recognition (see pageLanguage)
+	AutoLanguage bool
+	languages    []string // installed, with AutoLanguage
+
+	// Page image preprocessing: "" or PreprocessNone, PreprocessAuto (chosen
+	// per page from its noise, skew and contrast) or a comma-separated list
+	// of steps applied to every page
+	Preprocess string
+
+	// Enlarges scans below minScanDPI before OCR (nil uses bicubic interpolation)
+	Upscaler Upscaler
+
+	// Rendering backend and OCR engine names ("" selects the build default)
+	Renderer string
+	Engine   string
+	// Models of the ONNX Runtime engines (gpu, onnx), and the directory
+	// those given by URL are fetched to ("" for the user cache directory)
+	ONNXModels ONNXModels
+	ModelCache string
+
+	// Pages a cloud engine keeps in flight: services taking several
+	// images per request (google-vision) get them in batches of up to
+	// this many, and the worker pool grows to twice as many workers so
+	// the next batch renders and uploads while one is recognized (0 sends
+	// one page per request from config.Workers workers)
+	CloudBatch int
+
+	// Directory queuing the OCR'd pages a cloud engine cannot be reached
+	// for: they are recognized with DefaultEngine meanwhile, marked
+	// PageResult.Provisional, and SyncQueue sends them once the service is
+	// back, updating them in OutputFile ("" fails such pages)
+	OfflineQueue string
+
+	// Recognize OCR'd pages in several voting passes, retrying poor pages
+	// at a higher resolution, and correct doubtful words against
+	// Dictionary (see ApplyBest)
+	Best       bool
+	Dictionary map[string]bool // lower-case words
+
+	// Skip OCR of blank pages and of pages that repeat a recent one (see
+	// ApplyFast)
+	Fast   bool
+	dedupe *pageDeduper // shared by the workers of a document
+
+	// Settings bundle for a kind of document, e.g. PresetChart
+	Preset string
+
+	// Append a section listing the document's hyperlinks to the text
+	Links bool
+
+	// Source locale for normalizing amounts and dates to ISO formats ("" disables)
+	NormalizeLocale string
+
+	// Also OCR pages that have a text layer and merge both line by line
+	MergeNative bool
+
+	// Directory for page images with word boxes colored by confidence
+	DebugOverlayDir string
+
+	// Add the text of stamp and free-text annotations, OCR'ing their appearance
+	Annotations bool
+
+	// Render a small PNG of every page, about 200 pixels wide, in
+	// PageResult.Thumbnail
+	Thumbnails bool
+
+	// Page areas left out of OCR and text output, e.g. letterheads, and
+	// templates whose regions apply to the pages matching their fingerprint
+	IgnoreRegions   []IgnoreRegion
+	RegionTemplates []RegionTemplate
+
+	// Per engine and language mapping of raw word confidences
+	Calibration Calibration
+
+	// Rewrite OutputFile with the pages so far every FlushEvery pages (0
+	// disables): their text, or with OutputFormat (FormatJSON, FormatHOCR,
+	// FormatALTO, FormatMarkdown or FormatTSV) a document in that format
+	// with DocumentManifest.Partial set
+	FlushEvery int
+	// Format OutputFile is written in, for FlushEvery and for SyncQueue to
+	// update the provisional pages of text and JSON outputs
+	OutputFormat string
+
+	// Pages to process (the zero value selects all); attachments are
+	// processed whole
+	PageSelection PageSet
+
+	// Mean word confidence (0-100) below which an OCR'd page is recognized
+	// again and, if it stays below, flagged for review in
+	// PageResult.LowConfidence (0 disables; costs a word recognition pass
+	// per page)
+	MinConfidence float64
+
+	// Find tables on every page, from the text layer lines or the OCR'd
+	// word positions, in PageResult.Tables (costs a word recognition pass
+	// per OCR'd page)
+	Tables bool
+
+	// Stop every document at the first page mentioning one of these terms,
+	// whole words in any case, reporting it in DocumentManifest.Keyword
+	Keywords []string
+
+	// Count the words, sentences and terms of every document and estimate
+	// its language and readability, in DocumentManifest.Stats
+	Stats bool
+	stats *textStatsCounter // of the document being processed
+
+	// Stop after this many pages or this long, keeping partial results (0 disables)
+	MaxPages    int
+	MaxDuration time.Duration
+	budget      *processingBudget // shared with sub-documents, set for the top-level document
+
+	// Receives the progress of every page in place of the progress messages
+	// (nil logs them)
+	Progress ProgressFunc
+	progress *progressReporter // of the document being processed
+
+	// Receives the warnings of every document in place of Logger (nil logs
+	// them); they are also listed in DocumentManifest.Warnings
+	Warnings WarningFunc
+	warnings *warningLog // of the document being processed
+
+	// Receives the progress messages and warnings of the pipeline, with
+	// details at level Debug (nil logs to slog.Default(), or on the command
+	// line to its -log-format and -quiet/-v logger)
+	Logger *slog.Logger
+
+	// Receives every page of the top-level document as soon as it is done,
+	// in page order; an error stops the extraction with it
+	Pages      PageFunc
+	stream     io.Writer                    // receives the text of the pages in place of the returned text, set by ExtractTo
+	trackPages func(page int, running bool) // told as every page of the top-level document starts and ends, set by sandbox workers
+}
+
+// PageOCROptions overrides rendering and recognition settings for one page;
+// engines receive it with every image.
+type PageOCROptions struct {
+	DPI         float64 // render resolution; 0 uses the renderer default
+	SparseText  bool    // use Tesseract's sparse text segmentation
+	SingleLine  bool    // treat the image as a single line of text
+	SingleBlock bool    // treat the image as a single uniform block of text
+	Whitelist   string  // restrict recognized characters
+	Labels      bool    // output words grouped into labels by proximity
+	Rotation    int     // clockwise degrees the page image was turned upright, set by ocrPage
+}
+
+// ExtractTextFromPDF extracts text from PDF files, including scanned PDFs using OCR.
+// Canceling ctx stops the extraction before the next page; the text of the
+// pages done so far is returned along with an error wrapping ctx.Err().
+func ExtractTextFromPDF(ctx context.Context, pdfPath string, config OCRConfig) (string, error) {
+	text, _, err := ExtractDocument(ctx, pdfPath, config)
+	return text, err
+}
+
+// ExtractDocument extracts text like ExtractTextFromPDF and also returns a
+// manifest of the document, including embedded attachments and portfolio
+// members. After a cancellation the manifest is marked truncated.
+func ExtractDocument(ctx context.Context, pdfPath string, config OCRConfig) (string, DocumentManifest, error) {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return "", DocumentManifest{}, fmt.Errorf("error reading PDF: %w", err)
+	}
+	return extractPDFData(ctx, data, filepath.Base(pdfPath), config, 0)
+}
+
+// ExtractTo extracts text like ExtractDocument but writes the text of every
+// page to w as soon as the page is done, so memory stays flat however long
+// the document is; links, form data and attachments follow the pages as in
+// the returned text of ExtractDocument. The pages of the manifest are left
+// without their text and words, which only config.Pages receives.
+// Recollation and document detection need the whole text and are refused,
+// and the page order is not checked.
+func ExtractTo(ctx context.Context, w io.Writer, pdfPath string, config OCRConfig) (DocumentManifest, error) {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return DocumentManifest{}, fmt.Errorf("error reading PDF: %w", err)
+	}
+	return extractPDFDataTo(ctx, w, data, filepath.Base(pdfPath), config)
+}
+
+// extractPDFDataTo is ExtractTo for an in-memory PDF.
+func extractPDFDataTo(ctx context.Context, w io.Writer, data []byte, name string, config OCRConfig) (DocumentManifest, error) {
+	if config.Recollate || config.DetectDocuments {
+		return DocumentManifest{}, fmt.Errorf("recollation and document detection need the whole text and cannot stream it")
+	}
+	config.stream = w
+	rest, manifest, err := extractPDFData(ctx, data, name, config, 0)
+	if _, werr := io.WriteString(w, rest); werr != nil && err == nil {
+		err = fmt.Errorf("error writing text: %w", werr)
+	}
+	return manifest, err
+}
+
+// extractPDFData extracts the text of an in-memory PDF. Portfolio members are
+// always processed; other attachments follow config.Attachments. depth counts
+// the levels of embedding above this document.
+func extractPDFData(ctx context.Context, data []byte, name string, config OCRConfig, depth int) (text string, manifest DocumentManifest, err error) {
+	manifest = DocumentManifest{Name: name, Size: len(data)}
+	if depth > 0 {
+		// Only the top-level document owns the output file and the
+		// page stream
+		config.FlushEvery = 0
+		config.Pages, config.stream, config.trackPages = nil, nil, nil
+	} else if config.budget == nil {
+		config.budget = newProcessingBudget(config.MaxPages, config.MaxDuration)
+	}
+
+	if config.Fast {
+		config.dedupe = &pageDeduper{}
+	}
+	if config.AutoLanguage && config.languages == nil {
+		if engine, err := lookupEngine(config.Engine); err == nil {
+			config.languages, _ = installedLanguages(engine, config)
+		}
+	}
+
+	config.warnings = newWarningLog(&config, name)
+	if config.Stats {
+		config.stats = newTextStatsCounter()
+	}
+	defer func() { manifest.Warnings = config.warnings.list() }()
+
+	// Open the PDF document
+	src, err := openPDFSource(data, name, config.Renderer)
+	if err != nil {
+		return "", manifest, err
+	}
+	defer src.Close()
+	src.warnings = config.warnings
+	src.noScratch = config.EncryptKey != nil || config.NoDisk
+	manifest.Pages = src.doc.NumPage()
+	manifest.MIMEType = src.mediaType
+	if !config.PageSelection.All() {
+		manifest.Selection = config.PageSelection.String()
+	}
+
+	raw := src.raw
+	if raw == nil && config.Attachments != "" {
+		config.warnings.warnf(-1, "Warning: could not read PDF structure of %s\n", name)
+	}
+	if raw != nil && raw.isPortfolio() {
+		manifest.Portfolio = true
+		config.logf("%s is a PDF portfolio, processing its member documents\n", name)
+	}
+
+	var xfaData []string
+	if raw != nil {
+		xfaData = inspectXFA(raw, name, config, depth, &manifest)
+	}
+
+	text, pages, truncated, err := extractDocumentText(ctx, src, config)
+	manifest.Truncated = truncated
+	manifest.PageResults = pages
+	if err != nil {
+		// Canceled runs keep the text of the pages done
+		return text, manifest, err
+	}
+	// Links are link annotations on every page, and URLs printed in the
+	// text of OCR pages
+	for _, p := range pages {
+		manifest.Links = append(manifest.Links, p.Links...)
+	}
+	if config.Outline {
+		var lines []headingLine
+		for _, p := range pages {
+			lines = append(lines, p.lines...)
+		}
+		manifest.Outline = buildOutline(lines)
+	}
+	if len(config.Keywords) > 0 {
+		manifest.Keyword = keywordMatch(pages)
+	}
+	// Checking the page order takes the whole document, and its text
+	if !truncated && config.PageSelection.All() && config.stream == nil && manifest.Keyword == nil {
+		if c := checkCollation(pages); c != nil {
+			if config.Recollate {
+				config.logf("Reordering the pages of %s: %s\n", name, c)
+				pages = recollate(pages, c)
+				manifest.PageResults = pages
+				var b strings.Builder
+				for _, p := range pages {
+					b.WriteString(p.section())
+					b.WriteString(p.annotations)
+				}
+				text = b.String()
+				c.Applied = true
+			} else {
+				config.warnings.warnf(-1, "Warning: %s: %s (-recollate reorders them)\n", name, c)
+			}
+			manifest.Collation = c
+		}
+	}
+	if config.MinConfidence > 0 {
+		manifest.ReviewPages = lowConfidencePages(pages)
+	}
+	manifest.Stats = config.stats.stats()
+	if config.PageLabels && config.PageSelection.All() {
+		manifest.LabelIssues = checkPageLabels(pages)
+		for _, issue := range manifest.LabelIssues {
+			config.warnings.warnf(issue.Page-1, "Warning: %s: %s\n", name, issue)
+		}
+	}
+	if config.DetectDocuments && len(pages) > 0 {
+		manifest.Documents = detectDocuments(src, pages, config)
+		config.logf("Found %d document(s) in %s\n", len(manifest.Documents), name)
+		for i, d := range manifest.Documents {
+			config.logf("  Document %d: %s\n", i+1, d)
+		}
+	}
+	if config.Links {
+		text += linksSection(manifest.Links)
+	}
+
+	if len(xfaData) > 0 {
+		text += "=== XFA form data ===\n" + strings.Join(xfaData, "\n") + "\n\n"
+	}
+
+	if raw != nil && (manifest.Portfolio || config.Attachments != "") && depth < maxAttachmentDepth {
+		process := config.Attachments == AttachmentsProcess ||
+			(manifest.Portfolio && config.Attachments != AttachmentsList)
+		sub, members := extractSubDocuments(ctx, raw, name, config, process, depth)
+		text += sub
+		manifest.Members = members
+		if err := ctx.Err(); err != nil {
+			manifest.Truncated = true
+			return text, manifest, fmt.Errorf("extraction of %s canceled: %w", name, err)
+		}
+	}
+	manifest.Processed = true
+
+	return text, manifest, nil
+}
+
+// extractDocumentText runs the per-page native text / OCR pipeline over the
+// pages of an open document in config.PageSelection and returns its text and
+// the result of every page processed, in page order. With config.Workers above one, pages are processed in
+// parallel by a pagePool.
+// The flag is set when the processing budget ran out or ctx was canceled
+// before the last page; cancellation also returns the partial text with an
+// error.
+func extractDocumentText(ctx context.Context, src *pdfSource, config OCRConfig) (string, []PageResult, bool, error) {
+	pageNums := config.PageSelection.pageNums(src.doc.NumPage())
+	numPages := len(pageNums)
+	config.progress = newProgressReporter(config.Progress, src.name, pageNums)
+	switch {
+	case config.Progress != nil:
+		config.progress.report(StageStart, -1, "")
+	case config.PageSelection.All():
+		config.logf("Processing %d pages from %s\n", numPages, src.name)
+	default:
+		config.logf("Processing %d of %d pages from %s (pages %s)\n", numPages, src.doc.NumPage(), src.name, config.PageSelection)
+	}
+
+	var fullText strings.Builder
+	var pages []PageResult
+	canceled := func(pagesDone int) (string, []PageResult, bool, error) {
+		fullText.WriteString(truncatedNotice("canceled", pagesDone, numPages))
+		return fullText.String(), pages, true, fmt.Errorf("extraction of %s canceled after %d of %d pages: %w", src.name, pagesDone, numPages, ctx.Err())
+	}
+
+	workers := max(config.Workers, 1)
+	if config.CloudBatch > 0 {
+		workers = max(workers, 2*config.CloudBatch)
+	}
+	pool := newPagePool(src, min(workers, numPages), config)
+	defer pool.Close()
+	var pageErr error
+	spotted := false
+	dispatched := pool.run(ctx, pageNums, config, func(o pageOutcome) bool {
+		if o.err != nil {
+			pageErr = o.err
+			return false
+		}
+		o.page.annotations = o.annotations
+		config.progress.report(StageDone, o.pageNum, o.page.Method)
+		if o.page.Method == MethodFailed && config.ErrorPolicy == ErrorPolicySkip {
+			return true
+		}
+		config.stats.add(o.page.Text)
+		if len(config.Keywords) > 0 {
+			o.page.Keyword = findKeyword(o.page.Text, config.Keywords)
+			spotted = o.page.Keyword != ""
+		}
+		if config.Pages != nil {
+			if err := config.Pages(o.page); err != nil {
+				pageErr = err
+				return false
+			}
+		}
+		if o.page.Provisional {
+			src.recordProvisional(o.page, config)
+		}
+		if config.stream != nil {
+			if _, err := io.WriteString(config.stream, o.page.section()+o.annotations); err != nil {
+				pageErr = fmt.Errorf("error writing text: %w", err)
+				return false
+			}
+			o.page = o.page.withoutText()
+		} else {
+			fullText.WriteString(o.page.section())
+			fullText.WriteString(o.annotations)
+		}
+		pages = append(pages, o.page)
+		flushPartialOutput(config, src, pages, fullText.String())
+		return !spotted
+	})
+
+	switch {
+	case pageErr != nil && ctx.Err() != nil:
+		return canceled(len(pages))
+	case pageErr != nil:
+		return "", nil, false, pageErr
+	case spotted:
+		return fullText.String(), pages, false, nil
+	case dispatched < numPages && ctx.Err() != nil:
+		return canceled(len(pages))
+	case dispatched < numPages:
+		config.logf("Stopping: %s\n", config.budget.reason)
+		fullText.WriteString(truncatedNotice(config.budget.reason, len(pages), numPages))
+		return fullText.String(), pages, true, nil
+	}
+	return fullText.String(), pages, false, nil
+}
+
+// extractPageText returns the result of one page. A page whose text layer
+// or OCR fails yields a failed result with an empty section, or its
+// *PageError with ErrorPolicyFailFast.
+func extractPageText(ctx context.Context, src *pdfSource, pageNum int, config OCRConfig) (PageResult, error) {
+	doc := src.doc
+	result := PageResult{Page: pageNum + 1}
+	if src.raw != nil {
+		for _, uri := range src.raw.pageLinks(pageNum) {
+			result.Links = append(result.Links, Link{Page: pageNum + 1, URI: uri, Source: "annotation"})
+		}
+	}
+
+	// First, try to extract text directly (for text-based PDFs)
+	text, err := doc.Text(pageNum)
+	if err != nil {
+		return result, src.failPage(&result, PageErrorText, err, config)
+	}
+	text = src.ignoredText(pageNum, config, text)
+
+	// If text extraction yields substantial text, use it
+	cleanText := cleanNativeText(text)
+	if !src.pageNeedsOCR(pageNum, cleanText, config) {
+		config.progress.report(StageNative, pageNum, "")
+		if config.PageLabels {
+			result.Label = textPageLabel(cleanText)
+		}
+		if config.WordBoxes || config.HOCR || config.Markdown || config.Tables || len(config.OnlyRegions) > 0 {
+			if layout, ok := src.textLayout(pageNum, config); ok {
+				if config.WordBoxes || config.HOCR {
+					result.Width, result.Height = layout.Width, layout.Height
+				}
+				if config.WordBoxes || config.HOCR || config.Markdown {
+					result.layout = layout.Lines
+				}
+				if config.Tables {
+					result.Tables = detectTables(lineTablePieces(layout.Lines))
+				}
+				if len(config.OnlyRegions) > 0 {
+					cleanText = onlyRegionsText(layout.Lines, config.OnlyRegions)
+				}
+			}
+		}
+		if config.MergeNative {
+			words, err := ocrPageWords(ctx, src, pageNum, config, PageOCROptions{})
+			if err != nil && ctx.Err() != nil {
+				return result, err
+			}
+			if err == nil {
+				merged := mergeNativeAndOCR(cleanText, wordLines(words))
+				result.Method, result.Text = MethodMerged, applyBidiMarks(formatMergedLines(merged))
+				if config.WordBoxes {
+					result.Words = pageWords(words, config.DPI)
+				}
+				return result, nil
+			}
+			src.warnings.warnf(pageNum, "Warning: OCR failed for page %d, using its text layer only: %v\n", pageNum+1, err)
+		}
+		result.Method, result.Text = MethodNative, applyBidiMarks(cleanText)
+		return result, nil
+	}
+
+	opts := PageOCROptions{}
+	result.Method = MethodOCR
+	if config.Preset == PresetChart {
+		opts = chartOCROptions()
+		result.Method = MethodOCRChart
+	}
+	if config.Fast {
+		if method, page := src.fastSkip(pageNum, config); method != "" {
+			config.logf("Page %d: %s\n", pageNum+1, method)
+			result.Method, result.DuplicateOf = method, page
+			return result, nil
+		}
+	}
+	if config.VectorPages == VectorPagesDrawing || config.VectorPages == VectorPagesSkip {
+		vector, err := isVectorOnlyPage(doc, pageNum)
+		if err != nil {
+			src.warnings.warnf(pageNum, "Warning: could not analyze page %d: %v\n", pageNum+1, err)
+		}
+		if vector && config.VectorPages == VectorPagesSkip {
+			config.logf("Page %d is a vector drawing, skipping OCR\n", pageNum+1)
+			result.Method = MethodSkippedDrawing
+			return result, nil
+		}
+		if vector {
+			opts = drawingOCROptions(config)
+			result.Method = MethodOCRDrawing
+		}
+	}
+
+	// If no text or minimal text, perform OCR on the page image
+	switch {
+	case config.Progress != nil:
+	case config.OCRMode == OCRModeForce:
+		config.logf("Page %d: performing OCR...\n", pageNum+1)
+	default:
+		config.logf("Page %d has minimal text, performing OCR...\n", pageNum+1)
+	}
+
+	recognized, err := ocrPage(ctx, src, pageNum, config, opts)
+	if err != nil && config.OfflineQueue != "" && errors.Is(err, ErrCloudUnreachable) {
+		if result.QueueID, err = src.queueOffline(pageNum, config, opts); err == nil {
+			config.logf("Page %d: %s is unreachable, queued for later and recognized with %s meanwhile\n", pageNum+1, config.Engine, DefaultEngine)
+			config.Engine, config.CloudBatch = DefaultEngine, 0
+			result.Provisional = true
+			recognized, err = ocrPage(ctx, src, pageNum, config, opts)
+		}
+	}
+	if err != nil && ctx.Err() != nil {
+		return result, err
+	}
+	if err != nil {
+		return result, src.failPage(&result, PageErrorOCR, err, config)
+	}
+	recognized, opts, result.LowConfidence = src.retryLowConfidence(ctx, pageNum, config, opts, recognized)
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	// OCR has no link annotations to fall back on, so recover URLs from the
+	// recognized text
+	ocrText := repairWrappedURLs(recognized.text)
+	for _, uri := range textLinks(ocrText) {
+		result.Links = append(result.Links, Link{Page: pageNum + 1, URI: uri, Source: "text"})
+	}
+
+	result.Text = applyBidiMarks(ocrText)
+	dpi := opts.DPI
+	if dpi == 0 {
+		dpi = config.DPI
+	}
+	result.ScanDPI = math.Round(src.lowScanDPI(pageNum, dpi))
+	result.Preprocessing = recognized.steps
+	result.Rotation = recognized.rotation
+	result.Language = recognized.language
+	result.lines = recognized.lines
+	result.Label = recognized.label
+	result.Confidence = math.Round(recognized.confidence*10) / 10
+	result.Tables = recognized.tables
+	result.ChartLabels = pageChartLabels(recognized.labels, dpi)
+	if config.WordBoxes {
+		result.Words = pageWords(recognized.words, dpi)
+	}
+	result.hocr = recognized.hocr
+	if config.WordBoxes || config.HOCR {
+		result.setSize(recognized.size, dpi)
+	}
+	return result, nil
+}
+
+// pageOCR is the recognition result of a page image.
+type pageOCR struct {
+	text  string
+	steps []string      // preprocessing applied to the image
+	lines []headingLine // with config.Outline or config.Markdown
+	words []OCRWord     // with config.WordBoxes
+	hocr  string        // ocr_page element, with config.HOCR
+	label string        // with config.PageLabels
+	size  image.Point   // of the page image before rotation, in pixels
+
+	confidence float64      // mean word confidence, when the words were recognized
+	tables     []Table      // with config.Tables
+	labels     []ChartLabel // with the chart preset
+
+	rotation int    // clockwise degrees, with config.AutoRotate
+	language string // picked with config.AutoLanguage
+}
+
+// ocrPage performs OCR on a single PDF page. ctx is checked between
+// rendering and recognition; a recognition in progress runs to its end.
+func ocrPage(ctx context.Context, src *pdfSource, pageNum int, config OCRConfig, opts PageOCROptions) (pageOCR, error) {
+	var out pageOCR
+	if opts.DPI == 0 {
+		opts.DPI = config.DPI
+	}
+	// Render page as image
+	config.progress.report(StageRender, pageNum, "")
+	img, err := src.renderForOCR(pageNum, opts.DPI, config)
+	if err != nil {
+		return out, fmt.Errorf("error rendering page image: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return out, err
+	}
+	config.progress.report(StageOCR, pageNum, "")
+	img = src.maskIgnored(img, pageNum, config, opts.DPI)
+	out.size = img.Bounds().Size()
+
+	engine, err := lookupEngine(config.Engine)
+	if err != nil {
+		return out, err
+	}
+	img, opts.Rotation, out.language = uprightPage(img, pageNum, engine, config)
+	out.rotation = opts.Rotation
+	if out.language != "" {
+		config.Language = out.language
+	}
+	// Word boxes and hOCR are reported on the page, so the image is not
+	// deskewed
+	img, out.steps = preprocessPage(img, pageNum, config, !config.WordBoxes && !config.HOCR)
+	if he, ok := engine.(hocrEngine); ok && config.HOCR {
+		raw, err := he.HOCR(img, config, opts)
+		if err != nil {
+			return out, err
+		}
+		if out.hocr, err = hocrPage(raw, pageNum, opts.DPI); err != nil {
+			return out, err
+		}
+	}
+	// Overlays, outlines and word boxes need word positions, which costs a
+	// second recognition pass unless the words are the output anyway
+	if we, ok := engine.(wordEngine); ok && (opts.Labels || config.DebugOverlayDir != "" || config.Outline || config.Markdown || config.WordBoxes || config.MinConfidence > 0 || config.Tables) {
+		words, err := we.Words(img, config, opts)
+		if err != nil {
+			return out, err
+		}
+		calibrateWords(words, engine.Name(), config)
+		out.confidence = meanConfidence(words)
+		src.debugOverlay(config, pageNum, img, words)
+		words = unrotateWords(words, opts.Rotation, out.size)
+		if config.Tables {
+			out.tables = detectTables(wordTablePieces(pageWords(words, opts.DPI)))
+		}
+		if config.Outline || config.Markdown {
+			out.lines = headingLines(pageNum+1, words, out.size.Y, opts.DPI)
+		}
+		if config.WordBoxes {
+			out.words = words
+		}
+		if opts.Labels {
+			// Charts carry no page numbers worth reading
+			out.labels = groupLabels(words)
+			out.text = chartLabelsText(out.labels)
+			return out, nil
+		}
+	}
+	we, ok := engine.(wordEngine)
+	switch {
+	case ok && config.TwoPass && !opts.SparseText && !opts.SingleLine:
+		// Pages read with settings of their own are not segmented
+		out.text, err = twoPassText(img, we, engine, config, opts)
+	case ok && config.Best:
+		out.text, err = src.bestText(ctx, img, pageNum, we, engine, config, opts)
+	default:
+		out.text, err = engine.Text(img, config, opts)
+	}
+	if err == nil && config.PageLabels {
+		// Handwritten or small numbers are often lost in the page text
+		if out.label = textPageLabel(out.text); out.label == "" {
+			out.label = ocrPageLabel(img, engine, config)
+		}
+	}
+	return out, err
+}
+
+// ocrPageWords performs OCR on a single PDF page and returns the words with
+// their positions and confidences. ctx is checked as in ocrPage.
+func ocrPageWords(ctx context.Context, src *pdfSource, pageNum int, config OCRConfig, opts PageOCROptions) ([]OCRWord, error) {
+	_, words, err := ocrPageImageWords(ctx, src, pageNum, config, opts)
+	return words, err
+}
+
+// ocrPageImageWords is ocrPageWords that also returns the page image the
+// word boxes refer to, as rendered before masking and preprocessing.
+func ocrPageImageWords(ctx context.Context, src *pdfSource, pageNum int, config OCRConfig, opts PageOCROptions) (image.Image, []OCRWord, error) {
+	if opts.DPI == 0 {
+		opts.DPI = config.DPI
+	}
+	engine, err := lookupEngine(config.Engine)
+	if err != nil {
+		return nil, nil, err
+	}
+	we, ok := engine.(wordEngine)
+	if !ok {
+		return nil, nil, fmt.Errorf("OCR engine %s does not report word positions", engine.Name())
+	}
+
+	config.progress.report(StageRender, pageNum, "")
+	rendered, err := src.renderForOCR(pageNum, opts.DPI, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error rendering page image: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	config.progress.report(StageOCR, pageNum, "")
+	img := src.maskIgnored(rendered, pageNum, config, opts.DPI)
+	var lang string
+	img, opts.Rotation, lang = uprightPage(img, pageNum, engine, config)
+	if lang != "" {
+		config.Language = lang
+	}
+	// Callers place the words on the page, so the image is not deskewed
+	img, _ = preprocessPage(img, pageNum, config, false)
+	words, err := we.Words(img, config, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	calibrateWords(words, engine.Name(), config)
+	src.debugOverlay(config, pageNum, img, words)
+	return rendered, unrotateWords(words, opts.Rotation, rendered.Bounds().Size()), nil
+}
+
+// ExtractImagesFromPDF extracts the embedded images of the pages of a PDF
+// in config.PageSelection, or with config.RenderPageImages their renders
+func ExtractImagesFromPDF(pdfPath, outputDir string, config OCRConfig) (err error) {
+	if err := validateImageFormat(config.ImageFormat); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return fmt.Errorf("error reading PDF: %w", err)
+	}
+	src, err := openPDFSource(data, filepath.Base(pdfPath), config.Renderer)
+	if err != nil {
+		return err
+	}
+	defer closeChecked(&err, src, "PDF")
+	src.warnings = newWarningLog(&config, src.name)
+	doc := src.doc
+
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+
+	if !config.RenderPageImages {
+		if src.raw != nil {
+			sums, err := extractEmbeddedImages(src, outputDir, config)
+			if err != nil {
+				return err
+			}
+			config.logf("Total images extracted: %d\n", len(sums))
+			return sums.write(outputDir)
+		}
+		src.warnings.warnf(-1, "Warning: could not read the structure of %s; extracting page renders instead\n", src.name)
+	}
+
+	imageCount := 0
+	sums := checksums{}
+
+	for _, pageNum := range config.PageSelection.pageNums(doc.NumPage()) {
+		img, err := src.renderPage(pageNum, 0, config.RobustDecode)
+		if err != nil {
+			src.warnings.warnf(pageNum, "Warning: could not extract image from page %d: %v\n", pageNum+1, err)
+			continue
+		}
+
+		ext := "jpg"
+		var buf bytes.Buffer
+		if config.ImageFormat == ImageFormatPNG {
+			ext, err = "png", png.Encode(&buf, img)
+		} else {
+			err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+		}
+		name := fmt.Sprintf("page_%d.%s", pageNum+1, ext)
+		filename := filepath.Join(outputDir, name)
+		if err != nil {
+			src.warnings.warnf(pageNum, "Warning: could not encode image: %v\n", err)
+			continue
+		}
+		if err := os.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+			src.warnings.warnf(pageNum, "Warning: could not create file %s: %v\n", filename, err)
+			continue
+		}
+		sums.add(name, buf.Bytes())
+
+		imageCount++
+		config.logf("Extracted image from page %d to %s\n", pageNum+1, filename)
+	}
+
+	config.logf("Total images extracted: %d\n", imageCount)
+	return sums.write(outputDir)
+}
@@ -0,0 +1,72 @@
+package pdfocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// DocumentManifest describes a processed document and the sub-documents
+// (attachments or portfolio members) found inside it.
+type DocumentManifest struct {
+	Name        string             `json:"name"`
+	Folder      string             `json:"folder,omitempty"`
+	Description string             `json:"description,omitempty"`
+	MIMEType    string             `json:"mime_type,omitempty"`
+	Size        int                `json:"size"`
+	Pages       int                `json:"pages,omitempty"`
+	Selection   string             `json:"selected_pages,omitempty"` // OCRConfig.PageSelection, when not all pages
+	Portfolio   bool               `json:"portfolio,omitempty"`
+	XFA         bool               `json:"xfa,omitempty"`
+	Processed   bool               `json:"processed"`
+	Truncated   bool               `json:"truncated,omitempty"` // stopped early by -max-pages or -max-duration
+	Partial     bool               `json:"partial,omitempty"`   // pages so far, flushed with OCRConfig.FlushEvery
+	Degraded    []string           `json:"degraded,omitempty"`  // quality reductions made to meet a server deadline, e.g. DegradedFast
+	Error       string             `json:"error,omitempty"`
+	Links       []Link             `json:"links,omitempty"`
+	Outline     []OutlineEntry     `json:"outline,omitempty"` // headings found with OCRConfig.Outline
+	LabelIssues []PageLabelIssue   `json:"label_issues,omitempty"`
+	Collation   *Collation         `json:"collation,omitempty"` // likely scanning mistake in the page order
+	Documents   []DocumentPart     `json:"documents,omitempty"` // documents of a batch scan, with OCRConfig.DetectDocuments
+	Members     []DocumentManifest `json:"members,omitempty"`
+	ReviewPages []int              `json:"low_confidence_pages,omitempty"` // below OCRConfig.MinConfidence, for manual review
+	Keyword     *KeywordMatch      `json:"keyword_match,omitempty"`        // with OCRConfig.Keywords, when found
+	Stats       *TextStats         `json:"stats,omitempty"`                // with OCRConfig.Stats
+	Warnings    []Warning          `json:"warnings,omitempty"`
+
+	// PageResults holds the processed pages; they are written separately
+	// by WritePagesDir rather than inlined in the manifest.
+	PageResults []PageResult `json:"-"`
+}
+
+// versionedManifest is a manifest written on its own, the top-level one
+// carrying the schema version.
+type versionedManifest struct {
+	SchemaVersion string `json:"schema_version"`
+	DocumentManifest
+}
+
+// manifestJSON returns a manifest as indented JSON.
+func manifestJSON(manifest DocumentManifest) ([]byte, error) {
+	data, err := json.MarshalIndent(versionedManifest{SchemaVersion, manifest}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding manifest: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteManifest writes a manifest as indented JSON, compressed if path ends
+// in .gz or .zst and encrypted if key is set.
+func WriteManifest(path string, manifest DocumentManifest, key []byte) error {
+	data, err := manifestJSON(manifest)
+	if err != nil {
+		return err
+	}
+	if data, err = SealOutput(path, data, key); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing manifest: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package pdfocr
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxHeadingLines is the number of text lines a heading of DocumentMarkdown
+// may wrap over.
+const maxHeadingLines = 3
+
+var (
+	// Bullets and numbers starting the list items of page texts
+	markdownBullet   = regexp.MustCompile(`^[•◦▪‣●○■□·*+\-–]\s+(.*)$`)
+	markdownNumbered = regexp.MustCompile(`^(\d{1,3})[.)]\s+(.*)$`)
+
+	// Lines Markdown would read as a heading, quote or rule
+	markdownSyntax = regexp.MustCompile(`^(#|>|[-=_*\s]{3,}$)`)
+)
+
+// markdownLines returns the lines of a page with their heights for heading
+// detection: the recognized lines of an OCR'd page, or the text layer
+// lines of a native one.
+func markdownLines(p PageResult) []headingLine {
+	if len(p.lines) > 0 {
+		return p.lines
+	}
+	var lines []headingLine
+	for i, l := range p.layout {
+		if l.Y1 <= l.Y0 {
+			continue
+		}
+		lines = append(lines, headingLine{
+			page: p.Page, index: i, text: strings.TrimSpace(l.Text),
+			height: l.Y1 - l.Y0, words: len(strings.Fields(l.Text)),
+		})
+	}
+	return lines
+}
+
+// DocumentMarkdown returns the text of a processed document as Markdown.
+// Headings are the lines clearly taller than the body text, as for the
+// outline, from the font size of text layer lines and the height of OCR'd
+// ones (with OCRConfig.Markdown); paragraphs stay apart, bulleted and
+// numbered lines become list items and pages are separated by rules.
+// Superscripts and subscripts of OCR'd lines, told by their offset to the
+// baseline, are kept as <sup> and <sub>. Failed and skipped pages are left
+// out.
+func DocumentMarkdown(manifest DocumentManifest) string {
+	var lines []headingLine
+	for _, p := range manifest.PageResults {
+		lines = append(lines, markdownLines(p)...)
+	}
+	headings := map[int][]OutlineEntry{}
+	for _, e := range buildOutline(lines) {
+		headings[e.Page] = append(headings[e.Page], e)
+	}
+
+	var pages []string
+	for _, p := range manifest.PageResults {
+		if p.Method == MethodFailed || strings.TrimSpace(p.Text) == "" {
+			continue
+		}
+		scripts := map[string]string{}
+		for _, l := range markdownLines(p) {
+			if l.markup != "" {
+				scripts[joinLines([]string{l.text})] = l.markup
+			}
+		}
+		pages = append(pages, markdownPage(p.Text, headings[p.Page], scripts))
+	}
+	return strings.Join(pages, "\n---\n\n")
+}
+
+// markdownPage converts the text of a page, marking up the lines that
+// spell out its headings, and those in scripts, by their text, with their
+// superscripts and subscripts.
+func markdownPage(text string, headings []OutlineEntry, scripts map[string]string) string {
+	used := make([]bool, len(headings))
+	var out []string
+	blank := func() {
+		if len(out) > 0 && out[len(out)-1] != "" {
+			out = append(out, "")
+		}
+	}
+	inList := false
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		// Right-to-left lines keep their mark in front of the text
+		line := strings.TrimSpace(lines[i])
+		mark := ""
+		if strings.HasPrefix(line, rtlMark) {
+			mark, line = rtlMark, strings.TrimSpace(strings.TrimPrefix(line, rtlMark))
+		}
+		if line == "" {
+			blank()
+			inList = false
+			continue
+		}
+		if markup, ok := scripts[joinLines([]string{line})]; ok {
+			line = markup
+		}
+		if n, level := matchHeading(lines[i:], headings, used); n > 0 {
+			blank()
+			out = append(out, strings.Repeat("#", level)+" "+mark+joinLines(lines[i:i+n]), "")
+			i += n - 1
+			inList = false
+			continue
+		}
+		if m := markdownBullet.FindStringSubmatch(line); m != nil {
+			if !inList {
+				blank()
+			}
+			out = append(out, "- "+mark+m[1])
+			inList = true
+			continue
+		}
+		if m := markdownNumbered.FindStringSubmatch(line); m != nil {
+			if !inList {
+				blank()
+			}
+			out = append(out, m[1]+". "+mark+m[2])
+			inList = true
+			continue
+		}
+		if markdownSyntax.MatchString(line) {
+			line = `\` + line
+		}
+		// Lines after a list item continue it, as wrapped items do
+		out = append(out, mark+line)
+	}
+	for len(out) > 0 && out[len(out)-1] == "" {
+		out = out[:len(out)-1]
+	}
+	return strings.Join(out, "\n") + "\n"
+}
+
+// matchHeading reports how many of the text lines, up to maxHeadingLines,
+// spell out one of the headings not used yet, and its level, or 0.
+func matchHeading(lines []string, headings []OutlineEntry, used []bool) (int, int) {
+	for n := 1; n <= min(maxHeadingLines, len(lines)); n++ {
+		text := joinLines(lines[:n])
+		for i, h := range headings {
+			if !used[i] && text == joinLines([]string{h.Title}) {
+				used[i] = true
+				return n, h.Level
+			}
+		}
+	}
+	return 0, 0
+}
+
+// joinLines joins text lines into one, with single spaces and without
+// bidi marks.
+func joinLines(lines []string) string {
+	var words []string
+	for _, l := range lines {
+		words = append(words, strings.Fields(strings.ReplaceAll(l, rtlMark, ""))...)
+	}
+	return strings.Join(words, " ")
+}
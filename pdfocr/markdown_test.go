@@ -0,0 +1,38 @@
+package pdfocr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDocumentMarkdown(t *testing.T) {
+	body := func(page, index int, text string) headingLine {
+		return headingLine{page: page, index: index, text: text, height: 10, words: len(strings.Fields(text))}
+	}
+	manifest := DocumentManifest{PageResults: []PageResult{
+		{
+			Page: 1, Method: MethodOCR,
+			Text: "Annual Report\n\nSales grew this year\nacross all regions.\nWe thank:\n• our staff\n• our customers\n# of stores: 12",
+			lines: []headingLine{
+				{page: 1, index: 0, text: "Annual Report", height: 20, words: 2},
+				body(1, 1, "Sales grew this year"), body(1, 2, "across all regions."), body(1, 3, "We thank:"),
+				body(1, 4, "• our staff"), body(1, 5, "• our customers"),
+			},
+		},
+		{Page: 2, Method: MethodFailed, Error: "render failed"},
+		{
+			Page: 3, Method: MethodNative,
+			Text: "Outlook\n1. Open two stores\n2) Hire\n---",
+			layout: []TextLine{
+				{Text: "Outlook", Y0: 0, Y1: 15},
+				{Text: "1. Open two stores", Y0: 20, Y1: 30}, {Text: "2) Hire", Y0: 32, Y1: 42},
+			},
+		},
+	}}
+	want := "# Annual Report\n\nSales grew this year\nacross all regions.\nWe thank:\n\n- our staff\n- our customers\n\\# of stores: 12\n" +
+		"\n---\n\n" +
+		"## Outlook\n\n1. Open two stores\n2. Hire\n\\---\n"
+	if got := DocumentMarkdown(manifest); got != want {
+		t.Errorf("DocumentMarkdown() =\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,275 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// mergeMinSimilarity is the bigram similarity above which a native line
+	// and an OCR line are treated as the same line of the page.
+	mergeMinSimilarity = 0.3
+
+	// mergeMinOCRConfidence is the mean word confidence an OCR-only line
+	// needs to be added to the transcript.
+	mergeMinOCRConfidence = 50
+
+	// badCharWeight is how many characters of a native line a single sign of
+	// a broken encoding costs; one wrong glyph usually ruins its word.
+	badCharWeight = 4
+)
+
+// Provenance of a merged line.
+const (
+	SourceNative = "native"
+	SourceOCR    = "ocr"
+)
+
+// mergedLine is one line of a merged transcript.
+type mergedLine struct {
+	Text       string
+	Source     string  // SourceNative or SourceOCR
+	Confidence float64 // 0-100; native lines are scored by nativeLineScore
+}
+
+// ocrLine is a line of recognized words with their mean confidence and the
+// box around them.
+type ocrLine struct {
+	Text       string
+	Confidence float64
+	Box        image.Rectangle
+	Words      []OCRWord // left to right
+}
+
+// wordLines assembles words into lines: words whose boxes overlap
+// vertically by at least half the smaller height share a line.
+func wordLines(words []OCRWord) []ocrLine {
+	sorted := append([]OCRWord(nil), words...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Box.Min.Y < sorted[j].Box.Min.Y })
+
+	type line struct {
+		box   image.Rectangle
+		words []OCRWord
+	}
+	var lines []*line
+	for _, w := range sorted {
+		var target *line
+		for _, l := range lines {
+			overlap := min(l.box.Max.Y, w.Box.Max.Y) - max(l.box.Min.Y, w.Box.Min.Y)
+			if overlap*2 >= min(l.box.Dy(), w.Box.Dy()) {
+				target = l
+				break
+			}
+		}
+		if target == nil {
+			target = &line{box: w.Box}
+			lines = append(lines, target)
+		}
+		target.box = target.box.Union(w.Box)
+		target.words = append(target.words, w)
+	}
+
+	out := make([]ocrLine, 0, len(lines))
+	for _, l := range lines {
+		sort.SliceStable(l.words, func(i, j int) bool { return l.words[i].Box.Min.X < l.words[j].Box.Min.X })
+		parts := make([]string, len(l.words))
+		total := 0.0
+		for i, w := range l.words {
+			parts[i] = w.Text
+			total += w.Confidence
+		}
+		out = append(out, ocrLine{
+			Text: strings.Join(parts, " "), Confidence: total / float64(len(l.words)),
+			Box: l.box, Words: l.words,
+		})
+	}
+	return out
+}
+
+// nativeLineScore rates a text-layer line from 0 to 100 by how much of it
+// looks like the output of a broken font encoding: replacement and
+// private-use characters, control characters, UTF-8 decoded as Latin-1
+// ("Ã©") and letters spaced out one per word.
+func nativeLineScore(line string) float64 {
+	runes := []rune(line)
+	if len(runes) == 0 {
+		return 0
+	}
+	bad := 0
+	for i, r := range runes {
+		switch {
+		case r == unicode.ReplacementChar,
+			r >= 0xE000 && r <= 0xF8FF,
+			unicode.IsControl(r) && r != '\t':
+			bad++
+		case (r == 'Ã' || r == 'Â' || r == 'â') && i+1 < len(runes) && runes[i+1] >= 0x80 && runes[i+1] <= 0xFF:
+			bad += 2
+		}
+	}
+	score := 100 * (1 - float64(bad*badCharWeight)/float64(len(runes)))
+
+	fields := strings.Fields(line)
+	single := 0
+	for _, f := range fields {
+		if len([]rune(f)) == 1 {
+			single++
+		}
+	}
+	if len(fields) >= 4 && single*2 > len(fields) {
+		score /= 2
+	}
+	return max(score, 0)
+}
+
+// lineBigrams returns the character bigrams of a line, ignoring case and
+// whitespace.
+func lineBigrams(s string) map[string]int {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if !unicode.IsSpace(r) {
+			b.WriteRune(r)
+		}
+	}
+	runes := []rune(b.String())
+	grams := map[string]int{}
+	for i := 0; i+1 < len(runes); i++ {
+		grams[string(runes[i:i+2])]++
+	}
+	return grams
+}
+
+// lineSimilarity is the Dice coefficient of the bigrams of two lines.
+func lineSimilarity(a, b map[string]int) float64 {
+	total, common := 0, 0
+	for g, n := range a {
+		total += n
+		common += min(n, b[g])
+	}
+	for _, n := range b {
+		total += n
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// mergeNativeAndOCR aligns the lines of a page's text layer with the OCR
+// lines of the same page and keeps the better source for every line. Aligned
+// pairs keep the native line unless its score is below the OCR confidence.
+// Between aligned pairs, runs of lines found by only one source are kept;
+// when both sources have lines there (typically a garbled text layer that
+// does not resemble the OCR at all) the run with the better mean score wins.
+func mergeNativeAndOCR(native string, ocr []ocrLine) []mergedLine {
+	var nat []string
+	for _, l := range strings.Split(native, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			nat = append(nat, l)
+		}
+	}
+
+	natGrams := make([]map[string]int, len(nat))
+	for i, l := range nat {
+		natGrams[i] = lineBigrams(l)
+	}
+	ocrGrams := make([]map[string]int, len(ocr))
+	for j, l := range ocr {
+		ocrGrams[j] = lineBigrams(l.Text)
+	}
+
+	// Global alignment maximizing the total similarity of matched lines
+	n, m := len(nat), len(ocr)
+	score := make([][]float64, n+1)
+	for i := range score {
+		score[i] = make([]float64, m+1)
+	}
+	sim := func(i, j int) float64 { return lineSimilarity(natGrams[i], ocrGrams[j]) }
+	for i := 1; i <= n; i++ {
+		for j := 1; j <= m; j++ {
+			best := max(score[i-1][j], score[i][j-1])
+			if s := sim(i-1, j-1); s >= mergeMinSimilarity {
+				best = max(best, score[i-1][j-1]+s)
+			}
+			score[i][j] = best
+		}
+	}
+	type pair struct{ nat, ocr int }
+	var pairs []pair
+	for i, j := n, m; i > 0 && j > 0; {
+		switch {
+		case sim(i-1, j-1) >= mergeMinSimilarity && score[i][j] == score[i-1][j-1]+sim(i-1, j-1):
+			pairs = append(pairs, pair{i - 1, j - 1})
+			i, j = i-1, j-1
+		case score[i][j] == score[i-1][j]:
+			i--
+		default:
+			j--
+		}
+	}
+	for a, b := 0, len(pairs)-1; a < b; a, b = a+1, b-1 {
+		pairs[a], pairs[b] = pairs[b], pairs[a]
+	}
+	pairs = append(pairs, pair{n, m}) // sentinel closing the last gap
+
+	var out []mergedLine
+	ni, oj := 0, 0
+	for _, p := range pairs {
+		out = append(out, mergeGap(nat[ni:p.nat], ocr[oj:p.ocr])...)
+		if p.nat == n {
+			break
+		}
+		natScore := nativeLineScore(nat[p.nat])
+		if o := ocr[p.ocr]; natScore < o.Confidence {
+			out = append(out, mergedLine{Text: o.Text, Source: SourceOCR, Confidence: o.Confidence})
+		} else {
+			out = append(out, mergedLine{Text: nat[p.nat], Source: SourceNative, Confidence: natScore})
+		}
+		ni, oj = p.nat+1, p.ocr+1
+	}
+	return out
+}
+
+// mergeGap chooses between unaligned native and OCR lines that sit between
+// the same two aligned lines.
+func mergeGap(nat []string, ocr []ocrLine) []mergedLine {
+	var natLines, ocrLines []mergedLine
+	natTotal, ocrTotal := 0.0, 0.0
+	for _, l := range nat {
+		s := nativeLineScore(l)
+		natTotal += s
+		natLines = append(natLines, mergedLine{Text: l, Source: SourceNative, Confidence: s})
+	}
+	for _, l := range ocr {
+		if l.Confidence < mergeMinOCRConfidence {
+			continue
+		}
+		ocrTotal += l.Confidence
+		ocrLines = append(ocrLines, mergedLine{Text: l.Text, Source: SourceOCR, Confidence: l.Confidence})
+	}
+	switch {
+	case len(ocrLines) == 0:
+		return natLines
+	case len(natLines) == 0:
+		return ocrLines
+	case natTotal/float64(len(natLines)) >= ocrTotal/float64(len(ocrLines)):
+		return natLines
+	}
+	return ocrLines
+}
+
+// formatMergedLines renders a merged transcript with a provenance tag on
+// every line: "[native]" or "[ocr NN]" with the mean word confidence.
+func formatMergedLines(lines []mergedLine) string {
+	var b strings.Builder
+	for _, l := range lines {
+		if l.Source == SourceOCR {
+			fmt.Fprintf(&b, "[ocr %.0f] %s\n", l.Confidence, l.Text)
+		} else {
+			fmt.Fprintf(&b, "[native] %s\n", l.Text)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,191 @@
+package pdfocr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// METS structures, limited to what a digitization package needs: a
+// descriptive section, one file group per kind of artifact and the physical
+// page sequence.
+type metsDocument struct {
+	XMLName   xml.Name      `xml:"mets:mets"`
+	XMLNSMets string        `xml:"xmlns:mets,attr"`
+	XMLNSLink string        `xml:"xmlns:xlink,attr"`
+	XMLNSDC   string        `xml:"xmlns:dc,attr"`
+	ObjID     string        `xml:"OBJID,attr"`
+	Header    metsHeader    `xml:"mets:metsHdr"`
+	Dmd       metsDmdSec    `xml:"mets:dmdSec"`
+	FileSec   metsFileSec   `xml:"mets:fileSec"`
+	StructMap metsStructMap `xml:"mets:structMap"`
+}
+
+type metsHeader struct {
+	Created string    `xml:"CREATEDATE,attr"`
+	Agent   metsAgent `xml:"mets:agent"`
+}
+
+type metsAgent struct {
+	Role string `xml:"ROLE,attr"`
+	Type string `xml:"TYPE,attr"`
+	Name string `xml:"mets:name"`
+}
+
+type metsDmdSec struct {
+	ID   string     `xml:"ID,attr"`
+	Wrap metsMdWrap `xml:"mets:mdWrap"`
+}
+
+type metsMdWrap struct {
+	MDType string      `xml:"MDTYPE,attr"`
+	Data   metsDCEntry `xml:"mets:xmlData"`
+}
+
+type metsDCEntry struct {
+	Title  string `xml:"dc:title"`
+	Format string `xml:"dc:format"`
+	Extent string `xml:"dc:extent"`
+}
+
+type metsFileSec struct {
+	Groups []metsFileGrp `xml:"mets:fileGrp"`
+}
+
+type metsFileGrp struct {
+	Use   string     `xml:"USE,attr"`
+	Files []metsFile `xml:"mets:file"`
+}
+
+type metsFile struct {
+	ID           string     `xml:"ID,attr"`
+	MIMEType     string     `xml:"MIMETYPE,attr"`
+	Size         int        `xml:"SIZE,attr"`
+	Checksum     string     `xml:"CHECKSUM,attr"`
+	ChecksumType string     `xml:"CHECKSUMTYPE,attr"`
+	Location     metsFLocat `xml:"mets:FLocat"`
+}
+
+type metsFLocat struct {
+	LocType string `xml:"LOCTYPE,attr"`
+	Href    string `xml:"xlink:href,attr"`
+}
+
+type metsStructMap struct {
+	Type string  `xml:"TYPE,attr"`
+	Root metsDiv `xml:"mets:div"`
+}
+
+type metsDiv struct {
+	Type     string     `xml:"TYPE,attr"`
+	Order    int        `xml:"ORDER,attr,omitempty"`
+	DmdID    string     `xml:"DMDID,attr,omitempty"`
+	Pointers []metsFptr `xml:"mets:fptr"`
+	Children []metsDiv  `xml:"mets:div"`
+}
+
+type metsFptr struct {
+	FileID string `xml:"FILEID,attr"`
+}
+
+// metsPageImageDir and metsPageTextDir are the package folders for page
+// images and page texts.
+const (
+	metsPageImageDir = "images"
+	metsPageTextDir  = "text"
+)
+
+// WriteMETSPackage writes a METS package for a processed document into
+// dir: page images (with the SHA256SUMS of -extract-images), one text file
+// per page and a mets.xml that ties them to the pages and lists their
+// checksums.
+func WriteMETSPackage(dir, pdfPath string, manifest DocumentManifest, config OCRConfig) error {
+	imageDir := filepath.Join(dir, metsPageImageDir)
+	if err := ExtractImagesFromPDF(pdfPath, imageDir, config); err != nil {
+		return fmt.Errorf("error writing page images: %w", err)
+	}
+	imageSums, err := readChecksums(imageDir)
+	if err != nil {
+		return err
+	}
+	textDir := filepath.Join(dir, metsPageTextDir)
+	if err := os.MkdirAll(textDir, 0755); err != nil {
+		return fmt.Errorf("error creating text directory: %w", err)
+	}
+
+	images := metsFileGrp{Use: "IMAGE"}
+	texts := metsFileGrp{Use: "FULLTEXT"}
+	physical := metsDiv{Type: "physSequence", DmdID: "DMD1"}
+	for _, p := range manifest.PageResults {
+		page := metsDiv{Type: "page", Order: p.Page}
+
+		imageName := fmt.Sprintf("page_%d.jpg", p.Page)
+		if sum, ok := imageSums[imageName]; ok {
+			info, err := os.Stat(filepath.Join(imageDir, imageName))
+			if err != nil {
+				return fmt.Errorf("error reading page image: %w", err)
+			}
+			id := fmt.Sprintf("IMG%06d", p.Page)
+			images.Files = append(images.Files, metsFile{
+				ID: id, MIMEType: "image/jpeg", Size: int(info.Size()), Checksum: sum, ChecksumType: "SHA-256",
+				Location: metsFLocat{LocType: "URL", Href: path.Join(metsPageImageDir, imageName)},
+			})
+			page.Pointers = append(page.Pointers, metsFptr{FileID: id})
+		}
+
+		text := p.Text
+		if config.NormalizeLocale != "" {
+			if text, err = NormalizeLocaleFormats(text, config.NormalizeLocale); err != nil {
+				return err
+			}
+		}
+		data := []byte(text + "\n")
+		textName := fmt.Sprintf("page_%d.txt", p.Page)
+		if err := os.WriteFile(filepath.Join(textDir, textName), data, 0644); err != nil {
+			return fmt.Errorf("error writing page text: %w", err)
+		}
+		sum := sha256.Sum256(data)
+		id := fmt.Sprintf("TXT%06d", p.Page)
+		texts.Files = append(texts.Files, metsFile{
+			ID: id, MIMEType: "text/plain", Size: len(data), Checksum: hex.EncodeToString(sum[:]), ChecksumType: "SHA-256",
+			Location: metsFLocat{LocType: "URL", Href: path.Join(metsPageTextDir, textName)},
+		})
+		page.Pointers = append(page.Pointers, metsFptr{FileID: id})
+		physical.Children = append(physical.Children, page)
+	}
+
+	extent := fmt.Sprintf("%d pages", manifest.Pages)
+	if manifest.Pages == 1 {
+		extent = "1 page"
+	}
+	doc := metsDocument{
+		XMLNSMets: "http://www.loc.gov/METS/",
+		XMLNSLink: "http://www.w3.org/1999/xlink",
+		XMLNSDC:   "http://purl.org/dc/elements/1.1/",
+		ObjID:     strings.TrimSuffix(manifest.Name, filepath.Ext(manifest.Name)),
+		Header: metsHeader{
+			Created: time.Now().UTC().Format(time.RFC3339),
+			Agent:   metsAgent{Role: "CREATOR", Type: "OTHER", Name: "pdf-ocr-tool " + Version()},
+		},
+		Dmd: metsDmdSec{ID: "DMD1", Wrap: metsMdWrap{MDType: "DC", Data: metsDCEntry{
+			Title: manifest.Name, Format: "application/pdf", Extent: extent,
+		}}},
+		FileSec:   metsFileSec{Groups: []metsFileGrp{images, texts}},
+		StructMap: metsStructMap{Type: "PHYSICAL", Root: physical},
+	}
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding METS: %w", err)
+	}
+	data := append([]byte(xml.Header), out...)
+	if err := os.WriteFile(filepath.Join(dir, "mets.xml"), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing METS: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package pdfocr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// modelClient downloads the models of the ONNX Runtime engines.
+var modelClient = &http.Client{Timeout: 30 * time.Minute}
+
+// ONNXModels are the models of the ONNX Runtime engines, built with the
+// onnx tag: a text detection model segmenting page images into text boxes
+// (DBNet, as PP-OCR exports it), a line recognition model whose output is
+// decoded with CTC (CRNN), and the characters of its classes, one per line
+// of a text file. Each is a file path, or an http(s) URL fetched once into
+// the model cache (see FetchModel).
+type ONNXModels struct {
+	Detector   string
+	Recognizer string
+	Charset    string
+}
+
+// ParseONNXModels parses the -onnx-models list: the detector, the
+// recognizer and the charset, separated by commas.
+func ParseONNXModels(list string) (ONNXModels, error) {
+	parts := strings.Split(list, ",")
+	if len(parts) != 3 {
+		return ONNXModels{}, fmt.Errorf("want <detector.onnx>,<recognizer.onnx>,<charset.txt>, got %q", list)
+	}
+	for i, p := range parts {
+		if parts[i] = strings.TrimSpace(p); parts[i] == "" {
+			return ONNXModels{}, fmt.Errorf("empty model in %q", list)
+		}
+	}
+	return ONNXModels{Detector: parts[0], Recognizer: parts[1], Charset: parts[2]}, nil
+}
+
+// ModelCacheDir returns the directory models fetched by URL are kept in:
+// config.ModelCache, or pdf-ocr-tool/models in the user cache directory.
+func ModelCacheDir(config OCRConfig) (string, error) {
+	if config.ModelCache != "" {
+		return config.ModelCache, nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("no model cache directory (set -model-cache): %w", err)
+	}
+	return filepath.Join(dir, "pdf-ocr-tool", "models"), nil
+}
+
+// isModelURL reports whether a model is given by URL rather than path.
+func isModelURL(model string) bool {
+	return strings.HasPrefix(model, "https://") || strings.HasPrefix(model, "http://")
+}
+
+// cachedModelPath returns where the model at rawURL is kept in the cache
+// dir, named by the hash of the URL and its file name, and the SHA-256
+// digest its #sha256=<hex> fragment asks for ("" for none).
+func cachedModelPath(rawURL, dir string) (string, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid model URL: %w", err)
+	}
+	var digest string
+	if u.Fragment != "" {
+		var ok bool
+		if digest, ok = strings.CutPrefix(u.Fragment, "sha256="); !ok || len(digest) != sha256.Size*2 {
+			return "", "", fmt.Errorf("invalid model URL %s: want a #sha256=<hex> fragment", rawURL)
+		}
+		digest = strings.ToLower(digest)
+		u.Fragment = ""
+	}
+	sum := sha256.Sum256([]byte(u.String()))
+	name := hex.EncodeToString(sum[:8])
+	if base := path.Base(u.Path); base != "." && base != "/" {
+		name += "-" + base
+	}
+	return filepath.Join(dir, name), digest, nil
+}
+
+// FetchModel returns the local path of a model: a path as is, and a URL as
+// its copy in the model cache, downloaded on first use and checked against
+// its #sha256= digest when the URL has one. Later runs use the cached copy
+// without a network connection.
+func FetchModel(model string, config OCRConfig) (string, error) {
+	if !isModelURL(model) {
+		return model, nil
+	}
+	dir, err := ModelCacheDir(config)
+	if err != nil {
+		return "", err
+	}
+	local, digest, err := cachedModelPath(model, dir)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(local); err == nil {
+		return local, nil
+	}
+	config.logf("Downloading model %s to %s\n", model, dir)
+	if err := downloadModel(strings.SplitN(model, "#", 2)[0], local, digest); err != nil {
+		return "", fmt.Errorf("error downloading model %s: %w", model, err)
+	}
+	return local, nil
+}
+
+// downloadModel writes the file at rawURL to local through a temporary
+// file, so an interrupted download never leaves a model in the cache.
+func downloadModel(rawURL, local, digest string) error {
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		return err
+	}
+	resp, err := modelClient.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	f, err := os.CreateTemp(filepath.Dir(local), "."+filepath.Base(local)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); digest != "" && got != digest {
+		return fmt.Errorf("SHA-256 is %s, want %s", got, digest)
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), local)
+}
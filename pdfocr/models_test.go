@@ -0,0 +1,79 @@
+package pdfocr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseONNXModels(t *testing.T) {
+	m, err := ParseONNXModels("det.onnx, rec.onnx ,keys.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ONNXModels{Detector: "det.onnx", Recognizer: "rec.onnx", Charset: "keys.txt"}); m != want {
+		t.Errorf("got %+v, want %+v", m, want)
+	}
+	for _, list := range []string{"", "det.onnx,rec.onnx", "det.onnx,,keys.txt", "a,b,c,d"} {
+		if _, err := ParseONNXModels(list); err == nil {
+			t.Errorf("%q: no error", list)
+		}
+	}
+}
+
+func TestFetchModel(t *testing.T) {
+	model := []byte("model weights")
+	sum := sha256.Sum256(model)
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1/rec.onnx" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(model)
+	}))
+	defer srv.Close()
+	config := OCRConfig{ModelCache: t.TempDir()}
+
+	if path, err := FetchModel("models/det.onnx", config); err != nil || path != "models/det.onnx" {
+		t.Errorf("local model: got %q, %v", path, err)
+	}
+
+	url := srv.URL + "/v1/rec.onnx#sha256=" + hex.EncodeToString(sum[:])
+	path, err := FetchModel(url, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(path) != config.ModelCache || !strings.HasSuffix(path, "-rec.onnx") {
+		t.Errorf("cached as %s", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(model) {
+		t.Errorf("cached model %q, %v", data, err)
+	}
+	// Offline from now on: the cached copy is used
+	srv.Close()
+	if again, err := FetchModel(url, config); err != nil || again != path || requests != 1 {
+		t.Errorf("second fetch: %q, %v after %d requests", again, err, requests)
+	}
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tampered"))
+	}))
+	defer srv.Close()
+	if _, err := FetchModel(srv.URL+"/rec.onnx#sha256="+hex.EncodeToString(sum[:]), config); err == nil || !strings.Contains(err.Error(), "SHA-256") {
+		t.Errorf("digest mismatch: %v", err)
+	}
+	if _, err := FetchModel(srv.URL+"/missing.onnx#md5=abc", config); err == nil {
+		t.Error("invalid fragment: no error")
+	}
+	entries, _ := os.ReadDir(config.ModelCache)
+	if len(entries) != 1 {
+		t.Errorf("cache holds %d files, want the verified model only", len(entries))
+	}
+}
@@ -0,0 +1,65 @@
+package pdfocr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// directoryOutputFlags lists the options set in a run that write files
+// besides -o, -manifest and -bundle.
+func directoryOutputFlags(config OCRConfig, extractImages bool) []string {
+	var flags []string
+	for _, o := range []struct {
+		set  bool
+		flag string
+	}{
+		{config.PagesDir != "", "-pages-dir"},
+		{config.METSDir != "", "-mets"},
+		{config.IIIFDir != "", "-iiif"},
+		{config.TablesDir != "", "-tables"},
+		{config.XFAOutputFile != "", "-xfa-out"},
+		{config.DebugOverlayDir != "", "-debug-overlay"},
+		{extractImages, "-extract-images"},
+	} {
+		if o.set {
+			flags = append(flags, o.flag)
+		}
+	}
+	return flags
+}
+
+// checkNoTempFiles rejects the poppler renderer, which copies the PDF to a
+// temporary file, for runs where option forbids them.
+func checkNoTempFiles(config OCRConfig, option string) error {
+	r, err := lookupRenderer(config.Renderer)
+	if err != nil {
+		return err
+	}
+	if r.Name() == "poppler" {
+		return fmt.Errorf("the poppler renderer copies the PDF to a temporary file and cannot be combined with %s", option)
+	}
+	return nil
+}
+
+// CheckNoDisk verifies that a -no-disk run keeps everything in memory: the
+// PDF is read, rendered and recognized in memory and the result is written
+// to stdout only, so every option that writes a file is refused up front.
+func CheckNoDisk(config OCRConfig, extractImages bool, bundleFile string) error {
+	flags := directoryOutputFlags(config, extractImages)
+	for _, o := range []struct {
+		set  bool
+		flag string
+	}{
+		{config.OutputFile != "", "-o"},
+		{config.ManifestFile != "", "-manifest"},
+		{bundleFile != "", "-bundle"},
+	} {
+		if o.set {
+			flags = append(flags, o.flag)
+		}
+	}
+	if len(flags) > 0 {
+		return fmt.Errorf("-no-disk writes the text to stdout only and cannot be combined with %s", strings.Join(flags, ", "))
+	}
+	return checkNoTempFiles(config, "-no-disk")
+}
@@ -0,0 +1,234 @@
+package pdfocr
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// numberLocale describes how a locale writes numbers and dates.
+type numberLocale struct {
+	Decimal   string // decimal separator
+	Group     string // digit group separators (any of these characters)
+	DateOrder string // order of numeric dates: "DMY", "MDY" or "YMD"
+	Dollar    string // ISO 4217 code the $ sign stands for
+}
+
+// numberLocales are the source locales supported by -normalize-locale.
+var numberLocales = map[string]numberLocale{
+	"en-us": {Decimal: ".", Group: ",", DateOrder: "MDY", Dollar: "USD"},
+	"en-gb": {Decimal: ".", Group: ",", DateOrder: "DMY", Dollar: "USD"},
+	"en-ca": {Decimal: ".", Group: ",", DateOrder: "YMD", Dollar: "CAD"},
+	"en-au": {Decimal: ".", Group: ",", DateOrder: "DMY", Dollar: "AUD"},
+	"en-in": {Decimal: ".", Group: ",", DateOrder: "DMY", Dollar: "USD"},
+	"sw-tz": {Decimal: ".", Group: ",", DateOrder: "DMY", Dollar: "USD"},
+	"sw-ke": {Decimal: ".", Group: ",", DateOrder: "DMY", Dollar: "USD"},
+	"de-de": {Decimal: ",", Group: ".", DateOrder: "DMY", Dollar: "USD"},
+	"de-ch": {Decimal: ".", Group: "'’", DateOrder: "DMY", Dollar: "USD"},
+	"fr-fr": {Decimal: ",", Group: " \u00a0\u202f", DateOrder: "DMY", Dollar: "USD"},
+	"es-es": {Decimal: ",", Group: ".", DateOrder: "DMY", Dollar: "USD"},
+	"it-it": {Decimal: ",", Group: ".", DateOrder: "DMY", Dollar: "USD"},
+	"nl-nl": {Decimal: ",", Group: ".", DateOrder: "DMY", Dollar: "USD"},
+	"pt-br": {Decimal: ",", Group: ".", DateOrder: "DMY", Dollar: "BRL"},
+	"ja-jp": {Decimal: ".", Group: ",", DateOrder: "YMD", Dollar: "USD"},
+}
+
+// lookupNumberLocale returns the conventions of a locale such as "de-DE".
+func lookupNumberLocale(name string) (numberLocale, error) {
+	key := strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+	if loc, ok := numberLocales[key]; ok {
+		return loc, nil
+	}
+	names := make([]string, 0, len(numberLocales))
+	for n := range numberLocales {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return numberLocale{}, fmt.Errorf("unsupported locale %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// currencySymbols maps currency signs and abbreviations to ISO 4217 codes.
+// The $ sign is resolved per locale.
+var currencySymbols = map[string]string{
+	"€": "EUR", "£": "GBP", "¥": "JPY", "₹": "INR", "₦": "NGN", "R$": "BRL",
+	"CHF": "CHF", "Fr.": "CHF", "TSh": "TZS", "KSh": "KES", "USh": "UGX",
+	"EUR": "EUR", "USD": "USD", "GBP": "GBP", "JPY": "JPY", "TZS": "TZS",
+	"KES": "KES", "UGX": "UGX", "CAD": "CAD", "AUD": "AUD", "INR": "INR",
+	"BRL": "BRL", "ZAR": "ZAR", "NGN": "NGN", "CNY": "CNY",
+}
+
+// monthNames maps lower-case month names and abbreviations of the supported
+// languages to month numbers.
+var monthNames = map[string]int{}
+
+func init() {
+	for _, names := range [][]string{
+		{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october", "november", "december"},
+		{"januar", "februar", "märz", "april", "mai", "juni", "juli", "august", "september", "oktober", "november", "dezember"},
+		{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		{"januari", "februari", "machi", "aprili", "mei", "juni", "julai", "agosti", "septemba", "oktoba", "novemba", "desemba"},
+	} {
+		for i, n := range names {
+			monthNames[n] = i + 1
+			if r := []rune(n); len(r) > 3 {
+				if _, taken := monthNames[string(r[:3])]; !taken {
+					monthNames[string(r[:3])] = i + 1
+				}
+			}
+		}
+	}
+}
+
+// Currency markers recognized before and after amounts.
+const (
+	currencyPrefixPattern = `R\$|US\$|[$€£¥₹₦]|\b(?:CHF|Fr\.|TSh|KSh|USh|EUR|USD|GBP|JPY|TZS|KES|UGX|CAD|AUD|INR|BRL|ZAR|NGN|CNY)`
+	currencySuffixPattern = `[$€£¥₹₦]|(?:CHF|TSh|KSh|USh|EUR|USD|GBP|JPY|TZS|KES|UGX|CAD|AUD|INR|BRL|ZAR|NGN|CNY)\b`
+)
+
+// amountRegexp matches a number with a currency sign or code before or after
+// it. The number may only contain the separators loc uses; it is validated
+// further by parseLocaleNumber.
+func amountRegexp(loc numberLocale) *regexp.Regexp {
+	number := `-?\d(?:[\d` + regexp.QuoteMeta(loc.Decimal+loc.Group) + `]*\d)?`
+	return regexp.MustCompile(`(?:(` + currencyPrefixPattern + `)\s?(` + number + `))|(?:(` + number + `)\s?(` + currencySuffixPattern + `))`)
+}
+
+var (
+	// numericDateRe matches dates written with /, . or - separators.
+	numericDateRe = regexp.MustCompile(`\b(\d{1,4})([/.-])(\d{1,2})([/.-])(\d{1,4})\b`)
+
+	// textDateRe matches "5 March 2024", "5. März 2024" and "March 5, 2024".
+	textDateRe = regexp.MustCompile(`(?i)\b(?:(\d{1,2})\.?\s+([\p{L}]{3,10})\.?,?\s+(\d{4})|([\p{L}]{3,10})\.?\s+(\d{1,2}),?\s+(\d{4}))\b`)
+)
+
+// parseLocaleNumber converts a number written in loc to the canonical form
+// with a dot as decimal separator and no grouping. Group separators must
+// split the integer part into groups of three digits; anything else is not
+// treated as a number.
+func parseLocaleNumber(s string, loc numberLocale) (string, bool) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	intPart, frac := s, ""
+	if i := strings.LastIndex(s, loc.Decimal); i >= 0 {
+		intPart, frac = s[:i], s[i+len(loc.Decimal):]
+		if frac == "" || strings.Trim(frac, "0123456789") != "" {
+			return "", false
+		}
+	}
+
+	var digits strings.Builder
+	groups := strings.FieldsFunc(intPart, func(r rune) bool { return strings.ContainsRune(loc.Group, r) })
+	if len(groups) == 0 {
+		return "", false
+	}
+	for i, g := range groups {
+		if g == "" || strings.Trim(g, "0123456789") != "" {
+			return "", false
+		}
+		if len(groups) > 1 && ((i == 0 && len(g) > 3) || (i > 0 && len(g) != 3)) {
+			return "", false
+		}
+		digits.WriteString(g)
+	}
+	if len(groups) > 1 && len(intPart) != digits.Len()+len(groups)-1 {
+		// more than one separator character between two groups
+		return "", false
+	}
+
+	out := digits.String()
+	if frac != "" {
+		out += "." + frac
+	}
+	if neg {
+		out = "-" + out
+	}
+	return out, true
+}
+
+// validDate reports whether year, month and day form a plausible date.
+func validDate(y, m, d int) bool {
+	if y < 1000 || y > 9999 || m < 1 || m > 12 || d < 1 {
+		return false
+	}
+	days := []int{31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+	return d <= days[m-1]
+}
+
+// NormalizeLocaleFormats rewrites currency amounts and dates written in the
+// conventions of a source locale (e.g. "de-DE") to ISO formats: amounts
+// become "EUR 1234.56" and dates "2024-03-05". Numbers without a currency
+// marker are left alone, since "1.234" is ambiguous out of context.
+func NormalizeLocaleFormats(text, locale string) (string, error) {
+	loc, err := lookupNumberLocale(locale)
+	if err != nil {
+		return "", err
+	}
+
+	amountRe := amountRegexp(loc)
+	text = amountRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := amountRe.FindStringSubmatch(m)
+		symbol, number := sub[1], sub[2]
+		if symbol == "" {
+			number, symbol = sub[3], sub[4]
+		}
+		value, ok := parseLocaleNumber(strings.TrimSpace(number), loc)
+		if !ok {
+			return m
+		}
+		code := currencySymbols[symbol]
+		switch symbol {
+		case "$":
+			code = loc.Dollar
+		case "US$":
+			code = "USD"
+		}
+		return code + " " + value
+	})
+
+	text = numericDateRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := numericDateRe.FindStringSubmatch(m)
+		if sub[2] != sub[4] {
+			return m
+		}
+		a, _ := strconv.Atoi(sub[1])
+		b, _ := strconv.Atoi(sub[3])
+		c, _ := strconv.Atoi(sub[5])
+		var y, mo, d int
+		switch {
+		case len(sub[1]) == 4:
+			y, mo, d = a, b, c
+		case len(sub[5]) != 4:
+			return m
+		case loc.DateOrder == "MDY":
+			y, mo, d = c, a, b
+		default: // DMY; YMD locales write day-first dates as DMY too
+			y, mo, d = c, b, a
+		}
+		if !validDate(y, mo, d) {
+			return m
+		}
+		return fmt.Sprintf("%04d-%02d-%02d", y, mo, d)
+	})
+
+	text = textDateRe.ReplaceAllStringFunc(text, func(m string) string {
+		sub := textDateRe.FindStringSubmatch(m)
+		day, month, year := sub[1], sub[2], sub[3]
+		if day == "" {
+			month, day, year = sub[4], sub[5], sub[6]
+		}
+		mo, ok := monthNames[strings.ToLower(month)]
+		if !ok {
+			return m
+		}
+		d, _ := strconv.Atoi(day)
+		y, _ := strconv.Atoi(year)
+		if !validDate(y, mo, d) {
+			return m
+		}
+		return fmt.Sprintf("%04d-%02d-%02d", y, mo, d)
+	})
+	return text, nil
+}
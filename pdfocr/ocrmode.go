@@ -0,0 +1,254 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// Modes of deciding which pages are OCR'd, in OCRConfig.OCRMode.
+const (
+	OCRModeAuto  = "auto"  // pages whose text layer does not account for the page (the default)
+	OCRModeForce = "force" // every page, its text layer ignored
+	OCRModeNever = "never" // no page, the text layer used as it is
+)
+
+const (
+	// minImageCoverage is the part of a page its images must cover for it
+	// to have anything to recognize beyond its text layer; smaller images
+	// are logos and icons.
+	minImageCoverage = 0.05
+
+	// scanImageCoverage is the part of a page its images cover on scans,
+	// whose text layer, if any, was added by an earlier OCR.
+	scanImageCoverage = 0.5
+
+	// minTextCoverage is the part of the image area of a scan its text
+	// layer lines must cover to be taken for its text; a broken hidden
+	// layer holds a few lines of a page full of text.
+	minTextCoverage = 0.03
+)
+
+// validateOCRMode checks an -ocr-mode given on the command line.
+func validateOCRMode(mode string) error {
+	switch mode {
+	case "", OCRModeAuto, OCRModeForce, OCRModeNever:
+		return nil
+	}
+	return fmt.Errorf("unsupported OCR mode %q (use auto, force or never)", mode)
+}
+
+// pageNeedsOCR decides whether a page with the cleaned text layer text is
+// OCR'd, following config.OCRMode. In OCRModeAuto pages of documents whose
+// images can be located are judged by needsCoverageOCR, the others by
+// needsOCR.
+func (src *pdfSource) pageNeedsOCR(pageNum int, text string, config OCRConfig) bool {
+	switch config.OCRMode {
+	case OCRModeForce:
+		return true
+	case OCRModeNever:
+		return false
+	}
+	if src.raw == nil || text == "" {
+		return needsOCR(text)
+	}
+	images := src.raw.imageCoverage(pageNum)
+	textCoverage := -1.0
+	if images >= scanImageCoverage && !needsOCR(text) {
+		if layout, ok := src.textLayout(pageNum, config); ok && layout.Width > 0 && layout.Height > 0 {
+			textCoverage = linesCoverage(layout)
+		}
+	}
+	ocr := needsCoverageOCR(text, images, textCoverage)
+	switch {
+	case ocr && !needsOCR(text):
+		config.debugf("Page %d: its text layer covers %.1f%% of a page %.0f%% covered by images\n", pageNum+1, textCoverage*100, images*100)
+	case !ocr && needsOCR(text):
+		config.debugf("Page %d has no images, keeping its short text layer\n", pageNum+1)
+	}
+	return ocr
+}
+
+// needsCoverageOCR reports whether a page whose cleaned text layer is text
+// counts as scanned, given the part of the page its images cover and the
+// part its text layer lines cover (negative when unknown). Unlike needsOCR
+// it keeps the short text of pages without images, such as a title page or
+// a page number on a mostly blank page, and OCRs scans whose text layer
+// covers a small part of the scan however much text it has.
+func needsCoverageOCR(text string, images, textCoverage float64) bool {
+	switch {
+	case text == "" || isXFAPlaceholderText(text):
+		return true
+	case images < minImageCoverage:
+		return false
+	case needsOCR(text):
+		return true
+	}
+	return images >= scanImageCoverage && textCoverage >= 0 && textCoverage < minTextCoverage*images
+}
+
+// linesCoverage returns the part of a page covered by the boxes of its
+// text layer lines.
+func linesCoverage(layout PageLayout) float64 {
+	var area float64
+	for _, l := range layout.Lines {
+		area += math.Abs(l.X1-l.X0) * math.Abs(l.Y1-l.Y0)
+	}
+	return math.Min(area/(layout.Width*layout.Height), 1)
+}
+
+// imageCoverage returns the part of a page covered by the images it draws,
+// inline images and those of form XObjects included, from the placement
+// of every image in its content stream. Overlapping images count for each
+// of them, up to the whole page.
+func (pdf *rawPDF) imageCoverage(pageNum int) float64 {
+	page := pdf.page(pageNum)
+	box, ok := pdf.mediaBox(page)
+	if !ok {
+		return 0
+	}
+	var content []byte
+	contents := pdf.resolve(page["Contents"])
+	refs, isArray := contents.(pdfArray)
+	if !isArray {
+		refs = pdfArray{page["Contents"]}
+	}
+	for _, ref := range refs {
+		obj := pdf.object(ref)
+		if obj == nil {
+			continue
+		}
+		if data, err := pdf.decodeStream(obj); err == nil {
+			content = append(append(content, data...), '\n')
+		}
+	}
+	var area float64
+	pdf.imagePlacements(content, page["Resources"], [6]float64{1, 0, 0, 1, 0, 0}, 0, func(m [6]float64) {
+		x0, y0, x1, y1 := transformedBox([4]float64{0, 0, 1, 1}, m)
+		x0, y0 = max(x0, box[0]), max(y0, box[1])
+		x1, y1 = min(x1, box[2]), min(y1, box[3])
+		if x1 > x0 && y1 > y0 {
+			area += (x1 - x0) * (y1 - y0)
+		}
+	})
+	return math.Min(area/((box[2]-box[0])*(box[3]-box[1])), 1)
+}
+
+// imagePlacements calls image with the matrix of every image a content
+// stream draws, which maps the unit square to the image's place on the
+// page; ctm is the transformation in effect at the start of the stream.
+func (pdf *rawPDF) imagePlacements(content []byte, resources pdfValue, ctm [6]float64, depth int, image func(m [6]float64)) {
+	if depth > 8 {
+		return
+	}
+	p := &pdfParser{data: content}
+	var operands []pdfValue
+	var stack [][6]float64
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return
+		}
+		if c := p.data[p.pos]; isPDFDelimiter(c) || c == '+' || c == '-' || c == '.' || c >= '0' && c <= '9' {
+			v, err := p.parseValue()
+			if err != nil {
+				return
+			}
+			operands = append(operands, v)
+			continue
+		}
+		switch op := p.keyword(); op {
+		case "":
+			return
+		case "q":
+			stack = append(stack, ctm)
+		case "Q":
+			if len(stack) > 0 {
+				ctm, stack = stack[len(stack)-1], stack[:len(stack)-1]
+			}
+		case "cm":
+			if m, ok := pdfMatrix(operands); ok {
+				ctm = multiplyMatrix(m, ctm)
+			}
+		case "BI":
+			// Inline image data runs from ID to EI
+			end := bytes.Index(p.data[p.pos:], []byte("EI"))
+			if end < 0 {
+				return
+			}
+			p.pos += end + 2
+			image(ctm)
+		case "Do":
+			if len(operands) == 0 {
+				break
+			}
+			name, _ := operands[len(operands)-1].(pdfName)
+			obj := pdf.object(pdf.dict(pdf.dict(resources)["XObject"])[string(name)])
+			if obj == nil {
+				break
+			}
+			dict, _ := obj.Value.(pdfDict)
+			switch pdf.name(dict["Subtype"]) {
+			case "Image":
+				image(ctm)
+			case "Form":
+				m, ok := pdfMatrix(pdf.array(dict["Matrix"]))
+				if !ok {
+					m = [6]float64{1, 0, 0, 1, 0, 0}
+				}
+				formResources := dict["Resources"]
+				if formResources == nil {
+					formResources = resources
+				}
+				if data, err := pdf.decodeStream(obj); err == nil {
+					pdf.imagePlacements(data, formResources, multiplyMatrix(m, ctm), depth+1, image)
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// mediaBox returns the media box of a page as x0, y0, x1, y1, or false when
+// it has none or it is empty.
+func (pdf *rawPDF) mediaBox(page pdfDict) ([4]float64, bool) {
+	box := pdf.array(page["MediaBox"])
+	if len(box) != 4 {
+		return [4]float64{}, false
+	}
+	var coords [4]float64
+	for i, v := range box {
+		coords[i], _ = pdf.resolve(v).(float64)
+	}
+	x0, x1 := math.Min(coords[0], coords[2]), math.Max(coords[0], coords[2])
+	y0, y1 := math.Min(coords[1], coords[3]), math.Max(coords[1], coords[3])
+	return [4]float64{x0, y0, x1, y1}, x1 > x0 && y1 > y0
+}
+
+// pdfMatrix reads the last six operands, or array elements, as a matrix.
+func pdfMatrix(values []pdfValue) ([6]float64, bool) {
+	var m [6]float64
+	if len(values) < 6 {
+		return m, false
+	}
+	for i, v := range values[len(values)-6:] {
+		f, ok := v.(float64)
+		if !ok {
+			return m, false
+		}
+		m[i] = f
+	}
+	return m, true
+}
+
+// multiplyMatrix returns the matrix applying m, then n.
+func multiplyMatrix(m, n [6]float64) [6]float64 {
+	return [6]float64{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
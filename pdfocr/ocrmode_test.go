@@ -0,0 +1,60 @@
+package pdfocr
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestNeedsCoverageOCR(t *testing.T) {
+	paragraph := "The quick brown fox jumps over the lazy dog, twice over and over."
+	tests := []struct {
+		name                 string
+		text                 string
+		images, textCoverage float64
+		want                 bool
+	}{
+		{"no text layer", "", 0, -1, true},
+		{"page number without images", "12", 0, -1, false},
+		{"page number beside a logo", "12", 0.02, -1, false},
+		{"caption under a photo", "Figure 3", 0.3, -1, true},
+		{"paragraph without images", paragraph, 0, -1, false},
+		{"scan with its text layer", paragraph, 1, 0.4, false},
+		{"scan with a broken text layer", paragraph, 1, 0.01, true},
+		{"scan, text lines unknown", paragraph, 1, -1, false},
+		{"XFA placeholder", "Please wait while the form loads. Your viewer does not support XFA forms.", 0, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsCoverageOCR(tt.text, tt.images, tt.textCoverage); got != tt.want {
+				t.Errorf("needsCoverageOCR(%q, %g, %g) = %v, want %v", tt.text, tt.images, tt.textCoverage, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageCoverage(t *testing.T) {
+	content := "q 100 0 0 100 0 0 cm /Im1 Do Q\n" +
+		"q 0.5 0 0 0.5 100 0 cm /Fm1 Do Q\n" +
+		"q 20 0 0 20 150 50 cm BI /W 1 /H 1 /BPC 8 /CS /G ID \x80 EI Q\n" +
+		"BT /F1 12 Tf 10 90 Td (Do not count this text) Tj ET\n"
+	form := "100 0 0 50 0 0 cm /Im1 Do"
+	data := []byte("%PDF-1.4\n" +
+		"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n" +
+		"2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n" +
+		"3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Contents 4 0 R" +
+		" /Resources << /XObject << /Im1 5 0 R /Fm1 6 0 R >> >> >> endobj\n" +
+		fmt.Sprintf("4 0 obj << /Length %d >>\nstream\n%s\nendstream endobj\n", len(content), content) +
+		"5 0 obj << /Type /XObject /Subtype /Image /Width 1 /Height 1 /BitsPerComponent 8 /ColorSpace /DeviceGray /Length 1 >>\nstream\n\x00\nendstream endobj\n" +
+		fmt.Sprintf("6 0 obj << /Type /XObject /Subtype /Form /BBox [0 0 1 1] /Length %d >>\nstream\n%s\nendstream endobj\n", len(form), form) +
+		"trailer << /Root 1 0 R >>\n%%EOF\n")
+	pdf, err := parseRawPDF(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 100x100 drawn directly, 50x25 through the form and 20x20 inline, on
+	// a 200x100 page
+	if got, want := pdf.imageCoverage(0), (10000.0+1250+400)/20000; math.Abs(got-want) > 1e-9 {
+		t.Errorf("imageCoverage() = %g, want %g", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package pdfocr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// queueDoneDir is the directory of OCRConfig.OfflineQueue the synced
+// entries are moved to.
+const queueDoneDir = "done"
+
+// QueuedPage is an entry of OCRConfig.OfflineQueue: a page image a cloud
+// engine could not be reached for, stored as <ID>.png next to this as
+// <ID>.json. Once synced it holds the cloud engine's text and is moved to
+// the done directory of the queue.
+type QueuedPage struct {
+	ID       string         `json:"id"`
+	Document string         `json:"document"`
+	Page     int            `json:"page"` // 1-based
+	Method   string         `json:"method,omitempty"`
+	Engine   string         `json:"engine"`
+	Language string         `json:"language"`
+	Options  PageOCROptions `json:"options"`
+	Queued   time.Time      `json:"queued"`
+	Synced   *time.Time     `json:"synced,omitempty"`
+	Text     string         `json:"text,omitempty"`
+
+	// Text or JSON output holding the page, which SyncQueue updates ("" when
+	// the output is missing or written in a form it cannot update), its
+	// format, and the provisional text of the page in it
+	Output          string `json:"output,omitempty"`
+	OutputFormat    string `json:"output_format,omitempty"`
+	ProvisionalText string `json:"provisional_text,omitempty"`
+}
+
+// queueOffline stores the page image of a page in config.OfflineQueue for
+// SyncQueue and returns the ID of its entry.
+func (src *pdfSource) queueOffline(pageNum int, config OCRConfig, opts PageOCROptions) (string, error) {
+	dpi := opts.DPI
+	if dpi == 0 {
+		dpi = config.DPI
+	}
+	img, err := src.renderForOCR(pageNum, dpi, config)
+	if err != nil {
+		return "", fmt.Errorf("error rendering page image: %w", err)
+	}
+	img = src.maskIgnored(img, pageNum, config, dpi)
+	if err := os.MkdirAll(config.OfflineQueue, 0755); err != nil {
+		return "", fmt.Errorf("error creating offline queue: %w", err)
+	}
+	f, err := os.CreateTemp(config.OfflineQueue, fmt.Sprintf("%s-p%04d-*.png", runID, pageNum+1))
+	if err != nil {
+		return "", fmt.Errorf("error queuing page: %w", err)
+	}
+	err = png.Encode(f, img)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	id := strings.TrimSuffix(filepath.Base(f.Name()), ".png")
+	opts.DPI = dpi
+	entry := QueuedPage{ID: id, Document: src.name, Page: pageNum + 1, Engine: config.Engine, Language: config.Language, Options: opts, Queued: time.Now()}
+	entry.Output, entry.OutputFormat = mergeableOutput(config)
+	if err == nil {
+		err = writeQueueEntry(filepath.Join(config.OfflineQueue, id+".json"), entry)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("error queuing page: %w", err)
+	}
+	return id, nil
+}
+
+// mergeableOutput returns config.OutputFile and its format when SyncQueue
+// can replace a page of it: text or JSON written as is, without encoding,
+// whitespace, locale or compression post-processing that would hide the
+// provisional text.
+func mergeableOutput(config OCRConfig) (string, string) {
+	if config.OutputFile == "" || config.NormalizeLocale != "" {
+		return "", ""
+	}
+	switch strings.ToLower(filepath.Ext(config.OutputFile)) {
+	case ".gz", ".zst":
+		return "", ""
+	}
+	switch config.OutputFormat {
+	case "", FormatText:
+		if config.Newline != "" || config.TabWidth > 0 || config.TrimTrailingSpace || config.CollapseBlankLines || (config.Encoding != "" && config.Encoding != EncodingUTF8) {
+			return "", ""
+		}
+	case FormatJSON:
+	default:
+		return "", ""
+	}
+	output, err := filepath.Abs(config.OutputFile)
+	if err != nil {
+		return "", ""
+	}
+	return output, config.OutputFormat
+}
+
+// recordProvisional stores the text a provisional page has in the output
+// in its queue entry, for SyncQueue to find it there.
+func (src *pdfSource) recordProvisional(page PageResult, config OCRConfig) {
+	path := filepath.Join(config.OfflineQueue, page.QueueID+".json")
+	entry, err := readQueueEntry(path)
+	if err == nil {
+		entry.Method, entry.ProvisionalText = page.Method, page.Text
+		err = writeQueueEntry(path, entry)
+	}
+	if err != nil {
+		src.warnings.warnf(page.Page-1, "Warning: could not record the provisional text of page %d: %v\n", page.Page, err)
+	}
+}
+
+func readQueueEntry(path string) (QueuedPage, error) {
+	var entry QueuedPage
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, fmt.Errorf("error reading queue entry: %w", err)
+	}
+	return entry, nil
+}
+
+func writeQueueEntry(path string, entry QueuedPage) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'))
+}
+
+// SyncQueue recognizes the pages waiting in the offline queue dir with the
+// cloud engines and page options they were queued with, oldest first,
+// replaces their provisional text in the text or JSON output they were
+// written to, and moves every entry recognized to the done directory of
+// the queue with its text; for other outputs that is where the text ends
+// up. It stops at the first page whose engine is still unreachable,
+// returning an error wrapping ErrCloudUnreachable; pages the service fails
+// otherwise are reported as warnings and stay queued. config supplies the
+// other settings of the recognition.
+func SyncQueue(ctx context.Context, dir string, config OCRConfig) ([]QueuedPage, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var synced []QueuedPage
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return synced, err
+		}
+		entry, err := syncQueueEntry(dir, path, config)
+		if errors.Is(err, ErrCloudUnreachable) {
+			return synced, err
+		}
+		if err != nil {
+			warnf("Warning: %s stays queued: %v\n", path, err)
+			continue
+		}
+		synced = append(synced, entry)
+	}
+	return synced, nil
+}
+
+// syncQueueEntry recognizes the page of the queue entry at path, updates
+// its output and moves it to the done directory.
+func syncQueueEntry(dir, path string, config OCRConfig) (QueuedPage, error) {
+	entry, err := readQueueEntry(path)
+	if err != nil {
+		return entry, err
+	}
+	engine, err := lookupEngine(entry.Engine)
+	if err != nil {
+		return entry, err
+	}
+	imagePath := filepath.Join(dir, entry.ID+".png")
+	f, err := os.Open(imagePath)
+	if err != nil {
+		return entry, err
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		return entry, fmt.Errorf("error reading page image: %w", err)
+	}
+	config.Engine, config.Language = entry.Engine, entry.Language
+	text, err := engine.Text(img, config, entry.Options)
+	if err != nil {
+		return entry, err
+	}
+
+	now := time.Now()
+	entry.Text, entry.Synced = applyBidiMarks(repairWrappedURLs(text)), &now
+	if entry.Output != "" {
+		if err := mergeQueuedPage(entry); err != nil {
+			warnf("Warning: could not update page %d in %s, its text is in %s: %v\n", entry.Page, entry.Output, filepath.Join(dir, queueDoneDir, entry.ID+".json"), err)
+			entry.Output = ""
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, queueDoneDir), 0755); err != nil {
+		return entry, err
+	}
+	if err := writeQueueEntry(filepath.Join(dir, queueDoneDir, entry.ID+".json"), entry); err != nil {
+		return entry, err
+	}
+	os.Remove(imagePath)
+	os.Remove(path)
+	return entry, nil
+}
+
+// mergeQueuedPage replaces the provisional text of the page of a synced
+// entry in its output with the text of the entry.
+func mergeQueuedPage(entry QueuedPage) error {
+	data, err := os.ReadFile(entry.Output)
+	if err != nil {
+		return err
+	}
+	if entry.OutputFormat == FormatJSON {
+		var doc jsonDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		i := slices.IndexFunc(doc.Pages, func(p PageResult) bool { return p.QueueID == entry.ID })
+		if i < 0 {
+			return fmt.Errorf("the output has no page queued as %s", entry.ID)
+		}
+		// The words and confidence were the local engine's
+		p := &doc.Pages[i]
+		p.Text, p.Paragraphs, p.Provisional = entry.Text, textParagraphs(entry.Text), false
+		p.Words, p.Confidence, p.LowConfidence = nil, 0, false
+		if data, err = json.MarshalIndent(doc, "", "  "); err != nil {
+			return err
+		}
+		return writeFileAtomic(entry.Output, append(data, '\n'))
+	}
+	heading := pageSeparator(entry.Page, entry.Method, 0)
+	old := heading + entry.ProvisionalText + "\n\n"
+	if entry.Method == "" || strings.Count(string(data), old) != 1 {
+		return fmt.Errorf("the provisional text of the page is no longer in the output")
+	}
+	return writeFileAtomic(entry.Output, []byte(strings.Replace(string(data), old, heading+entry.Text+"\n\n", 1)))
+}
@@ -0,0 +1,249 @@
+//go:build onnx
+
+package pdfocr
+
+import (
+	"image"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Geometry of the ONNX models: page images are scaled for detection so
+// that their longer side is at most onnxDetectorMaxSide pixels, and text
+// lines to onnxLineHeight pixels for recognition unless the recognition
+// model has a fixed input height (48 for PP-OCRv3 and later).
+const (
+	onnxDetectorMaxSide = 1600
+	onnxLineHeight      = 48
+)
+
+// Thresholds of the DBNet probability map: pixels above
+// onnxTextThreshold are text, and a box is kept when its pixels average
+// onnxBoxThreshold. Boxes grow by onnxUnclipRatio times their area over
+// their perimeter, since the model marks the shrunk core of the text.
+const (
+	onnxTextThreshold = 0.3
+	onnxBoxThreshold  = 0.6
+	onnxUnclipRatio   = 1.5
+)
+
+// detectorInput scales a page image so that its longer side is at most
+// onnxDetectorMaxSide and both sides are multiples of 32, as DBNet needs,
+// and returns it as a 1x3xHxW tensor normalized with the ImageNet mean and
+// deviation, with its width and height.
+func detectorInput(gray *image.Gray) ([]float32, int, int) {
+	size := gray.Rect.Size()
+	scale := math.Min(1, onnxDetectorMaxSide/float64(max(size.X, size.Y)))
+	w := max(32, int(math.Round(float64(size.X)*scale/32))*32)
+	h := max(32, int(math.Round(float64(size.Y)*scale/32))*32)
+	mean := [3]float32{0.485, 0.456, 0.406}
+	std := [3]float32{0.229, 0.224, 0.225}
+	data := make([]float32, 3*w*h)
+	sx, sy := float64(size.X)/float64(w), float64(size.Y)/float64(h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := sampleGray(gray, (float64(x)+0.5)*sx-0.5, (float64(y)+0.5)*sy-0.5) / 255
+			for c := 0; c < 3; c++ {
+				data[c*w*h+y*w+x] = (v - mean[c]) / std[c]
+			}
+		}
+	}
+	return data, w, h
+}
+
+// recognizerInput crops box from a page image and scales it to height h,
+// keeping its aspect ratio, as a 1x3xhxW tensor normalized to [-1, 1]. A
+// model with a fixed input width fixedW gets the line squeezed into it if
+// need be and padded to it; W is returned.
+func recognizerInput(gray *image.Gray, box image.Rectangle, h, fixedW int) ([]float32, int) {
+	w := max(1, int(math.Ceil(float64(box.Dx())*float64(h)/float64(box.Dy()))))
+	width := w
+	if fixedW > 0 {
+		w, width = min(w, fixedW), fixedW
+	}
+	data := make([]float32, 3*width*h)
+	sx, sy := float64(box.Dx())/float64(w), float64(box.Dy())/float64(h)
+	origin := box.Min.Sub(gray.Rect.Min)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := sampleGray(gray, float64(origin.X)+(float64(x)+0.5)*sx-0.5, float64(origin.Y)+(float64(y)+0.5)*sy-0.5)/127.5 - 1
+			for c := 0; c < 3; c++ {
+				data[c*width*h+y*width+x] = v
+			}
+		}
+	}
+	return data, width
+}
+
+// sampleGray returns the bilinear interpolation of a gray image at x, y,
+// relative to its top-left corner and clamped to it.
+func sampleGray(gray *image.Gray, x, y float64) float32 {
+	size := gray.Rect.Size()
+	x = math.Max(0, math.Min(x, float64(size.X-1)))
+	y = math.Max(0, math.Min(y, float64(size.Y-1)))
+	x0, y0 := int(x), int(y)
+	x1, y1 := min(x0+1, size.X-1), min(y0+1, size.Y-1)
+	fx, fy := x-float64(x0), y-float64(y0)
+	at := func(x, y int) float64 { return float64(gray.Pix[y*gray.Stride+x]) }
+	top := at(x0, y0)*(1-fx) + at(x1, y0)*fx
+	bottom := at(x0, y1)*(1-fx) + at(x1, y1)*fx
+	return float32(top*(1-fy) + bottom*fy)
+}
+
+// textBoxes returns the text boxes of a w by h DBNet probability map, in
+// pixels of the page image of size it was computed for, top to bottom.
+// Boxes are the bounds of the connected regions of text pixels, kept when
+// confident enough and grown by their unclip distance.
+func textBoxes(probs []float32, w, h int, size image.Point) []image.Rectangle {
+	seen := make([]bool, w*h)
+	sx, sy := float64(size.X)/float64(w), float64(size.Y)/float64(h)
+	var boxes []image.Rectangle
+	var stack []int
+	for start := range probs[:w*h] {
+		if seen[start] || probs[start] <= onnxTextThreshold {
+			continue
+		}
+		seen[start] = true
+		stack = append(stack[:0], start)
+		r := image.Rect(start%w, start/w, start%w+1, start/w+1)
+		var sum float64
+		var n int
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%w, i/w
+			sum += float64(probs[i])
+			n++
+			r = r.Union(image.Rect(x, y, x+1, y+1))
+			for _, j := range [4]int{i - 1, i + 1, i - w, i + w} {
+				if j < 0 || j >= w*h || (j == i-1 && x == 0) || (j == i+1 && x == w-1) {
+					continue
+				}
+				if !seen[j] && probs[j] > onnxTextThreshold {
+					seen[j] = true
+					stack = append(stack, j)
+				}
+			}
+		}
+		if r.Dx() < 3 || r.Dy() < 3 || sum/float64(n) < onnxBoxThreshold {
+			continue
+		}
+		d := float64(r.Dx()*r.Dy()) * onnxUnclipRatio / float64(2*(r.Dx()+r.Dy()))
+		box := image.Rect(
+			int(math.Floor((float64(r.Min.X)-d)*sx)), int(math.Floor((float64(r.Min.Y)-d)*sy)),
+			int(math.Ceil((float64(r.Max.X)+d)*sx)), int(math.Ceil((float64(r.Max.Y)+d)*sy)),
+		).Intersect(image.Rectangle{Max: size})
+		if !box.Empty() {
+			boxes = append(boxes, box)
+		}
+	}
+	sort.SliceStable(boxes, func(i, j int) bool {
+		if boxes[i].Min.Y != boxes[j].Min.Y {
+			return boxes[i].Min.Y < boxes[j].Min.Y
+		}
+		return boxes[i].Min.X < boxes[j].Min.X
+	})
+	return boxes
+}
+
+// ctcChar is a character read by a recognition model, with the time step
+// it was read at and its probability.
+type ctcChar struct {
+	text string
+	step int
+	prob float32
+}
+
+// ctcCharset returns the characters of the classes of a recognition model
+// with classes outputs: class 0 is the CTC blank, the charset follows, and
+// a last class beyond it is the space PP-OCR models add.
+func ctcCharset(charset []string, classes int) []string {
+	if classes == len(charset)+2 {
+		return append(charset[:len(charset):len(charset)], " ")
+	}
+	return charset
+}
+
+// ctcDecode decodes the steps by classes output of a recognition model
+// greedily: the most likely class of every step, with repeats collapsed
+// and blanks dropped.
+func ctcDecode(probs []float32, steps, classes int, charset []string) []ctcChar {
+	var chars []ctcChar
+	prev := 0
+	for t := 0; t < steps; t++ {
+		row := probs[t*classes : (t+1)*classes]
+		best := 0
+		for c := range row {
+			if row[c] > row[best] {
+				best = c
+			}
+		}
+		if best != 0 && best != prev && best-1 < len(charset) {
+			chars = append(chars, ctcChar{text: charset[best-1], step: t, prob: row[best]})
+		}
+		prev = best
+	}
+	return chars
+}
+
+// lineWords splits the characters read in a text box of a steps long
+// recognition into words, placed in the box by the steps they were read
+// at.
+func lineWords(chars []ctcChar, box image.Rectangle, steps int) []OCRWord {
+	var words []OCRWord
+	stepX := func(t int) int { return box.Min.X + t*box.Dx()/steps }
+	var text strings.Builder
+	var first, last int
+	var prob float32
+	var n int
+	flush := func() {
+		if n > 0 {
+			words = append(words, OCRWord{
+				Text:       text.String(),
+				Box:        image.Rect(stepX(first), box.Min.Y, stepX(last+1), box.Max.Y),
+				Confidence: float64(prob) / float64(n) * 100,
+			})
+		}
+		text.Reset()
+		prob, n = 0, 0
+	}
+	for _, c := range chars {
+		if strings.TrimSpace(c.text) == "" {
+			flush()
+			continue
+		}
+		if n == 0 {
+			first = c.step
+		}
+		text.WriteString(c.text)
+		last = c.step
+		prob += c.prob
+		n++
+	}
+	flush()
+	return words
+}
+
+// groupLines groups words into lines, top to bottom and left to right: a
+// word joins the line whose vertical middle is within its height.
+func groupLines(words []OCRWord) [][]OCRWord {
+	sort.SliceStable(words, func(i, j int) bool {
+		return words[i].Box.Min.Y+words[i].Box.Max.Y < words[j].Box.Min.Y+words[j].Box.Max.Y
+	})
+	var lines [][]OCRWord
+	for _, w := range words {
+		if n := len(lines); n > 0 {
+			first := lines[n-1][0].Box
+			if mid := (first.Min.Y + first.Max.Y) / 2; mid >= w.Box.Min.Y && mid < w.Box.Max.Y {
+				lines[n-1] = append(lines[n-1], w)
+				continue
+			}
+		}
+		lines = append(lines, []OCRWord{w})
+	}
+	for _, line := range lines {
+		sort.SliceStable(line, func(i, j int) bool { return line[i].Box.Min.X < line[j].Box.Min.X })
+	}
+	return lines
+}
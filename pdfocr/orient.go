@@ -0,0 +1,126 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"strconv"
+	"strings"
+)
+
+// minOrientationConfidence is the orientation confidence reported by
+// Tesseract's OSD from which a page is rotated; below it, pages with little
+// text are easily turned the wrong way.
+const minOrientationConfidence = 14
+
+// detectOSD runs the orientation and script detection of engine on a
+// rendered page when config.AutoRotate or config.AutoLanguage needs it.
+func detectOSD(img image.Image, pageNum int, engine Engine, config OCRConfig) pageOSD {
+	oe, ok := engine.(osdEngine)
+	if !ok || (!config.AutoRotate && !config.AutoLanguage) {
+		return pageOSD{}
+	}
+	osd, err := oe.OSD(img, config)
+	if err != nil {
+		config.warnings.warnf(pageNum, "Warning: could not detect the orientation and script of page %d: %v\n", pageNum+1, err)
+	}
+	return osd
+}
+
+// orientPage turns a rendered page upright with config.AutoRotate and
+// returns it with the clockwise rotation in degrees applied to it.
+func orientPage(img image.Image, pageNum int, osd pageOSD, config OCRConfig) (image.Image, int) {
+	if !config.AutoRotate || osd.Rotation == 0 || osd.OrientationConfidence < minOrientationConfidence {
+		return img, 0
+	}
+	config.debugf("Page %d: rotating %d° (orientation confidence %.1f)\n", pageNum+1, osd.Rotation, osd.OrientationConfidence)
+	return rotateImage(img, osd.Rotation), osd.Rotation
+}
+
+// uprightPage prepares a rendered page for recognition: turned upright with
+// config.AutoRotate and its languages picked with config.AutoLanguage, from
+// one OSD run. It returns the image, the rotation applied and the languages
+// to recognize ("" keeps config.Language).
+func uprightPage(img image.Image, pageNum int, engine Engine, config OCRConfig) (image.Image, int, string) {
+	osd := detectOSD(img, pageNum, engine, config)
+	img, rotation := orientPage(img, pageNum, osd, config)
+	return img, rotation, pageLanguage(img, pageNum, osd, engine, config)
+}
+
+// rotateImage turns img clockwise by a multiple of 90 degrees.
+func rotateImage(img image.Image, deg int) image.Image {
+	deg = ((deg % 360) + 360) % 360
+	if deg == 0 {
+		return img
+	}
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	if deg != 180 {
+		out = image.NewRGBA(image.Rect(0, 0, h, w))
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var nx, ny int
+			switch deg {
+			case 90:
+				nx, ny = h-1-y, x
+			case 180:
+				nx, ny = w-1-x, h-1-y
+			default:
+				nx, ny = y, w-1-x
+			}
+			i, j := src.PixOffset(x, y), out.PixOffset(nx, ny)
+			copy(out.Pix[j:j+4], src.Pix[i:i+4])
+		}
+	}
+	return out
+}
+
+// unrotateWords moves the boxes of words recognized on a page image turned
+// clockwise by deg degrees back onto the unturned image of the given size.
+func unrotateWords(words []OCRWord, deg int, size image.Point) []OCRWord {
+	w, h := size.X, size.Y
+	for i, word := range words {
+		r := word.Box
+		switch ((deg % 360) + 360) % 360 {
+		case 90:
+			words[i].Box = image.Rect(r.Min.Y, h-r.Max.X, r.Max.Y, h-r.Min.X)
+		case 180:
+			words[i].Box = image.Rect(w-r.Max.X, h-r.Max.Y, w-r.Min.X, h-r.Min.Y)
+		case 270:
+			words[i].Box = image.Rect(w-r.Max.Y, r.Min.X, w-r.Min.Y, r.Max.X)
+		}
+	}
+	return words
+}
+
+// parseTesseractOSD reads the output of "tesseract --psm 0", which holds
+// lines such as "Rotate: 180", "Orientation confidence: 12.34", "Script:
+// Latin" and "Script confidence: 3.21".
+func parseTesseractOSD(out string) (pageOSD, error) {
+	osd := pageOSD{Rotation: -1}
+	for _, line := range strings.Split(out, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "Rotate":
+			osd.Rotation, _ = strconv.Atoi(value)
+		case "Orientation confidence":
+			osd.OrientationConfidence, _ = strconv.ParseFloat(value, 64)
+		case "Script":
+			osd.Script = value
+		case "Script confidence":
+			osd.ScriptConfidence, _ = strconv.ParseFloat(value, 64)
+		}
+	}
+	if osd.Rotation < 0 {
+		return pageOSD{}, fmt.Errorf("no orientation in OSD output")
+	}
+	return osd, nil
+}
@@ -0,0 +1,239 @@
+package pdfocr
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+const (
+	// headingScale is how much taller than the body text a line must be to
+	// be taken for a heading.
+	headingScale = 1.3
+
+	// headingLevelStep separates heading levels: a heading this much
+	// smaller than the smallest of a level starts the next level.
+	headingLevelStep = 1.15
+
+	// maxHeadingWords and maxOutlineLevels keep large print such as pull
+	// quotes and deeply nested levels out of the outline.
+	maxHeadingWords  = 12
+	maxOutlineLevels = 3
+
+	// minOutlineLines is the number of lines needed to tell body text from
+	// headings.
+	minOutlineLines = 5
+)
+
+// OutlineEntry is a heading of a document outline.
+type OutlineEntry struct {
+	Title string  `json:"title"`
+	Level int     `json:"level"` // 1 for the largest headings
+	Page  int     `json:"page"`  // 1-based
+	Top   float64 `json:"top"`   // position from the top of the page, as a fraction of its height
+}
+
+// headingLine is a recognized line considered for the outline, with its
+// height in points so pages rendered at different resolutions compare.
+type headingLine struct {
+	page   int
+	index  int // line number on the page
+	text   string
+	height float64
+	words  int
+	top    float64
+	markup string // the text with its superscripts and subscripts marked up, if any
+}
+
+// headingLines returns the lines of the words recognized on a page image
+// pageHeight pixels tall, rendered at dpi (0 meaning defaultRenderDPI).
+func headingLines(page int, words []OCRWord, pageHeight int, dpi float64) []headingLine {
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	var out []headingLine
+	for i, l := range wordLines(words) {
+		if l.Box.Dy() <= 0 || pageHeight <= 0 {
+			continue
+		}
+		out = append(out, headingLine{
+			page: page, index: i, text: strings.TrimSpace(l.Text),
+			height: float64(l.Box.Dy()) * 72 / dpi, words: len(l.Words),
+			top: float64(l.Box.Min.Y) / float64(pageHeight), markup: scriptMarkup(l.Words),
+		})
+	}
+	return out
+}
+
+// buildOutline picks the headings among the lines of a document: short
+// lines clearly taller than the median line. Heading sizes are grouped into
+// levels, and headings spanning consecutive lines are joined.
+func buildOutline(lines []headingLine) []OutlineEntry {
+	if len(lines) < minOutlineLines {
+		return nil
+	}
+	heights := make([]float64, len(lines))
+	for i, l := range lines {
+		heights[i] = l.height
+	}
+	sort.Float64s(heights)
+	body := heights[len(heights)/2]
+
+	var headings []headingLine
+	for _, l := range lines {
+		if l.height >= body*headingScale && l.words <= maxHeadingWords && strings.IndexFunc(l.text, unicode.IsLetter) >= 0 {
+			headings = append(headings, l)
+		}
+	}
+	if len(headings) == 0 {
+		return nil
+	}
+
+	sizes := make([]float64, len(headings))
+	for i, h := range headings {
+		sizes[i] = h.height
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+	levels := map[float64]int{}
+	level, ref := 1, sizes[0]
+	for _, size := range sizes {
+		if size*headingLevelStep < ref && level < maxOutlineLevels {
+			level, ref = level+1, size
+		}
+		levels[size] = level
+	}
+
+	var outline []OutlineEntry
+	var prev headingLine
+	for _, h := range headings {
+		entry := OutlineEntry{Title: h.text, Level: levels[h.height], Page: h.page, Top: h.top}
+		if n := len(outline); n > 0 && prev.page == h.page && prev.index+1 == h.index && outline[n-1].Level == entry.Level {
+			outline[n-1].Title += " " + h.text
+		} else {
+			outline = append(outline, entry)
+		}
+		prev = h
+	}
+	return outline
+}
+
+// outlineMarkdown renders an outline as a nested Markdown list.
+func outlineMarkdown(outline []OutlineEntry) []byte {
+	var b strings.Builder
+	b.WriteString("# Contents\n\n")
+	for _, e := range outline {
+		fmt.Fprintf(&b, "%s- %s (page %d)\n", strings.Repeat("  ", e.Level-1), e.Title, e.Page)
+	}
+	return []byte(b.String())
+}
+
+// WriteOutline writes an outline as JSON if path ends in .json (before any
+// .gz or .zst) and as a Markdown table of contents otherwise, compressed and
+// encrypted like WriteManifest.
+func WriteOutline(path string, outline []OutlineEntry, key []byte) error {
+	var data []byte
+	base := strings.TrimSuffix(strings.TrimSuffix(path, ".gz"), ".zst")
+	if strings.EqualFold(filepath.Ext(base), ".json") {
+		if outline == nil {
+			outline = []OutlineEntry{}
+		}
+		var err error
+		if data, err = json.MarshalIndent(outline, "", "  "); err != nil {
+			return fmt.Errorf("error encoding outline: %w", err)
+		}
+		data = append(data, '\n')
+	} else {
+		data = outlineMarkdown(outline)
+	}
+	data, err := SealOutput(path, data, key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing outline: %w", err)
+	}
+	return nil
+}
+
+// pdfOutlineObjects returns the outline dictionary (object first) and its
+// items for a PDF whose pages are the objects pageObjects, pageHeights
+// points tall.
+func pdfOutlineObjects(outline []OutlineEntry, pageObjects []int, pageHeights []float64, first int) []string {
+	// parents[i] is the item under which item i nests, -1 for the top level
+	parents := make([]int, len(outline))
+	var stack []int
+	for i, e := range outline {
+		for len(stack) > 0 && outline[stack[len(stack)-1]].Level >= e.Level {
+			stack = stack[:len(stack)-1]
+		}
+		parents[i] = -1
+		if len(stack) > 0 {
+			parents[i] = stack[len(stack)-1]
+		}
+		stack = append(stack, i)
+	}
+	children := map[int][]int{}
+	for i, p := range parents {
+		children[p] = append(children[p], i)
+	}
+	ref := func(i int) string {
+		if i < 0 {
+			return fmt.Sprintf("%d 0 R", first)
+		}
+		return fmt.Sprintf("%d 0 R", first+1+i)
+	}
+	// descendants counts the open items below an item
+	var descendants func(i int) int
+	descendants = func(i int) int {
+		n := 0
+		for _, c := range children[i] {
+			n += 1 + descendants(c)
+		}
+		return n
+	}
+	links := func(i int) string {
+		kids := children[i]
+		if len(kids) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" /First %s /Last %s /Count %d", ref(kids[0]), ref(kids[len(kids)-1]), descendants(i))
+	}
+
+	objects := []string{"<< /Type /Outlines" + links(-1) + " >>"}
+	for i, e := range outline {
+		siblings := children[parents[i]]
+		pos := sort.SearchInts(siblings, i)
+		var b strings.Builder
+		fmt.Fprintf(&b, "<< /Title <feff%s> /Parent %s", hex.EncodeToString(utf16BE(e.Title)), ref(parents[i]))
+		if pos > 0 {
+			fmt.Fprintf(&b, " /Prev %s", ref(siblings[pos-1]))
+		}
+		if pos < len(siblings)-1 {
+			fmt.Fprintf(&b, " /Next %s", ref(siblings[pos+1]))
+		}
+		b.WriteString(links(i))
+		if p := e.Page - 1; p >= 0 && p < len(pageObjects) {
+			fmt.Fprintf(&b, " /Dest [%d 0 R /XYZ 0 %s null]", pageObjects[p], pdfNumber(math.Round(pageHeights[p]*(1-e.Top))))
+		}
+		b.WriteString(" >>")
+		objects = append(objects, b.String())
+	}
+	return objects
+}
+
+// utf16BE encodes s as big-endian UTF-16, the encoding of PDF text strings
+// after their byte order mark.
+func utf16BE(s string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
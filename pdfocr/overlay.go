@@ -0,0 +1,87 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// overlayBorder is the width in pixels of the boxes drawn around words.
+const overlayBorder = 3
+
+// confidenceColor maps a word confidence to a color from red (50 and
+// below) through yellow (75) to green (100).
+func confidenceColor(conf float64) color.RGBA {
+	t := (conf - 50) / 50
+	t = min(max(t, 0), 1)
+	if t < 0.5 {
+		return color.RGBA{R: 220, G: uint8(440 * t), A: 255}
+	}
+	return color.RGBA{R: uint8(440 * (1 - t)), G: 200, A: 255}
+}
+
+// drawOverlay returns a copy of img with every word boxed in its confidence
+// color over a light tint of the same color.
+func drawOverlay(img image.Image, words []OCRWord) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+
+	for _, w := range words {
+		c := confidenceColor(w.Confidence)
+		box := w.Box.Add(b.Min).Intersect(b)
+		if box.Empty() {
+			continue
+		}
+		tint := image.NewUniform(color.RGBA{R: c.R / 4, G: c.G / 4, B: c.B / 4, A: 64})
+		draw.Draw(out, box, tint, image.Point{}, draw.Over)
+
+		edge := image.NewUniform(c)
+		for _, r := range []image.Rectangle{
+			image.Rect(box.Min.X, box.Min.Y, box.Max.X, box.Min.Y+overlayBorder),
+			image.Rect(box.Min.X, box.Max.Y-overlayBorder, box.Max.X, box.Max.Y),
+			image.Rect(box.Min.X, box.Min.Y, box.Min.X+overlayBorder, box.Max.Y),
+			image.Rect(box.Max.X-overlayBorder, box.Min.Y, box.Max.X, box.Max.Y),
+		} {
+			draw.Draw(out, r.Intersect(box), edge, image.Point{}, draw.Src)
+		}
+	}
+	return out
+}
+
+// writeDebugOverlay saves the overlay of one page as
+// <dir>/<document>_page_<n>_<run>.png, run being the runID, so that runs
+// sharing the directory never overwrite each other's overlays.
+func writeDebugOverlay(dir, docName string, pageNum int, img image.Image, words []OCRWord) (err error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating overlay directory: %w", err)
+	}
+	base := strings.TrimSuffix(filepath.Base(docName), filepath.Ext(docName))
+	path := filepath.Join(dir, fmt.Sprintf("%s_page_%d_%s.png", base, pageNum+1, runID))
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("error creating overlay image: %w", err)
+	}
+	defer closeChecked(&err, f, "overlay image")
+	if err := png.Encode(f, drawOverlay(img, words)); err != nil {
+		return fmt.Errorf("error encoding overlay image: %w", err)
+	}
+	return nil
+}
+
+// debugOverlay writes the overlay of a recognized page when -debug-overlay
+// is set. Failures only cost the overlay, not the page text.
+func (src *pdfSource) debugOverlay(config OCRConfig, pageNum int, img image.Image, words []OCRWord) {
+	if config.DebugOverlayDir == "" {
+		return
+	}
+	if err := writeDebugOverlay(config.DebugOverlayDir, src.name, pageNum, img, words); err != nil {
+		src.warnings.warnf(pageNum, "Warning: could not write overlay for page %d: %v\n", pageNum+1, err)
+	}
+}
@@ -0,0 +1,109 @@
+package pdfocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Policies for pages that fail, in OCRConfig.ErrorPolicy.
+const (
+	ErrorPolicyCollect  = "collect"   // keep failed pages as MethodFailed results and report their errors (the default)
+	ErrorPolicySkip     = "skip"      // leave failed pages out of the results, with a warning
+	ErrorPolicyFailFast = "fail-fast" // stop the document at the first failed page with its *PageError
+)
+
+// Stages a page can fail at, in PageError.Stage.
+const (
+	PageErrorText  = "text"  // reading the text layer
+	PageErrorOCR   = "ocr"   // rendering and recognizing the page image
+	PageErrorCrash = "crash" // the sandbox worker processing the page crashed (ErrWorkerCrashed)
+)
+
+// validateErrorPolicy checks an -on-error policy given on the command line.
+func validateErrorPolicy(policy string) error {
+	switch policy {
+	case "", ErrorPolicyCollect, ErrorPolicySkip, ErrorPolicyFailFast:
+		return nil
+	}
+	return fmt.Errorf("unsupported error policy %q (use collect, skip or fail-fast)", policy)
+}
+
+// PageError is the failure of one page of a document.
+type PageError struct {
+	Document string
+	Page     int    // 1-based
+	Stage    string // PageErrorText, PageErrorOCR or PageErrorCrash
+	Err      error
+}
+
+func (e *PageError) Error() string {
+	switch e.Stage {
+	case PageErrorText:
+		return fmt.Sprintf("error extracting text from page %d of %s: %v", e.Page, e.Document, e.Err)
+	case PageErrorCrash:
+		return fmt.Sprintf("page %d of %s was skipped: %v", e.Page, e.Document, e.Err)
+	}
+	return fmt.Sprintf("OCR failed for page %d of %s: %v", e.Page, e.Document, e.Err)
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Err
+}
+
+// ExtractionReport lists the pages of a document and its sub-documents
+// that failed, with ErrorPolicyCollect.
+type ExtractionReport struct {
+	Document string
+	Pages    int // pages processed, failed ones included
+	Failed   []*PageError
+}
+
+// Err returns the errors of the failed pages joined, or nil when every
+// page succeeded.
+func (r ExtractionReport) Err() error {
+	errs := make([]error, len(r.Failed))
+	for i, e := range r.Failed {
+		errs[i] = e
+	}
+	return errors.Join(errs...)
+}
+
+// Report returns the report of the failed pages of a processed document
+// and its sub-documents.
+func (m DocumentManifest) Report() ExtractionReport {
+	r := ExtractionReport{Document: m.Name, Pages: len(m.PageResults)}
+	for _, p := range m.PageResults {
+		if p.err != nil {
+			r.Failed = append(r.Failed, p.err)
+		}
+	}
+	for _, member := range m.Members {
+		r.Failed = append(r.Failed, member.Report().Failed...)
+	}
+	return r
+}
+
+// ExtractWithReport extracts text like ExtractDocument and returns the
+// report of its failed pages in place of the manifest.
+func ExtractWithReport(ctx context.Context, pdfPath string, config OCRConfig) (string, ExtractionReport, error) {
+	text, manifest, err := ExtractDocument(ctx, pdfPath, config)
+	return text, manifest.Report(), err
+}
+
+// failPage applies config.ErrorPolicy to a page that failed at stage:
+// fail-fast returns the *PageError, the other policies record it on the
+// failed result with a warning.
+func (src *pdfSource) failPage(result *PageResult, stage string, err error, config OCRConfig) error {
+	pageErr := &PageError{Document: src.name, Page: result.Page, Stage: stage, Err: err}
+	if config.ErrorPolicy == ErrorPolicyFailFast {
+		return pageErr
+	}
+	if stage == PageErrorText {
+		src.warnings.warnf(result.Page-1, "Warning: could not extract the text of page %d: %v\n", result.Page, err)
+	} else {
+		src.warnings.warnf(result.Page-1, "Warning: OCR failed for page %d: %v\n", result.Page, err)
+	}
+	result.Method, result.Error, result.err = MethodFailed, err.Error(), pageErr
+	return nil
+}
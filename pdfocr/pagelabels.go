@@ -0,0 +1,217 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Problems found by comparing printed page numbers with the page order.
+const (
+	LabelMissing    = "missing"      // numbers skipped before the page
+	LabelOutOfOrder = "out-of-order" // the page is numbered out of sequence
+	LabelDuplicate  = "duplicate"    // the number was already seen, e.g. scanned twice
+)
+
+const (
+	// pageLabelZone is the height of the header and footer strips searched
+	// for page numbers, as a fraction of the page height. Each strip is
+	// split into three zones: the corners and the middle.
+	pageLabelZone = 0.08
+
+	// maxPageLabel bounds the numbers taken for page numbers, keeping years
+	// and amounts in footers out.
+	maxPageLabel = 2000
+)
+
+// pageLabelPattern matches a page number on its own: "12", "- 12 -",
+// "(12)", "Page 12" or "p. 12".
+var pageLabelPattern = regexp.MustCompile(`(?i)^(?:page\s+|p\.\s*)?[-–—(\[]?\s*(\d{1,4})\s*[-–—)\]]?$`)
+
+// PageLabelIssue is a disagreement between the printed page numbers and the
+// physical order of the pages.
+type PageLabelIssue struct {
+	Page  int    `json:"page"`  // physical page, 1-based
+	Label string `json:"label"` // the printed number(s) concerned
+	Issue string `json:"issue"` // LabelMissing, LabelOutOfOrder or LabelDuplicate
+
+	// Count is the number of missing pages when unnumbered pages in the gap
+	// may hold some of the numbers in Label.
+	Count int `json:"count,omitempty"`
+}
+
+func (i PageLabelIssue) String() string {
+	switch i.Issue {
+	case LabelMissing:
+		if i.Count > 0 {
+			return fmt.Sprintf("pages are missing before page %d: %d of the numbers %s", i.Page, i.Count, i.Label)
+		}
+		return fmt.Sprintf("page numbers %s are missing before page %d", i.Label, i.Page)
+	case LabelDuplicate:
+		return fmt.Sprintf("page %d is numbered %s again, possibly scanned twice", i.Page, i.Label)
+	}
+	return fmt.Sprintf("page %d is numbered %s, out of order", i.Page, i.Label)
+}
+
+// parsePageLabel returns the page number in s, or "" if s is not a page
+// number on its own.
+func parsePageLabel(s string) string {
+	m := pageLabelPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return ""
+	}
+	n, _ := strconv.Atoi(m[1])
+	if n <= 0 || n > maxPageLabel {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// textPageLabel returns the page number printed in the last or first line of
+// a page's text layer.
+func textPageLabel(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if label := parsePageLabel(lines[len(lines)-1]); label != "" {
+		return label
+	}
+	return parsePageLabel(lines[0])
+}
+
+// pageLabelOCROptions recognizes one line of digits.
+func pageLabelOCROptions() PageOCROptions {
+	return PageOCROptions{Whitelist: "0123456789", SingleLine: true}
+}
+
+// ocrPageLabel reads the page number of a rendered page from the footer and
+// header zones, footer first, returning "" if none holds a number alone.
+func ocrPageLabel(img image.Image, engine Engine, config OCRConfig) string {
+	b := img.Bounds()
+	strip := int(float64(b.Dy()) * pageLabelZone)
+	third := b.Dx() / 3
+	if strip <= 0 || third <= 0 {
+		return ""
+	}
+	bottom, top := b.Max.Y-strip, b.Min.Y
+	left, middle, right := b.Min.X, b.Min.X+third, b.Max.X-third
+	zones := []image.Rectangle{
+		image.Rect(middle, bottom, middle+third, b.Max.Y),
+		image.Rect(right, bottom, b.Max.X, b.Max.Y),
+		image.Rect(left, bottom, left+third, b.Max.Y),
+		image.Rect(right, top, b.Max.X, top+strip),
+		image.Rect(left, top, left+third, top+strip),
+		image.Rect(middle, top, middle+third, top+strip),
+	}
+	for _, zone := range zones {
+		crop := image.NewRGBA(image.Rect(0, 0, zone.Dx(), zone.Dy()))
+		draw.Draw(crop, crop.Bounds(), img, zone.Min, draw.Src)
+		text, err := engine.Text(crop, config, pageLabelOCROptions())
+		if err != nil {
+			continue
+		}
+		if label := parsePageLabel(text); label != "" {
+			return label
+		}
+	}
+	return ""
+}
+
+// checkPageLabels compares the page numbers read from pages with their
+// order. The longest increasing run of numbers is taken as the true
+// sequence: pages off it are out of order (or repeated), and numbers it
+// skips that appear nowhere are missing. Pages without a number, such as
+// plates or unreadable footers, may fill gaps.
+func checkPageLabels(pages []PageResult) []PageLabelIssue {
+	// pos is the place of the page in pages, which recollated documents
+	// hold out of physical order
+	type labeled struct{ page, pos, n int }
+	var seq []labeled
+	for pos, p := range pages {
+		if n, err := strconv.Atoi(p.Label); err == nil {
+			seq = append(seq, labeled{p.Page, pos, n})
+		}
+	}
+	if len(seq) < 2 {
+		return nil
+	}
+
+	// Longest strictly increasing subsequence by patience sorting
+	tails := []int{}              // indices into seq of the smallest tail of each length
+	prev := make([]int, len(seq)) // predecessor of each element in its subsequence
+	for i, l := range seq {
+		k := sort.Search(len(tails), func(j int) bool { return seq[tails[j]].n >= l.n })
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	inRun := make([]bool, len(seq))
+	for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+		inRun[i] = true
+	}
+
+	seen := map[int]bool{}
+	for _, l := range seq {
+		seen[l.n] = true
+	}
+	runLabels := map[int]bool{}
+	for i, l := range seq {
+		if inRun[i] {
+			runLabels[l.n] = true
+		}
+	}
+
+	var issues []PageLabelIssue
+	last := -1 // previous page of the run
+	for i, l := range seq {
+		if !inRun[i] {
+			issue := LabelOutOfOrder
+			if runLabels[l.n] {
+				issue = LabelDuplicate
+			}
+			issues = append(issues, PageLabelIssue{Page: l.page, Label: strconv.Itoa(l.n), Issue: issue})
+			continue
+		}
+		if last >= 0 {
+			a := seq[last]
+			if gap := (l.n - a.n) - (l.pos - a.pos); gap > 0 {
+				var missing []int
+				for n := a.n + 1; n < l.n; n++ {
+					if !seen[n] {
+						missing = append(missing, n)
+					}
+				}
+				if len(missing) > 0 {
+					issue := PageLabelIssue{Page: l.page, Label: labelRange(missing), Issue: LabelMissing}
+					if gap < len(missing) {
+						issue.Count = gap
+					}
+					issues = append(issues, issue)
+				}
+			}
+		}
+		last = i
+	}
+	return issues
+}
+
+// labelRange formats ascending page numbers, collapsing a contiguous run
+// into "first-last".
+func labelRange(numbers []int) string {
+	if len(numbers) > 2 && numbers[len(numbers)-1]-numbers[0] == len(numbers)-1 {
+		return fmt.Sprintf("%d-%d", numbers[0], numbers[len(numbers)-1])
+	}
+	parts := make([]string, len(numbers))
+	for i, n := range numbers {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ", ")
+}
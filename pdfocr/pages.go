@@ -0,0 +1,244 @@
+package pdfocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// How the text of a page was obtained.
+const (
+	MethodNative           = "native"
+	MethodMerged           = "native+OCR"
+	MethodOCR              = "OCR"
+	MethodOCRChart         = "OCR, chart"
+	MethodOCRDrawing       = "OCR, drawing"
+	MethodSkippedDrawing   = "vector drawing, skipped"
+	MethodSkippedBlank     = "blank, skipped"     // with OCRConfig.Fast
+	MethodSkippedDuplicate = "duplicate, skipped" // with OCRConfig.Fast, see PageResult.DuplicateOf
+	MethodFailed           = "failed"
+)
+
+// PageResult is the outcome of one page.
+type PageResult struct {
+	Page   int    `json:"page"` // 1-based
+	Method string `json:"method"`
+	Text   string `json:"text"`
+	Error  string `json:"error,omitempty"`
+	Links  []Link `json:"links,omitempty"`
+
+	DuplicateOf int `json:"duplicate_of,omitempty"` // the page a skipped duplicate repeats
+
+	ScanDPI float64 `json:"scan_dpi,omitempty"` // resolution of a low-resolution scan upscaled for OCR
+	// Clockwise degrees the page image was turned upright before OCR, with
+	// OCRConfig.AutoRotate
+	Rotation int `json:"rotation,omitempty"`
+	// Languages the page was recognized in, when picked with
+	// OCRConfig.AutoLanguage
+	Language string `json:"language,omitempty"`
+	// Preprocessing steps applied to the page image before OCR
+	Preprocessing []string `json:"preprocessing,omitempty"`
+
+	// Mean confidence of the words of an OCR'd page, 0-100, when they were
+	// recognized with their positions (OCRConfig.WordBoxes or
+	// OCRConfig.MinConfidence), and whether it stayed below
+	// OCRConfig.MinConfidence
+	Confidence    float64 `json:"confidence,omitempty"`
+	LowConfidence bool    `json:"low_confidence,omitempty"`
+
+	// Recognized with DefaultEngine because the cloud engine could not be
+	// reached; the page waits in OCRConfig.OfflineQueue under QueueID
+	Provisional bool   `json:"provisional,omitempty"`
+	QueueID     string `json:"queue_id,omitempty"`
+
+	// Printed page number, with OCRConfig.PageLabels
+	Label string `json:"label,omitempty"`
+
+	// Term of OCRConfig.Keywords found on the page keyword spotting
+	// stopped at
+	Keyword string `json:"keyword,omitempty"`
+
+	// Tables found with OCRConfig.Tables
+	Tables []Table `json:"tables,omitempty"`
+
+	// Labels of a chart, with OCRConfig.Preset PresetChart
+	ChartLabels []PageChartLabel `json:"chart_labels,omitempty"`
+
+	// Paragraphs of the text with their direction, in -format json and the
+	// pages directory
+	Paragraphs []PageParagraph `json:"paragraphs,omitempty"`
+
+	// Words of OCR'd pages with OCRConfig.WordBoxes
+	Words []PageWord `json:"words,omitempty"`
+
+	// Page size in points, with OCRConfig.WordBoxes or OCRConfig.HOCR
+	Width  float64 `json:"width,omitempty"`
+	Height float64 `json:"height,omitempty"`
+
+	// PNG of the page, with OCRConfig.Thumbnails
+	Thumbnail []byte `json:"-"`
+
+	lines  []headingLine // outline candidates of an OCR'd page
+	hocr   string        // ocr_page element of an OCR'd page
+	layout []TextLine    // text layer lines of a native page
+
+	annotations string     // text of its stamp and free-text annotations, after the section
+	err         *PageError // of a failed page
+}
+
+// PageFunc receives a finished page (see OCRConfig.Pages).
+type PageFunc func(PageResult) error
+
+// withoutText returns a page without its text, words, labels, layout and
+// thumbnail, for extractions that stream them (see ExtractTo).
+func (p PageResult) withoutText() PageResult {
+	p.Text, p.Paragraphs, p.ChartLabels, p.Words, p.Thumbnail = "", nil, nil, nil, nil
+	p.hocr, p.layout, p.annotations = "", nil, ""
+	return p
+}
+
+// PageWord is a recognized word of a page.
+type PageWord struct {
+	Text       string     `json:"text"`
+	Box        [4]float64 `json:"box"`        // x0, y0, x1, y1 in points from the top-left corner of the page
+	Confidence float64    `json:"confidence"` // 0-100
+}
+
+// pageWords converts the words recognized on a page image rendered at dpi
+// (0 meaning defaultRenderDPI) to page coordinates.
+func pageWords(words []OCRWord, dpi float64) []PageWord {
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	points := func(px int) float64 {
+		return math.Round(float64(px)*72/dpi*100) / 100
+	}
+	out := make([]PageWord, 0, len(words))
+	for _, w := range words {
+		out = append(out, PageWord{
+			Text:       w.Text,
+			Box:        [4]float64{points(w.Box.Min.X), points(w.Box.Min.Y), points(w.Box.Max.X), points(w.Box.Max.Y)},
+			Confidence: w.Confidence,
+		})
+	}
+	return out
+}
+
+// setSize records the size of a page recognized from an image size pixels
+// large, rendered at dpi (0 meaning defaultRenderDPI).
+func (p *PageResult) setSize(size image.Point, dpi float64) {
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	p.Width = math.Round(float64(size.X)*72/dpi*100) / 100
+	p.Height = math.Round(float64(size.Y)*72/dpi*100) / 100
+}
+
+// jsonDocument is the -format json output.
+type jsonDocument struct {
+	SchemaVersion string           `json:"schema_version"`
+	Document      DocumentManifest `json:"document"`
+	Pages         []PageResult     `json:"pages"`
+}
+
+// DocumentJSON returns a processed document as one indented JSON object:
+// the manifest and every page with its method, text, paragraphs and, with
+// OCRConfig.WordBoxes, words.
+func DocumentJSON(manifest DocumentManifest, config OCRConfig) ([]byte, error) {
+	doc := jsonDocument{SchemaVersion: SchemaVersion, Document: manifest, Pages: []PageResult{}}
+	for _, p := range manifest.PageResults {
+		if config.NormalizeLocale != "" {
+			text, err := NormalizeLocaleFormats(p.Text, config.NormalizeLocale)
+			if err != nil {
+				return nil, err
+			}
+			p.Text = text
+		}
+		p.Paragraphs = textParagraphs(p.Text)
+		doc.Pages = append(doc.Pages, p)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding document: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// pagesIndex is the index.json of a pages directory.
+type pagesIndex struct {
+	SchemaVersion string           `json:"schema_version"`
+	Document      DocumentManifest `json:"document"`
+	Pages         []pagesIndexPage `json:"pages"`
+}
+
+// pageFile is a page file of a pages directory.
+type pageFile struct {
+	SchemaVersion string `json:"schema_version"`
+	PageResult
+}
+
+type pagesIndexPage struct {
+	Page       int    `json:"page"`
+	Method     string `json:"method"`
+	File       string `json:"file"` // relative to the index
+	Characters int    `json:"characters"`
+}
+
+// pageFileName returns the path of a page file relative to the index.
+func pageFileName(page int) string {
+	return fmt.Sprintf("pages/%06d.json", page)
+}
+
+// pagesFiles lays out the processed pages of a document as one JSON file
+// per page (pages/000001.json, ...) and an index.json with the manifest and
+// a summary of every page, so consumers of huge documents can read single
+// pages directly.
+func pagesFiles(manifest DocumentManifest, config OCRConfig) ([]outputFile, error) {
+	var files []outputFile
+	index := pagesIndex{SchemaVersion: SchemaVersion, Document: manifest, Pages: []pagesIndexPage{}}
+	for _, p := range manifest.PageResults {
+		if config.NormalizeLocale != "" {
+			text, err := NormalizeLocaleFormats(p.Text, config.NormalizeLocale)
+			if err != nil {
+				return nil, err
+			}
+			p.Text = text
+		}
+		p.Paragraphs = textParagraphs(p.Text)
+		data, err := json.MarshalIndent(pageFile{SchemaVersion, p}, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error encoding page %d: %w", p.Page, err)
+		}
+		name := pageFileName(p.Page)
+		files = append(files, outputFile{Name: name, Data: append(data, '\n')})
+		index.Pages = append(index.Pages, pagesIndexPage{
+			Page: p.Page, Method: p.Method, File: name, Characters: len([]rune(p.Text)),
+		})
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error encoding pages index: %w", err)
+	}
+	return append(files, outputFile{Name: "index.json", Data: append(data, '\n')}), nil
+}
+
+// WritePagesDir writes the page files of a document (see pagesFiles) to dir.
+func WritePagesDir(dir string, manifest DocumentManifest, config OCRConfig) error {
+	files, err := pagesFiles(manifest, config)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "pages"), 0755); err != nil {
+		return fmt.Errorf("error creating pages directory: %w", err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(f.Name)), f.Data, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
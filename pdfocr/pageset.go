@@ -0,0 +1,137 @@
+package pdfocr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageSet is a selection of pages, parsed by ParsePageSet. The zero value
+// selects every page.
+type PageSet struct {
+	ranges []pageRange
+}
+
+// pageRange is an inclusive range of 1-based pages; last 0 runs to the end
+// of the document.
+type pageRange struct {
+	first, last int
+}
+
+// ParsePageSet parses a comma-separated list of pages and ranges such as
+// "1-5,10,20-", where "20-" runs to the last page.
+func ParsePageSet(spec string) (PageSet, error) {
+	var set PageSet
+	for _, part := range strings.Split(spec, ",") {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		first, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || first < 1 {
+			return PageSet{}, fmt.Errorf("invalid page selection %q (use pages and ranges like 1-5,10,20-)", spec)
+		}
+		r := pageRange{first, first}
+		if isRange {
+			r.last = 0
+			if hi = strings.TrimSpace(hi); hi != "" {
+				if r.last, err = strconv.Atoi(hi); err != nil || r.last < first {
+					return PageSet{}, fmt.Errorf("invalid page selection %q (use pages and ranges like 1-5,10,20-)", spec)
+				}
+			}
+		}
+		set.ranges = append(set.ranges, r)
+	}
+	return set, nil
+}
+
+// All reports whether the set selects every page.
+func (s PageSet) All() bool {
+	return len(s.ranges) == 0
+}
+
+// Contains reports whether a 1-based page is selected.
+func (s PageSet) Contains(page int) bool {
+	if s.All() {
+		return true
+	}
+	for _, r := range s.ranges {
+		if page >= r.first && (r.last == 0 || page <= r.last) {
+			return true
+		}
+	}
+	return false
+}
+
+// without returns the set without the 1-based pages, and false when no
+// page is left of it.
+func (s PageSet) without(pages []int) (PageSet, bool) {
+	ranges := s.ranges
+	if s.All() {
+		ranges = []pageRange{{1, 0}}
+	}
+	for _, page := range pages {
+		var kept []pageRange
+		for _, r := range ranges {
+			if page < r.first || (r.last != 0 && page > r.last) {
+				kept = append(kept, r)
+				continue
+			}
+			if page > r.first {
+				kept = append(kept, pageRange{r.first, page - 1})
+			}
+			if r.last == 0 || page < r.last {
+				kept = append(kept, pageRange{page + 1, r.last})
+			}
+		}
+		ranges = kept
+	}
+	return PageSet{ranges}, len(ranges) > 0
+}
+
+// String returns the selection as ParsePageSet accepts it, or "all".
+func (s PageSet) String() string {
+	if s.All() {
+		return "all"
+	}
+	parts := make([]string, len(s.ranges))
+	for i, r := range s.ranges {
+		switch r.last {
+		case r.first:
+			parts[i] = strconv.Itoa(r.first)
+		case 0:
+			parts[i] = fmt.Sprintf("%d-", r.first)
+		default:
+			parts[i] = fmt.Sprintf("%d-%d", r.first, r.last)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// GobEncode and GobDecode write and read the selection as String does, so
+// it travels with an OCRConfig to sandboxed workers.
+func (s PageSet) GobEncode() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *PageSet) GobDecode(text []byte) error {
+	if string(text) == "all" {
+		*s = PageSet{}
+		return nil
+	}
+	set, err := ParsePageSet(string(text))
+	if err != nil {
+		return err
+	}
+	*s = set
+	return nil
+}
+
+// pageNums returns the selected pages of a document of numPages pages as
+// zero-based page numbers, in order.
+func (s PageSet) pageNums(numPages int) []int {
+	nums := make([]int, 0, numPages)
+	for pageNum := 0; pageNum < numPages; pageNum++ {
+		if s.Contains(pageNum + 1) {
+			nums = append(nums, pageNum)
+		}
+	}
+	return nums
+}
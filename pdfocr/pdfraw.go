@@ -0,0 +1,827 @@
+package pdfocr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+// The raw PDF reader below gives access to document structures that MuPDF
+// renders but go-fitz does not expose (embedded files, form data, annotations
+// and so on). It scans the file for indirect objects instead of trusting the
+// cross-reference table, which also makes it tolerant of damaged files.
+
+// PDF object values.
+type (
+	pdfDict   map[string]pdfValue
+	pdfArray  []pdfValue
+	pdfName   string
+	pdfString string
+	pdfRef    struct{ Num, Gen int }
+	pdfValue  interface{}
+)
+
+// rawObject is an indirect object, with its stream data still encoded.
+type rawObject struct {
+	Num    int
+	Value  pdfValue
+	Stream []byte
+}
+
+// rawPDF holds all indirect objects found in a PDF file.
+type rawPDF struct {
+	objects  map[int]*rawObject
+	trailer  pdfDict
+	pageList []pdfDict
+}
+
+var (
+	errEncryptedPDF = errors.New("PDF is encrypted")
+	objHeaderRe     = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+	trailerRe       = regexp.MustCompile(`trailer\s*<<`)
+)
+
+// maxDecodedStream bounds the size of a decoded stream to protect against
+// compression bombs.
+const maxDecodedStream = 512 << 20
+
+// Bounds of the PNG predictor parameters of a Flate stream: Colors and
+// BitsPerComponent are at most 32 and 16 in valid files, and maxColumns
+// keeps a row of the widest plausible image within maxDecodedStream.
+const (
+	maxPredictorColors  = 32
+	maxPredictorBits    = 16
+	maxPredictorColumns = 1 << 20
+)
+
+// parseRawPDF scans data for indirect objects, including those stored in
+// object streams, and locates the document trailer.
+func parseRawPDF(data []byte) (*rawPDF, error) {
+	if !isPDFData(data) {
+		return nil, fmt.Errorf("not a PDF file")
+	}
+
+	pdf := &rawPDF{objects: map[int]*rawObject{}}
+	for _, m := range objHeaderRe.FindAllSubmatchIndex(data, -1) {
+		num, _ := strconv.Atoi(string(data[m[2]:m[3]]))
+		p := &pdfParser{data: data, pos: m[1]}
+		value, err := p.parseValue()
+		if err != nil {
+			continue
+		}
+		obj := &rawObject{Num: num, Value: value}
+		if dict, ok := value.(pdfDict); ok {
+			obj.Stream = p.readStream(dict)
+			if typ, _ := dict["Type"].(pdfName); typ == "XRef" {
+				pdf.trailer = dict
+			}
+		}
+		// Later objects win, matching incremental update semantics.
+		pdf.objects[num] = obj
+	}
+
+	for _, m := range trailerRe.FindAllIndex(data, -1) {
+		p := &pdfParser{data: data, pos: m[1] - 2}
+		if v, err := p.parseValue(); err == nil {
+			if dict, ok := v.(pdfDict); ok {
+				pdf.trailer = dict
+			}
+		}
+	}
+
+	pdf.expandObjectStreams()
+
+	if pdf.trailer == nil {
+		return nil, fmt.Errorf("no trailer found")
+	}
+	if _, ok := pdf.trailer["Encrypt"]; ok {
+		return pdf, errEncryptedPDF
+	}
+	return pdf, nil
+}
+
+// expandObjectStreams adds objects stored inside /Type /ObjStm streams.
+// Objects that were also found directly in the file keep their direct form.
+func (pdf *rawPDF) expandObjectStreams() {
+	for _, num := range pdf.sortedObjectNumbers() {
+		obj := pdf.objects[num]
+		dict, ok := obj.Value.(pdfDict)
+		if !ok || dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := pdf.decodeStream(obj)
+		if err != nil {
+			continue
+		}
+		n, first := pdf.int(dict["N"]), pdf.int(dict["First"])
+		if first < 0 || first >= len(data) {
+			continue
+		}
+		header := &pdfParser{data: data}
+		for i := 0; i < n; i++ {
+			objNum, err1 := header.parseValue()
+			offset, err2 := header.parseValue()
+			if err1 != nil || err2 != nil {
+				break
+			}
+			on, ok1 := objNum.(float64)
+			off, ok2 := offset.(float64)
+			if !ok1 || !ok2 || off < 0 || off >= float64(len(data)-first) {
+				continue
+			}
+			if _, exists := pdf.objects[int(on)]; exists {
+				continue
+			}
+			p := &pdfParser{data: data, pos: first + int(off)}
+			if value, err := p.parseValue(); err == nil {
+				pdf.objects[int(on)] = &rawObject{Num: int(on), Value: value}
+			}
+		}
+	}
+}
+
+// sortedObjectNumbers returns the numbers of all objects in ascending order.
+func (pdf *rawPDF) sortedObjectNumbers() []int {
+	nums := make([]int, 0, len(pdf.objects))
+	for num := range pdf.objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	return nums
+}
+
+// resolve follows indirect references until it reaches a direct value.
+func (pdf *rawPDF) resolve(v pdfValue) pdfValue {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		obj := pdf.objects[ref.Num]
+		if obj == nil {
+			return nil
+		}
+		v = obj.Value
+	}
+	return nil
+}
+
+// object returns the indirect object a value refers to, or nil.
+func (pdf *rawPDF) object(v pdfValue) *rawObject {
+	if ref, ok := v.(pdfRef); ok {
+		return pdf.objects[ref.Num]
+	}
+	return nil
+}
+
+func (pdf *rawPDF) dict(v pdfValue) pdfDict {
+	d, _ := pdf.resolve(v).(pdfDict)
+	return d
+}
+
+func (pdf *rawPDF) array(v pdfValue) pdfArray {
+	a, _ := pdf.resolve(v).(pdfArray)
+	return a
+}
+
+func (pdf *rawPDF) int(v pdfValue) int {
+	f, _ := pdf.resolve(v).(float64)
+	return int(f)
+}
+
+func (pdf *rawPDF) name(v pdfValue) string {
+	n, _ := pdf.resolve(v).(pdfName)
+	return string(n)
+}
+
+// text decodes a PDF text string (UTF-16BE with BOM, UTF-8 with BOM, or
+// PDFDocEncoding, which is treated as Latin-1).
+func (pdf *rawPDF) text(v pdfValue) string {
+	s, ok := pdf.resolve(v).(pdfString)
+	if !ok {
+		return ""
+	}
+	b := []byte(s)
+	switch {
+	case len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF:
+		units := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF:
+		return string(b[3:])
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// catalog returns the document catalog (/Root).
+func (pdf *rawPDF) catalog() pdfDict {
+	return pdf.dict(pdf.trailer["Root"])
+}
+
+// pages returns the page dictionaries in document order. Inheritable
+// attributes (Resources, MediaBox, CropBox, Rotate) are copied down from
+// their ancestors in the page tree.
+func (pdf *rawPDF) pages() []pdfDict {
+	if pdf.pageList != nil {
+		return pdf.pageList
+	}
+	inheritable := []string{"Resources", "MediaBox", "CropBox", "Rotate"}
+	seen := map[int]bool{}
+	var walk func(v pdfValue, inherited pdfDict, depth int)
+	walk = func(v pdfValue, inherited pdfDict, depth int) {
+		if ref, ok := v.(pdfRef); ok {
+			if seen[ref.Num] {
+				return
+			}
+			seen[ref.Num] = true
+		}
+		node := pdf.dict(v)
+		if node == nil || depth > 64 {
+			return
+		}
+		attrs := pdfDict{}
+		for _, key := range inheritable {
+			if value, ok := node[key]; ok {
+				attrs[key] = value
+			} else if value, ok := inherited[key]; ok {
+				attrs[key] = value
+			}
+		}
+		kids, hasKids := node["Kids"]
+		if !hasKids || node["Type"] == pdfName("Page") {
+			page := pdfDict{}
+			for k, v := range node {
+				page[k] = v
+			}
+			for k, v := range attrs {
+				page[k] = v
+			}
+			pdf.pageList = append(pdf.pageList, page)
+			return
+		}
+		for _, kid := range pdf.array(kids) {
+			walk(kid, attrs, depth+1)
+		}
+	}
+	walk(pdf.catalog()["Pages"], nil, 0)
+	if pdf.pageList == nil {
+		pdf.pageList = []pdfDict{}
+	}
+	return pdf.pageList
+}
+
+// page returns the dictionary of a page by zero-based index, or nil.
+func (pdf *rawPDF) page(pageNum int) pdfDict {
+	pages := pdf.pages()
+	if pageNum < 0 || pageNum >= len(pages) {
+		return nil
+	}
+	return pages[pageNum]
+}
+
+// pageImages returns the image XObjects drawn by a page, including those
+// inside form XObjects.
+func (pdf *rawPDF) pageImages(pageNum int) []*rawObject {
+	var out []*rawObject
+	seen := map[int]bool{}
+	var walk func(resources pdfValue, depth int)
+	walk = func(resources pdfValue, depth int) {
+		if depth > 16 {
+			return
+		}
+		xobjects := pdf.dict(pdf.dict(resources)["XObject"])
+		for _, ref := range xobjects {
+			obj := pdf.object(ref)
+			if obj == nil || seen[obj.Num] {
+				continue
+			}
+			seen[obj.Num] = true
+			dict, _ := obj.Value.(pdfDict)
+			switch pdf.name(dict["Subtype"]) {
+			case "Image":
+				out = append(out, obj)
+			case "Form":
+				walk(dict["Resources"], depth+1)
+			}
+		}
+	}
+	if page := pdf.page(pageNum); page != nil {
+		walk(page["Resources"], 0)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Num < out[j].Num })
+	return out
+}
+
+// nameTree flattens a name tree into key/value pairs in tree order.
+func (pdf *rawPDF) nameTree(root pdfValue) []nameTreeEntry {
+	var out []nameTreeEntry
+	seen := map[int]bool{}
+	var walk func(v pdfValue, depth int)
+	walk = func(v pdfValue, depth int) {
+		if ref, ok := v.(pdfRef); ok {
+			if seen[ref.Num] {
+				return
+			}
+			seen[ref.Num] = true
+		}
+		node := pdf.dict(v)
+		if node == nil || depth > 32 {
+			return
+		}
+		names := pdf.array(node["Names"])
+		for i := 0; i+1 < len(names); i += 2 {
+			out = append(out, nameTreeEntry{Key: pdf.text(names[i]), Value: names[i+1]})
+		}
+		for _, kid := range pdf.array(node["Kids"]) {
+			walk(kid, depth+1)
+		}
+	}
+	walk(root, 0)
+	return out
+}
+
+type nameTreeEntry struct {
+	Key   string
+	Value pdfValue
+}
+
+// decodeStream returns the decoded data of a stream object. Image codecs
+// (DCT, JPX, JBIG2, CCITT) are left encoded and reported by streamFilters.
+func (pdf *rawPDF) decodeStream(obj *rawObject) ([]byte, error) {
+	dict, _ := obj.Value.(pdfDict)
+	if dict == nil || obj.Stream == nil {
+		return nil, fmt.Errorf("object %d is not a stream", obj.Num)
+	}
+	data := obj.Stream
+	filters := pdf.streamFilters(dict)
+	params := pdf.resolve(dict["DecodeParms"])
+	for i, filter := range filters {
+		var parms pdfDict
+		switch p := params.(type) {
+		case pdfDict:
+			parms = p
+		case pdfArray:
+			if i < len(p) {
+				parms = pdf.dict(p[i])
+			}
+		}
+
+		var err error
+		switch filter {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+			if err == nil {
+				data, err = pdf.unpredict(data, parms)
+			}
+		case "ASCIIHexDecode", "AHx":
+			data, err = asciiHexDecode(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		case "DCTDecode", "DCT", "JPXDecode", "JBIG2Decode", "CCITTFaxDecode", "CCF":
+			return data, nil
+		default:
+			return nil, fmt.Errorf("unsupported stream filter %s", filter)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s stream: %w", filter, err)
+		}
+	}
+	return data, nil
+}
+
+// streamFilters lists the filter names applied to a stream, in order.
+func (pdf *rawPDF) streamFilters(dict pdfDict) []string {
+	switch f := pdf.resolve(dict["Filter"]).(type) {
+	case pdfName:
+		return []string{string(f)}
+	case pdfArray:
+		out := make([]string, 0, len(f))
+		for _, v := range f {
+			out = append(out, pdf.name(v))
+		}
+		return out
+	}
+	return nil
+}
+
+// unpredict reverses PNG predictors (Predictor >= 10) applied before Flate.
+// Parameters out of range are rejected rather than trusted to size rows.
+func (pdf *rawPDF) unpredict(data []byte, parms pdfDict) ([]byte, error) {
+	predictor := pdf.int(parms["Predictor"])
+	if predictor < 10 {
+		return data, nil
+	}
+	columns, colors, bpc := 1, 1, 8
+	for _, p := range []struct {
+		key string
+		v   *int
+		max int
+	}{
+		{"Columns", &columns, maxPredictorColumns},
+		{"Colors", &colors, maxPredictorColors},
+		{"BitsPerComponent", &bpc, maxPredictorBits},
+	} {
+		value, ok := pdf.resolve(parms[p.key]).(float64)
+		if !ok {
+			continue
+		}
+		if value < 1 || value > float64(p.max) {
+			return nil, fmt.Errorf("invalid predictor %s %g", p.key, value)
+		}
+		*p.v = int(value)
+	}
+	bpp := (colors*bpc + 7) / 8
+	rowLen := (columns*colors*bpc + 7) / 8
+	if rowLen+1 > len(data) {
+		return nil, nil
+	}
+
+	out := make([]byte, 0, len(data))
+	prev := make([]byte, rowLen)
+	for len(data) >= rowLen+1 {
+		kind, row := data[0], append([]byte(nil), data[1:rowLen+1]...)
+		data = data[rowLen+1:]
+		for i := range row {
+			var left, up, upLeft byte
+			if i >= bpp {
+				left, upLeft = row[i-bpp], prev[i-bpp]
+			}
+			up = prev[i]
+			switch kind {
+			case 1:
+				row[i] += left
+			case 2:
+				row[i] += up
+			case 3:
+				row[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				row[i] += paeth(left, up, upLeft)
+			}
+		}
+		out = append(out, row...)
+		prev = row
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := absInt(p-int(a)), absInt(p-int(b)), absInt(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func absInt(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func inflate(data []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxDecodedStream+1))
+	if len(out) > maxDecodedStream {
+		return nil, fmt.Errorf("decoded stream exceeds %d bytes", maxDecodedStream)
+	}
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+	// Truncated streams are common in damaged files; keep what was decoded.
+	return out, nil
+}
+
+func asciiHexDecode(data []byte) ([]byte, error) {
+	clean := make([]byte, 0, len(data))
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isPDFWhitespace(c) {
+			continue
+		}
+		clean = append(clean, c)
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	return hex.DecodeString(string(clean))
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	var out []byte
+	var group [5]byte
+	n := 0
+	for _, c := range data {
+		switch {
+		case isPDFWhitespace(c):
+			continue
+		case c == 'z' && n == 0:
+			out = append(out, 0, 0, 0, 0)
+			continue
+		case c < '!' || c > 'u':
+			return nil, fmt.Errorf("invalid ASCII85 byte %q", c)
+		}
+		group[n] = c - '!'
+		n++
+		if n == 5 {
+			v := uint32(0)
+			for _, g := range group {
+				v = v*85 + uint32(g)
+			}
+			out = append(out, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+			n = 0
+		}
+	}
+	if n > 0 {
+		for i := n; i < 5; i++ {
+			group[i] = 84
+		}
+		v := uint32(0)
+		for _, g := range group {
+			v = v*85 + uint32(g)
+		}
+		out = append(out, []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}[:n-1]...)
+	}
+	return out, nil
+}
+
+// pdfParser is a tokenizer and value parser for PDF object syntax.
+type pdfParser struct {
+	data []byte
+	pos  int
+}
+
+var errPDFSyntax = errors.New("PDF syntax error")
+
+func isPDFWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return bytes.IndexByte([]byte("()<>[]{}/%"), c) >= 0
+}
+
+func (p *pdfParser) skipSpace() {
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		if c == '%' {
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' && p.data[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if !isPDFWhitespace(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// keyword reads a bare token such as a number, true/false/null or an operator.
+func (p *pdfParser) keyword() string {
+	start := p.pos
+	for p.pos < len(p.data) && !isPDFWhitespace(p.data[p.pos]) && !isPDFDelimiter(p.data[p.pos]) {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *pdfParser) parseValue() (pdfValue, error) {
+	return p.parseValueDepth(0)
+}
+
+func (p *pdfParser) parseValueDepth(depth int) (pdfValue, error) {
+	if depth > 64 {
+		return nil, errPDFSyntax
+	}
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	switch c := p.data[p.pos]; {
+	case c == '<' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '<':
+		p.pos += 2
+		dict := pdfDict{}
+		for {
+			p.skipSpace()
+			if p.pos+1 < len(p.data) && p.data[p.pos] == '>' && p.data[p.pos+1] == '>' {
+				p.pos += 2
+				return dict, nil
+			}
+			key, err := p.parseValueDepth(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, errPDFSyntax
+			}
+			value, err := p.parseValueDepth(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			dict[string(name)] = value
+		}
+	case c == '[':
+		p.pos++
+		arr := pdfArray{}
+		for {
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return arr, nil
+			}
+			value, err := p.parseValueDepth(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, value)
+		}
+	case c == '(':
+		return p.literalString()
+	case c == '<':
+		p.pos++
+		end := bytes.IndexByte(p.data[p.pos:], '>')
+		if end < 0 {
+			return nil, errPDFSyntax
+		}
+		b, err := asciiHexDecode(p.data[p.pos : p.pos+end])
+		p.pos += end + 1
+		return pdfString(b), err
+	case c == '/':
+		p.pos++
+		raw := p.keyword()
+		if !bytes.Contains([]byte(raw), []byte("#")) {
+			return pdfName(raw), nil
+		}
+		var b []byte
+		for i := 0; i < len(raw); i++ {
+			if raw[i] == '#' && i+2 < len(raw) {
+				if v, err := strconv.ParseUint(raw[i+1:i+3], 16, 8); err == nil {
+					b = append(b, byte(v))
+					i += 2
+					continue
+				}
+			}
+			b = append(b, raw[i])
+		}
+		return pdfName(b), nil
+	case c == ']' || c == '>' || c == ')' || c == '{' || c == '}':
+		return nil, errPDFSyntax
+	}
+
+	kw := p.keyword()
+	switch kw {
+	case "":
+		p.pos++
+		return nil, errPDFSyntax
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	num, err := strconv.ParseFloat(kw, 64)
+	if err != nil {
+		return nil, errPDFSyntax
+	}
+
+	// An integer may start an indirect reference "num gen R".
+	if save := p.pos; num == float64(int(num)) {
+		p.skipSpace()
+		genStart := p.pos
+		gen := p.keyword()
+		if g, err := strconv.Atoi(gen); err == nil && p.pos > genStart {
+			p.skipSpace()
+			if p.pos < len(p.data) && p.data[p.pos] == 'R' &&
+				(p.pos+1 == len(p.data) || isPDFWhitespace(p.data[p.pos+1]) || isPDFDelimiter(p.data[p.pos+1])) {
+				p.pos++
+				return pdfRef{Num: int(num), Gen: g}, nil
+			}
+		}
+		p.pos = save
+	}
+	return num, nil
+}
+
+func (p *pdfParser) literalString() (pdfValue, error) {
+	p.pos++ // opening parenthesis
+	var b []byte
+	depth := 1
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		p.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(b), nil
+			}
+		case '\\':
+			if p.pos >= len(p.data) {
+				return nil, errPDFSyntax
+			}
+			e := p.data[p.pos]
+			p.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if p.pos < len(p.data) && p.data[p.pos] == '\n' {
+					p.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && p.pos < len(p.data) && p.data[p.pos] >= '0' && p.data[p.pos] <= '7'; i++ {
+						v = v*8 + int(p.data[p.pos]-'0')
+						p.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return nil, errPDFSyntax
+}
+
+// readStream returns the raw bytes of the stream following a dictionary, if
+// any. A direct /Length is trusted when it lands on "endstream"; otherwise
+// the data runs to the next "endstream" keyword.
+func (p *pdfParser) readStream(dict pdfDict) []byte {
+	p.skipSpace()
+	if !bytes.HasPrefix(p.data[p.pos:], []byte("stream")) {
+		return nil
+	}
+	start := p.pos + len("stream")
+	if start < len(p.data) && p.data[start] == '\r' {
+		start++
+	}
+	if start < len(p.data) && p.data[start] == '\n' {
+		start++
+	}
+
+	if length, ok := dict["Length"].(float64); ok {
+		end := start + int(length)
+		if end >= start && end <= len(p.data) {
+			rest := bytes.TrimLeft(p.data[end:], " \t\r\n")
+			if bytes.HasPrefix(rest, []byte("endstream")) {
+				return p.data[start:end]
+			}
+		}
+	}
+
+	end := bytes.Index(p.data[start:], []byte("endstream"))
+	if end < 0 {
+		return nil
+	}
+	data := p.data[start : start+end]
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	data = bytes.TrimSuffix(data, []byte("\r"))
+	return data
+}
@@ -0,0 +1,117 @@
+package pdfocr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+// flateStream returns a stream object numbered num holding data
+// compressed with Flate, dict being its other entries.
+func flateStream(num int, dict, data string) string {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(data))
+	w.Close()
+	return fmt.Sprintf("%d 0 obj << %s /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream endobj\n", num, dict, buf.Len(), buf.String())
+}
+
+// rawPDFWith returns a one-page PDF whose catalog and page are followed by
+// the objects extra.
+func rawPDFWith(extra string) []byte {
+	return []byte("%PDF-1.5\n" +
+		"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n" +
+		"2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n" +
+		"3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] >> endobj\n" +
+		extra +
+		"trailer << /Root 1 0 R >>\n%%EOF\n")
+}
+
+func TestParseRawPDF(t *testing.T) {
+	tests := []struct {
+		name   string
+		extra  string
+		object int    // an object expected from the object stream, 0 for none
+		want   string // its /Kind, if any
+	}{
+		{"object stream", flateStream(10, "/Type /ObjStm /N 1 /First 5", "11 0 << /Kind /Found >>"), 11, "Found"},
+		{"negative offset", flateStream(10, "/Type /ObjStm /N 1 /First 6", "11 -100 << /Kind /Found >>"), 0, ""},
+		{"negative first", flateStream(10, "/Type /ObjStm /N 1 /First -100", "11 0 << /Kind /Found >>"), 0, ""},
+		{"offset past the end", flateStream(10, "/Type /ObjStm /N 1 /First 5", "11 900 << >>"), 0, ""},
+		{"huge offset", flateStream(10, "/Type /ObjStm /N 1 /First 5", "11 1e300 << >>"), 0, ""},
+		{"first past the end", flateStream(10, "/Type /ObjStm /N 1 /First 5000", "11 0 << >>"), 0, ""},
+		{"negative columns", flateStream(10, "/Type /ObjStm /N 1 /First 5 /DecodeParms << /Predictor 12 /Columns -5 >>", "11 0 << >>"), 0, ""},
+		{"huge columns", flateStream(10, "/Type /ObjStm /N 1 /First 5 /DecodeParms << /Predictor 12 /Columns 1e15 >>", "11 0 << >>"), 0, ""},
+		{"zero colors", flateStream(10, "/Type /ObjStm /N 1 /First 5 /DecodeParms << /Predictor 12 /Colors 0 >>", "11 0 << >>"), 0, ""},
+		{"huge bits", flateStream(10, "/Type /ObjStm /N 1 /First 5 /DecodeParms << /Predictor 12 /BitsPerComponent 4096 >>", "11 0 << >>"), 0, ""},
+		{"columns wider than the data", flateStream(10, "/Type /ObjStm /N 1 /First 5 /DecodeParms << /Predictor 12 /Columns 100000 >>", "11 0 << >>"), 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pdf, err := parseRawPDF(rawPDFWith(tt.extra))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pdf.pages()) != 1 {
+				t.Errorf("found %d pages, want 1", len(pdf.pages()))
+			}
+			obj := pdf.objects[11]
+			if tt.object == 0 {
+				if obj != nil {
+					t.Errorf("object 11 = %v, want none", obj.Value)
+				}
+				return
+			}
+			if obj == nil {
+				t.Fatal("object 11 not found")
+			}
+			if got := pdf.name(pdf.dict(obj.Value)["Kind"]); got != tt.want {
+				t.Errorf("object 11 /Kind = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnpredict(t *testing.T) {
+	pdf := &rawPDF{objects: map[int]*rawObject{}}
+	// Two rows of two bytes, the second "up" predicted
+	got, err := pdf.unpredict([]byte{0, 1, 2, 2, 1, 1}, pdfDict{"Predictor": 12.0, "Columns": 2.0})
+	if err != nil || !bytes.Equal(got, []byte{1, 2, 2, 3}) {
+		t.Errorf("unpredict = %v, %v, want [1 2 2 3]", got, err)
+	}
+	for _, parms := range []pdfDict{
+		{"Predictor": 12.0, "Columns": -1.0},
+		{"Predictor": 12.0, "Columns": 0.0},
+		{"Predictor": 12.0, "Columns": 1e12},
+		{"Predictor": 12.0, "Colors": -3.0},
+		{"Predictor": 12.0, "Colors": 1000.0},
+		{"Predictor": 12.0, "BitsPerComponent": 0.0},
+		{"Predictor": 12.0, "BitsPerComponent": 64.0},
+	} {
+		if _, err := pdf.unpredict([]byte{0, 1, 2}, parms); err == nil {
+			t.Errorf("unpredict accepted %v", parms)
+		}
+	}
+}
+
+func FuzzParseRawPDF(f *testing.F) {
+	f.Add(rawPDFWith(""))
+	f.Add(rawPDFWith(flateStream(10, "/Type /ObjStm /N 2 /First 10", "11 0 12 12 << /A 1 >> [1 2 R]")))
+	f.Add(rawPDFWith(flateStream(10, "/Type /ObjStm /N 1 /First 5 /DecodeParms << /Predictor 12 /Columns 4 >>", "\x02\x00\x00\x00\x00")))
+	f.Add(rawPDFWith("4 0 obj << /Type /XRef /Root 1 0 R /Length 3 >>\nstream\nabc\nendstream endobj\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		pdf, err := parseRawPDF(data)
+		if pdf == nil {
+			return
+		}
+		_ = err
+		for i := range pdf.pages() {
+			pdf.pageImages(i)
+			pdf.imageCoverage(i)
+		}
+		for _, obj := range pdf.objects {
+			pdf.decodeStream(obj)
+		}
+	})
+}
@@ -0,0 +1,210 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// buildPDF assembles numbered objects (object i+1 is objects[i], object 1
+// being the catalog) into a PDF file with a classic cross-reference table.
+func buildPDF(objects []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfNumber formats a number without an exponent, which PDF does not allow.
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// writePDFValue serializes a parsed value, mapping indirect references
+// through remap. References remap does not know are written as null.
+func writePDFValue(buf *bytes.Buffer, v pdfValue, remap func(pdfRef) (int, bool)) {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case float64:
+		buf.WriteString(pdfNumber(v))
+	case pdfName:
+		buf.WriteByte('/')
+		for i := 0; i < len(v); i++ {
+			if c := v[i]; c <= ' ' || c >= 0x7F || c == '#' || isPDFDelimiter(c) {
+				fmt.Fprintf(buf, "#%02X", c)
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+	case pdfString:
+		buf.WriteByte('<')
+		fmt.Fprintf(buf, "%X", []byte(v))
+		buf.WriteByte('>')
+	case pdfRef:
+		if n, ok := remap(v); ok {
+			fmt.Fprintf(buf, "%d 0 R", n)
+		} else {
+			buf.WriteString("null")
+		}
+	case pdfArray:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				buf.WriteByte(' ')
+			}
+			writePDFValue(buf, e, remap)
+		}
+		buf.WriteByte(']')
+	case pdfDict:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		buf.WriteString("<<")
+		for _, k := range keys {
+			writePDFValue(buf, pdfName(k), remap)
+			buf.WriteByte(' ')
+			writePDFValue(buf, v[k], remap)
+		}
+		buf.WriteString(">>")
+	default:
+		buf.WriteString("null")
+	}
+}
+
+// maxCopiedObjects bounds the objects copied into an extracted document.
+const maxCopiedObjects = 10000
+
+// formXObjectPDF builds a one-page PDF that shows only the form XObject ref
+// (for example an annotation appearance stream), with the page sized to the
+// form's bounding box. The objects the form depends on are copied over,
+// except back-links into the page tree.
+func (pdf *rawPDF) formXObjectPDF(ref pdfValue) ([]byte, error) {
+	obj := pdf.object(ref)
+	if obj == nil || obj.Stream == nil {
+		return nil, fmt.Errorf("appearance is not a stream")
+	}
+	form, _ := obj.Value.(pdfDict)
+	bbox := pdf.array(form["BBox"])
+	if len(bbox) != 4 {
+		return nil, fmt.Errorf("appearance stream has no bounding box")
+	}
+	var box [4]float64
+	for i, v := range bbox {
+		box[i], _ = pdf.resolve(v).(float64)
+	}
+	matrix := [6]float64{1, 0, 0, 1, 0, 0}
+	if m := pdf.array(form["Matrix"]); len(m) == 6 {
+		for i, v := range m {
+			matrix[i], _ = pdf.resolve(v).(float64)
+		}
+	}
+	// The page shows the bounding box as transformed by the form matrix
+	x0, y0, x1, y1 := transformedBox(box, matrix)
+	if x1-x0 < 1 || y1-y0 < 1 {
+		return nil, fmt.Errorf("appearance stream is empty")
+	}
+
+	// Objects 1-4 are the catalog, page tree, page and content stream;
+	// copied objects follow
+	numbers := map[int]int{}
+	var order []int
+	var collect func(v pdfValue, depth int)
+	collect = func(v pdfValue, depth int) {
+		if depth > 64 || len(order) >= maxCopiedObjects {
+			return
+		}
+		switch v := v.(type) {
+		case pdfRef:
+			o := pdf.objects[v.Num]
+			if o == nil {
+				return
+			}
+			if _, ok := numbers[v.Num]; ok {
+				return
+			}
+			numbers[v.Num] = len(order) + 5
+			order = append(order, v.Num)
+			collect(o.Value, depth+1)
+		case pdfArray:
+			for _, e := range v {
+				collect(e, depth+1)
+			}
+		case pdfDict:
+			for k, e := range v {
+				if k == "Parent" || k == "P" {
+					continue
+				}
+				collect(e, depth+1)
+			}
+		}
+	}
+	collect(pdfRef{Num: obj.Num}, 0)
+	remap := func(r pdfRef) (int, bool) {
+		n, ok := numbers[r.Num]
+		return n, ok
+	}
+
+	content := fmt.Sprintf("q 1 0 0 1 %s %s cm /Ap Do Q", pdfNumber(-x0), pdfNumber(-y0))
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << /Ap %d 0 R >> >> /Contents 4 0 R >>",
+			pdfNumber(x1-x0), pdfNumber(y1-y0), numbers[obj.Num]),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+	}
+	for _, num := range order {
+		o := pdf.objects[num]
+		var buf bytes.Buffer
+		if o.Stream != nil {
+			dict, _ := o.Value.(pdfDict)
+			copied := pdfDict{}
+			for k, v := range dict {
+				if k != "Parent" && k != "P" {
+					copied[k] = v
+				}
+			}
+			copied["Length"] = float64(len(o.Stream))
+			writePDFValue(&buf, copied, remap)
+			buf.WriteString("\nstream\n")
+			buf.Write(o.Stream)
+			buf.WriteString("\nendstream")
+		} else {
+			writePDFValue(&buf, o.Value, remap)
+		}
+		objects = append(objects, buf.String())
+	}
+	return buildPDF(objects), nil
+}
+
+// transformedBox returns the bounding box of rectangle box (x0 y0 x1 y1)
+// after applying the PDF matrix m.
+func transformedBox(box [4]float64, m [6]float64) (x0, y0, x1, y1 float64) {
+	first := true
+	for _, p := range [][2]float64{{box[0], box[1]}, {box[2], box[1]}, {box[0], box[3]}, {box[2], box[3]}} {
+		x := m[0]*p[0] + m[2]*p[1] + m[4]
+		y := m[1]*p[0] + m[3]*p[1] + m[5]
+		if first {
+			x0, y0, x1, y1 = x, y, x, y
+			first = false
+			continue
+		}
+		x0, y0, x1, y1 = min(x0, x), min(y0, y), max(x1, x), max(y1, y)
+	}
+	return
+}
@@ -0,0 +1,767 @@
+package pdfocr_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"ocr-tool/pdfocr"
+	"ocr-tool/pdfocr/testsupport"
+)
+
+const nativeText = "This page has a text layer long enough to be used without OCR."
+
+// TestMain runs the test binary as a sandbox worker when TestSandbox starts
+// it as one; PDFOCR_TEST_WORKER_CRASH makes the worker die on its first
+// document, and PDFOCR_TEST_WORKER_CRASH_ON on the page recognized as its
+// value.
+func TestMain(m *testing.M) {
+	if len(os.Args) > 1 && os.Args[1] == pdfocr.SandboxWorkerCommand {
+		if os.Getenv("PDFOCR_TEST_WORKER_CRASH") != "" {
+			io.ReadFull(os.Stdin, make([]byte, 1))
+			os.Exit(3)
+		}
+		if marker := os.Getenv("PDFOCR_TEST_WORKER_CRASH_ON"); marker != "" {
+			pdfocr.RegisterEngine(crashingEngine{marker: marker})
+		}
+		if err := pdfocr.RunSandboxWorker(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// crashingEngine is the mock engine exiting on the page image whose text
+// is marker.
+type crashingEngine struct {
+	testsupport.Engine
+	marker string
+}
+
+func (e crashingEngine) Text(img image.Image, config pdfocr.OCRConfig, opts pdfocr.PageOCROptions) (string, error) {
+	if p, ok := img.(*testsupport.PageImage); ok && p.Text == e.marker {
+		os.Exit(3)
+	}
+	return e.Engine.Text(img, config, opts)
+}
+
+func extract(t *testing.T, config pdfocr.OCRConfig, fixture testsupport.Fixture) (string, pdfocr.DocumentManifest) {
+	t.Helper()
+	ex, err := pdfocr.NewExtractor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, manifest, err := ex.ExtractData(context.Background(), fixture.Bytes(), "fixture.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return text, manifest
+}
+
+func TestPipelineMethods(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{Text: nativeText},
+		{OCR: "Scanned invoice 42"},
+		{Text: "Please wait...", OCR: "Form contents"},
+	}}
+	text, manifest := extract(t, testsupport.Config(), fixture)
+
+	tests := []struct {
+		page   int
+		method string
+		text   string
+	}{
+		{1, pdfocr.MethodNative, nativeText},
+		{2, pdfocr.MethodOCR, "Scanned invoice 42"},
+		{3, pdfocr.MethodOCR, "Form contents"},
+	}
+	if len(manifest.PageResults) != len(tests) {
+		t.Fatalf("got %d page results, want %d", len(manifest.PageResults), len(tests))
+	}
+	for i, tt := range tests {
+		p := manifest.PageResults[i]
+		if p.Page != tt.page || p.Method != tt.method || strings.TrimSpace(p.Text) != tt.text {
+			t.Errorf("page %d = (%d, %q, %q), want (%d, %q, %q)", i+1, p.Page, p.Method, p.Text, tt.page, tt.method, tt.text)
+		}
+	}
+	for _, want := range []string{"--- Page 1 ---\n" + nativeText, "--- Page 2 (OCR) ---\nScanned invoice 42"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text does not contain %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestPipelinePageSelection(t *testing.T) {
+	config := testsupport.Config()
+	set, err := pdfocr.ParsePageSet("2-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.PageSelection = set
+	_, manifest := extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{OCR: "one"}, {OCR: "two"}, {OCR: "three"},
+	}})
+	var pages []int
+	for _, p := range manifest.PageResults {
+		pages = append(pages, p.Page)
+	}
+	if len(pages) != 2 || pages[0] != 2 || pages[1] != 3 {
+		t.Errorf("processed pages %v, want [2 3]", pages)
+	}
+}
+
+func TestPipelineOCRMode(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{Text: nativeText, OCR: "Recognized page one"},
+		{OCR: "Scanned invoice 42"},
+	}}
+	for _, tt := range []struct {
+		mode  string
+		pages [2]string
+	}{
+		{pdfocr.OCRModeAuto, [2]string{nativeText, "Scanned invoice 42"}},
+		{pdfocr.OCRModeForce, [2]string{"Recognized page one", "Scanned invoice 42"}},
+		{pdfocr.OCRModeNever, [2]string{nativeText, ""}},
+	} {
+		config := testsupport.Config()
+		config.OCRMode = tt.mode
+		_, manifest := extract(t, config, fixture)
+		var pages [2]string
+		for i, p := range manifest.PageResults {
+			pages[i] = strings.TrimSpace(p.Text)
+		}
+		if pages != tt.pages {
+			t.Errorf("-ocr-mode %s: pages %q, want %q", tt.mode, pages, tt.pages)
+		}
+	}
+}
+
+func TestPipelineWordBoxes(t *testing.T) {
+	config := testsupport.Config()
+	config.WordBoxes = true
+	_, manifest := extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{{OCR: "Total due 120.00"}}})
+	data, err := pdfocr.DocumentJSON(manifest, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Pages []struct {
+			Words []struct {
+				Text       string  `json:"text"`
+				Confidence float64 `json:"confidence"`
+			} `json:"words"`
+		} `json:"pages"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Pages) != 1 || len(doc.Pages[0].Words) != 3 {
+		t.Fatalf("got %+v, want one page with three words", doc.Pages)
+	}
+	for _, w := range doc.Pages[0].Words {
+		if w.Confidence != 95 {
+			t.Errorf("word %q has confidence %v, want 95", w.Text, w.Confidence)
+		}
+	}
+}
+
+func TestPipelineFastSkipsBlankPages(t *testing.T) {
+	config := testsupport.Config()
+	config.Fast = true
+	_, manifest := extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{OCR: "Cover letter"}, {}, {OCR: "Cover letter"},
+	}})
+	want := []string{pdfocr.MethodOCR, pdfocr.MethodSkippedBlank, pdfocr.MethodSkippedDuplicate}
+	for i, p := range manifest.PageResults {
+		if i < len(want) && p.Method != want[i] {
+			t.Errorf("page %d method %q, want %q", p.Page, p.Method, want[i])
+		}
+	}
+}
+
+func TestPipelineProgress(t *testing.T) {
+	config := testsupport.Config()
+	config.Workers = 2
+	var events []pdfocr.ProgressEvent
+	config.Progress = func(e pdfocr.ProgressEvent) { events = append(events, e) }
+	set, err := pdfocr.ParsePageSet("2-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.PageSelection = set
+	extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{OCR: "skipped"}, {Text: nativeText}, {OCR: "Scanned page"},
+	}})
+
+	if len(events) == 0 || events[0].Stage != pdfocr.StageStart || events[0].Pages != 2 {
+		t.Fatalf("first event %+v, want start of 2 pages", events)
+	}
+	stages := map[int][]string{}
+	var done []int
+	for _, e := range events[1:] {
+		stages[e.Page] = append(stages[e.Page], e.Stage)
+		if e.Index != e.Page-1 {
+			t.Errorf("page %d has index %d, want %d", e.Page, e.Index, e.Page-1)
+		}
+		if e.Stage == pdfocr.StageDone {
+			done = append(done, e.Page)
+		}
+	}
+	want := map[int]string{
+		2: "native done",
+		3: "render ocr done",
+	}
+	for page, w := range want {
+		if got := strings.Join(stages[page], " "); got != w {
+			t.Errorf("page %d stages %q, want %q", page, got, w)
+		}
+	}
+	if len(done) != 2 || done[0] != 2 || done[1] != 3 {
+		t.Errorf("pages done in order %v, want [2 3]", done)
+	}
+}
+
+func TestPipelineMinConfidence(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}, {OCR: "Faint scan"}}}
+	config := testsupport.Config()
+	config.MinConfidence = 60
+	_, manifest := extract(t, config, fixture)
+	if p := manifest.PageResults[1]; p.Confidence != 95 || p.LowConfidence || len(manifest.ReviewPages) != 0 {
+		t.Errorf("confident page: confidence %v, flagged %v, review pages %v", p.Confidence, p.LowConfidence, manifest.ReviewPages)
+	}
+
+	pdfocr.RegisterEngine(testsupport.Engine{Confidence: 40})
+	defer pdfocr.RegisterEngine(testsupport.Engine{})
+	_, manifest = extract(t, config, fixture)
+	if p := manifest.PageResults[1]; p.Confidence != 40 || !p.LowConfidence {
+		t.Errorf("faint page: confidence %v, flagged %v, want 40 and flagged", p.Confidence, p.LowConfidence)
+	}
+	if p := manifest.PageResults[0]; p.LowConfidence {
+		t.Errorf("native page flagged for review")
+	}
+	if len(manifest.ReviewPages) != 1 || manifest.ReviewPages[0] != 2 {
+		t.Errorf("review pages %v, want [2]", manifest.ReviewPages)
+	}
+}
+
+func TestPipelineWarnings(t *testing.T) {
+	config := testsupport.Config()
+	var received []pdfocr.Warning
+	config.Warnings = func(w pdfocr.Warning) { received = append(received, w) }
+	_, manifest := extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{OCR: "Readable"}, {OCR: "Unreadable", RenderError: "decoder failure"},
+	}})
+	if len(manifest.Warnings) == 0 {
+		t.Fatal("no warnings for a page that cannot be rendered")
+	}
+	if len(received) != len(manifest.Warnings) {
+		t.Errorf("callback received %d warnings, manifest lists %d", len(received), len(manifest.Warnings))
+	}
+	for _, w := range manifest.Warnings {
+		if w.Document != "fixture.pdf" || w.Page != 2 || strings.HasPrefix(w.Message, "Warning") || strings.HasSuffix(w.Message, "\n") {
+			t.Errorf("warning %+v, want a message about page 2 of fixture.pdf", w)
+		}
+	}
+	if p := manifest.PageResults[1]; p.Method != pdfocr.MethodFailed {
+		t.Errorf("page 2 method %q, want %q", p.Method, pdfocr.MethodFailed)
+	}
+}
+
+func TestPipelineTables(t *testing.T) {
+	scan := "Prices as of the first of the month, subject to change.\n\n" +
+		"Item      Qty   Price\n" +
+		"Apples    3     1.20\n" +
+		"Pears     12    0.80\n" +
+		"Plums           2.10"
+	config := testsupport.Config()
+	config.Tables = true
+	_, manifest := extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{{OCR: scan}}})
+	tables := manifest.PageResults[0].Tables
+	if len(tables) != 1 {
+		t.Fatalf("found %d tables, want 1: %+v", len(tables), tables)
+	}
+	want := [][]string{{"Item", "Qty", "Price"}, {"Apples", "3", "1.20"}, {"Pears", "12", "0.80"}, {"Plums", "", "2.10"}}
+	if !reflect.DeepEqual(tables[0].Rows, want) {
+		t.Errorf("table rows %q, want %q", tables[0].Rows, want)
+	}
+
+	dir := t.TempDir()
+	if err := pdfocr.WriteTablesDir(dir, manifest); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "page_1_table_1.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csv := "Item,Qty,Price\nApples,3,1.20\nPears,12,0.80\nPlums,,2.10\n"; string(data) != csv {
+		t.Errorf("CSV file:\n%s\nwant:\n%s", data, csv)
+	}
+}
+
+func TestPipelineChartLabels(t *testing.T) {
+	config := testsupport.Config()
+	config.Preset = pdfocr.PresetChart
+	chart := "Sales by region\n\n\n\nNorth          South"
+	text, manifest := extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{{OCR: chart}}})
+	if !strings.Contains(text, "Sales by region\nNorth\nSouth") {
+		t.Errorf("text %q, want one label per line", text)
+	}
+	data, err := pdfocr.DocumentJSON(manifest, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Pages []struct {
+			ChartLabels []struct {
+				Text string     `json:"text"`
+				Box  [4]float64 `json:"box"`
+			} `json:"chart_labels"`
+		} `json:"pages"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	labels := doc.Pages[0].ChartLabels
+	if len(labels) != 3 {
+		t.Fatalf("chart labels %+v, want 3", labels)
+	}
+	for i, want := range []string{"Sales by region", "North", "South"} {
+		if labels[i].Text != want {
+			t.Errorf("label %d is %q, want %q", i, labels[i].Text, want)
+		}
+	}
+	// Labels are placed on the page in points, as the words are
+	if b := labels[0].Box; b[0] != 72 || b[2] != 72+15*6 {
+		t.Errorf("label %q box %v, want it from x 72 to 162", labels[0].Text, b)
+	}
+	if labels[2].Box[0] <= labels[1].Box[2] || labels[1].Box[1] <= labels[0].Box[3] {
+		t.Errorf("label boxes out of place: %+v", labels)
+	}
+}
+
+func TestSearchablePDFTextLayer(t *testing.T) {
+	dir := t.TempDir()
+	in, out := filepath.Join(dir, "fixture.pdf"), filepath.Join(dir, "searchable.pdf")
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{OCR: "Hello wide   world\nSecond line"}}}
+	if err := os.WriteFile(in, fixture.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pdfocr.WriteSearchablePDF(context.Background(), in, out, testsupport.Config()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Word Tm operators: "<scale> Tz 1 0 0 1 <x> <y> Tm <hex> Tj"
+	baselines := map[string]bool{}
+	positions := map[string]string{}
+	spaces := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		switch {
+		case len(f) == 11 && f[8] == "Tm":
+			text, err := hex.DecodeString(strings.Trim(f[9], "<>"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			positions[string(text)] = f[6]
+			baselines[f[7]] = true
+		case len(f) == 4 && f[2] == "<20>":
+			spaces++
+		}
+	}
+	if len(positions) != 5 || len(baselines) != 2 {
+		t.Errorf("words %v on %d baselines, want 5 words on 2", positions, len(baselines))
+	}
+	if spaces != 3 {
+		t.Errorf("%d spaces between words, want 3", spaces)
+	}
+	// The fixture lays out 6-point characters from a 72-point margin
+	if x := positions["world"]; x != "150.00" {
+		t.Errorf("world placed at x %s, want 150.00", x)
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestPipelineExtractTo(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}, {OCR: "Scanned"}, {OCR: "Another scan"}}}
+	path := filepath.Join(t.TempDir(), "fixture.pdf")
+	if err := os.WriteFile(path, fixture.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := testsupport.Config()
+	config.Workers = 2
+	var seen []int
+	config.Pages = func(p pdfocr.PageResult) error {
+		if p.Text == "" {
+			t.Errorf("page %d delivered without its text", p.Page)
+		}
+		seen = append(seen, p.Page)
+		return nil
+	}
+	ex, err := pdfocr.NewExtractor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _, err := ex.ExtractDocument(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen = nil
+	var streamed bytes.Buffer
+	manifest, err := ex.ExtractTo(context.Background(), &streamed, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamed.String() != want {
+		t.Errorf("streamed text %q, want %q", streamed.String(), want)
+	}
+	if !reflect.DeepEqual(seen, []int{1, 2, 3}) {
+		t.Errorf("callback received pages %v, want [1 2 3]", seen)
+	}
+	for _, p := range manifest.PageResults {
+		if p.Text != "" {
+			t.Errorf("page %d kept its text in the manifest", p.Page)
+		}
+	}
+
+	if _, err := ex.ExtractTo(context.Background(), failingWriter{}, path); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("writing to a failing writer: error %v, want disk full", err)
+	}
+}
+
+func TestPipelineFlush(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}, {OCR: "Scanned"}, {OCR: "Another scan"}}}
+	for _, format := range []string{pdfocr.FormatText, pdfocr.FormatJSON, pdfocr.FormatALTO, pdfocr.FormatMarkdown} {
+		config := testsupport.Config()
+		config.OutputFile = filepath.Join(t.TempDir(), "out")
+		config.FlushEvery, config.OutputFormat = 2, format
+		config.WordBoxes = format == pdfocr.FormatJSON || format == pdfocr.FormatALTO
+		config.Markdown = format == pdfocr.FormatMarkdown
+		extract(t, config, fixture)
+		// The run leaves the flush after page 2, which the command line
+		// replaces with the complete output
+		data, err := os.ReadFile(config.OutputFile)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if !bytes.Contains(data, []byte("Scanned")) || bytes.Contains(data, []byte("Another scan")) {
+			t.Errorf("%s: flushed output does not hold pages 1 and 2 alone:\n%s", format, data)
+		}
+		switch format {
+		case pdfocr.FormatJSON:
+			var doc struct {
+				Document pdfocr.DocumentManifest `json:"document"`
+				Pages    []pdfocr.PageResult     `json:"pages"`
+			}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatalf("flushed JSON: %v", err)
+			}
+			if !doc.Document.Partial || doc.Document.Pages != 3 || len(doc.Pages) != 2 {
+				t.Errorf("flushed JSON: partial %v, %d of %d pages", doc.Document.Partial, len(doc.Pages), doc.Document.Pages)
+			}
+		}
+	}
+}
+
+// regionEngine is the mock engine recording the segmentation of every
+// recognition of a region.
+type regionEngine struct {
+	testsupport.Engine
+	calls *[]string
+}
+
+func (e regionEngine) Text(img image.Image, config pdfocr.OCRConfig, opts pdfocr.PageOCROptions) (string, error) {
+	switch {
+	case opts.SingleBlock:
+		*e.calls = append(*e.calls, "block")
+	case opts.SparseText:
+		*e.calls = append(*e.calls, "sparse")
+	default:
+		*e.calls = append(*e.calls, "page")
+	}
+	return (*e.calls)[len(*e.calls)-1], nil
+}
+
+func (e regionEngine) Words(img image.Image, config pdfocr.OCRConfig, opts pdfocr.PageOCROptions) ([]pdfocr.OCRWord, error) {
+	if opts.SparseText {
+		*e.calls = append(*e.calls, "table")
+	}
+	return e.Engine.Words(img, config, opts)
+}
+
+func TestPipelineTwoPass(t *testing.T) {
+	var calls []string
+	pdfocr.RegisterEngine(regionEngine{calls: &calls})
+	defer pdfocr.RegisterEngine(testsupport.Engine{})
+
+	scan := "Prices are listed for the current month\nand may change without notice.\n\n" +
+		"Item      Qty   Price\n" +
+		"Apples    3     1.20\n" +
+		"Pears     12    0.80\n\n" +
+		"Signature            Date"
+	config := testsupport.Config()
+	config.TwoPass = true
+	text, _ := extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{{OCR: scan}}})
+	if want := []string{"block", "table", "sparse"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("regions recognized as %v, want %v", calls, want)
+	}
+	if !strings.Contains(text, "block\n\nsparse") {
+		t.Errorf("page text %q does not join the regions", text)
+	}
+
+	calls = nil
+	config.OnlyRegions = []string{pdfocr.RegionBodyText}
+	text, _ = extract(t, config, testsupport.Fixture{Pages: []testsupport.FixturePage{{OCR: scan}}})
+	if want := []string{"block"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("only body text: regions recognized as %v, want %v", calls, want)
+	}
+	if !strings.HasSuffix(strings.TrimSpace(text), "---\nblock") {
+		t.Errorf("only body text: page text %q", text)
+	}
+	if _, err := pdfocr.ParseRegionKinds("body-text,figure"); err == nil {
+		t.Error("ParseRegionKinds accepted an unknown kind")
+	}
+
+	config.TwoPass = false
+	if _, err := pdfocr.NewExtractor(config); err == nil {
+		t.Error("region filter accepted without two-pass recognition")
+	}
+	config.TwoPass, config.OnlyRegions = true, nil
+	config.Best = true
+	if _, err := pdfocr.NewExtractor(config); err == nil {
+		t.Error("two-pass recognition accepted with best mode")
+	}
+}
+
+func TestPipelineKeywords(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}, {OCR: "Quarterly INVOICE total"}, {OCR: "Another invoice"}}}
+	config := testsupport.Config()
+	config.Keywords = []string{"receipt", "invoice"}
+	_, manifest := extract(t, config, fixture)
+	if len(manifest.PageResults) != 2 || manifest.Truncated {
+		t.Errorf("processed %d pages (truncated %v), want 2 and not truncated", len(manifest.PageResults), manifest.Truncated)
+	}
+	if m := manifest.Keyword; m == nil || *m != (pdfocr.KeywordMatch{Term: "invoice", Page: 2}) {
+		t.Errorf("keyword match %+v, want invoice on page 2", m)
+	}
+
+	config.Keywords = []string{"voice"}
+	_, manifest = extract(t, config, fixture)
+	if len(manifest.PageResults) != 3 || manifest.Keyword != nil {
+		t.Errorf("part of a word: processed %d pages, match %+v, want 3 and none", len(manifest.PageResults), manifest.Keyword)
+	}
+}
+
+func TestPipelineErrorPolicy(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{Text: nativeText}, {TextError: "broken content stream"}, {OCR: "Unreadable", RenderError: "decoder failure"},
+	}}
+	config := testsupport.Config()
+	_, manifest := extract(t, config, fixture)
+	if len(manifest.PageResults) != 3 || manifest.PageResults[1].Method != pdfocr.MethodFailed || manifest.PageResults[2].Method != pdfocr.MethodFailed {
+		t.Fatalf("collect: pages %+v, want 3 with pages 2 and 3 failed", manifest.PageResults)
+	}
+	report := manifest.Report()
+	if report.Pages != 3 || len(report.Failed) != 2 || report.Err() == nil {
+		t.Fatalf("collect: report %+v, want 2 failed of 3 pages", report)
+	}
+	for i, want := range []pdfocr.PageError{{Document: "fixture.pdf", Page: 2, Stage: pdfocr.PageErrorText}, {Document: "fixture.pdf", Page: 3, Stage: pdfocr.PageErrorOCR}} {
+		if e := report.Failed[i]; e.Document != want.Document || e.Page != want.Page || e.Stage != want.Stage || e.Err == nil {
+			t.Errorf("collect: failure %d %+v, want page %d at stage %s", i, e, want.Page, want.Stage)
+		}
+	}
+
+	config.ErrorPolicy = pdfocr.ErrorPolicySkip
+	_, manifest = extract(t, config, fixture)
+	if len(manifest.PageResults) != 1 || len(manifest.Report().Failed) != 0 || len(manifest.Warnings) == 0 {
+		t.Errorf("skip: %d pages, %d failures, %d warnings, want 1 page left with warnings", len(manifest.PageResults), len(manifest.Report().Failed), len(manifest.Warnings))
+	}
+
+	config.ErrorPolicy = pdfocr.ErrorPolicyFailFast
+	ex, err := pdfocr.NewExtractor(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = ex.ExtractData(context.Background(), fixture.Bytes(), "fixture.pdf")
+	var pageErr *pdfocr.PageError
+	if !errors.As(err, &pageErr) || pageErr.Page != 2 || pageErr.Stage != pdfocr.PageErrorText || !strings.Contains(err.Error(), "broken content stream") {
+		t.Errorf("fail-fast: error %v, want the text error of page 2", err)
+	}
+}
+
+func TestPipelineLogger(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}, {TextError: "broken content stream"}}}
+	var logs bytes.Buffer
+	config := testsupport.Config()
+	config.Logger = slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	extract(t, config, fixture)
+
+	var sawProgress, sawWarning bool
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var record struct {
+			Level, Msg, Document string
+			Page                 int
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		switch {
+		case record.Level == "INFO" && strings.HasPrefix(record.Msg, "Processing 2 pages"):
+			sawProgress = true
+		case record.Level == "WARN" && record.Document == "fixture.pdf" && record.Page == 2:
+			sawWarning = true
+		}
+	}
+	if !sawProgress || !sawWarning {
+		t.Errorf("logged %s, want the progress and the warning of page 2", logs.String())
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{Text: nativeText},
+		{OCR: "Scanned invoice 42"},
+		{TextError: "broken content stream"},
+	}}
+	config := testsupport.Config()
+	config.WordBoxes, config.Markdown = true, true
+	config.PageSelection, _ = pdfocr.ParsePageSet("1-3")
+	wantText, wantManifest := extract(t, config, fixture)
+
+	t.Setenv("PDFOCR_TEST_WORKER_CRASH", "1")
+	sandbox, err := pdfocr.NewSandbox(pdfocr.SandboxOptions{Workers: 1, MemoryMB: 2048, Command: []string{os.Args[0], pdfocr.SandboxWorkerCommand}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sandbox.Close()
+	if _, _, err := sandbox.ExtractData(context.Background(), fixture.Bytes(), "fixture.pdf", config); err == nil || !strings.Contains(err.Error(), "crashed") {
+		t.Fatalf("extraction by a crashing worker: err = %v, want a crash", err)
+	}
+
+	// The next document gets a new worker
+	os.Setenv("PDFOCR_TEST_WORKER_CRASH", "")
+	text, manifest, err := sandbox.ExtractData(context.Background(), fixture.Bytes(), "fixture.pdf", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != wantText {
+		t.Errorf("sandboxed text = %q, want %q", text, wantText)
+	}
+	if !reflect.DeepEqual(manifest, wantManifest) {
+		t.Errorf("sandboxed manifest = %+v, want %+v", manifest, wantManifest)
+	}
+}
+
+func TestSandboxCrashedPage(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{Text: nativeText},
+		{OCR: "killer page"},
+		{OCR: "Scanned invoice 42"},
+		{OCR: "Scanned receipt 7"},
+	}}
+	t.Setenv("PDFOCR_TEST_WORKER_CRASH_ON", "killer page")
+	sandbox, err := pdfocr.NewSandbox(pdfocr.SandboxOptions{Workers: 1, Command: []string{os.Args[0], pdfocr.SandboxWorkerCommand}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sandbox.Close()
+	config := testsupport.Config()
+	config.Workers = 4
+	text, manifest, err := sandbox.ExtractData(context.Background(), fixture.Bytes(), "fixture.pdf", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var methods []string
+	for _, p := range manifest.PageResults {
+		methods = append(methods, p.Method)
+	}
+	if want := []string{pdfocr.MethodNative, pdfocr.MethodFailed, pdfocr.MethodOCR, pdfocr.MethodOCR}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("page methods = %v, want %v", methods, want)
+	}
+	failed := manifest.Report().Failed
+	if len(failed) != 1 || failed[0].Page != 2 || failed[0].Stage != pdfocr.PageErrorCrash || !errors.Is(failed[0], pdfocr.ErrWorkerCrashed) {
+		t.Errorf("failed pages = %v, want page 2 with ErrWorkerCrashed", failed)
+	}
+	if !strings.Contains(text, "Scanned invoice 42") || !strings.Contains(text, "Scanned receipt 7") {
+		t.Errorf("text is missing the pages after the crash:\n%s", text)
+	}
+
+	config.ErrorPolicy = pdfocr.ErrorPolicyFailFast
+	_, _, err = sandbox.ExtractData(context.Background(), fixture.Bytes(), "fixture.pdf", config)
+	var pageErr *pdfocr.PageError
+	if !errors.As(err, &pageErr) || pageErr.Page != 2 || !errors.Is(err, pdfocr.ErrWorkerCrashed) {
+		t.Errorf("fail-fast extraction: err = %v, want the crash of page 2", err)
+	}
+}
+
+func TestSandboxRegionTemplates(t *testing.T) {
+	// A template whose reference is the scanned page, ignoring all of it
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{
+		{Text: nativeText},
+		{OCR: "ACME Corporation letterhead\nInvoice 42"},
+	}}
+	doc, err := testsupport.Renderer{}.Open(fixture.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := doc.ImageDPI(1, 50)
+	doc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, ref); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "acme.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	templatesFile := filepath.Join(dir, "templates.json")
+	if err := os.WriteFile(templatesFile, []byte(`{"acme": {"reference": "acme.png", "regions": [{"box": [0, 0, 1, 1]}]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := testsupport.Config()
+	if config.RegionTemplates, err = pdfocr.LoadRegionTemplates(templatesFile, testsupport.RendererName); err != nil {
+		t.Fatal(err)
+	}
+	wantText, _ := extract(t, config, fixture)
+	if strings.Contains(wantText, "Invoice 42") {
+		t.Fatalf("the template did not apply to the scanned page:\n%s", wantText)
+	}
+
+	sandbox, err := pdfocr.NewSandbox(pdfocr.SandboxOptions{Workers: 1, Command: []string{os.Args[0], pdfocr.SandboxWorkerCommand}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sandbox.Close()
+	text, _, err := sandbox.ExtractData(context.Background(), fixture.Bytes(), "fixture.pdf", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != wantText {
+		t.Errorf("sandboxed text = %q, want %q", text, wantText)
+	}
+}
@@ -0,0 +1,128 @@
+package pdfocr
+
+import (
+	"context"
+)
+
+// pageOutcome is the result of one page processed by a worker.
+type pageOutcome struct {
+	pageNum     int
+	page        PageResult
+	annotations string // annotation section, with -annotations
+	err         error
+}
+
+// pagePool renders and recognizes the pages of a document on several
+// workers. Every worker has its own copy of the document, since renderers
+// are not safe for concurrent use; the first worker uses the caller's.
+type pagePool struct {
+	sources []*pdfSource
+}
+
+// newPagePool opens the document copies for up to workers workers. Copies
+// that fail to open only reduce the pool.
+func newPagePool(src *pdfSource, workers int, config OCRConfig) *pagePool {
+	p := &pagePool{sources: []*pdfSource{src}}
+	for len(p.sources) < workers {
+		s, err := openPDFSource(src.data, src.name, config.Renderer)
+		if err != nil {
+			src.warnings.warnf(-1, "Warning: running %d of %d workers on %s: %v\n", len(p.sources), workers, src.name, err)
+			break
+		}
+		s.noScratch, s.warnings = src.noScratch, src.warnings
+		p.sources = append(p.sources, s)
+	}
+	return p
+}
+
+// Close releases the document copies, not the caller's document.
+func (p *pagePool) Close() {
+	for _, s := range p.sources[1:] {
+		s.Close()
+	}
+}
+
+// run processes the zero-based pages pageNums in order of dispatch until
+// the last, a cancellation of ctx, the end of the budget or a false return
+// of deliver. A page is dispatched only once a worker is free, so the budget
+// is checked when the page would start, as in a sequential run. Outcomes
+// are delivered in page order; run returns the number of pages dispatched.
+func (p *pagePool) run(ctx context.Context, pageNums []int, config OCRConfig, deliver func(pageOutcome) bool) int {
+	jobs := make(chan int)
+	ready := make(chan struct{}, len(p.sources))
+	outcomes := make(chan pageOutcome, len(p.sources))
+	stop := make(chan struct{})
+
+	done := make(chan struct{})
+	for _, src := range p.sources {
+		go func(src *pdfSource) {
+			defer func() { done <- struct{}{} }()
+			for {
+				ready <- struct{}{}
+				pageNum, ok := <-jobs
+				if !ok {
+					return
+				}
+				o := pageOutcome{pageNum: pageNum}
+				if config.trackPages != nil {
+					config.trackPages(pageNum+1, true)
+				}
+				o.page, o.err = extractPageText(ctx, src, pageNum, config)
+				if o.err == nil && config.Annotations {
+					o.annotations = annotationSection(src, pageNum, config)
+				}
+				if o.err == nil && config.Thumbnails {
+					o.page.Thumbnail = src.thumbnail(pageNum)
+				}
+				if config.trackPages != nil {
+					config.trackPages(pageNum+1, false)
+				}
+				outcomes <- o
+			}
+		}(src)
+	}
+
+	dispatched := make(chan int, 1)
+	go func() {
+		n := 0
+	dispatch:
+		for ; n < len(pageNums); n++ {
+			select {
+			case <-ready:
+			case <-stop:
+				break dispatch
+			}
+			if ctx.Err() != nil || !config.budget.take() {
+				break
+			}
+			jobs <- pageNums[n]
+		}
+		close(jobs)
+		dispatched <- n
+	}()
+	go func() {
+		for range p.sources {
+			<-done
+		}
+		close(outcomes)
+	}()
+
+	pending := map[int]pageOutcome{}
+	next, delivering := 0, true
+	for o := range outcomes {
+		pending[o.pageNum] = o
+		for delivering && next < len(pageNums) {
+			o, ok := pending[pageNums[next]]
+			if !ok {
+				break
+			}
+			delete(pending, pageNums[next])
+			next++
+			if !deliver(o) {
+				delivering = false
+				close(stop)
+			}
+		}
+	}
+	return <-dispatched
+}
@@ -0,0 +1,354 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Preprocessing of page images before OCR. PreprocessAuto measures every
+// page and picks its steps; a comma-separated list of steps applies the same
+// chain to every page.
+const (
+	PreprocessAuto      = "auto"
+	PreprocessNone      = "none"
+	PreprocessGrayscale = "grayscale" // drop color only
+	PreprocessContrast  = "contrast"  // stretch the gray levels to the full range
+	PreprocessDenoise   = "denoise"   // 3x3 median filter against speckle
+	PreprocessDeskew    = "deskew"    // rotate text lines level
+	PreprocessBinarize  = "binarize"  // black and white at the Otsu threshold
+)
+
+// preprocessSteps are the steps in the order they are applied. Binarizing
+// comes last so the interpolated gray of deskewing is thresholded too.
+var preprocessSteps = []string{PreprocessGrayscale, PreprocessContrast, PreprocessDenoise, PreprocessDeskew, PreprocessBinarize}
+
+// preprocessAliases are other names accepted for steps.
+var preprocessAliases = map[string]string{"despeckle": PreprocessDenoise, "gray": PreprocessGrayscale}
+
+const (
+	// Thresholds used by PreprocessAuto
+	lowContrast  = 0.6   // spread of the 1st-99th percentile gray levels
+	speckleShare = 0.002 // share of pixels that are isolated dark specks
+	minSkew      = 0.5   // degrees
+	maxSkew      = 5.0   // degrees searched either way
+	skewStep     = 0.25  // degrees
+
+	// skewSample is the pixel stride of the skew estimate
+	skewSample = 4
+)
+
+// pageMetrics describes the quality of a rendered page.
+type pageMetrics struct {
+	Noise    float64 // share of pixels that are isolated dark specks
+	Skew     float64 // degrees; positive when lines fall to the right
+	Contrast float64 // 0 (flat) to 1 (black on white)
+
+	lo, hi uint8 // 1st and 99th percentile gray levels
+}
+
+// validatePreprocess checks a -preprocess value given on the command line.
+func validatePreprocess(value string) error {
+	_, err := parsePreprocess(value)
+	return err
+}
+
+// parsePreprocess returns the fixed steps named by value, in the order they
+// are applied. Auto and none return no steps.
+func parsePreprocess(value string) ([]string, error) {
+	if value == "" || value == PreprocessNone || value == PreprocessAuto {
+		return nil, nil
+	}
+	var steps []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if step, ok := preprocessAliases[name]; ok {
+			name = step
+		}
+		if !slices.Contains(preprocessSteps, name) {
+			return nil, fmt.Errorf("unsupported preprocessing step %q (use auto, none or a list of grayscale, contrast, denoise, deskew, binarize)", name)
+		}
+		steps = append(steps, name)
+	}
+	var ordered []string
+	for _, step := range preprocessSteps {
+		if slices.Contains(steps, step) {
+			ordered = append(ordered, step)
+		}
+	}
+	return ordered, nil
+}
+
+// preprocessPage applies config.Preprocess to a rendered page and returns
+// the image to OCR with the steps applied. Deskewing moves words, so it is
+// left out unless deskew is set, for callers that need word positions on
+// the page.
+func preprocessPage(img image.Image, pageNum int, config OCRConfig, deskew bool) (image.Image, []string) {
+	if config.Preprocess == "" || config.Preprocess == PreprocessNone {
+		return img, nil
+	}
+	gray := toGray(img)
+	m := measurePage(gray)
+
+	var steps []string
+	if config.Preprocess == PreprocessAuto {
+		if m.Contrast < lowContrast {
+			steps = append(steps, PreprocessContrast)
+		}
+		if m.Noise > speckleShare {
+			steps = append(steps, PreprocessDenoise)
+		}
+		if math.Abs(m.Skew) >= minSkew {
+			steps = append(steps, PreprocessDeskew)
+		}
+	} else {
+		// Validated with the config
+		steps, _ = parsePreprocess(config.Preprocess)
+	}
+	if !deskew {
+		steps = slices.DeleteFunc(steps, func(s string) bool { return s == PreprocessDeskew })
+	}
+	if len(steps) == 0 {
+		return img, nil
+	}
+	if config.Preprocess == PreprocessAuto {
+		config.debugf("Page %d: noise %.3f, skew %.1f°, contrast %.2f; applying %s\n",
+			pageNum+1, m.Noise, m.Skew, m.Contrast, strings.Join(steps, ", "))
+	}
+
+	for _, step := range steps {
+		switch step {
+		case PreprocessContrast:
+			gray = stretchContrast(gray, m.lo, m.hi)
+		case PreprocessDenoise:
+			gray = medianFilter(gray)
+		case PreprocessDeskew:
+			gray = rotateGray(gray, m.Skew)
+		case PreprocessBinarize:
+			gray = binarize(gray, otsuThreshold(gray))
+		}
+	}
+	return gray, steps
+}
+
+// toGray converts an image to 8-bit gray.
+func toGray(img image.Image) *image.Gray {
+	if g, ok := img.(*image.Gray); ok {
+		return g
+	}
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			gray.Pix[y*gray.Stride+x] = color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+		}
+	}
+	return gray
+}
+
+// measurePage estimates the contrast, speckle noise and skew of a page.
+func measurePage(gray *image.Gray) pageMetrics {
+	var m pageMetrics
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	if w == 0 || h == 0 {
+		return m
+	}
+
+	var hist [256]int
+	for y := 0; y < h; y++ {
+		for _, v := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			hist[v]++
+		}
+	}
+	m.lo, m.hi = percentile(hist, w*h, 0.01), percentile(hist, w*h, 0.99)
+	m.Contrast = float64(int(m.hi)-int(m.lo)) / 255
+	if m.hi-m.lo < 16 {
+		// Blank page: nothing to measure noise or skew on
+		return m
+	}
+	threshold := uint8((int(m.lo) + int(m.hi)) / 2)
+	dark := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && gray.Pix[y*gray.Stride+x] < threshold
+	}
+
+	isolated := 0
+	var points [][2]int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !dark(x, y) {
+				continue
+			}
+			if !dark(x-1, y-1) && !dark(x, y-1) && !dark(x+1, y-1) && !dark(x-1, y) &&
+				!dark(x+1, y) && !dark(x-1, y+1) && !dark(x, y+1) && !dark(x+1, y+1) {
+				isolated++
+				continue
+			}
+			if x%skewSample == 0 && y%skewSample == 0 {
+				points = append(points, [2]int{x, y})
+			}
+		}
+	}
+	m.Noise = float64(isolated) / float64(w*h)
+	m.Skew = estimateSkew(points, w, h)
+	return m
+}
+
+// percentile returns the gray level below which share p of the n pixels of
+// hist lie.
+func percentile(hist [256]int, n int, p float64) uint8 {
+	target := int(float64(n) * p)
+	sum := 0
+	for v, count := range hist {
+		sum += count
+		if sum > target {
+			return uint8(v)
+		}
+	}
+	return 255
+}
+
+// estimateSkew finds the angle at which the dark points line up into the
+// sharpest horizontal rows (the projection profile method).
+func estimateSkew(points [][2]int, w, h int) float64 {
+	if len(points) < 100 {
+		return 0
+	}
+	score := func(deg float64) float64 {
+		tan := math.Tan(deg * math.Pi / 180)
+		off := float64(w) * math.Abs(tan)
+		rows := make([]float64, int(float64(h)+2*off)/skewSample+2)
+		for _, p := range points {
+			r := int((float64(p[1]) - float64(p[0])*tan + off) / skewSample)
+			if r >= 0 && r < len(rows) {
+				rows[r]++
+			}
+		}
+		var sum float64
+		for _, n := range rows {
+			sum += n * n
+		}
+		return sum
+	}
+	best, bestScore := 0.0, score(0)
+	for deg := -maxSkew; deg <= maxSkew; deg += skewStep {
+		if s := score(deg); s > bestScore*1.02 {
+			best, bestScore = deg, s
+		}
+	}
+	return best
+}
+
+// stretchContrast maps the gray levels lo-hi linearly onto 0-255.
+func stretchContrast(gray *image.Gray, lo, hi uint8) *image.Gray {
+	out := image.NewGray(gray.Rect)
+	span := math.Max(float64(hi)-float64(lo), 1)
+	var lut [256]uint8
+	for v := range lut {
+		lut[v] = clampByte((float64(v) - float64(lo)) * 255 / span)
+	}
+	for i, v := range gray.Pix {
+		out.Pix[i] = lut[v]
+	}
+	return out
+}
+
+// medianFilter replaces every pixel by the median of its 3x3 neighbourhood.
+func medianFilter(gray *image.Gray) *image.Gray {
+	out := image.NewGray(gray.Rect)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	var window [9]uint8
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			n := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					sx, sy := min(max(x+dx, 0), w-1), min(max(y+dy, 0), h-1)
+					window[n] = gray.Pix[sy*gray.Stride+sx]
+					n++
+				}
+			}
+			slices.Sort(window[:])
+			out.Pix[y*out.Stride+x] = window[4]
+		}
+	}
+	return out
+}
+
+// rotateGray undoes a skew of deg degrees about the image center with
+// bilinear sampling, filling uncovered corners with white.
+func rotateGray(gray *image.Gray, deg float64) *image.Gray {
+	out := image.NewGray(gray.Rect)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	sin, cos := math.Sincos(deg * math.Pi / 180)
+	cx, cy := float64(w)/2, float64(h)/2
+	at := func(x, y int) float64 {
+		if x < 0 || y < 0 || x >= w || y >= h {
+			return 255
+		}
+		return float64(gray.Pix[y*gray.Stride+x])
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			sx, sy := cx+dx*cos-dy*sin-0.5, cy+dx*sin+dy*cos-0.5
+			x0, y0 := int(math.Floor(sx)), int(math.Floor(sy))
+			fx, fy := sx-float64(x0), sy-float64(y0)
+			v := (at(x0, y0)*(1-fx)+at(x0+1, y0)*fx)*(1-fy) + (at(x0, y0+1)*(1-fx)+at(x0+1, y0+1)*fx)*fy
+			out.Pix[y*out.Stride+x] = clampByte(v)
+		}
+	}
+	return out
+}
+
+// otsuThreshold returns the gray level that best separates ink from paper:
+// the one maximizing the variance between the levels below and above it.
+func otsuThreshold(gray *image.Gray) uint8 {
+	var hist [256]int
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	for y := 0; y < h; y++ {
+		for _, v := range gray.Pix[y*gray.Stride : y*gray.Stride+w] {
+			hist[v]++
+		}
+	}
+	total, sum := w*h, 0.0
+	for v, count := range hist {
+		sum += float64(v * count)
+	}
+	var best uint8
+	var bestVar, sumBelow float64
+	below := 0
+	for v, count := range hist {
+		below += count
+		if below == 0 {
+			continue
+		}
+		above := total - below
+		if above == 0 {
+			break
+		}
+		sumBelow += float64(v * count)
+		meanBelow := sumBelow / float64(below)
+		meanAbove := (sum - sumBelow) / float64(above)
+		if between := float64(below) * float64(above) * (meanBelow - meanAbove) * (meanBelow - meanAbove); between > bestVar {
+			best, bestVar = uint8(v), between
+		}
+	}
+	return best
+}
+
+// binarize turns the gray levels up to threshold black and the rest white.
+func binarize(gray *image.Gray, threshold uint8) *image.Gray {
+	out := image.NewGray(gray.Rect)
+	w, h := gray.Rect.Dx(), gray.Rect.Dy()
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if gray.Pix[y*gray.Stride+x] > threshold {
+				out.Pix[y*out.Stride+x] = 255
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package pdfocr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// previewDPI is the render resolution of preview runs: lower than the
+// default, but still enough to classify a document or show its gist.
+const previewDPI = 150
+
+// fastTessdataDirs are the usual install locations of the tessdata_fast
+// models, which trade some accuracy for several times the speed.
+var fastTessdataDirs = []string{
+	"/usr/share/tesseract-ocr/5/tessdata_fast",
+	"/usr/share/tesseract-ocr/4.00/tessdata_fast",
+	"/usr/share/tessdata_fast",
+	"/usr/local/share/tessdata_fast",
+	"/opt/homebrew/share/tessdata_fast",
+}
+
+// findFastTessdata returns a directory with fast models for every language
+// of lang (e.g. "eng+deu"), or "" if there is none. A tessdata_fast
+// directory next to TESSDATA_PREFIX is tried first.
+func findFastTessdata(lang string) string {
+	return findTessdataVariant("tessdata_fast", fastTessdataDirs, lang)
+}
+
+// findTessdataVariant returns the first of dirs, after the directory named
+// variant next to TESSDATA_PREFIX, with models for every language of lang.
+func findTessdataVariant(variant string, dirs []string, lang string) string {
+	if prefix := os.Getenv("TESSDATA_PREFIX"); prefix != "" {
+		dirs = append([]string{filepath.Join(filepath.Dir(filepath.Clean(prefix)), variant)}, dirs...)
+	}
+	for _, dir := range dirs {
+		found := true
+		for _, l := range strings.Split(lang, "+") {
+			if _, err := os.Stat(filepath.Join(dir, l+".traineddata")); err != nil {
+				found = false
+				break
+			}
+		}
+		if found {
+			return dir
+		}
+	}
+	return ""
+}
+
+// ApplyPreview configures a quick run over the first pages pages: a page
+// budget, 150 DPI and the fast models when they are installed.
+func ApplyPreview(config *OCRConfig, pages int) {
+	if config.MaxPages == 0 || config.MaxPages > pages {
+		config.MaxPages = pages
+	}
+	config.DPI = previewDPI
+	if config.TessdataDir == "" {
+		if dir := findFastTessdata(config.Language); dir != "" {
+			config.TessdataDir = dir
+		} else {
+			config.logf("Preview: no tessdata_fast models for %s found, using the default models\n", config.Language)
+		}
+	}
+	config.logf("Preview: first %d page(s) at %d DPI\n", config.MaxPages, previewDPI)
+}
@@ -0,0 +1,17 @@
+//go:build darwin || freebsd || netbsd || openbsd
+
+package pdfocr
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// SetNice sets the scheduling priority of the process; child processes
+// inherit it.
+func SetNice(level int) error {
+	if err := syscall.Setpriority(syscall.PRIO_PROCESS, 0, level); err != nil {
+		return fmt.Errorf("error setting nice level %d: %w", level, err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package pdfocr
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// SetNice sets the scheduling priority of the process. Linux keeps a nice
+// value per thread, so every thread of the process is changed; threads and
+// child processes started later inherit it.
+func SetNice(level int) error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return fmt.Errorf("error setting nice level: %w", err)
+	}
+	for _, t := range tasks {
+		tid, err := strconv.Atoi(t.Name())
+		if err != nil {
+			continue
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, level); err != nil {
+			return fmt.Errorf("error setting nice level %d: %w", level, err)
+		}
+	}
+	return nil
+}
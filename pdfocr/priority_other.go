@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package pdfocr
+
+import "fmt"
+
+// SetNice is not supported on this platform.
+func SetNice(level int) error {
+	return fmt.Errorf("-nice is not supported on this platform")
+}
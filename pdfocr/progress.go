@@ -0,0 +1,118 @@
+package pdfocr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Stages of a ProgressEvent.
+const (
+	StageStart  = "start"  // the document is open and the pages to process are known
+	StageNative = "native" // the page's text layer is used
+	StageRender = "render" // the page image is being rendered for OCR
+	StageOCR    = "ocr"    // the page image is being recognized
+	StageDone   = "done"   // the page is finished, with Method set
+)
+
+// Progress output modes of the command line.
+const (
+	ProgressText = "text"
+	ProgressJSON = "json"
+)
+
+// ProgressEvent reports a step of the extraction of a document. Embedded
+// documents report their own events, under their names.
+type ProgressEvent struct {
+	Document string
+	Stage    string
+	Page     int           // 1-based page number, 0 for StageStart
+	Index    int           // 1-based position of the page among the pages processed, 0 for StageStart
+	Pages    int           // number of pages processed
+	Method   string        // with StageDone
+	Elapsed  time.Duration // since the document was opened
+}
+
+// ProgressFunc receives the progress of an extraction. The events of a
+// document are delivered one at a time, even with several workers, but
+// documents processed concurrently call it concurrently.
+type ProgressFunc func(ProgressEvent)
+
+// progressReporter delivers the progress events of an open document to
+// config.Progress.
+type progressReporter struct {
+	fn       ProgressFunc
+	document string
+	pages    int
+	index    map[int]int // zero-based page number to 1-based position
+	start    time.Time
+	mu       sync.Mutex
+}
+
+func newProgressReporter(fn ProgressFunc, document string, pageNums []int) *progressReporter {
+	p := &progressReporter{fn: fn, document: document, pages: len(pageNums), index: map[int]int{}, start: time.Now()}
+	for i, n := range pageNums {
+		p.index[n] = i + 1
+	}
+	return p
+}
+
+// report delivers an event for the zero-based page pageNum, or for the
+// document with -1. A nil reporter or one without a ProgressFunc does
+// nothing.
+func (p *progressReporter) report(stage string, pageNum int, method string) {
+	if p == nil || p.fn == nil {
+		return
+	}
+	e := ProgressEvent{Document: p.document, Stage: stage, Pages: p.pages, Method: method, Elapsed: time.Since(p.start)}
+	if pageNum >= 0 {
+		e.Page, e.Index = pageNum+1, p.index[pageNum]
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fn(e)
+}
+
+// progressLine is the JSON form of a ProgressEvent written by -progress json.
+type progressLine struct {
+	SchemaVersion string `json:"schema_version"`
+	Document      string `json:"document"`
+	Stage         string `json:"stage"`
+	Page          int    `json:"page,omitempty"`
+	Index         int    `json:"index,omitempty"`
+	Pages         int    `json:"pages"`
+	Method        string `json:"method,omitempty"`
+	ElapsedMS     int64  `json:"elapsed_ms"`
+}
+
+// JSONProgress returns a ProgressFunc writing every event to w as a line of
+// JSON, safe for documents processed concurrently.
+func JSONProgress(w io.Writer) ProgressFunc {
+	var mu sync.Mutex
+	return func(e ProgressEvent) {
+		line, _ := json.Marshal(progressLine{
+			SchemaVersion: SchemaVersion,
+			Document:      e.Document,
+			Stage:         e.Stage,
+			Page:          e.Page,
+			Index:         e.Index,
+			Pages:         e.Pages,
+			Method:        e.Method,
+			ElapsedMS:     e.Elapsed.Milliseconds(),
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(line, '\n'))
+	}
+}
+
+// ValidateProgress checks a -progress mode.
+func ValidateProgress(mode string) error {
+	switch mode {
+	case "", ProgressText, ProgressJSON:
+		return nil
+	}
+	return fmt.Errorf("unsupported progress mode %q (use text or json)", mode)
+}
@@ -0,0 +1,247 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Units of an IgnoreRegion box.
+const (
+	RegionRelative = "relative" // fractions of the page width and height
+	RegionPoints   = "pt"       // PDF points (1/72 inch)
+)
+
+// IgnoreRegion is a rectangle excluded from OCR and text output, measured
+// from the top-left corner of the page.
+type IgnoreRegion struct {
+	Box   [4]float64 `json:"box"`             // x0, y0, x1, y1
+	Unit  string     `json:"unit,omitempty"`  // RegionRelative or RegionPoints; inferred if empty
+	Pages string     `json:"pages,omitempty"` // "all" (default), "first", "last", "odd", "even" or a list like "1,3-5"
+}
+
+// regionRect is a rectangle in points or pixels.
+type regionRect struct{ X0, Y0, X1, Y1 float64 }
+
+func (r regionRect) contains(x, y float64) bool {
+	return x >= r.X0 && x < r.X1 && y >= r.Y0 && y < r.Y1
+}
+
+// normalizeRegion validates a region and fills in its unit: boxes whose
+// coordinates are all between 0 and 1 are relative, others are in points.
+func normalizeRegion(r IgnoreRegion) (IgnoreRegion, error) {
+	b := r.Box
+	if b[2] <= b[0] || b[3] <= b[1] {
+		return r, fmt.Errorf("invalid ignore region %v: x1,y1 must be greater than x0,y0", b)
+	}
+	if r.Unit == "" {
+		r.Unit = RegionPoints
+		if b[0] >= 0 && b[1] >= 0 && b[2] <= 1 && b[3] <= 1 {
+			r.Unit = RegionRelative
+		}
+	}
+	if r.Unit != RegionRelative && r.Unit != RegionPoints {
+		return r, fmt.Errorf("invalid ignore region unit %q (use relative or pt)", r.Unit)
+	}
+	if _, err := pageSelected(r.Pages, 0, 1); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// ParseIgnoreRegion parses a region given as "[pages:]x0,y0,x1,y1[pt]".
+func ParseIgnoreRegion(spec string) (IgnoreRegion, error) {
+	var r IgnoreRegion
+	if pages, rest, ok := strings.Cut(spec, ":"); ok {
+		r.Pages, spec = pages, rest
+	}
+	if strings.HasSuffix(spec, "pt") {
+		r.Unit, spec = RegionPoints, strings.TrimSuffix(spec, "pt")
+	}
+	parts := strings.Split(spec, ",")
+	if len(parts) != 4 {
+		return r, fmt.Errorf("invalid ignore region %q (use [pages:]x0,y0,x1,y1[pt])", spec)
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return r, fmt.Errorf("invalid ignore region coordinate %q", p)
+		}
+		r.Box[i] = v
+	}
+	return normalizeRegion(r)
+}
+
+// LoadIgnoreTemplate reads a JSON file mapping template names (e.g. document
+// types) to region lists and returns the regions of template name. An empty
+// name is accepted when the file holds a single template.
+func LoadIgnoreTemplate(path, name string) ([]IgnoreRegion, error) {
+	templates, err := readRegionTemplates(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(templates))
+	for n := range templates {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if name == "" && len(names) == 1 {
+		name = names[0]
+	}
+	t, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("ignore template %q not found in %s (available: %s)", name, path, strings.Join(names, ", "))
+	}
+	regions := t.Regions
+	for i, r := range regions {
+		if regions[i], err = normalizeRegion(r); err != nil {
+			return nil, err
+		}
+	}
+	return regions, nil
+}
+
+// pageSelected reports whether a zero-based page matches a page selector.
+func pageSelected(sel string, pageNum, numPages int) (bool, error) {
+	page := pageNum + 1
+	switch strings.ToLower(strings.TrimSpace(sel)) {
+	case "", "all":
+		return true, nil
+	case "first":
+		return page == 1, nil
+	case "last":
+		return page == numPages, nil
+	case "odd":
+		return page%2 == 1, nil
+	case "even":
+		return page%2 == 0, nil
+	}
+	set, err := ParsePageSet(sel)
+	if err != nil {
+		return false, fmt.Errorf("invalid page selector %q", sel)
+	}
+	return set.Contains(page), nil
+}
+
+// pageIgnoreRegions returns the regions that apply to a page: the fixed
+// ones and those of the template the page matches.
+func (src *pdfSource) pageIgnoreRegions(config OCRConfig, pageNum int) []IgnoreRegion {
+	regions := config.IgnoreRegions
+	if t := src.pageTemplate(config, pageNum); t != nil {
+		regions = append(regions[:len(regions):len(regions)], t.Regions...)
+	}
+	numPages := src.doc.NumPage()
+	var out []IgnoreRegion
+	for _, r := range regions {
+		if ok, _ := pageSelected(r.Pages, pageNum, numPages); ok {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// rect converts a region to coordinates on a surface of width by height
+// units, where one point is scale units.
+func (r IgnoreRegion) rect(width, height, scale float64) regionRect {
+	b := r.Box
+	if r.Unit == RegionRelative {
+		return regionRect{b[0] * width, b[1] * height, b[2] * width, b[3] * height}
+	}
+	return regionRect{b[0] * scale, b[1] * scale, b[2] * scale, b[3] * scale}
+}
+
+// maskRegions paints the regions white on a copy of a page image rendered
+// at dpi, so OCR does not see them.
+func maskRegions(img image.Image, regions []IgnoreRegion, dpi float64) image.Image {
+	if len(regions) == 0 {
+		return img
+	}
+	b := img.Bounds()
+	out := image.NewRGBA(b)
+	draw.Draw(out, b, img, b.Min, draw.Src)
+	for _, r := range regions {
+		rr := r.rect(float64(b.Dx()), float64(b.Dy()), dpi/72)
+		px := image.Rect(int(rr.X0), int(rr.Y0), int(rr.X1+0.5), int(rr.Y1+0.5)).Add(b.Min)
+		draw.Draw(out, px.Intersect(b), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	return out
+}
+
+// maskIgnored applies the page's ignore regions to an image rendered at dpi
+// (0 means defaultRenderDPI).
+func (src *pdfSource) maskIgnored(img image.Image, pageNum int, config OCRConfig, dpi float64) image.Image {
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	return maskRegions(img, src.pageIgnoreRegions(config, pageNum), dpi)
+}
+
+// ignoredText returns the text layer of a page without the lines in its
+// ignore regions. Backends that cannot position lines keep the whole text.
+func (src *pdfSource) ignoredText(pageNum int, config OCRConfig, text string) string {
+	regions := src.pageIgnoreRegions(config, pageNum)
+	if len(regions) == 0 || strings.TrimSpace(text) == "" {
+		return text
+	}
+	ld, ok := src.doc.(layoutDocument)
+	if !ok {
+		src.warnings.warnf(pageNum, "Warning: renderer cannot locate text lines, ignore regions only apply to OCR on page %d\n", pageNum+1)
+		return text
+	}
+	layout, err := ld.TextLayout(pageNum)
+	if err != nil {
+		src.warnings.warnf(pageNum, "Warning: could not locate text lines on page %d, keeping them all: %v\n", pageNum+1, err)
+		return text
+	}
+	return filterTextLines(layout, regions)
+}
+
+// textLayout returns the positioned text layer of a page without the lines
+// in its ignore regions, or false if the renderer cannot locate lines.
+func (src *pdfSource) textLayout(pageNum int, config OCRConfig) (PageLayout, bool) {
+	ld, ok := src.doc.(layoutDocument)
+	if !ok {
+		return PageLayout{}, false
+	}
+	layout, err := ld.TextLayout(pageNum)
+	if err != nil {
+		src.warnings.warnf(pageNum, "Warning: could not locate text lines on page %d: %v\n", pageNum+1, err)
+		return PageLayout{}, false
+	}
+	layout.Lines = keptTextLines(layout, src.pageIgnoreRegions(config, pageNum))
+	return layout, true
+}
+
+// filterTextLines joins the lines of a layout whose center is outside all
+// of the regions.
+func filterTextLines(layout PageLayout, regions []IgnoreRegion) string {
+	var kept []string
+	for _, l := range keptTextLines(layout, regions) {
+		kept = append(kept, l.Text)
+	}
+	return strings.Join(kept, "\n")
+}
+
+// keptTextLines returns the lines of a layout whose center is outside all of
+// the regions.
+func keptTextLines(layout PageLayout, regions []IgnoreRegion) []TextLine {
+	var kept []TextLine
+	for _, l := range layout.Lines {
+		cx, cy := (l.X0+l.X1)/2, (l.Y0+l.Y1)/2
+		ignored := false
+		for _, r := range regions {
+			if r.rect(layout.Width, layout.Height, 1).contains(cx, cy) {
+				ignored = true
+				break
+			}
+		}
+		if !ignored {
+			kept = append(kept, l)
+		}
+	}
+	return kept
+}
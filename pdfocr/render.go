@@ -0,0 +1,178 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+)
+
+// defaultRenderDPI matches the resolution go-fitz uses for Document.Image.
+const defaultRenderDPI = 300
+
+// thumbnailDPI renders page thumbnails, about 200 pixels wide for a
+// letter-size page.
+const thumbnailDPI = 24
+
+// fallbackRenderDPI is used for the last-resort retry, which helps when a
+// render fails because a huge decoded image does not fit in memory.
+const fallbackRenderDPI = 150
+
+// pdfSource bundles an open document with its raw bytes and structure, which
+// the fallback render paths need.
+type pdfSource struct {
+	name      string
+	doc       RenderDocument
+	data      []byte
+	raw       *rawPDF // nil if the structure could not be read
+	mediaType string  // of image inputs, "" for PDFs
+
+	templates map[int]*RegionTemplate // matched template by page, see pageTemplate
+	noScratch bool                    // no plaintext temporary files, which rules out the pdftoppm fallback
+	warnings  *warningLog             // of the extraction, nil logging only
+}
+
+// openPDFSource opens an in-memory PDF with the named renderer. The raw
+// structure is parsed on a best-effort basis; a nil raw field only disables
+// the structure-based features. PNG, JPEG and TIFF images, told apart by
+// their magic bytes, are opened as documents without a text layer instead.
+func openPDFSource(data []byte, name, renderer string) (*pdfSource, error) {
+	if mediaType := inputMediaType(data); mediaType != "" {
+		doc, err := openImageDocument(data, mediaType)
+		if err != nil {
+			return nil, fmt.Errorf("error opening image: %w", err)
+		}
+		return &pdfSource{name: name, doc: doc, data: data, mediaType: mediaType}, nil
+	}
+	r, err := lookupRenderer(renderer)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := r.Open(data)
+	if err != nil {
+		return nil, fmt.Errorf("error opening PDF: %w", err)
+	}
+	src := &pdfSource{name: name, doc: doc, data: data}
+	if raw, err := parseRawPDF(data); err == nil {
+		src.raw = raw
+	}
+	return src, nil
+}
+
+// Close releases the underlying document.
+func (src *pdfSource) Close() error {
+	return src.doc.Close()
+}
+
+// hasFragileImages reports whether a page draws JBIG2 or CCITT images, the
+// codecs most often hit by decoder failures on real-world scans.
+func (src *pdfSource) hasFragileImages(pageNum int) bool {
+	if src.raw == nil {
+		return false
+	}
+	for _, obj := range src.raw.pageImages(pageNum) {
+		dict, _ := obj.Value.(pdfDict)
+		for _, f := range src.raw.streamFilters(dict) {
+			switch f {
+			case "JBIG2Decode", "CCITTFaxDecode", "CCF":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// renderPage renders a page at dpi (0 means defaultRenderDPI). When MuPDF
+// fails, the page is re-rendered with poppler's pdftoppm if it is installed,
+// then with MuPDF at a lower resolution. With robust set, a blank render of a
+// page that holds JBIG2/CCITT images is treated as a failure too, because
+// MuPDF draws nothing for image streams it cannot decode.
+func (src *pdfSource) renderPage(pageNum int, dpi float64, robust bool) (image.Image, error) {
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+
+	img, err := src.doc.ImageDPI(pageNum, dpi)
+	if err == nil && !(robust && isBlankImage(img) && src.hasFragileImages(pageNum)) {
+		return img, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("page rendered blank although it contains JBIG2/CCITT images")
+	}
+	src.warnings.warnf(pageNum, "Warning: page %d of %s: %v, trying fallback renderers\n", pageNum+1, src.name, err)
+
+	if src.noScratch {
+		src.warnings.warnf(pageNum, "Warning: pdftoppm fallback skipped for page %d, it needs the PDF in a temporary file\n", pageNum+1)
+	} else if img, perr := renderWithPdftoppm(src.data, pageNum, dpi); perr == nil {
+		return img, nil
+	} else {
+		src.warnings.warnf(pageNum, "Warning: pdftoppm fallback failed for page %d: %v\n", pageNum+1, perr)
+	}
+
+	if dpi > fallbackRenderDPI {
+		if img, lerr := src.doc.ImageDPI(pageNum, fallbackRenderDPI); lerr == nil {
+			return img, nil
+		}
+	}
+	return nil, err
+}
+
+// thumbnail returns a PNG of a page at thumbnailDPI, or nil when the page
+// cannot be rendered: the fallback renderers are left to the page's OCR,
+// which reports the failure.
+func (src *pdfSource) thumbnail(pageNum int) []byte {
+	img, err := src.doc.ImageDPI(pageNum, thumbnailDPI)
+	if err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// renderWithPdftoppm renders one page of an in-memory PDF with poppler,
+// which has independent JBIG2 and CCITT decoders.
+func renderWithPdftoppm(data []byte, pageNum int, dpi float64) (image.Image, error) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		return nil, fmt.Errorf("pdftoppm not found in PATH")
+	}
+	f, err := os.CreateTemp("", scratchPattern("fallback", pageNum, ".pdf"))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return pdftoppmPage(f.Name(), pageNum, dpi)
+}
+
+// isBlankImage reports whether an image is (nearly) uniformly white, judged
+// on a sample grid of pixels.
+func isBlankImage(img image.Image) bool {
+	b := img.Bounds()
+	if b.Empty() {
+		return true
+	}
+	const grid = 200
+	stepX, stepY := max(b.Dx()/grid, 1), max(b.Dy()/grid, 1)
+	samples, dark := 0, 0
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			if (r+g+bl)/3 < 0xE000 {
+				dark++
+			}
+			samples++
+		}
+	}
+	return dark*1000 < samples
+}
@@ -0,0 +1,93 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// Renderer is a PDF rendering backend. The pipeline only talks to documents
+// through this interface, so a backend can be swapped (for licensing reasons
+// or to work around rendering bugs) without changing callers.
+type Renderer interface {
+	// Name identifies the backend on the command line, e.g. "mupdf".
+	Name() string
+	// Version describes the backend library or tool version.
+	Version() string
+	// Open loads a document from memory.
+	Open(data []byte) (RenderDocument, error)
+}
+
+// RenderDocument is a document opened by a Renderer. Page numbers are
+// zero-based.
+type RenderDocument interface {
+	NumPage() int
+	Text(pageNumber int) (string, error)
+	ImageDPI(pageNumber int, dpi float64) (image.Image, error)
+	Close() error
+}
+
+// svgDocument is implemented by documents that can export pages as SVG,
+// which vector-page detection relies on.
+type svgDocument interface {
+	SVG(pageNumber int) (string, error)
+}
+
+// TextLine is one line of a page's text layer, with its bounding box in
+// points from the top-left corner of the page.
+type TextLine struct {
+	Text           string
+	X0, Y0, X1, Y1 float64
+}
+
+// PageLayout is the positioned text layer of a page.
+type PageLayout struct {
+	Width, Height float64 // page size in points
+	Lines         []TextLine
+}
+
+// layoutDocument is implemented by documents that can report where text
+// lines sit on the page, which ignore regions rely on.
+type layoutDocument interface {
+	TextLayout(pageNumber int) (PageLayout, error)
+}
+
+var renderers = map[string]Renderer{}
+
+// RegisterRenderer makes a rendering backend selectable by name.
+func RegisterRenderer(r Renderer) {
+	renderers[r.Name()] = r
+}
+
+// lookupRenderer returns the backend registered under name ("" selects
+// DefaultRenderer, which depends on the build profile).
+func lookupRenderer(name string) (Renderer, error) {
+	if name == "" {
+		name = DefaultRenderer
+	}
+	r, ok := renderers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown renderer %q (available: %s)", name, strings.Join(rendererNames(), ", "))
+	}
+	return r, nil
+}
+
+// rendererNames lists the registered backends in alphabetical order.
+func rendererNames() []string {
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Renderers returns the registered backends in alphabetical order.
+func Renderers() []Renderer {
+	var list []Renderer
+	for _, name := range rendererNames() {
+		list = append(list, renderers[name])
+	}
+	return list
+}
@@ -0,0 +1,85 @@
+//go:build !nomupdf
+
+package pdfocr
+
+import (
+	"html"
+	"regexp"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+// DefaultRenderer is the backend used when OCRConfig.Renderer is empty.
+const DefaultRenderer = "mupdf"
+
+// fitzRenderer renders with MuPDF through go-fitz.
+type fitzRenderer struct{}
+
+func init() {
+	RegisterRenderer(fitzRenderer{})
+}
+
+func (fitzRenderer) Name() string { return "mupdf" }
+
+// Version reports the go-fitz module version, whose major and minor numbers
+// follow the bundled MuPDF release.
+func (fitzRenderer) Version() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/gen2brain/go-fitz" {
+				return "go-fitz " + dep.Version
+			}
+		}
+	}
+	return "go-fitz (unknown version)"
+}
+
+func (fitzRenderer) Open(data []byte) (RenderDocument, error) {
+	doc, err := fitz.NewFromMemory(data)
+	if err != nil {
+		return nil, err
+	}
+	return fitzDocument{doc}, nil
+}
+
+// fitzDocument adds text layout to a go-fitz document.
+type fitzDocument struct {
+	*fitz.Document
+}
+
+var (
+	fitzPageSizeRe = regexp.MustCompile(`<div id="page\d+" style="width:([\d.]+)pt;height:([\d.]+)pt">`)
+	fitzLineRe     = regexp.MustCompile(`(?s)<p style="top:([\d.]+)pt;left:([\d.]+)pt;line-height:([\d.]+)pt">(.*?)</p>`)
+	fitzTagRe      = regexp.MustCompile(`<[^>]*>`)
+)
+
+// TextLayout reads line positions from MuPDF's HTML output, which places
+// each line absolutely. The HTML carries no line widths, so the right edge
+// is estimated from the line's character count and height.
+func (d fitzDocument) TextLayout(pageNumber int) (PageLayout, error) {
+	out, err := d.HTML(pageNumber, false)
+	if err != nil {
+		return PageLayout{}, err
+	}
+	var layout PageLayout
+	if m := fitzPageSizeRe.FindStringSubmatch(out); m != nil {
+		layout.Width, _ = strconv.ParseFloat(m[1], 64)
+		layout.Height, _ = strconv.ParseFloat(m[2], 64)
+	}
+	for _, m := range fitzLineRe.FindAllStringSubmatch(out, -1) {
+		text := strings.TrimSpace(html.UnescapeString(fitzTagRe.ReplaceAllString(m[4], "")))
+		if text == "" {
+			continue
+		}
+		top, _ := strconv.ParseFloat(m[1], 64)
+		left, _ := strconv.ParseFloat(m[2], 64)
+		height, _ := strconv.ParseFloat(m[3], 64)
+		width := float64(utf8.RuneCountInString(text)) * height * 0.5
+		layout.Lines = append(layout.Lines, TextLine{Text: text, X0: left, Y0: top, X1: left + width, Y1: top + height})
+	}
+	return layout, nil
+}
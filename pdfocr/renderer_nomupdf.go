@@ -0,0 +1,8 @@
+//go:build nomupdf
+
+package pdfocr
+
+// DefaultRenderer is the backend used when OCRConfig.Renderer is empty. Builds
+// tagged nomupdf leave MuPDF (AGPL) out of the binary and render through the
+// poppler command-line tools instead.
+const DefaultRenderer = "poppler"
@@ -0,0 +1,162 @@
+package pdfocr
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// popplerRenderer renders by running the poppler command-line tools
+// (pdfinfo, pdftotext, pdftoppm) as subprocesses. Nothing from poppler is
+// linked into the binary, which makes it usable in builds that must not
+// contain AGPL code.
+type popplerRenderer struct{}
+
+func init() {
+	RegisterRenderer(popplerRenderer{})
+}
+
+func (popplerRenderer) Name() string { return "poppler" }
+
+func (popplerRenderer) Version() string {
+	// pdftoppm -v prints its version banner on stderr.
+	out, err := exec.Command("pdftoppm", "-v").CombinedOutput()
+	if err != nil && len(out) == 0 {
+		return "poppler (pdftoppm not found)"
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return strings.TrimSpace(line)
+}
+
+var pdfinfoPagesRe = regexp.MustCompile(`(?m)^Pages:\s+(\d+)`)
+
+// Open writes the document to a private temporary file, since the poppler
+// tools only read from files.
+func (popplerRenderer) Open(data []byte) (RenderDocument, error) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		return nil, fmt.Errorf("poppler renderer: pdftoppm not found in PATH")
+	}
+	dir, err := os.MkdirTemp("", scratchPattern("poppler", -1, ""))
+	if err != nil {
+		return nil, err
+	}
+	doc := &popplerDocument{dir: dir, path: filepath.Join(dir, "input.pdf")}
+	if err := os.WriteFile(doc.path, data, 0600); err != nil {
+		doc.Close()
+		return nil, err
+	}
+
+	out, err := exec.Command("pdfinfo", doc.path).Output()
+	if err != nil {
+		doc.Close()
+		return nil, fmt.Errorf("poppler renderer: pdfinfo failed: %w", err)
+	}
+	m := pdfinfoPagesRe.FindSubmatch(out)
+	if m == nil {
+		doc.Close()
+		return nil, fmt.Errorf("poppler renderer: page count not found in pdfinfo output")
+	}
+	doc.pages, _ = strconv.Atoi(string(m[1]))
+	return doc, nil
+}
+
+// popplerDocument is a document opened by popplerRenderer.
+type popplerDocument struct {
+	dir, path string
+	pages     int
+}
+
+func (d *popplerDocument) NumPage() int { return d.pages }
+
+func (d *popplerDocument) Text(pageNumber int) (string, error) {
+	page := strconv.Itoa(pageNumber + 1)
+	var stderr bytes.Buffer
+	cmd := exec.Command("pdftotext", "-f", page, "-l", page, "-enc", "UTF-8", d.path, "-")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("pdftotext failed: %v: %s", err, stderr.Bytes())
+	}
+	return string(out), nil
+}
+
+var (
+	bboxPageRe = regexp.MustCompile(`<page width="([\d.]+)" height="([\d.]+)">`)
+	bboxLineRe = regexp.MustCompile(`(?s)<line xMin="([\d.]+)" yMin="([\d.]+)" xMax="([\d.]+)" yMax="([\d.]+)">(.*?)</line>`)
+	bboxWordRe = regexp.MustCompile(`<word[^>]*>(.*?)</word>`)
+)
+
+// TextLayout reads line positions from pdftotext -bbox-layout.
+func (d *popplerDocument) TextLayout(pageNumber int) (PageLayout, error) {
+	page := strconv.Itoa(pageNumber + 1)
+	var stderr bytes.Buffer
+	cmd := exec.Command("pdftotext", "-f", page, "-l", page, "-bbox-layout", d.path, "-")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return PageLayout{}, fmt.Errorf("pdftotext failed: %v: %s", err, stderr.Bytes())
+	}
+	var layout PageLayout
+	if m := bboxPageRe.FindSubmatch(out); m != nil {
+		layout.Width, _ = strconv.ParseFloat(string(m[1]), 64)
+		layout.Height, _ = strconv.ParseFloat(string(m[2]), 64)
+	}
+	for _, m := range bboxLineRe.FindAllSubmatch(out, -1) {
+		var words []string
+		for _, w := range bboxWordRe.FindAllSubmatch(m[5], -1) {
+			words = append(words, html.UnescapeString(string(w[1])))
+		}
+		line := TextLine{Text: strings.Join(words, " ")}
+		line.X0, _ = strconv.ParseFloat(string(m[1]), 64)
+		line.Y0, _ = strconv.ParseFloat(string(m[2]), 64)
+		line.X1, _ = strconv.ParseFloat(string(m[3]), 64)
+		line.Y1, _ = strconv.ParseFloat(string(m[4]), 64)
+		layout.Lines = append(layout.Lines, line)
+	}
+	return layout, nil
+}
+
+func (d *popplerDocument) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	return pdftoppmPage(d.path, pageNumber, dpi)
+}
+
+func (d *popplerDocument) Close() error {
+	return os.RemoveAll(d.dir)
+}
+
+// pdftoppmPage renders one page of a PDF file with poppler's pdftoppm.
+func pdftoppmPage(path string, pageNum int, dpi float64) (image.Image, error) {
+	bin, err := exec.LookPath("pdftoppm")
+	if err != nil {
+		return nil, fmt.Errorf("pdftoppm not found in PATH")
+	}
+
+	dir, err := os.MkdirTemp("", scratchPattern("render", pageNum, ""))
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	page := strconv.Itoa(pageNum + 1)
+	prefix := filepath.Join(dir, "page")
+	cmd := exec.Command(bin, "-f", page, "-l", page, "-r", strconv.FormatFloat(dpi, 'f', 0, 64),
+		"-png", "-singlefile", path, prefix)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%v: %s", err, out)
+	}
+
+	f, err := os.Open(prefix + ".png")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
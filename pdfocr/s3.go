@@ -0,0 +1,183 @@
+//go:build !nocloud
+
+package pdfocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3PartSize is the size of the parts of multipart uploads to S3; smaller
+// results are uploaded in one request.
+const s3PartSize = 8 << 20
+
+// checkS3 reports missing credentials for S3 delivery.
+func checkS3() error {
+	var missing []string
+	for _, names := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_REGION|AWS_DEFAULT_REGION"} {
+		if cloudEnv(names) == "" {
+			missing = append(missing, strings.ReplaceAll(names, "|", " or "))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("delivery to S3 needs %s set in the environment", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// s3ObjectURL returns the URL of an object: virtual-hosted style on AWS,
+// path style for bucket names with dots and on the S3-compatible service
+// of AWS_ENDPOINT_URL_S3.
+func s3ObjectURL(bucket, key string) *url.URL {
+	u := &url.URL{Scheme: "https", Host: bucket + ".s3." + cloudEnv("AWS_REGION|AWS_DEFAULT_REGION") + ".amazonaws.com", Path: "/" + key}
+	if endpoint, err := url.Parse(cloudEnv("AWS_ENDPOINT_URL_S3")); err == nil && endpoint.Host != "" {
+		u.Scheme, u.Host, u.Path = endpoint.Scheme, endpoint.Host, "/"+bucket+"/"+key
+	} else if strings.Contains(bucket, ".") {
+		u.Host, u.Path = "s3."+cloudEnv("AWS_REGION|AWS_DEFAULT_REGION")+".amazonaws.com", "/"+bucket+"/"+key
+	}
+	u.RawPath = awsEscape(u.Path, false)
+	return u
+}
+
+// awsEscape percent-encodes s as Signature Version 4 expects: everything
+// but unreserved characters, and slashes unless query is set.
+func awsEscape(s string, query bool) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 || c == '/' && !query {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// awsQuery encodes query parameters in the sorted form of canonical
+// requests.
+func awsQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range values[k] {
+			parts = append(parts, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// s3Do sends a signed request for an object and returns the response body
+// and header.
+func s3Do(ctx context.Context, method string, u *url.URL, query url.Values, body []byte, header http.Header) ([]byte, http.Header, error) {
+	target := *u
+	target.RawQuery = awsQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("X-Amz-Content-Sha256", sha256Hex(body))
+	signAWSRequest(req, body, "s3", cloudEnv("AWS_REGION|AWS_DEFAULT_REGION"), time.Now())
+	data, respHeader, err := cloudDo(req)
+	if err == nil && bytes.Contains(data, []byte("<Error>")) {
+		// CompleteMultipartUpload reports late failures with status 200
+		err = fmt.Errorf("S3 error: %s", strings.TrimSpace(string(data)))
+	}
+	return data, respHeader, err
+}
+
+// uploadS3 writes data to an object, in parts of s3PartSize for larger
+// results; a failed multipart upload is aborted so its parts are not kept.
+func uploadS3(ctx context.Context, bucket, key string, data []byte, contentType string) (err error) {
+	u := s3ObjectURL(bucket, key)
+	header := http.Header{"Content-Type": {contentType}}
+	if len(data) <= s3PartSize {
+		if _, _, err := s3Do(ctx, http.MethodPut, u, nil, data, header); err != nil {
+			return fmt.Errorf("error uploading to s3://%s/%s: %w", bucket, key, err)
+		}
+		return nil
+	}
+
+	resp, _, err := s3Do(ctx, http.MethodPost, u, url.Values{"uploads": {""}}, nil, header)
+	if err != nil {
+		return fmt.Errorf("error starting upload to s3://%s/%s: %w", bucket, key, err)
+	}
+	var started struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(resp, &started); err != nil || started.UploadID == "" {
+		return fmt.Errorf("error starting upload to s3://%s/%s: unexpected response", bucket, key)
+	}
+	defer func() {
+		if err != nil {
+			// Best effort, with a fresh context as ctx may be what failed
+			s3Do(context.Background(), http.MethodDelete, u, url.Values{"uploadId": {started.UploadID}}, nil, nil)
+		}
+	}()
+
+	type part struct {
+		PartNumber int
+		ETag       string
+	}
+	var complete struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []part   `xml:"Part"`
+	}
+	for n, offset := 1, 0; offset < len(data); n, offset = n+1, offset+s3PartSize {
+		chunk := data[offset:min(offset+s3PartSize, len(data))]
+		query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {started.UploadID}}
+		_, h, err := s3Do(ctx, http.MethodPut, u, query, chunk, nil)
+		if err != nil {
+			return fmt.Errorf("error uploading part %d to s3://%s/%s: %w", n, bucket, key, err)
+		}
+		complete.Parts = append(complete.Parts, part{n, h.Get("ETag")})
+	}
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	if _, _, err := s3Do(ctx, http.MethodPost, u, url.Values{"uploadId": {started.UploadID}}, body, http.Header{"Content-Type": {"application/xml"}}); err != nil {
+		return fmt.Errorf("error completing upload to s3://%s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// presignS3 returns a URL that downloads an object without credentials
+// until expires from now.
+func presignS3(bucket, key string, expires time.Duration, now time.Time) string {
+	region := cloudEnv("AWS_REGION|AWS_DEFAULT_REGION")
+	u := s3ObjectURL(bucket, key)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + region + "/s3/aws4_request"
+	query := url.Values{
+		"X-Amz-Algorithm":     {"AWS4-HMAC-SHA256"},
+		"X-Amz-Credential":    {cloudEnv("AWS_ACCESS_KEY_ID") + "/" + scope},
+		"X-Amz-Date":          {amzDate},
+		"X-Amz-Expires":       {strconv.Itoa(int(expires.Seconds()))},
+		"X-Amz-SignedHeaders": {"host"},
+	}
+	if token := cloudEnv("AWS_SESSION_TOKEN"); token != "" {
+		query.Set("X-Amz-Security-Token", token)
+	}
+	canonical := strings.Join([]string{http.MethodGet, u.EscapedPath(), awsQuery(query), "host:" + u.Host + "\n", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+	query.Set("X-Amz-Signature", hex.EncodeToString(hmacSHA256(awsSigningKey(date, region, "s3"), toSign)))
+	u.RawQuery = awsQuery(query)
+	return u.String()
+}
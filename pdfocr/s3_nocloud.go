@@ -0,0 +1,20 @@
+//go:build nocloud
+
+package pdfocr
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Builds tagged nocloud send no data to cloud services, S3 included.
+var errNoS3 = errors.New("delivery to S3 is not available in builds tagged nocloud")
+
+func checkS3() error { return errNoS3 }
+
+func uploadS3(ctx context.Context, bucket, key string, data []byte, contentType string) error {
+	return errNoS3
+}
+
+func presignS3(bucket, key string, expires time.Duration, now time.Time) string { return "" }
@@ -0,0 +1,98 @@
+//go:build ignore
+
+// generate renders the text of the embedded demo sample and wraps the
+// resulting bitmap in an image-only PDF, so the sample behaves like a scan
+// with no text layer. Run it with "go generate" from the repository root
+// after changing sampleLines (keep them in sync with demoSampleLines).
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gen2brain/go-fitz"
+)
+
+var sampleLines = []string{
+	"SAMPLE SCANNED DOCUMENT",
+	"This page is an image without a text layer.",
+	"If this sentence appears in the output,",
+	"OCR is working on your machine.",
+	"Invoice 2024-0417 Total 1250.00",
+}
+
+const (
+	pageWidth, pageHeight = 612, 396 // points, half-letter landscape
+	scanDPI               = 200
+)
+
+// writePDF serializes objects as a PDF with a classic cross-reference table.
+func writePDF(objects []string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func main() {
+	var content strings.Builder
+	content.WriteString("BT /F1 20 Tf 28 TL 48 330 Td\n")
+	for _, line := range sampleLines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", line)
+	}
+	content.WriteString("ET\n")
+	textPDF := writePDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	})
+
+	doc, err := fitz.NewFromMemory(textPDF)
+	if err != nil {
+		log.Fatal(err)
+	}
+	img, err := doc.ImageDPI(0, scanDPI)
+	doc.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	gray := image.NewGray(img.Bounds())
+	draw.Draw(gray, gray.Bounds(), img, img.Bounds().Min, draw.Src)
+
+	var scan bytes.Buffer
+	if err := jpeg.Encode(&scan, gray, &jpeg.Options{Quality: 70}); err != nil {
+		log.Fatal(err)
+	}
+
+	b := gray.Bounds()
+	paint := fmt.Sprintf("q %d 0 0 %d 0 0 cm /Im1 Do Q\n", pageWidth, pageHeight)
+	scanPDF := writePDF([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /XObject << /Im1 4 0 R >> >> /Contents 5 0 R >>", pageWidth, pageHeight),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream",
+			b.Dx(), b.Dy(), scan.Len(), scan.Bytes()),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(paint), paint),
+	})
+	if err := os.WriteFile("samples/scanned.pdf", scanPDF, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,510 @@
+package pdfocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SandboxWorkerCommand is the subcommand of pdf-ocr-tool that runs a
+// sandbox worker (see RunSandboxWorker).
+const SandboxWorkerCommand = "sandbox-worker"
+
+// SandboxOptions configures the worker processes of a Sandbox.
+type SandboxOptions struct {
+	Workers  int           // worker processes, started when first needed (0 for one)
+	MemoryMB int           // address space limit of every worker (0 for none)
+	Timeout  time.Duration // longest extraction; its worker is then killed (0 for none)
+	Network  bool          // let workers open network connections, which cloud OCR engines need
+
+	// Command line of a worker, to which the options above are added (nil
+	// runs this executable with SandboxWorkerCommand); programs other than
+	// pdf-ocr-tool call RunSandboxWorker for it
+	Command []string
+	Args    []string  // further worker arguments, e.g. -ui-lang or -log-format
+	Stderr  io.Writer // where the messages of workers go (nil for the standard error)
+}
+
+// Sandbox renders and recognizes documents in child processes, so a
+// document that crashes MuPDF or Tesseract, or exhausts the memory, fails
+// alone: its worker is replaced by a new one for the next document. On
+// Linux workers run under resource limits and a seccomp filter denying
+// networking, debugging and system administration calls. Workers are
+// reused across documents, keeping their loaded traineddata.
+type Sandbox struct {
+	opts    SandboxOptions
+	command []string
+	workers chan *sandboxWorker // idle workers, nil for one not started
+}
+
+// NewSandbox returns a sandbox running up to opts.Workers extractions at a
+// time.
+func NewSandbox(opts SandboxOptions) (*Sandbox, error) {
+	command := opts.Command
+	if len(command) == 0 {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("error locating the sandbox worker: %w", err)
+		}
+		command = []string{exe, SandboxWorkerCommand}
+	}
+	command = append(command[:len(command):len(command)], "-memory", strconv.Itoa(opts.MemoryMB))
+	if opts.Network {
+		command = append(command, "-network")
+	}
+	command = append(command, opts.Args...)
+
+	s := &Sandbox{opts: opts, command: command, workers: make(chan *sandboxWorker, max(opts.Workers, 1))}
+	for i := 0; i < cap(s.workers); i++ {
+		s.workers <- nil
+	}
+	return s, nil
+}
+
+// ErrWorkerCrashed is the error of the pages a sandbox worker crashed on,
+// in their *PageError with PageErrorCrash.
+var ErrWorkerCrashed = errors.New("the sandbox worker crashed on the page")
+
+// sandboxMaxCrashedPages bounds the pages of a document skipped after
+// crashing its worker; the next crash fails the document.
+const sandboxMaxCrashedPages = 8
+
+// ExtractData extracts an in-memory document like Extractor.ExtractData in
+// a worker. The settings that cannot leave the process stay behind:
+// config.Upscaler and config.Logger are not used, and config.Progress,
+// config.Warnings and config.Pages are not called; the worker logs to the
+// standard error of the process. A canceled ctx or the sandbox timeout
+// kills the worker, losing the pages done.
+//
+// A worker crashing on a page is replaced and the document extracted again
+// without the page, which config.ErrorPolicy then treats as failed with
+// ErrWorkerCrashed. When several pages were in progress, the document is
+// extracted again a page at a time until the one at fault is found. Crashes
+// outside the pages, or on more than a few pages, fail the document.
+func (s *Sandbox) ExtractData(ctx context.Context, data []byte, name string, config OCRConfig) (string, DocumentManifest, error) {
+	var w *sandboxWorker
+	select {
+	case w = <-s.workers:
+	case <-ctx.Done():
+		return "", DocumentManifest{Name: name, Size: len(data)}, fmt.Errorf("error extracting %s: %w", name, ctx.Err())
+	}
+	defer func() { s.workers <- w }()
+
+	var timeout <-chan time.Time
+	if s.opts.Timeout > 0 {
+		timer := time.NewTimer(s.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	warnings := &warningLog{logger: defaultLogger(), document: name}
+	var crashed []int // 1-based pages skipped, in the order they crashed
+	attempt := config
+	for {
+		req, err := newSandboxRequest(data, name, attempt)
+		if err != nil {
+			return "", DocumentManifest{Name: name, Size: len(data)}, err
+		}
+		if w == nil {
+			if w, err = startSandboxWorker(s.command, s.opts.Stderr); err != nil {
+				return "", DocumentManifest{Name: name, Size: len(data)}, err
+			}
+		}
+
+		results := make(chan sandboxResult, 1)
+		go w.extract(req, results)
+		var r sandboxResult
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			w.kill(results)
+			w = nil
+			return "", DocumentManifest{Name: name, Size: len(data), Truncated: true}, fmt.Errorf("error extracting %s: %w", name, ctx.Err())
+		case <-timeout:
+			w.kill(results)
+			w = nil
+			warnf("Warning: the sandbox worker processing %s took over %v and was stopped\n", name, s.opts.Timeout)
+			return "", DocumentManifest{Name: name, Size: len(data)}, fmt.Errorf("extraction of %s exceeded the sandbox timeout of %v", name, s.opts.Timeout)
+		}
+		if r.err == nil {
+			var err error
+			switch {
+			case r.resp.ErrStage != "":
+				err = &PageError{Document: name, Page: r.resp.ErrPage, Stage: r.resp.ErrStage, Err: errors.New(r.resp.ErrMessage)}
+			case r.resp.Err != "":
+				err = errors.New(r.resp.Err)
+			}
+			manifest := r.resp.Manifest.manifest()
+			addCrashedPages(&manifest, crashed, config)
+			manifest.Warnings = append(manifest.Warnings, warnings.list()...)
+			return r.resp.Text, manifest, err
+		}
+
+		status := w.stop()
+		w = nil
+		switch {
+		case len(r.running) == 1 && len(crashed) < sandboxMaxCrashedPages:
+			page := r.running[0]
+			warnings.warnf(page-1, "Warning: the sandbox worker crashed on page %d (%v); the page is skipped\n", page, status)
+			if config.ErrorPolicy == ErrorPolicyFailFast {
+				return "", DocumentManifest{Name: name, Size: len(data)}, &PageError{Document: name, Page: page, Stage: PageErrorCrash, Err: ErrWorkerCrashed}
+			}
+			crashed = append(crashed, page)
+			attempt.Workers = config.Workers
+			var left bool
+			if attempt.PageSelection, left = config.PageSelection.without(crashed); left {
+				continue
+			}
+		case len(r.running) > 1 && attempt.Workers != 1:
+			config.debugf("The sandbox worker processing %s crashed with %d pages in progress; retrying a page at a time\n", name, len(r.running))
+			attempt.Workers = 1
+			continue
+		}
+		warnf("Warning: the sandbox worker processing %s crashed (%v); it is replaced for the next document\n", name, status)
+		return "", DocumentManifest{Name: name, Size: len(data)}, fmt.Errorf("the sandbox worker processing %s crashed: %v", name, status)
+	}
+}
+
+// addCrashedPages records the pages a worker crashed on in the manifest
+// of the rest of the document as config.ErrorPolicy has it: failed results
+// in page order, or none with ErrorPolicySkip.
+func addCrashedPages(manifest *DocumentManifest, crashed []int, config OCRConfig) {
+	if config.ErrorPolicy == ErrorPolicySkip {
+		return
+	}
+	for _, page := range crashed {
+		result := PageResult{Page: page, Method: MethodFailed, Error: ErrWorkerCrashed.Error(),
+			err: &PageError{Document: manifest.Name, Page: page, Stage: PageErrorCrash, Err: ErrWorkerCrashed}}
+		i := sort.Search(len(manifest.PageResults), func(i int) bool { return manifest.PageResults[i].Page > page })
+		manifest.PageResults = slices.Insert(manifest.PageResults, i, result)
+	}
+}
+
+// sandboxResult is the response of a worker, or why there is none with
+// the pages that were in progress, in page order.
+type sandboxResult struct {
+	resp    sandboxResponse
+	err     error
+	running []int
+}
+
+// Close stops the workers, waiting for the extractions in progress.
+func (s *Sandbox) Close() {
+	for i := 0; i < cap(s.workers); i++ {
+		if w := <-s.workers; w != nil {
+			w.stop()
+		}
+	}
+}
+
+// sandboxWorker is a worker process, which extracts the documents of
+// sandboxRequests read from its standard input one at a time and answers
+// each with a sandboxResponse on its standard output, after one for every
+// page starting and finishing.
+type sandboxWorker struct {
+	cmd   *exec.Cmd
+	stdin io.Closer
+	enc   *gob.Encoder
+	dec   *gob.Decoder
+}
+
+func startSandboxWorker(command []string, stderr io.Writer) (*sandboxWorker, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = stderr
+	if stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	cmd.SysProcAttr = sandboxProcAttr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error starting a sandbox worker: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error starting a sandbox worker: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting a sandbox worker: %w", err)
+	}
+	return &sandboxWorker{cmd: cmd, stdin: stdin, enc: gob.NewEncoder(stdin), dec: gob.NewDecoder(bufio.NewReader(stdout))}, nil
+}
+
+// extract sends a request to the worker and delivers its response, or the
+// decoding error with the pages in progress when the worker died.
+func (w *sandboxWorker) extract(req sandboxRequest, results chan<- sandboxResult) {
+	var r sandboxResult
+	running := map[int]bool{}
+	if r.err = w.enc.Encode(req); r.err == nil {
+		for {
+			var resp sandboxResponse
+			if r.err = w.dec.Decode(&resp); r.err != nil {
+				break
+			}
+			switch {
+			case resp.Started > 0:
+				running[resp.Started] = true
+			case resp.Finished > 0:
+				delete(running, resp.Finished)
+			default:
+				r.resp = resp
+				results <- r
+				return
+			}
+		}
+	}
+	for page := range running {
+		r.running = append(r.running, page)
+	}
+	sort.Ints(r.running)
+	results <- r
+}
+
+// stop ends the worker by closing its input and returns how it exited.
+func (w *sandboxWorker) stop() error {
+	w.stdin.Close()
+	return w.cmd.Wait()
+}
+
+// kill ends a busy worker, once the goroutine waiting for its response
+// has given up.
+func (w *sandboxWorker) kill(results <-chan sandboxResult) {
+	w.cmd.Process.Kill()
+	w.stdin.Close()
+	<-results
+	w.cmd.Wait()
+}
+
+// RunSandboxWorker runs a sandbox worker, restricted as its arguments ask
+// (-memory <MB> and -network), until its standard input is closed; with
+// -progress json it reports the progress of its pages on stderr. Programs
+// starting their own executable as the worker command call it when run
+// that way, and exit with a failure status when it returns an error.
+func RunSandboxWorker(args []string) error {
+	memoryMB, network, progress := 0, false, ProgressText
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "-progress":
+			if i+1 < len(args) {
+				progress = strings.ToLower(args[i+1])
+				i++
+			}
+		case "-memory":
+			if i+1 < len(args) {
+				n, err := strconv.Atoi(args[i+1])
+				if err != nil || n < 0 {
+					return fmt.Errorf("invalid -memory value %q", args[i+1])
+				}
+				memoryMB = n
+				i++
+			}
+		case "-network":
+			network = true
+		}
+	}
+	if err := restrictWorker(memoryMB, network); err != nil {
+		return err
+	}
+	// Interrupts are for the parent, which stops its workers as it sees fit
+	signal.Ignore(os.Interrupt, syscall.SIGTERM)
+
+	// The responses own the standard output; messages written to it would
+	// corrupt them
+	var outMu sync.Mutex
+	out := gob.NewEncoder(os.Stdout)
+	send := func(resp sandboxResponse) error {
+		outMu.Lock()
+		defer outMu.Unlock()
+		return out.Encode(resp)
+	}
+	os.Stdout = os.Stderr
+	in := gob.NewDecoder(bufio.NewReader(os.Stdin))
+	for {
+		var req sandboxRequest
+		if err := in.Decode(&req); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		var resp sandboxResponse
+		config, err := req.decodeConfig()
+		if progress == ProgressJSON {
+			config.Progress = JSONProgress(os.Stderr)
+		}
+		// A broken output fails the response of the document below
+		config.trackPages = func(page int, running bool) {
+			if running {
+				send(sandboxResponse{Started: page})
+			} else {
+				send(sandboxResponse{Finished: page})
+			}
+		}
+		if err == nil {
+			var manifest DocumentManifest
+			resp.Text, manifest, err = extractPDFData(context.Background(), req.Data, req.Name, config, 0)
+			resp.Manifest = newSandboxManifest(manifest)
+		}
+		if err != nil {
+			resp.Err = err.Error()
+			var pageErr *PageError
+			if errors.As(err, &pageErr) && pageErr.Err != nil {
+				resp.ErrPage, resp.ErrStage, resp.ErrMessage = pageErr.Page, pageErr.Stage, pageErr.Err.Error()
+			}
+		}
+		if err := send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// sandboxRequest is a document for a worker to extract.
+type sandboxRequest struct {
+	Config []byte // sandboxConfigType, gob-encoded
+	Name   string
+	Data   []byte
+}
+
+// sandboxConfigType is OCRConfig without the fields that cannot be sent
+// to a worker: functions, interfaces and the Logger.
+var sandboxConfigType = func() reflect.Type {
+	t := reflect.TypeOf(OCRConfig{})
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		kind := f.Type.Kind()
+		if !f.IsExported() || kind == reflect.Func || kind == reflect.Interface || f.Type == reflect.TypeOf((*slog.Logger)(nil)) {
+			continue
+		}
+		fields = append(fields, reflect.StructField{Name: f.Name, Type: f.Type})
+	}
+	return reflect.StructOf(fields)
+}()
+
+func newSandboxRequest(data []byte, name string, config OCRConfig) (sandboxRequest, error) {
+	wire := reflect.New(sandboxConfigType).Elem()
+	src := reflect.ValueOf(config)
+	for i := 0; i < wire.NumField(); i++ {
+		wire.Field(i).Set(src.FieldByName(sandboxConfigType.Field(i).Name))
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(wire.Interface()); err != nil {
+		return sandboxRequest{}, fmt.Errorf("error encoding the settings for the sandbox: %w", err)
+	}
+	return sandboxRequest{Config: buf.Bytes(), Name: name, Data: data}, nil
+}
+
+func (req sandboxRequest) decodeConfig() (OCRConfig, error) {
+	wire := reflect.New(sandboxConfigType)
+	if err := gob.NewDecoder(bytes.NewReader(req.Config)).Decode(wire.Interface()); err != nil {
+		return OCRConfig{}, fmt.Errorf("error decoding the settings from the sandbox: %w", err)
+	}
+	var config OCRConfig
+	dst := reflect.ValueOf(&config).Elem()
+	for i := 0; i < sandboxConfigType.NumField(); i++ {
+		dst.FieldByName(sandboxConfigType.Field(i).Name).Set(wire.Elem().Field(i))
+	}
+	// gob leaves out the parsed fingerprints of the templates, which are
+	// unexported
+	for i, t := range config.RegionTemplates {
+		if t.Matchable() {
+			fp, err := parseFingerprint(t.Fingerprint)
+			if err != nil {
+				return OCRConfig{}, fmt.Errorf("ignore template %q: %w", t.Name, err)
+			}
+			config.RegionTemplates[i].fingerprint = fp
+		}
+	}
+	return config, nil
+}
+
+// sandboxResponse is the result of a worker's extraction.
+type sandboxResponse struct {
+	Text     string
+	Manifest sandboxManifest
+	Err      string
+	// The page, stage and cause of an Err that is a *PageError, as with
+	// ErrorPolicyFailFast
+	ErrPage              int
+	ErrStage, ErrMessage string
+
+	// Set alone in the messages before the response, which follow the
+	// pages of the document starting and finishing
+	Started, Finished int
+}
+
+// sandboxManifest is a DocumentManifest with its pages and members in
+// forms that keep the unexported page details output formats use.
+type sandboxManifest struct {
+	DocumentManifest // without PageResults and Members
+	Pages            []sandboxPage
+	Members          []sandboxManifest
+}
+
+type sandboxPage struct {
+	PageResult
+	Lines       []sandboxLine
+	HOCR        string
+	Layout      []TextLine
+	Annotations string
+	// err of a failed page, its Err as a message
+	ErrDocument, ErrStage, ErrMessage string
+}
+
+type sandboxLine struct {
+	Page, Index, Words int
+	Text               string
+	Height, Top        float64
+}
+
+func newSandboxManifest(m DocumentManifest) sandboxManifest {
+	wire := sandboxManifest{DocumentManifest: m}
+	wire.PageResults, wire.DocumentManifest.Members = nil, nil
+	for _, p := range m.PageResults {
+		page := sandboxPage{PageResult: p, HOCR: p.hocr, Layout: p.layout, Annotations: p.annotations}
+		for _, l := range p.lines {
+			page.Lines = append(page.Lines, sandboxLine{l.page, l.index, l.words, l.text, l.height, l.top})
+		}
+		if p.err != nil && p.err.Err != nil {
+			page.ErrDocument, page.ErrStage, page.ErrMessage = p.err.Document, p.err.Stage, p.err.Err.Error()
+		}
+		wire.Pages = append(wire.Pages, page)
+	}
+	for _, member := range m.Members {
+		wire.Members = append(wire.Members, newSandboxManifest(member))
+	}
+	return wire
+}
+
+func (wire sandboxManifest) manifest() DocumentManifest {
+	m := wire.DocumentManifest
+	for _, page := range wire.Pages {
+		p := page.PageResult
+		p.hocr, p.layout, p.annotations = page.HOCR, page.Layout, page.Annotations
+		for _, l := range page.Lines {
+			p.lines = append(p.lines, headingLine{page: l.Page, index: l.Index, text: l.Text, height: l.Height, words: l.Words, top: l.Top})
+		}
+		if page.ErrStage != "" {
+			p.err = &PageError{Document: page.ErrDocument, Page: p.Page, Stage: page.ErrStage, Err: errors.New(page.ErrMessage)}
+		}
+		m.PageResults = append(m.PageResults, p)
+	}
+	for _, member := range wire.Members {
+		m.Members = append(m.Members, member.manifest())
+	}
+	return m
+}
@@ -0,0 +1,78 @@
+//go:build linux && (amd64 || arm64)
+
+package pdfocr
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// seccomp(2) and prctl(2) constants of restrictWorker.
+const (
+	prSetNoNewPrivs          = 38
+	seccompSetModeFilter     = 1
+	seccompFilterFlagTSync   = 1
+	seccompRetKillProcess    = 0x80000000
+	seccompRetErrno          = 0x00050000
+	seccompRetAllow          = 0x7fff0000
+	x32SyscallBit            = 0x40000000
+	seccompDataArchOffset    = 4
+	seccompDataSyscallOffset = 0
+)
+
+// sandboxProcAttr has workers killed when the process that started them
+// dies.
+func sandboxProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+}
+
+// restrictWorker limits the address space of a sandbox worker to memoryMB
+// (0 for no limit), turns off its core dumps, which would hold document
+// content, and installs a seccomp filter on all its threads that fails
+// with EPERM the system calls a renderer or OCR engine has no use for:
+// debugging other processes, mounts and namespaces, kernel modules, BPF,
+// keyrings, and with network unset, opening sockets.
+func restrictWorker(memoryMB int, network bool) error {
+	if memoryMB > 0 {
+		limit := uint64(memoryMB) << 20
+		if err := syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: limit, Max: limit}); err != nil {
+			return fmt.Errorf("error limiting the sandbox memory: %w", err)
+		}
+	}
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &syscall.Rlimit{}); err != nil {
+		return fmt.Errorf("error turning off core dumps: %w", err)
+	}
+
+	denied := sandboxDeniedCalls
+	if !network {
+		denied = append(denied[:len(denied):len(denied)], sandboxNetworkCalls...)
+	}
+	filter := []syscall.SockFilter{
+		{Code: syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS, K: seccompDataArchOffset},
+		{Code: syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K, Jt: 1, K: sandboxAuditArch},
+		{Code: syscall.BPF_RET | syscall.BPF_K, K: seccompRetKillProcess},
+		{Code: syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS, K: seccompDataSyscallOffset},
+		// The x32 ABI of x86-64 kernels numbers the same calls differently
+		{Code: syscall.BPF_JMP | syscall.BPF_JGE | syscall.BPF_K, Jf: 1, K: x32SyscallBit},
+		{Code: syscall.BPF_RET | syscall.BPF_K, K: seccompRetErrno | uint32(syscall.EPERM)},
+	}
+	for _, nr := range denied {
+		filter = append(filter,
+			syscall.SockFilter{Code: syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K, Jf: 1, K: nr},
+			syscall.SockFilter{Code: syscall.BPF_RET | syscall.BPF_K, K: seccompRetErrno | uint32(syscall.EPERM)})
+	}
+	filter = append(filter, syscall.SockFilter{Code: syscall.BPF_RET | syscall.BPF_K, K: seccompRetAllow})
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0); errno != 0 {
+		return fmt.Errorf("error restricting the sandbox worker: %w", errno)
+	}
+	if r, _, errno := syscall.RawSyscall(sysSeccomp, seccompSetModeFilter, seccompFilterFlagTSync, uintptr(unsafe.Pointer(&prog))); errno != 0 || r != 0 {
+		if errno == 0 {
+			return fmt.Errorf("error installing the sandbox system call filter: thread %d could not be synchronized", r)
+		}
+		return fmt.Errorf("error installing the sandbox system call filter: %w", errno)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package pdfocr
+
+// System call numbers of the seccomp filter of sandbox workers on x86-64.
+const (
+	sandboxAuditArch = 0xc000003e // AUDIT_ARCH_X86_64
+	sysSeccomp       = 317
+)
+
+var (
+	// socket, connect, accept, bind, listen, accept4
+	sandboxNetworkCalls = []uint32{41, 42, 43, 49, 50, 288}
+	// ptrace, process_vm_readv/writev, mount, umount2, pivot_root, chroot,
+	// setns, unshare, kexec_load, kexec_file_load, init_module,
+	// finit_module, delete_module, bpf, perf_event_open, keyctl, add_key,
+	// request_key, reboot, swapon, swapoff, acct, userfaultfd and io_uring
+	sandboxDeniedCalls = []uint32{101, 310, 311, 165, 166, 155, 161, 308, 272, 246, 320, 175, 313, 176,
+		321, 298, 250, 248, 249, 169, 167, 168, 163, 323, 425, 426, 427}
+)
@@ -0,0 +1,18 @@
+package pdfocr
+
+// System call numbers of the seccomp filter of sandbox workers on ARM64.
+const (
+	sandboxAuditArch = 0xc00000b7 // AUDIT_ARCH_AARCH64
+	sysSeccomp       = 277
+)
+
+var (
+	// socket, connect, accept, bind, listen, accept4
+	sandboxNetworkCalls = []uint32{198, 203, 202, 200, 201, 242}
+	// ptrace, process_vm_readv/writev, mount, umount2, pivot_root, chroot,
+	// setns, unshare, kexec_load, kexec_file_load, init_module,
+	// finit_module, delete_module, bpf, perf_event_open, keyctl, add_key,
+	// request_key, reboot, swapon, swapoff, acct, userfaultfd and io_uring
+	sandboxDeniedCalls = []uint32{117, 270, 271, 40, 39, 41, 51, 268, 97, 104, 294, 105, 273, 106,
+		280, 241, 219, 217, 218, 142, 224, 225, 89, 282, 425, 426, 427}
+)
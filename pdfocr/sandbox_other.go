@@ -0,0 +1,15 @@
+//go:build !linux || !(amd64 || arm64)
+
+package pdfocr
+
+import "syscall"
+
+func sandboxProcAttr() *syscall.SysProcAttr { return nil }
+
+// restrictWorker has no resource limits or system call filter to apply on
+// this platform; sandbox workers still keep crashes out of the process
+// that started them.
+func restrictWorker(memoryMB int, network bool) error {
+	warnf("Warning: sandbox workers run without resource limits or a system call filter on this platform\n")
+	return nil
+}
@@ -0,0 +1,183 @@
+package pdfocr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Time estimates of the server's deadline scheduling: the seconds a page
+// takes at the configured quality before any document was timed, and
+// the share of it a page takes at each degraded level.
+const (
+	defaultPageSeconds = 2.0
+	fastPageFactor     = 0.5
+	lowDPIPageFactor   = 0.25
+	deadlineLowDPI     = 100
+)
+
+// Quality reductions of DocumentManifest.Degraded.
+const (
+	DegradedFast   = "fast"    // fast mode (see ApplyFast)
+	DegradedLowDPI = "low-dpi" // rendered at deadlineLowDPI
+)
+
+// errDeadlinePassed is returned by jobScheduler.acquire when the deadline
+// of a job passes before a slot is free.
+var errDeadlinePassed = errors.New("the deadline passed before a slot was free")
+
+// jobScheduler hands out the extraction slots of the server, to the
+// waiting job with the earliest deadline first and then in order of
+// arrival, jobs without a deadline last. It also learns how long pages
+// take, for degradeForDeadline.
+type jobScheduler struct {
+	mu          sync.Mutex
+	free        int
+	waiting     []*waitingJob
+	arrivals    int
+	pageSeconds float64 // per page at the configured quality, moving average
+}
+
+type waitingJob struct {
+	deadline time.Time // zero for none
+	arrival  int
+	ready    chan struct{} // closed when the job is handed a slot
+}
+
+func newJobScheduler(slots int) *jobScheduler {
+	return &jobScheduler{free: slots, pageSeconds: defaultPageSeconds}
+}
+
+// acquire waits for a slot until ctx is done or the deadline passes. A
+// job whose ctx is done already gets none.
+func (s *jobScheduler) acquire(ctx context.Context, deadline time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	if s.free > 0 && len(s.waiting) == 0 {
+		s.free--
+		s.mu.Unlock()
+		return nil
+	}
+	job := &waitingJob{deadline: deadline, arrival: s.arrivals, ready: make(chan struct{})}
+	s.arrivals++
+	s.waiting = append(s.waiting, job)
+	s.mu.Unlock()
+
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var err error
+	select {
+	case <-job.ready:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-expired:
+		err = errDeadlinePassed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-job.ready:
+		// Handed a slot meanwhile, which goes to the next job
+		s.releaseLocked()
+	default:
+		for i, w := range s.waiting {
+			if w == job {
+				s.waiting = append(s.waiting[:i], s.waiting[i+1:]...)
+				break
+			}
+		}
+	}
+	return err
+}
+
+// release returns a slot, handing it to the most urgent waiting job.
+func (s *jobScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked()
+}
+
+func (s *jobScheduler) releaseLocked() {
+	if len(s.waiting) == 0 {
+		s.free++
+		return
+	}
+	next := 0
+	for i, w := range s.waiting[1:] {
+		if w.before(s.waiting[next]) {
+			next = i + 1
+		}
+	}
+	job := s.waiting[next]
+	s.waiting = append(s.waiting[:next], s.waiting[next+1:]...)
+	close(job.ready)
+}
+
+// before reports whether job w is more urgent than job o.
+func (w *waitingJob) before(o *waitingJob) bool {
+	switch {
+	case w.deadline.IsZero() != o.deadline.IsZero():
+		return o.deadline.IsZero()
+	case !w.deadline.Equal(o.deadline):
+		return w.deadline.Before(o.deadline)
+	}
+	return w.arrival < o.arrival
+}
+
+// observe updates the page time estimate with a document of pages pages
+// extracted in elapsed at a level taking factor of the full page time.
+func (s *jobScheduler) observe(pages int, elapsed time.Duration, factor float64) {
+	if pages == 0 {
+		return
+	}
+	perPage := elapsed.Seconds() / float64(pages) / factor
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSeconds = 0.7*s.pageSeconds + 0.3*perPage
+}
+
+// degradeForDeadline lowers the quality of config until pages pages are
+// estimated to take no longer than remaining: fast mode first, then
+// deadlineLowDPI as well. It returns the reductions made and the share of
+// the full page time the chosen level takes. The extraction also stops
+// starting pages at the deadline, whatever the estimate.
+func (s *jobScheduler) degradeForDeadline(config *OCRConfig, pages int, remaining time.Duration) ([]string, float64) {
+	s.mu.Lock()
+	pageSeconds := s.pageSeconds
+	s.mu.Unlock()
+	if config.MaxDuration == 0 || config.MaxDuration > remaining {
+		config.MaxDuration = remaining
+	}
+	fits := func(factor float64) bool {
+		return time.Duration(float64(pages)*pageSeconds*factor*float64(time.Second)) <= remaining
+	}
+
+	var degraded []string
+	factor := 1.0
+	if fits(factor) {
+		return degraded, factor
+	}
+	if !config.Fast {
+		config.Best, config.Fast = false, true
+		config.Preprocess = PreprocessNone
+		config.DPI = min(config.DPI, fastDPI)
+		degraded, factor = append(degraded, DegradedFast), fastPageFactor
+		if fits(factor) {
+			return degraded, factor
+		}
+	}
+	if config.DPI > deadlineLowDPI {
+		config.DPI = deadlineLowDPI
+		degraded, factor = append(degraded, DegradedLowDPI), lowDPIPageFactor
+	}
+	return degraded, factor
+}
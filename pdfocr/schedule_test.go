@@ -0,0 +1,77 @@
+package pdfocr
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJobScheduler(t *testing.T) {
+	s := newJobScheduler(1)
+	ctx := context.Background()
+	if err := s.acquire(ctx, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	var mu sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	for i, job := range []struct {
+		name     string
+		deadline time.Time
+	}{{"none", time.Time{}}, {"late", now.Add(time.Hour)}, {"soon", now.Add(time.Minute)}} {
+		wg.Add(1)
+		go func(name string, deadline time.Time) {
+			defer wg.Done()
+			if err := s.acquire(ctx, deadline); err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			order = append(order, name)
+			mu.Unlock()
+			s.release()
+		}(job.name, job.deadline)
+		// Queue the jobs in turn
+		for {
+			s.mu.Lock()
+			n := len(s.waiting)
+			s.mu.Unlock()
+			if n == i+1 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	s.release()
+	wg.Wait()
+	if want := []string{"soon", "late", "none"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("slots handed out in order %v, want %v", order, want)
+	}
+
+	if err := s.acquire(ctx, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.acquire(ctx, time.Now().Add(10*time.Millisecond)); !errors.Is(err, errDeadlinePassed) {
+		t.Errorf("acquire with a passing deadline: %v, want errDeadlinePassed", err)
+	}
+
+	for _, c := range []struct {
+		pages    int
+		degraded []string
+		dpi      float64
+	}{
+		{10, nil, 300},
+		{40, []string{DegradedFast}, fastDPI},
+		{100, []string{DegradedFast, DegradedLowDPI}, deadlineLowDPI},
+	} {
+		config := OCRConfig{DPI: 300}
+		degraded, _ := s.degradeForDeadline(&config, c.pages, 50*time.Second)
+		if !reflect.DeepEqual(degraded, c.degraded) || config.DPI != c.dpi || config.MaxDuration != 50*time.Second {
+			t.Errorf("%d pages in 50s: degraded %v at %g DPI, want %v at %g DPI", c.pages, degraded, config.DPI, c.degraded, c.dpi)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package pdfocr
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SchemaVersion is the schema_version of every JSON document written (the
+// manifest, -format json, the pages directory files, audit records, batch
+// manifests and -progress json events). It
+// changes when a field is removed or changes meaning; fields may be added
+// within a version.
+const SchemaVersion = "1"
+
+// schemaFiles holds the JSON Schemas of the structured outputs, one
+// <name>.schema.json per document type.
+//
+//go:embed schemas/*.schema.json
+var schemaFiles embed.FS
+
+// Schema returns the JSON Schema of a structured output by name, as listed
+// by SchemaNames.
+func Schema(name string) ([]byte, error) {
+	data, err := schemaFiles.ReadFile("schemas/" + name + ".schema.json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown schema %q (available: %s)", name, strings.Join(SchemaNames(), ", "))
+	}
+	return data, nil
+}
+
+// SchemaNames lists the schemas in alphabetical order.
+func SchemaNames() []string {
+	paths, _ := fs.Glob(schemaFiles, "schemas/*.schema.json")
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = strings.TrimSuffix(path.Base(p), ".schema.json")
+	}
+	sort.Strings(names)
+	return names
+}
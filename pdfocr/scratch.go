@@ -0,0 +1,58 @@
+package pdfocr
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// runID identifies this run of the tool in the names of its scratch and
+// debug files: its start time, process ID and a random part, e.g.
+// 20261014T093012-4711-9f3a07c2. Instances started in the same second on
+// one host still differ by process ID, and those in containers sharing a
+// temporary directory by the random part.
+var runID = newRunID()
+
+func newRunID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%s-%d-%s", time.Now().Format("20060102T150405"), os.Getpid(), hex.EncodeToString(b[:]))
+}
+
+// scratchPattern is the os.CreateTemp and os.MkdirTemp pattern of a
+// scratch file of this run, e.g. pdf-ocr-<run>-render-p0003-*.png; a
+// negative pageNum leaves the page out. The random suffix added for "*"
+// keeps pages processed in parallel apart.
+func scratchPattern(kind string, pageNum int, ext string) string {
+	if pageNum < 0 {
+		return fmt.Sprintf("pdf-ocr-%s-%s-*%s", runID, kind, ext)
+	}
+	return fmt.Sprintf("pdf-ocr-%s-%s-p%04d-*%s", runID, kind, pageNum+1, ext)
+}
+
+// scratchName matches the names scratchPattern gives, which start with a
+// run ID, so other programs' files under the same prefix are told apart.
+var scratchName = regexp.MustCompile(`^pdf-ocr-\d{8}T\d{6}-\d+-[0-9a-f]{8}-[a-z]+-`)
+
+// removeStaleScratch removes the scratch files and directories of any run
+// from the temporary directory once they are older than age by now. Runs
+// remove their own; these are left by runs that were killed.
+func removeStaleScratch(age time.Duration, now time.Time) {
+	paths, _ := filepath.Glob(filepath.Join(os.TempDir(), "pdf-ocr-*"))
+	for _, path := range paths {
+		if !scratchName.MatchString(filepath.Base(path)) {
+			continue
+		}
+		info, err := os.Lstat(path)
+		if err != nil || now.Sub(info.ModTime()) < age {
+			continue
+		}
+		if err := os.RemoveAll(path); err != nil {
+			warnf("Warning: could not remove stale scratch file: %v\n", err)
+		}
+	}
+}
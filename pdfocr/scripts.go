@@ -0,0 +1,140 @@
+package pdfocr
+
+import (
+	"image"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Positions of a word relative to the baseline of its line.
+const (
+	scriptNone = iota
+	scriptSuper
+	scriptSub
+)
+
+// Thresholds of script detection, as fractions of the height of the body
+// words of a line.
+const (
+	scriptMaxHeight = 0.75 // scripts are set smaller than the body
+	superscriptRise = 0.3  // a superscript ends this far above the baseline
+	subscriptDrop   = 0.15 // a subscript ends this far below it
+	subscriptTop    = 0.5  // and starts this far below the top of the body
+	scriptJoinGap   = 0.25 // scripts closer to a word than this are attached to it
+)
+
+// maxScriptRunes is the length of the longest script: footnote markers,
+// exponents and the counts of chemical formulas are short.
+const maxScriptRunes = 4
+
+// scriptCandidate reports whether a word could be set as a script: short,
+// with letters, digits or footnote marks, and for subscripts without the
+// descenders that put lowercase words below the baseline too.
+func scriptCandidate(text string, position int) bool {
+	runes := []rune(text)
+	if len(runes) == 0 || len(runes) > maxScriptRunes {
+		return false
+	}
+	for _, r := range runes {
+		switch {
+		case position == scriptSub && strings.ContainsRune("gjpqy", r):
+			return false
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("*†‡§+-−", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// median returns the middle of values, which it sorts.
+func median(values []int) int {
+	sort.Ints(values)
+	return values[len(values)/2]
+}
+
+// wordScripts tells the superscripts and subscripts among the words of a
+// line, left to right, from their offsets to the baseline. The baseline and
+// the body height are those of the line's full-size words; lines of a
+// single word have none.
+func wordScripts(texts []string, boxes []image.Rectangle) []int {
+	scripts := make([]int, len(boxes))
+	if len(boxes) < 2 {
+		return scripts
+	}
+	tallest := 0
+	for _, b := range boxes {
+		tallest = max(tallest, b.Dy())
+	}
+	var heights, tops, bottoms []int
+	for _, b := range boxes {
+		if float64(b.Dy()) >= float64(tallest)*0.8 {
+			heights = append(heights, b.Dy())
+			tops = append(tops, b.Min.Y)
+			bottoms = append(bottoms, b.Max.Y)
+		}
+	}
+	h := float64(median(heights))
+	top, baseline := float64(median(tops)), float64(median(bottoms))
+	if h <= 0 {
+		return scripts
+	}
+	for i, b := range boxes {
+		if float64(b.Dy()) > h*scriptMaxHeight {
+			continue
+		}
+		switch {
+		case float64(b.Max.Y) <= baseline-h*superscriptRise && scriptCandidate(texts[i], scriptSuper):
+			scripts[i] = scriptSuper
+		case float64(b.Max.Y) >= baseline+h*subscriptDrop && float64(b.Min.Y) >= top+h*subscriptTop &&
+			scriptCandidate(texts[i], scriptSub):
+			scripts[i] = scriptSub
+		}
+	}
+	return scripts
+}
+
+// scriptMarkup returns the text of a line of words, left to right, with
+// its superscripts and subscripts in <sup> and <sub> elements, or "" if it
+// has none. Scripts close to their neighbours are attached to them, so a
+// formula reads H<sub>2</sub>O rather than H <sub>2</sub> O.
+func scriptMarkup(words []OCRWord) string {
+	texts := make([]string, len(words))
+	boxes := make([]image.Rectangle, len(words))
+	for i, w := range words {
+		texts[i], boxes[i] = w.Text, w.Box
+	}
+	scripts := wordScripts(texts, boxes)
+	found := false
+	for _, s := range scripts {
+		found = found || s != scriptNone
+	}
+	if !found {
+		return ""
+	}
+	var b strings.Builder
+	for i, w := range words {
+		if i > 0 {
+			gap := float64(w.Box.Min.X - words[i-1].Box.Max.X)
+			attached := (scripts[i] != scriptNone || scripts[i-1] != scriptNone) &&
+				gap <= float64(max(w.Box.Dy(), words[i-1].Box.Dy()))*scriptJoinGap
+			if !attached {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(scriptElement(w.Text, scripts[i]))
+	}
+	return b.String()
+}
+
+// scriptElement wraps text in the element of its script position.
+func scriptElement(text string, position int) string {
+	switch position {
+	case scriptSuper:
+		return "<sup>" + text + "</sup>"
+	case scriptSub:
+		return "<sub>" + text + "</sub>"
+	}
+	return text
+}
@@ -0,0 +1,68 @@
+package pdfocr
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestScriptMarkup(t *testing.T) {
+	// Body words 30 pixels tall on a baseline at 130
+	word := func(text string, x0, y0, x1, y1 int) OCRWord {
+		return OCRWord{Text: text, Box: image.Rect(x0, y0, x1, y1)}
+	}
+	tests := []struct {
+		name  string
+		words []OCRWord
+		want  string
+	}{
+		{"footnote marker", []OCRWord{
+			word("Revenue", 0, 100, 140, 130), word("grew", 150, 108, 220, 130), word("1", 222, 95, 232, 112),
+		}, "Revenue grew<sup>1</sup>"},
+		{"formula", []OCRWord{
+			word("Water", 0, 100, 100, 130), word("is", 110, 100, 130, 130),
+			word("H", 140, 100, 165, 130), word("2", 167, 118, 180, 138), word("O", 182, 100, 210, 130),
+		}, "Water is H<sub>2</sub>O"},
+		{"exponent apart", []OCRWord{
+			word("Area", 0, 100, 80, 130), word("m", 90, 108, 120, 130), word("2", 140, 92, 150, 110),
+		}, "Area m <sup>2</sup>"},
+		{"lowercase and descenders", []OCRWord{
+			word("Were", 0, 100, 80, 130), word("we", 90, 110, 130, 130), word("gyp", 140, 110, 190, 138), word("up", 200, 110, 230, 138),
+		}, ""},
+		{"one word", []OCRWord{word("x", 0, 95, 10, 112)}, ""},
+		{"long raised word", []OCRWord{
+			word("Note", 0, 100, 80, 130), word("continued", 90, 95, 200, 112),
+		}, ""},
+	}
+	for _, tt := range tests {
+		if got := scriptMarkup(tt.words); got != tt.want {
+			t.Errorf("%s: scriptMarkup = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	lines := []headingLine{
+		{page: 1, index: 0, text: "Revenue grew 1", height: 10, words: 3, markup: "Revenue grew<sup>1</sup>"},
+		{page: 1, index: 1, text: "Water is H 2 O", height: 10, words: 5, markup: "Water is H<sub>2</sub>O"},
+	}
+	p := PageResult{Page: 1, Method: MethodOCR, Text: "Revenue grew 1\nWater is H 2 O\nNothing else\n", lines: lines}
+	got := DocumentMarkdown(DocumentManifest{PageResults: []PageResult{p}})
+	if want := "Revenue grew<sup>1</sup>\nWater is H<sub>2</sub>O\nNothing else\n"; got != want {
+		t.Errorf("DocumentMarkdown = %q, want %q", got, want)
+	}
+
+	raw := "<div class='ocr_page' id='page_1' title='bbox 0 0 300 300'>" +
+		"<span class='ocr_line' id='line_1_1' title='bbox 0 92 240 130'>" +
+		"<span class='ocrx_word' id='word_1_1' title='bbox 0 100 140 130; x_wconf 95'>Revenue</span> " +
+		"<span class='ocrx_word' id='word_1_2' title='bbox 150 108 220 130; x_wconf 95'>grew</span>" +
+		"<span class='ocrx_word' id='word_1_3' title='bbox 222 95 232 112; x_wconf 80'>1</span></span>" +
+		"<span class='ocr_line' id='line_1_2' title='bbox 0 200 200 230'>" +
+		"<span class='ocrx_word' id='word_1_4' title='bbox 0 200 100 230; x_wconf 95'>Next</span> " +
+		"<span class='ocrx_word' id='word_1_5' title='bbox 110 200 200 230; x_wconf 95'>line</span></span></div>"
+	page, err := hocrPage(raw, 0, 300)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(page, "x_wconf 80'><sup>1</sup></span>") || strings.Count(page, "<sup>") != 1 || strings.Contains(page, "<sub>") {
+		t.Errorf("hocrPage scripts:\n%s", page)
+	}
+}
@@ -0,0 +1,240 @@
+package pdfocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Output formats of the command line tool.
+const (
+	FormatText     = "text" // extracted text (default)
+	FormatPDF      = "pdf"  // searchable PDF: page images with an invisible text layer
+	FormatJSON     = "json" // the manifest and every page with its words (see DocumentJSON)
+	FormatHOCR     = "hocr" // see DocumentHOCR
+	FormatALTO     = "alto" // see DocumentALTO
+	FormatMarkdown = "md"   // see DocumentMarkdown
+	FormatTSV      = "tsv"  // see DocumentTSV
+)
+
+const (
+	// searchableJPEGQuality is the JPEG quality of the page images of a
+	// searchable PDF.
+	searchableJPEGQuality = 85
+
+	// helveticaDefaultWidth is used for the codes WinAnsiEncoding leaves
+	// undefined, in thousandths of the font size.
+	helveticaDefaultWidth = 556
+
+	// Helvetica's ascender and descender, in thousandths of the font size.
+	// The invisible text is sized so they span the line, which is where
+	// viewers draw search and selection highlights.
+	helveticaAscent  = 718
+	helveticaDescent = 207
+)
+
+// helveticaWidths are the advances of the WinAnsi characters 32-255 in
+// Helvetica, 0 for undefined codes. Text extractors judge word breaks from
+// them, so the invisible text keeps its words together.
+var helveticaWidths = [224]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, 0,
+	556, 0, 222, 556, 333, 1000, 556, 556, 333, 1000, 667, 333, 1000, 0, 611, 0,
+	0, 222, 222, 333, 333, 350, 556, 1000, 333, 1000, 500, 333, 944, 0, 500, 667,
+	278, 333, 556, 556, 556, 556, 260, 556, 333, 737, 370, 556, 584, 333, 737, 333,
+	400, 584, 333, 333, 333, 556, 537, 278, 333, 333, 365, 556, 834, 834, 834, 611,
+	667, 667, 667, 667, 667, 667, 1000, 722, 667, 667, 667, 667, 278, 278, 278, 278,
+	722, 722, 778, 778, 778, 778, 778, 584, 778, 722, 722, 722, 722, 667, 667, 611,
+	556, 556, 556, 556, 556, 556, 889, 500, 556, 556, 556, 556, 278, 278, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 584, 611, 556, 556, 556, 556, 500, 556, 500,
+}
+
+// helveticaWidth returns the advance of a WinAnsi character code.
+func helveticaWidth(c byte) int {
+	if c >= 32 && helveticaWidths[c-32] > 0 {
+		return helveticaWidths[c-32]
+	}
+	return helveticaDefaultWidth
+}
+
+// helveticaStretch returns the horizontal scaling, in percent, that makes
+// text set in Helvetica at size span width.
+func helveticaStretch(text []byte, size, width float64) float64 {
+	advance := 0
+	for _, c := range text {
+		advance += helveticaWidth(c)
+	}
+	return 100 * width / (size * float64(advance) / 1000)
+}
+
+// ValidateFormat checks a -format value given on the command line.
+func ValidateFormat(format string) error {
+	switch format {
+	case "", FormatText, FormatPDF, FormatJSON, FormatHOCR, FormatALTO, FormatMarkdown, FormatTSV:
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q (use text, pdf, json, hocr, alto, md or tsv)", format)
+}
+
+// SearchablePDFPath is where a searchable PDF is saved when no output file is
+// given: next to the original.
+func SearchablePDFPath(pdfPath string) string {
+	return strings.TrimSuffix(pdfPath, filepath.Ext(pdfPath)) + "_ocr.pdf"
+}
+
+// WriteSearchablePDF writes a copy of a PDF in which every page is its
+// rendered image with the OCR'd words laid over it as invisible text, so the
+// copy can be searched and its text selected. Pages are OCR'd whether or not
+// they have a text layer, as the original layer does not survive rendering.
+// Each word is set on the baseline of its line and stretched across its
+// box, with a space spanning the gap to the next, so copied text keeps its
+// word breaks and highlights cover the words of the scan. The text layer is
+// Windows-1252: characters outside it become '?'.
+// Headings found in the text become the bookmarks of the copy, and are
+// returned. The copy holds the pages in config.PageSelection.
+func WriteSearchablePDF(ctx context.Context, pdfPath, outPath string, config OCRConfig) ([]OutlineEntry, error) {
+	data, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading PDF: %w", err)
+	}
+	src, err := openPDFSource(data, filepath.Base(pdfPath), config.Renderer)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	src.noScratch = config.EncryptKey != nil || config.NoDisk
+	config.warnings = newWarningLog(&config, src.name)
+	src.warnings = config.warnings
+
+	dpi := config.DPI
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	widths := make([]string, 0, 224)
+	for c := 32; c <= 255; c++ {
+		widths = append(widths, fmt.Sprint(helveticaWidth(byte(c))))
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // page tree, once the pages are known
+		fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding /FirstChar 32 /LastChar 255 /Widths [%s] >>", strings.Join(widths, " ")),
+	}
+	var kids []string
+	var pageObjects []int
+	var pageHeights []float64
+	var lines []headingLine
+	numPages := src.doc.NumPage()
+	pageNums := config.PageSelection.pageNums(numPages)
+	config.progress = newProgressReporter(config.Progress, src.name, pageNums)
+	config.progress.report(StageStart, -1, "")
+	for _, pageNum := range pageNums {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("searchable PDF not written: %w", err)
+		}
+		if config.Progress == nil {
+			config.logf("Adding a text layer to page %d of %d...\n", pageNum+1, numPages)
+		}
+		img, words, err := ocrPageImageWords(ctx, src, pageNum, config, PageOCROptions{DPI: dpi})
+		if err != nil {
+			return nil, fmt.Errorf("error processing page %d: %w", pageNum+1, err)
+		}
+		config.progress.report(StageDone, pageNum, MethodOCR)
+		page, err := searchablePage(img, words, dpi, len(objects)+1)
+		if err != nil {
+			return nil, fmt.Errorf("error writing page %d: %w", pageNum+1, err)
+		}
+		kids = append(kids, fmt.Sprintf("%d 0 R", len(objects)+1))
+		pageObjects = append(pageObjects, len(objects)+1)
+		pageHeights = append(pageHeights, float64(img.Bounds().Dy())*72/dpi)
+		// Headings point to pages of the copy
+		lines = append(lines, headingLines(len(kids), words, img.Bounds().Dy(), dpi)...)
+		objects = append(objects, page...)
+	}
+	if len(kids) == 0 {
+		return nil, fmt.Errorf("no pages of %s selected", filepath.Base(pdfPath))
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(kids))
+	outline := buildOutline(lines)
+	if len(outline) > 0 {
+		objects[0] = fmt.Sprintf("<< /Type /Catalog /Pages 2 0 R /Outlines %d 0 R /PageMode /UseOutlines >>", len(objects)+1)
+		objects = append(objects, pdfOutlineObjects(outline, pageObjects, pageHeights, len(objects)+1)...)
+	}
+
+	out, err := SealOutput(outPath, buildPDF(objects), config.EncryptKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(outPath, out, 0644); err != nil {
+		return nil, fmt.Errorf("error writing searchable PDF: %w", err)
+	}
+	return outline, nil
+}
+
+// searchablePage returns the page, content stream and image objects of one
+// page of a searchable PDF, numbered from first. The page font is object 3.
+func searchablePage(img image.Image, words []OCRWord, dpi float64, first int) ([]string, error) {
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, &jpeg.Options{Quality: searchableJPEGQuality}); err != nil {
+		return nil, fmt.Errorf("error encoding page image: %w", err)
+	}
+	colorSpace := "/DeviceRGB"
+	if _, ok := img.(*image.Gray); ok {
+		colorSpace = "/DeviceGray"
+	}
+	b := img.Bounds()
+	scale := 72 / dpi
+	width, height := float64(b.Dx())*scale, float64(b.Dy())*scale
+
+	var content strings.Builder
+	fmt.Fprintf(&content, "q %s 0 0 %s 0 0 cm /Im0 Do Q\n", pdfNumber(width), pdfNumber(height))
+	// Render mode 3 draws nothing but keeps the text searchable
+	content.WriteString("BT 3 Tr\n")
+	for _, line := range wordLines(words) {
+		lineBox := line.Box.Sub(b.Min)
+		if lineBox.Dy() <= 0 {
+			continue
+		}
+		// The words of a line share its size and baseline, so selections
+		// run straight along it
+		size := float64(lineBox.Dy()) * scale * 1000 / (helveticaAscent + helveticaDescent)
+		baseline := height - float64(lineBox.Max.Y)*scale + size*helveticaDescent/1000
+		fmt.Fprintf(&content, "/F1 %.2f Tf\n", size)
+		for i, w := range line.Words {
+			text, _ := EncodeText(strings.TrimSpace(w.Text), EncodingWindows1252)
+			box := w.Box.Sub(b.Min)
+			if len(text) == 0 || box.Dx() <= 0 {
+				continue
+			}
+			// Stretch the word across its box
+			fmt.Fprintf(&content, "%.2f Tz 1 0 0 1 %.2f %.2f Tm <%s> Tj\n",
+				helveticaStretch(text, size, float64(box.Dx())*scale), float64(box.Min.X)*scale, baseline, hex.EncodeToString(text))
+			if i+1 < len(line.Words) {
+				// A space across the gap to the next word separates them
+				// when copied, without a gap extractors could read as a
+				// second one
+				gap := math.Max(float64(line.Words[i+1].Box.Min.X-w.Box.Max.X)*scale, size/20)
+				fmt.Fprintf(&content, "%.2f Tz <20> Tj\n", helveticaStretch([]byte{' '}, size, gap))
+			}
+		}
+	}
+	content.WriteString("ET")
+
+	return []string{
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 3 0 R >> /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+			pdfNumber(width), pdfNumber(height), first+2, first+1),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream",
+			b.Dx(), b.Dy(), colorSpace, jpg.Len(), jpg.String()),
+	}, nil
+}
@@ -0,0 +1,500 @@
+package pdfocr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxUpload is the largest PDF accepted by the server, in megabytes,
+// unless ServerOptions.MaxUpload says otherwise.
+const defaultMaxUpload = 100
+
+// Codes of the error responses of the server.
+const (
+	errMethodNotAllowed  = "method_not_allowed"
+	errInvalidRequest    = "invalid_request"
+	errUnsupportedFormat = "unsupported_format"
+	errUnsupportedType   = "unsupported_type"
+	errUploadTooLarge    = "upload_too_large"
+	errUploadRejected    = "upload_rejected"
+	errScanFailed        = "scan_failed"
+	errDeadlineExceeded  = "deadline_exceeded"
+	errDeliveryForbidden = "delivery_forbidden"
+	errExtractionFailed  = "extraction_failed"
+	errDeliveryFailed    = "delivery_failed"
+	errShuttingDown      = "shutting_down"
+	errNotFound          = "not_found"
+)
+
+// serveErrorBody is the JSON body of error responses. Retryable tells
+// clients whether the same request may succeed later; Page and Stage name
+// the page that failed the extraction and where, when one did (see
+// PageError).
+type serveErrorBody struct {
+	Error struct {
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		Retryable bool   `json:"retryable"`
+		Page      int    `json:"page,omitempty"`
+		Stage     string `json:"stage,omitempty"`
+	} `json:"error"`
+}
+
+// ocrServer serves the OCR REST API: POST /ocr extracts an uploaded PDF
+// with the settings of config, overridden per request by the query, GET
+// /jobs/{id}/pages/{n} returns one page of an extracted document, DELETE
+// /jobs/{id} drops it and GET /healthz reports that the server is up.
+type ocrServer struct {
+	config    OCRConfig
+	maxUpload int64
+	policy    uploadPolicy  // checks of uploads besides their size
+	scheduler *jobScheduler // one slot per document extracted at a time
+	s3Roots   []s3Location  // where requests may have results delivered
+	sandbox   *Sandbox      // runs the extractions, unless nil
+	jobs      *jobStore     // pages of the documents extracted, for the job endpoints
+}
+
+// ServerOptions configures NewServer. The lists take the values of the
+// options of the serve command.
+type ServerOptions struct {
+	Jobs       int      // documents extracted at a time (0 for one per CPU)
+	MaxUpload  int64    // largest upload in bytes (0 for 100 MB)
+	AllowTypes string   // upload types accepted, e.g. "pdf,png" ("" for all)
+	Clamd      []string // clamd addresses (host:port or a socket) scanning every upload
+	ICAP       []string // ICAP services (icap://host/service) scanning every upload
+	S3         []string // s3://bucket/prefix locations results may be delivered under
+	Sandbox    *Sandbox // extracts the documents, unless nil
+
+	// How long the pages of a document are kept for GET /jobs (0 for an
+	// hour, negative to keep none), and the age at which the janitor
+	// removes the scratch files killed runs, such as sandbox workers past
+	// their timeout, leave in the temporary directory (0 for a day,
+	// negative to leave them). Uploads are only held during their request.
+	JobTTL     time.Duration
+	ScratchTTL time.Duration
+	// Estimated bytes the kept jobs may take, the oldest dropped before
+	// they expire beyond it (0 for 256 MB)
+	JobMemory int64
+}
+
+// Server is the handler of the OCR REST API returned by NewServer. Close
+// stops its janitor.
+type Server struct {
+	handler http.Handler
+	stop    chan struct{}
+	once    sync.Once
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+// Close stops the janitor of the server.
+func (s *Server) Close() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// NewServer returns the handler of the OCR REST API: POST /ocr extracts an
+// uploaded document with the settings of config, overridden per request
+// by the query, GET /jobs/{id}/pages/{n} returns one page of it afterwards,
+// DELETE /jobs/{id} drops it and GET /healthz reports that the server is
+// up. Requests beyond opts.Jobs wait for a slot, the earliest deadline
+// first. A janitor expires the jobs and stale scratch files until Close.
+func NewServer(config OCRConfig, opts ServerOptions) (*Server, error) {
+	if opts.Jobs <= 0 {
+		opts.Jobs = runtime.NumCPU()
+	}
+	if opts.MaxUpload <= 0 {
+		opts.MaxUpload = defaultMaxUpload << 20
+	}
+	if opts.JobTTL == 0 {
+		opts.JobTTL = defaultJobTTL
+	}
+	if opts.JobMemory <= 0 {
+		opts.JobMemory = defaultJobMemory
+	}
+	if opts.ScratchTTL == 0 {
+		opts.ScratchTTL = defaultScratchTTL
+	}
+	var policy uploadPolicy
+	types, err := parseMediaTypes(opts.AllowTypes)
+	if err != nil {
+		return nil, err
+	}
+	policy.mediaTypes = types
+	for _, address := range opts.Clamd {
+		scanner, err := newClamdScanner(address)
+		if err != nil {
+			return nil, err
+		}
+		policy.scanners = append(policy.scanners, scanner)
+	}
+	for _, service := range opts.ICAP {
+		scanner, err := newICAPScanner(service)
+		if err != nil {
+			return nil, err
+		}
+		policy.scanners = append(policy.scanners, scanner)
+	}
+	var s3Roots []s3Location
+	for _, list := range opts.S3 {
+		locs, err := parseS3Locations(list)
+		if err != nil {
+			return nil, err
+		}
+		s3Roots = append(s3Roots, locs...)
+	}
+	if len(s3Roots) > 0 {
+		if err := checkS3(); err != nil {
+			return nil, err
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	s := &ocrServer{config: config, maxUpload: opts.MaxUpload, policy: policy, scheduler: newJobScheduler(opts.Jobs),
+		s3Roots: s3Roots, sandbox: opts.Sandbox, jobs: newJobStore(opts.JobTTL, opts.JobMemory)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ocr", s.handleOCR)
+	mux.HandleFunc("/jobs/", s.handleJob)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	server := &Server{handler: mux, stop: make(chan struct{})}
+	go s.janitor(opts.ScratchTTL, server.stop)
+	return server, nil
+}
+
+// handleOCR extracts the PDF uploaded as the "file" field of a
+// multipart/form-data request, once it has passed the upload policy. The
+// query may set lang, format (text, json, hocr, alto, md or tsv), pages
+// (as -pages) and deadline, a duration from now such as 90s or an RFC 3339
+// time. A document whose deadline cannot be met at full quality is
+// degraded (see degradeForDeadline), which the X-Degraded header and
+// DocumentManifest.Degraded report. The result is compressed as the
+// Accept-Encoding header allows.
+//
+// The pages of the document are kept as a job, unless the server keeps
+// none, under the ID of the X-Job-ID header, for GET /jobs/{id}/pages/{n}
+// (see handleJob); boxes=true records their word boxes, as format json
+// does.
+//
+// With s3, an s3://bucket/prefix under one of the server's -s3 locations,
+// the result is uploaded there in place of the response, which describes
+// it with an s3Delivery; presign, a duration, adds a download URL valid
+// that long, and on its own delivers to a new prefix under the first -s3
+// location.
+func (s *ocrServer) handleOCR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeServeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed, errors.New("use POST to upload a PDF"), false)
+		return
+	}
+	config := s.config
+	query := r.URL.Query()
+	if lang := query.Get("lang"); lang != "" {
+		config.Language = lang
+	}
+	format := strings.ToLower(query.Get("format"))
+	switch format {
+	case "", FormatText:
+		format = FormatText
+	case FormatJSON, FormatALTO, FormatTSV:
+		config.WordBoxes = true
+	case FormatHOCR:
+		config.HOCR = true
+	case FormatMarkdown:
+		config.Markdown = true
+	default:
+		writeServeError(w, http.StatusBadRequest, errUnsupportedFormat,
+			fmt.Errorf("unsupported output format %q (use text, json, hocr, alto, md or tsv)", format), false)
+		return
+	}
+	if query.Get("boxes") == "true" {
+		config.WordBoxes = true
+	}
+	config.Thumbnails = s.jobs.ttl > 0
+	if spec := query.Get("pages"); spec != "" {
+		set, err := ParsePageSet(spec)
+		if err != nil {
+			writeServeError(w, http.StatusBadRequest, errInvalidRequest, err, false)
+			return
+		}
+		config.PageSelection = set
+	}
+	var deadline time.Time
+	if spec := query.Get("deadline"); spec != "" {
+		var err error
+		if deadline, err = parseDeadline(spec, time.Now()); err != nil {
+			writeServeError(w, http.StatusBadRequest, errInvalidRequest, err, false)
+			return
+		}
+	}
+	dest, presign, ok := s.deliveryTarget(w, query)
+	if !ok {
+		return
+	}
+	if err := config.Validate(); err != nil {
+		writeServeError(w, http.StatusBadRequest, errInvalidRequest, err, false)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	if err := r.ParseMultipartForm(s.maxUpload); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeServeError(w, http.StatusRequestEntityTooLarge, errUploadTooLarge,
+				fmt.Errorf("the upload exceeds %d MB", s.maxUpload>>20), false)
+			return
+		}
+		writeServeError(w, http.StatusBadRequest, errInvalidRequest, errors.New("expected a multipart/form-data upload with the PDF in field \"file\""), false)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, errInvalidRequest, errors.New("missing PDF upload in field \"file\""), false)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, errInvalidRequest, fmt.Errorf("error reading upload: %v", err), false)
+		return
+	}
+	if rejection := s.policy.check(r.Context(), data); rejection != nil {
+		writeServeError(w, rejection.status, rejection.code, errors.New(rejection.message), rejection.retryable)
+		return
+	}
+
+	if err := s.scheduler.acquire(r.Context(), deadline); err != nil {
+		if errors.Is(err, errDeadlinePassed) {
+			writeServeError(w, http.StatusGatewayTimeout, errDeadlineExceeded, err, false)
+			return
+		}
+		// The server is stopping, or else the client is gone
+		writeServeError(w, http.StatusServiceUnavailable, errShuttingDown, errors.New("the server is shutting down"), true)
+		return
+	}
+	defer s.scheduler.release()
+	var degraded []string
+	factor := 1.0
+	if !deadline.IsZero() {
+		degraded, factor = s.scheduler.degradeForDeadline(&config, documentPages(data, header.Filename, config, s.sandbox != nil), time.Until(deadline))
+	}
+	started := time.Now()
+	var text string
+	var manifest DocumentManifest
+	if s.sandbox != nil {
+		text, manifest, err = s.sandbox.ExtractData(r.Context(), data, filepath.Base(header.Filename), config)
+	} else {
+		text, manifest, err = extractPDFData(r.Context(), data, filepath.Base(header.Filename), config, 0)
+	}
+	if errors.Is(err, context.Canceled) {
+		// The server is stopping, or else the client is gone
+		writeServeError(w, http.StatusServiceUnavailable, errShuttingDown, errors.New("the server is shutting down"), true)
+		return
+	}
+	if err != nil {
+		writeServeError(w, http.StatusUnprocessableEntity, errExtractionFailed, err, false)
+		return
+	}
+	s.scheduler.observe(len(manifest.PageResults), time.Since(started), factor)
+	manifest.Degraded = degraded
+
+	var body []byte
+	contentType, ext := "text/plain; charset=utf-8", ".txt"
+	switch format {
+	case FormatJSON:
+		body, err = DocumentJSON(manifest, config)
+		contentType, ext = "application/json", ".json"
+	case FormatHOCR:
+		body = DocumentHOCR(manifest, config)
+		contentType, ext = "application/xhtml+xml", ".html"
+	case FormatALTO:
+		body, err = DocumentALTO(manifest, config)
+		contentType, ext = "application/xml", ".xml"
+	case FormatMarkdown:
+		body, err = FormatOutput(DocumentMarkdown(manifest), config)
+		contentType, ext = "text/markdown; charset=utf-8", ".md"
+	case FormatTSV:
+		body = DocumentTSV(manifest, config)
+		contentType, ext = "text/tab-separated-values; charset=utf-8", ".tsv"
+	default:
+		body, err = FormatOutput(text, config)
+	}
+	if err != nil {
+		writeServeError(w, http.StatusInternalServerError, errExtractionFailed, err, false)
+		return
+	}
+	if s.jobs.ttl > 0 {
+		w.Header().Set("X-Job-ID", s.jobs.add(manifest.PageResults, time.Now()))
+	}
+	if dest != nil {
+		name := strings.TrimSuffix(filepath.Base(header.Filename), filepath.Ext(header.Filename)) + ext
+		s.deliver(w, r, *dest, name, body, contentType, presign, manifest)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Pages", strconv.Itoa(manifest.Pages))
+	if len(degraded) > 0 {
+		w.Header().Set("X-Degraded", strings.Join(degraded, ","))
+	}
+	if manifest.Truncated {
+		w.Header().Set("X-Truncated", "true")
+	}
+	writeResult(w, r, body)
+}
+
+// writeResult writes the result body of r, compressed with gzip or zstd
+// when its Accept-Encoding takes them: OCR output is mostly text and
+// shrinks several times over.
+func writeResult(w http.ResponseWriter, r *http.Request, body []byte) {
+	w.Header().Add("Vary", "Accept-Encoding")
+	if encoding := responseEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+		compressed, err := compressData(body, encoding, "")
+		if err == nil {
+			w.Header().Set("Content-Encoding", encoding)
+			body = compressed
+		} else {
+			warnf("Warning: error compressing a response with %s: %v\n", encoding, err)
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
+
+// deliveryTarget reads the s3 and presign query parameters, answering
+// requests that may not use them; dest is nil for results in the response.
+func (s *ocrServer) deliveryTarget(w http.ResponseWriter, query url.Values) (dest *s3Location, presign time.Duration, ok bool) {
+	spec, expiry := query.Get("s3"), query.Get("presign")
+	if spec == "" && expiry == "" {
+		return nil, 0, true
+	}
+	if len(s.s3Roots) == 0 {
+		writeServeError(w, http.StatusForbidden, errDeliveryForbidden, errors.New("this server does not deliver results to S3"), false)
+		return nil, 0, false
+	}
+	if expiry != "" {
+		var err error
+		if presign, err = time.ParseDuration(expiry); err != nil || presign <= 0 || presign > maxPresignExpiry {
+			writeServeError(w, http.StatusBadRequest, errInvalidRequest,
+				fmt.Errorf("invalid presign duration %q (use e.g. 1h, at most 168h)", expiry), false)
+			return nil, 0, false
+		}
+	}
+	if spec == "" {
+		loc := s.s3Roots[0]
+		loc.Prefix += newRunID() + "/"
+		return &loc, presign, true
+	}
+	loc, err := parseS3URL(spec)
+	if err != nil {
+		writeServeError(w, http.StatusBadRequest, errInvalidRequest, err, false)
+		return nil, 0, false
+	}
+	for _, root := range s.s3Roots {
+		if loc.within(root) {
+			return &loc, presign, true
+		}
+	}
+	writeServeError(w, http.StatusForbidden, errDeliveryForbidden, fmt.Errorf("results may not be delivered to %s", loc), false)
+	return nil, 0, false
+}
+
+// deliver uploads a result to dest and answers with its s3Delivery.
+func (s *ocrServer) deliver(w http.ResponseWriter, r *http.Request, dest s3Location, name string, body []byte, contentType string, presign time.Duration, manifest DocumentManifest) {
+	key := dest.key(name)
+	if err := uploadS3(r.Context(), dest.Bucket, key, body, contentType); err != nil {
+		writeServeError(w, http.StatusBadGateway, errDeliveryFailed, err, true)
+		return
+	}
+	resp := s3Delivery{
+		Location:    "s3://" + dest.Bucket + "/" + key,
+		Bytes:       len(body),
+		ContentType: contentType,
+		Pages:       manifest.Pages,
+		Degraded:    manifest.Degraded,
+		Truncated:   manifest.Truncated,
+	}
+	if presign > 0 {
+		now := time.Now()
+		expires := now.Add(presign).UTC().Truncate(time.Second)
+		resp.URL, resp.Expires = presignS3(dest.Bucket, key, presign, now), &expires
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Pages", strconv.Itoa(manifest.Pages))
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseDeadline reads the deadline query parameter: a duration from now or
+// an RFC 3339 time.
+func parseDeadline(spec string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(spec); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, spec); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid deadline %q (use a duration such as 90s or an RFC 3339 time)", spec)
+}
+
+// documentPages returns the number of pages of an uploaded document that
+// config selects, or 0 when it cannot be opened. With sandboxed set the
+// renderer is kept out of the server process: PDFs are counted by the Go
+// parser instead, and images not at all.
+func documentPages(data []byte, name string, config OCRConfig, sandboxed bool) int {
+	if sandboxed {
+		if !isPDFData(data) {
+			return 0
+		}
+		pdf, err := parseRawPDF(data)
+		if err != nil {
+			return 0
+		}
+		return len(config.PageSelection.pageNums(len(pdf.pages())))
+	}
+	src, err := openPDFSource(data, name, config.Renderer)
+	if err != nil {
+		return 0
+	}
+	defer src.Close()
+	return len(config.PageSelection.pageNums(src.doc.NumPage()))
+}
+
+// handleHealth answers health checks with the tool version.
+func (s *ocrServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeServeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed, errors.New("use GET"), false)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok", "version": Version()})
+}
+
+// writeServeError sends an error response with a serveErrorBody for err,
+// with the page and stage of the *PageError it wraps, if any.
+func writeServeError(w http.ResponseWriter, status int, code string, err error, retryable bool) {
+	var body serveErrorBody
+	body.Error.Code, body.Error.Message, body.Error.Retryable = code, err.Error(), retryable
+	var pageErr *PageError
+	if errors.As(err, &pageErr) {
+		body.Error.Page, body.Error.Stage = pageErr.Page, pageErr.Stage
+	}
+	if retryable {
+		w.Header().Set("Retry-After", "30")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,272 @@
+package pdfocr_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"ocr-tool/pdfocr"
+	"ocr-tool/pdfocr/testsupport"
+)
+
+// uploadRequest returns a request to the server uploading fixture to
+// target, a path with its query.
+func uploadRequest(t *testing.T, target string, fixture testsupport.Fixture) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", "fixture.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(fixture.Upload())
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, target, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestServeCompression(t *testing.T) {
+	handler, err := pdfocr.NewServer(testsupport.Config(), pdfocr.ServerOptions{Jobs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}, {OCR: "Scanned invoice 42"}}}
+	_, haveZstd := exec.LookPath("zstd")
+	zstdOrGzip := "gzip"
+	if haveZstd == nil {
+		zstdOrGzip = "zstd"
+	}
+	tests := []struct {
+		accept   string
+		encoding string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"br;q=1.0, gzip;q=0.5", "gzip"},
+		{"gzip;q=0", ""},
+		{"*;q=0", ""},
+		{"zstd, gzip;q=0.5", zstdOrGzip},
+		{"gzip, zstd", zstdOrGzip},
+		{"zstd;q=0.2, gzip", "gzip"},
+	}
+	for _, tt := range tests {
+		r := uploadRequest(t, "/ocr?format=json", fixture)
+		if tt.accept != "" {
+			r.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Accept-Encoding %q: status %d: %s", tt.accept, w.Code, w.Body)
+		}
+		if got := w.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("Accept-Encoding %q: Content-Encoding %q, want %q", tt.accept, got, tt.encoding)
+			continue
+		}
+		if !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+			t.Errorf("Accept-Encoding %q: Vary %q lacks Accept-Encoding", tt.accept, w.Header().Get("Vary"))
+		}
+		body := w.Body.Bytes()
+		switch tt.encoding {
+		case "gzip":
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body, err = io.ReadAll(zr); err != nil {
+				t.Fatal(err)
+			}
+		case "zstd":
+			cmd := exec.Command("zstd", "-d", "-q", "-c")
+			cmd.Stdin = bytes.NewReader(body)
+			if body, err = cmd.Output(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if !bytes.Contains(body, []byte("Scanned invoice 42")) || !bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+			t.Errorf("Accept-Encoding %q: body is not the JSON result:\n%.200s", tt.accept, body)
+		}
+	}
+}
+
+func TestServeJobPages(t *testing.T) {
+	handler, err := pdfocr.NewServer(testsupport.Config(), pdfocr.ServerOptions{Jobs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}, {OCR: "Scanned invoice 42"}}}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, uploadRequest(t, "/ocr?boxes=true", fixture))
+	id := w.Header().Get("X-Job-ID")
+	if w.Code != http.StatusOK || id == "" {
+		t.Fatalf("POST /ocr: status %d, job %q: %s", w.Code, id, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/"+id+"/pages/2", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET page 2: status %d: %s", w.Code, w.Body)
+	}
+	var page struct {
+		Page       int               `json:"page"`
+		Method     string            `json:"method"`
+		Text       string            `json:"text"`
+		Confidence float64           `json:"confidence"`
+		Words      []pdfocr.PageWord `json:"words"`
+		Thumbnail  []byte            `json:"thumbnail"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Page != 2 || page.Method != pdfocr.MethodOCR || strings.TrimSpace(page.Text) != "Scanned invoice 42" || page.Confidence != 95 {
+		t.Errorf("page 2 = %d, %q, %q, confidence %v", page.Page, page.Method, page.Text, page.Confidence)
+	}
+	if len(page.Words) != 3 || page.Words[0].Text != "Scanned" || page.Words[0].Box[2] <= page.Words[0].Box[0] {
+		t.Errorf("page 2 words = %+v", page.Words)
+	}
+	thumbnail, err := png.Decode(bytes.NewReader(page.Thumbnail))
+	if err != nil {
+		t.Fatalf("thumbnail: %v", err)
+	}
+	if width := thumbnail.Bounds().Dx(); width < 150 || width > 250 {
+		t.Errorf("thumbnail is %d pixels wide, want about 200", width)
+	}
+
+	for _, tt := range []struct {
+		method, target string
+		status         int
+	}{
+		{http.MethodGet, "/jobs/" + id + "/pages/1", http.StatusOK},
+		{http.MethodGet, "/jobs/" + id + "/pages/3", http.StatusNotFound},
+		{http.MethodGet, "/jobs/" + id + "/pages/two", http.StatusBadRequest},
+		{http.MethodGet, "/jobs/0123/pages/1", http.StatusNotFound},
+		{http.MethodGet, "/jobs/" + id + "/boxes/1", http.StatusNotFound},
+		{http.MethodPost, "/jobs/" + id + "/pages/1", http.StatusMethodNotAllowed},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, w.Code, tt.status)
+		}
+	}
+}
+
+func TestServeJobRetention(t *testing.T) {
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}}}
+	serve := func(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	handler, err := pdfocr.NewServer(testsupport.Config(), pdfocr.ServerOptions{Jobs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	id := serve(handler, uploadRequest(t, "/ocr", fixture)).Header().Get("X-Job-ID")
+	for _, tt := range []struct {
+		method, target string
+		status         int
+	}{
+		{http.MethodGet, "/jobs/" + id, http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/jobs/" + id, http.StatusNoContent},
+		{http.MethodGet, "/jobs/" + id + "/pages/1", http.StatusNotFound},
+		{http.MethodDelete, "/jobs/" + id, http.StatusNotFound},
+	} {
+		if w := serve(handler, httptest.NewRequest(tt.method, tt.target, nil)); w.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, w.Code, tt.status)
+		}
+	}
+
+	short, err := pdfocr.NewServer(testsupport.Config(), pdfocr.ServerOptions{Jobs: 1, JobTTL: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer short.Close()
+	id = serve(short, uploadRequest(t, "/ocr", fixture)).Header().Get("X-Job-ID")
+	time.Sleep(10 * time.Millisecond)
+	if w := serve(short, httptest.NewRequest(http.MethodGet, "/jobs/"+id+"/pages/1", nil)); w.Code != http.StatusNotFound {
+		t.Errorf("expired job: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	none, err := pdfocr.NewServer(testsupport.Config(), pdfocr.ServerOptions{Jobs: 1, JobTTL: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer none.Close()
+	if w := serve(none, uploadRequest(t, "/ocr", fixture)); w.Code != http.StatusOK || w.Header().Get("X-Job-ID") != "" {
+		t.Errorf("server keeping no jobs: status %d, job %q", w.Code, w.Header().Get("X-Job-ID"))
+	}
+}
+
+func TestServeErrorPage(t *testing.T) {
+	config := testsupport.Config()
+	config.ErrorPolicy = pdfocr.ErrorPolicyFailFast
+	handler, err := pdfocr.NewServer(config, pdfocr.ServerOptions{Jobs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}, {TextError: "broken content stream"}}}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, uploadRequest(t, "/ocr", fixture))
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+			Page    int    `json:"page"`
+			Stage   string `json:"stage"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("status %d: %v: %s", w.Code, err, w.Body)
+	}
+	e := body.Error
+	if w.Code != http.StatusUnprocessableEntity || e.Code != "extraction_failed" || e.Page != 2 || e.Stage != pdfocr.PageErrorText || !strings.Contains(e.Message, "broken content stream") {
+		t.Errorf("status %d, error %+v, want page 2 failing at stage %s", w.Code, e, pdfocr.PageErrorText)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ocr", nil))
+	if strings.Contains(w.Body.String(), `"page"`) || strings.Contains(w.Body.String(), `"stage"`) {
+		t.Errorf("error without a page: body %s", w.Body)
+	}
+}
+
+func TestServeCanceledWait(t *testing.T) {
+	handler, err := pdfocr.NewServer(testsupport.Config(), pdfocr.ServerOptions{Jobs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, uploadRequest(t, "/ocr", testsupport.Fixture{Pages: []testsupport.FixturePage{{Text: nativeText}}}).WithContext(ctx))
+	var body struct {
+		Error struct {
+			Code      string `json:"code"`
+			Retryable bool   `json:"retryable"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("status %d: %v: %s", w.Code, err, w.Body)
+	}
+	if w.Code != http.StatusServiceUnavailable || body.Error.Code != "shutting_down" || !body.Error.Retryable {
+		t.Errorf("status %d, error %+v, want a retryable 503 shutting_down", w.Code, body.Error)
+	}
+}
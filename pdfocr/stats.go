@@ -0,0 +1,147 @@
+package pdfocr
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextStats are statistics of the text of a document, with OCRConfig.Stats,
+// for cataloging collections.
+type TextStats struct {
+	Words       int          `json:"words"`
+	Characters  int          `json:"characters"` // not counting whitespace
+	Sentences   int          `json:"sentences"`
+	Language    string       `json:"language,omitempty"`    // estimated from common words, e.g. "eng" or "eng+swa"
+	Readability *Readability `json:"readability,omitempty"` // English text only
+	TopTerms    []TermCount  `json:"top_terms,omitempty"`
+}
+
+// Readability holds the Flesch readability scores of an English text,
+// from its words per sentence and estimated syllables per word.
+type Readability struct {
+	ReadingEase float64 `json:"flesch_reading_ease"`  // about 0 (very hard) to 100 (very easy)
+	Grade       float64 `json:"flesch_kincaid_grade"` // US school grade
+}
+
+// TermCount is a frequent term of a text.
+type TermCount struct {
+	Term  string `json:"term"`
+	Count int    `json:"count"`
+}
+
+const (
+	maxTopTerms   = 10
+	minTermLength = 3 // letters
+)
+
+// textStatsCounter accumulates the statistics of a document page by page,
+// so streamed extractions need not keep the text. A nil counter ignores
+// the pages.
+type textStatsCounter struct {
+	words, characters, sentences, syllables int
+	counts                                  map[string]int // lower-case words
+}
+
+func newTextStatsCounter() *textStatsCounter {
+	return &textStatsCounter{counts: map[string]int{}}
+}
+
+// add counts the text of a page. Words are the whitespace-separated
+// fields holding a letter or digit; a sentence ends with a word ending in
+// '.', '!' or '?', closing quotes and brackets aside.
+func (c *textStatsCounter) add(text string) {
+	if c == nil {
+		return
+	}
+	for _, f := range strings.Fields(text) {
+		c.characters += utf8.RuneCountInString(f)
+		word := strings.TrimFunc(f, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if word == "" {
+			continue
+		}
+		c.words++
+		c.syllables += countSyllables(word)
+		if end := strings.TrimRight(f, `"')]}»”’`); strings.HasSuffix(end, ".") || strings.HasSuffix(end, "!") || strings.HasSuffix(end, "?") {
+			c.sentences++
+		}
+		for _, w := range strings.FieldsFunc(strings.ToLower(word), func(r rune) bool { return !unicode.IsLetter(r) }) {
+			c.counts[w]++
+		}
+	}
+}
+
+// stats returns the statistics of the pages counted, or nil for a nil
+// counter. Text without sentence punctuation counts as one sentence.
+func (c *textStatsCounter) stats() *TextStats {
+	if c == nil {
+		return nil
+	}
+	s := &TextStats{Words: c.words, Characters: c.characters, Sentences: c.sentences}
+	if s.Words == 0 {
+		return s
+	}
+	s.Sentences = max(s.Sentences, 1)
+
+	languages := make([]string, 0, len(commonWords))
+	for l := range commonWords {
+		languages = append(languages, l)
+	}
+	sort.Strings(languages)
+	s.Language = rankLanguages(c.counts, languages)
+	if s.Language == "eng" || strings.HasPrefix(s.Language, "eng+") {
+		wordsPerSentence := float64(s.Words) / float64(s.Sentences)
+		syllablesPerWord := float64(c.syllables) / float64(s.Words)
+		round := func(v float64) float64 { return math.Round(v*10) / 10 }
+		s.Readability = &Readability{
+			ReadingEase: round(206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord),
+			Grade:       round(0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59),
+		}
+	}
+	s.TopTerms = topTerms(c.counts)
+	return s
+}
+
+// topTerms returns the maxTopTerms most frequent words of at least
+// minTermLength letters, leaving out the common words of every language.
+func topTerms(counts map[string]int) []TermCount {
+	common := map[string]bool{}
+	for _, words := range commonWords {
+		for _, w := range words {
+			common[w] = true
+		}
+	}
+	var terms []TermCount
+	for w, n := range counts {
+		if utf8.RuneCountInString(w) >= minTermLength && !common[w] {
+			terms = append(terms, TermCount{w, n})
+		}
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Term < terms[j].Term
+	})
+	return terms[:min(len(terms), maxTopTerms)]
+}
+
+// countSyllables estimates the syllables of an English word from its
+// groups of vowels, a final silent e aside; every word has at least one.
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	n, inVowels := 0, false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !inVowels {
+			n++
+		}
+		inVowels = vowel
+	}
+	if n > 1 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") {
+		n--
+	}
+	return max(n, 1)
+}
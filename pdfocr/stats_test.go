@@ -0,0 +1,29 @@
+package pdfocr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTextStats(t *testing.T) {
+	c := newTextStatsCounter()
+	c.add("The cat sat on the mat. The cat ran!")
+	c.add("Is the cat on the mat?")
+	want := &TextStats{
+		Words: 15, Characters: 45, Sentences: 3, Language: "eng",
+		Readability: &Readability{ReadingEase: 117.2, Grade: -1.8},
+		TopTerms:    []TermCount{{"cat", 3}, {"mat", 2}, {"ran", 1}, {"sat", 1}},
+	}
+	if got := c.stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("stats() = %+v, want %+v", got, want)
+	}
+	if got := (*textStatsCounter)(nil).stats(); got != nil {
+		t.Errorf("stats() of a nil counter = %+v, want nil", got)
+	}
+
+	for word, want := range map[string]int{"readability": 5, "table": 2, "make": 1, "rhythm": 1, "7": 1} {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
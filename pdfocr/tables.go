@@ -0,0 +1,245 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Table is a table found on a page with OCRConfig.Tables: text aligned in
+// columns over several consecutive lines.
+type Table struct {
+	Box  [4]float64 `json:"box"`  // x0, y0, x1, y1 in points from the top-left corner of the page
+	Rows [][]string `json:"rows"` // cell texts by row and column, "" for empty cells
+}
+
+// Thresholds of detectTables, the gaps in multiples of the text height.
+const (
+	tableCellGap      = 1.5 // horizontal gap between two cells of a line
+	tableRowGap       = 1.5 // vertical gap between two lines of a table
+	tableMinRows      = 3
+	tableMaxCellWords = 5 // mean words per cell above which aligned lines are text columns
+)
+
+// tablePiece is a positioned piece of page text, a word or a text line, in
+// points.
+type tablePiece struct {
+	text           string
+	x0, y0, x1, y1 float64
+}
+
+type tableCell struct {
+	text   string
+	x0, x1 float64
+	words  int
+}
+
+type tableRow struct {
+	y0, y1 float64
+	height float64 // mean height of its pieces
+	cells  []tableCell
+}
+
+// wordTablePieces returns the words of a page as table pieces.
+func wordTablePieces(words []PageWord) []tablePiece {
+	pieces := make([]tablePiece, 0, len(words))
+	for _, w := range words {
+		pieces = append(pieces, tablePiece{w.Text, w.Box[0], w.Box[1], w.Box[2], w.Box[3]})
+	}
+	return pieces
+}
+
+// lineTablePieces returns the text layer lines of a page as table pieces;
+// PDF producers usually lay out every table cell as a line of its own.
+func lineTablePieces(lines []TextLine) []tablePiece {
+	pieces := make([]tablePiece, 0, len(lines))
+	for _, l := range lines {
+		pieces = append(pieces, tablePiece{l.Text, l.X0, l.Y0, l.X1, l.Y1})
+	}
+	return pieces
+}
+
+// tableRows assembles pieces into lines as wordLines does, top to bottom,
+// and splits every line into cells at wide horizontal gaps.
+func tableRows(pieces []tablePiece) []tableRow {
+	sorted := append([]tablePiece(nil), pieces...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].y0 < sorted[j].y0 })
+
+	type line struct {
+		y0, y1 float64
+		pieces []tablePiece
+	}
+	var lines []*line
+	for _, p := range sorted {
+		if strings.TrimSpace(p.text) == "" {
+			continue
+		}
+		var target *line
+		for _, l := range lines {
+			overlap := math.Min(l.y1, p.y1) - math.Max(l.y0, p.y0)
+			if overlap*2 >= math.Min(l.y1-l.y0, p.y1-p.y0) {
+				target = l
+				break
+			}
+		}
+		if target == nil {
+			target = &line{y0: p.y0, y1: p.y1}
+			lines = append(lines, target)
+		}
+		target.y0, target.y1 = math.Min(target.y0, p.y0), math.Max(target.y1, p.y1)
+		target.pieces = append(target.pieces, p)
+	}
+
+	rows := make([]tableRow, 0, len(lines))
+	for _, l := range lines {
+		sort.SliceStable(l.pieces, func(i, j int) bool { return l.pieces[i].x0 < l.pieces[j].x0 })
+		row := tableRow{y0: l.y0, y1: l.y1}
+		for _, p := range l.pieces {
+			row.height += (p.y1 - p.y0) / float64(len(l.pieces))
+		}
+		for i, p := range l.pieces {
+			words := len(strings.Fields(p.text))
+			if i > 0 && p.x0-row.cells[len(row.cells)-1].x1 <= tableCellGap*row.height {
+				c := &row.cells[len(row.cells)-1]
+				c.text += " " + p.text
+				c.x1 = math.Max(c.x1, p.x1)
+				c.words += words
+				continue
+			}
+			row.cells = append(row.cells, tableCell{text: p.text, x0: p.x0, x1: p.x1, words: words})
+		}
+		rows = append(rows, row)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].y0 < rows[j].y0 })
+	return rows
+}
+
+// detectTables finds the tables of a page (see tableRuns).
+func detectTables(pieces []tablePiece) []Table {
+	var tables []Table
+	for _, run := range tableRuns(tableRows(pieces)) {
+		tables = append(tables, run.table)
+	}
+	return tables
+}
+
+// tableRun is a table found in rows[start:end].
+type tableRun struct {
+	start, end int
+	table      Table
+}
+
+// tableRuns finds the tables among rows: runs of at least tableMinRows
+// close lines of two or more cells whose cells line up in at least two
+// columns. Runs of long cells are text columns rather than tables.
+func tableRuns(rows []tableRow) []tableRun {
+	var runs []tableRun
+	for start := 0; start < len(rows); {
+		end := start
+		for end < len(rows) && len(rows[end].cells) >= 2 &&
+			(end == start || rows[end].y0-rows[end-1].y1 <= tableRowGap*math.Max(rows[end].height, rows[end-1].height)) {
+			end++
+		}
+		if end-start >= tableMinRows {
+			if t, ok := buildTable(rows[start:end]); ok {
+				runs = append(runs, tableRun{start, end, t})
+			}
+		}
+		start = max(end, start+1)
+	}
+	return runs
+}
+
+// buildTable lays out the cells of a run of lines in columns, the spans of
+// the cells merged where they overlap, or reports that they do not form a
+// table.
+func buildTable(rows []tableRow) (Table, bool) {
+	type span struct{ x0, x1 float64 }
+	var spans []span
+	cells, words := 0, 0
+	for _, r := range rows {
+		for _, c := range r.cells {
+			spans = append(spans, span{c.x0, c.x1})
+			cells++
+			words += c.words
+		}
+	}
+	if float64(words)/float64(cells) > tableMaxCellWords {
+		return Table{}, false
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].x0 < spans[j].x0 })
+	var columns []span
+	for _, s := range spans {
+		if n := len(columns); n > 0 && s.x0 <= columns[n-1].x1 {
+			columns[n-1].x1 = math.Max(columns[n-1].x1, s.x1)
+			continue
+		}
+		columns = append(columns, s)
+	}
+	if len(columns) < 2 {
+		return Table{}, false
+	}
+
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	t := Table{Box: [4]float64{round(columns[0].x0), round(rows[0].y0), round(columns[len(columns)-1].x1), round(rows[len(rows)-1].y1)}}
+	for _, r := range rows {
+		row := make([]string, len(columns))
+		for _, c := range r.cells {
+			center := (c.x0 + c.x1) / 2
+			for i, col := range columns {
+				if center >= col.x0 && center <= col.x1 {
+					row[i] = strings.TrimSpace(row[i] + " " + c.text)
+					break
+				}
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t, true
+}
+
+// TableFileName returns the name of the CSV file of the k-th table (1-based)
+// of a page.
+func TableFileName(page, k int) string {
+	return fmt.Sprintf("page_%d_table_%d.csv", page, k)
+}
+
+// tableFiles lays out the tables of the processed pages of a document as one
+// CSV file per table.
+func tableFiles(manifest DocumentManifest) ([]outputFile, error) {
+	var files []outputFile
+	for _, p := range manifest.PageResults {
+		for k, t := range p.Tables {
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			if err := w.WriteAll(t.Rows); err != nil {
+				return nil, fmt.Errorf("error encoding table %d of page %d: %w", k+1, p.Page, err)
+			}
+			files = append(files, outputFile{Name: TableFileName(p.Page, k+1), Data: buf.Bytes()})
+		}
+	}
+	return files, nil
+}
+
+// WriteTablesDir writes the tables found in a document (see tableFiles) to
+// dir, as page_<n>_table_<k>.csv.
+func WriteTablesDir(dir string, manifest DocumentManifest) error {
+	files, err := tableFiles(manifest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating tables directory: %w", err)
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.Name), f.Data, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
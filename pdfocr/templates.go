@@ -0,0 +1,295 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// fingerprintSize is the side of the grid a page is reduced to for its
+// fingerprint.
+const fingerprintSize = 16
+
+// fingerprintDPI is enough resolution to average a page onto the grid.
+const fingerprintDPI = 50
+
+// templateMatchMin is the fingerprint similarity a page needs to be treated
+// as an instance of a template.
+const templateMatchMin = 0.9
+
+// pageFingerprint is the ink density of a page on a coarse grid, row by row.
+// Pages with the same layout have correlated densities regardless of scan
+// resolution, contrast or the text filled in.
+type pageFingerprint [fingerprintSize * fingerprintSize]uint8
+
+// fingerprintImage computes the fingerprint of a page image.
+func fingerprintImage(img image.Image) pageFingerprint {
+	b := img.Bounds()
+	var sum [fingerprintSize * fingerprintSize]float64
+	var count [fingerprintSize * fingerprintSize]int
+	if b.Dx() > 0 && b.Dy() > 0 {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			row := (y - b.Min.Y) * fingerprintSize / b.Dy()
+			for x := b.Min.X; x < b.Max.X; x++ {
+				cell := row*fingerprintSize + (x-b.Min.X)*fingerprintSize/b.Dx()
+				sum[cell] += float64(255 - color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+				count[cell]++
+			}
+		}
+	}
+	var fp pageFingerprint
+	for i := range fp {
+		if count[i] > 0 {
+			fp[i] = uint8(sum[i]/float64(count[i]) + 0.5)
+		}
+	}
+	return fp
+}
+
+// String returns the fingerprint as hexadecimal, as stored in template files.
+func (fp pageFingerprint) String() string {
+	return hex.EncodeToString(fp[:])
+}
+
+// parseFingerprint parses the hexadecimal form of a fingerprint.
+func parseFingerprint(s string) (pageFingerprint, error) {
+	var fp pageFingerprint
+	raw, err := hex.DecodeString(s)
+	if err != nil || len(raw) != len(fp) {
+		return fp, fmt.Errorf("invalid fingerprint %q", s)
+	}
+	copy(fp[:], raw)
+	return fp, nil
+}
+
+// similarity returns the correlation of two fingerprints, from -1 to 1.
+// Blank pages correlate with nothing.
+func (fp pageFingerprint) similarity(other pageFingerprint) float64 {
+	var meanA, meanB float64
+	for i := range fp {
+		meanA += float64(fp[i])
+		meanB += float64(other[i])
+	}
+	meanA /= float64(len(fp))
+	meanB /= float64(len(fp))
+	var cov, varA, varB float64
+	for i := range fp {
+		da, db := float64(fp[i])-meanA, float64(other[i])-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+// RegionTemplate is a named set of ignore regions for one document type.
+// Templates with a fingerprint are applied automatically to the pages that
+// look like their reference page.
+type RegionTemplate struct {
+	Name        string         `json:"-"`
+	Fingerprint string         `json:"fingerprint,omitempty"` // of the reference page
+	Reference   string         `json:"reference,omitempty"`   // reference page image or PDF, relative to the template file
+	Regions     []IgnoreRegion `json:"regions"`
+
+	fingerprint pageFingerprint
+}
+
+// Matchable reports whether the template can be matched against pages.
+func (t RegionTemplate) Matchable() bool {
+	return t.Fingerprint != ""
+}
+
+// readRegionTemplates reads a template file without resolving references.
+// A template is either a list of regions or an object with a fingerprint or
+// reference page and its regions.
+func readRegionTemplates(path string) (map[string]RegionTemplate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading ignore regions: %w", err)
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error parsing ignore regions %s: %w", path, err)
+	}
+	templates := make(map[string]RegionTemplate, len(entries))
+	for name, entry := range entries {
+		t := RegionTemplate{}
+		if bytes.HasPrefix(bytes.TrimSpace(entry), []byte("[")) {
+			err = json.Unmarshal(entry, &t.Regions)
+		} else {
+			err = json.Unmarshal(entry, &t)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing ignore template %q in %s: %w", name, path, err)
+		}
+		t.Name = name
+		templates[name] = t
+	}
+	return templates, nil
+}
+
+// LoadRegionTemplates reads a template file, validates its regions and
+// fingerprints the reference pages of templates that have no fingerprint
+// yet. The templates are returned in name order.
+func LoadRegionTemplates(path, renderer string) ([]RegionTemplate, error) {
+	templates, err := readRegionTemplates(path)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]RegionTemplate, 0, len(templates))
+	for _, t := range templates {
+		for i, r := range t.Regions {
+			if t.Regions[i], err = normalizeRegion(r); err != nil {
+				return nil, fmt.Errorf("ignore template %q: %w", t.Name, err)
+			}
+		}
+		if t.Fingerprint == "" && t.Reference != "" {
+			ref := t.Reference
+			if !filepath.IsAbs(ref) {
+				ref = filepath.Join(filepath.Dir(path), ref)
+			}
+			fp, err := fingerprintFile(ref, 1, renderer)
+			if err != nil {
+				return nil, fmt.Errorf("ignore template %q: %w", t.Name, err)
+			}
+			t.Fingerprint = fp.String()
+		}
+		if t.Fingerprint != "" {
+			if t.fingerprint, err = parseFingerprint(t.Fingerprint); err != nil {
+				return nil, fmt.Errorf("ignore template %q: %w", t.Name, err)
+			}
+		}
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// fingerprintFile fingerprints an image file, or page page (1-based) of a
+// PDF file.
+func fingerprintFile(path string, page int, renderer string) (pageFingerprint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pageFingerprint{}, fmt.Errorf("error reading reference page: %w", err)
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF")) {
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return pageFingerprint{}, fmt.Errorf("error decoding reference image %s: %w", path, err)
+		}
+		return fingerprintImage(img), nil
+	}
+
+	src, err := openPDFSource(data, filepath.Base(path), renderer)
+	if err != nil {
+		return pageFingerprint{}, err
+	}
+	defer src.Close()
+	if page < 1 || page > src.doc.NumPage() {
+		return pageFingerprint{}, fmt.Errorf("reference %s has no page %d", path, page)
+	}
+	img, err := src.renderPage(page-1, fingerprintDPI, false)
+	if err != nil {
+		return pageFingerprint{}, fmt.Errorf("error rendering reference page: %w", err)
+	}
+	return fingerprintImage(img), nil
+}
+
+// matchTemplate returns the template most similar to a page fingerprint
+// and its similarity, or nil if none reaches templateMatchMin.
+func matchTemplate(templates []RegionTemplate, fp pageFingerprint) (*RegionTemplate, float64) {
+	var best *RegionTemplate
+	bestSim := -1.0
+	for i := range templates {
+		if !templates[i].Matchable() {
+			continue
+		}
+		if sim := templates[i].fingerprint.similarity(fp); sim > bestSim {
+			best, bestSim = &templates[i], sim
+		}
+	}
+	if bestSim < templateMatchMin {
+		return nil, bestSim
+	}
+	return best, bestSim
+}
+
+// pageTemplate returns the template matching a page, fingerprinting each
+// page once.
+func (src *pdfSource) pageTemplate(config OCRConfig, pageNum int) *RegionTemplate {
+	if len(config.RegionTemplates) == 0 {
+		return nil
+	}
+	if t, ok := src.templates[pageNum]; ok {
+		return t
+	}
+	if src.templates == nil {
+		src.templates = map[int]*RegionTemplate{}
+	}
+	var t *RegionTemplate
+	img, err := src.renderPage(pageNum, fingerprintDPI, false)
+	if err != nil {
+		src.warnings.warnf(pageNum, "Warning: page %d could not be fingerprinted, no template applied: %v\n", pageNum+1, err)
+	} else {
+		var sim float64
+		t, sim = matchTemplate(config.RegionTemplates, fingerprintImage(img))
+		if t != nil {
+			config.logf("Page %d matches template %s (similarity %.2f)\n", pageNum+1, t.Name, sim)
+		}
+	}
+	src.templates[pageNum] = t
+	return t
+}
+
+// RegisterTemplate registers page page (1-based) of the reference
+// document ref, a PDF opened with renderer or an image, as the reference
+// page of template name in a template file. The file and template are
+// created if needed; existing regions are kept. Other templates too
+// similar to tell apart from it are logged as a warning.
+func RegisterTemplate(file, name, ref string, page int, renderer string) error {
+	templates := map[string]RegionTemplate{}
+	if _, err := os.Stat(file); err == nil {
+		if templates, err = readRegionTemplates(file); err != nil {
+			return err
+		}
+	}
+	fp, err := fingerprintFile(ref, page, renderer)
+	if err != nil {
+		return err
+	}
+
+	t := templates[name]
+	t.Fingerprint = fp.String()
+	if t.Regions == nil {
+		t.Regions = []IgnoreRegion{}
+	}
+	templates[name] = t
+	for other, o := range templates {
+		if other == name || !o.Matchable() {
+			continue
+		}
+		if ofp, err := parseFingerprint(o.Fingerprint); err == nil {
+			if sim := ofp.similarity(fp); sim >= templateMatchMin {
+				warnf("Warning: template %s looks like %s (similarity %.2f); pages may match either\n", name, other, sim)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(templates, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
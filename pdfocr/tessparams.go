@@ -0,0 +1,83 @@
+package pdfocr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tesseract OCR engine modes, in OCRConfig.OEM.
+const (
+	OEMLegacy     = "legacy"      // the legacy character classifier (--oem 0)
+	OEMLSTM       = "lstm"        // the LSTM line recognizer (--oem 1)
+	OEMLegacyLSTM = "legacy+lstm" // both combined (--oem 2)
+	OEMDefault    = "default"     // whichever the traineddata holds (--oem 3)
+)
+
+// oemNumbers are the --oem values of the engine modes.
+var oemNumbers = map[string]int{OEMLegacy: 0, OEMLSTM: 1, OEMLegacyLSTM: 2, OEMDefault: 3}
+
+// MaxPSM is the highest Tesseract page segmentation mode (raw line).
+const MaxPSM = 13
+
+// ParseOEM reads an -oem value: a mode name or its Tesseract number.
+func ParseOEM(s string) (string, error) {
+	s = strings.ToLower(s)
+	if _, ok := oemNumbers[s]; ok {
+		return s, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		for mode, number := range oemNumbers {
+			if number == n {
+				return mode, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unsupported OCR engine mode %q (use 0-3, legacy, lstm, legacy+lstm or default)", s)
+}
+
+// ParseTessParam reads a -tess-param value, a Tesseract variable and its
+// value as name=value.
+func ParseTessParam(s string) (name, value string, err error) {
+	name, value, ok := strings.Cut(s, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid Tesseract parameter %q (use name=value)", s)
+	}
+	return name, value, nil
+}
+
+// validateTessSettings checks the page segmentation mode, engine mode and
+// variables of a configuration. PSM 0 would only detect the orientation,
+// and tessedit_ocr_engine_mode only takes effect when Tesseract loads its
+// traineddata, which OEM sees to.
+func validateTessSettings(config OCRConfig) error {
+	if config.PSM < 0 || config.PSM > MaxPSM {
+		return fmt.Errorf("unsupported page segmentation mode %d (use 1-%d)", config.PSM, MaxPSM)
+	}
+	if _, ok := oemNumbers[config.OEM]; config.OEM != "" && !ok {
+		return fmt.Errorf("unsupported OCR engine mode %q (use legacy, lstm, legacy+lstm or default)", config.OEM)
+	}
+	for name := range config.TessParams {
+		if name == "" || strings.ContainsAny(name, "= \t\n") {
+			return fmt.Errorf("invalid Tesseract parameter name %q", name)
+		}
+		if name == "tessedit_ocr_engine_mode" {
+			return fmt.Errorf("set the OCR engine mode with -oem rather than %s", name)
+		}
+	}
+	return nil
+}
+
+// tessParamNames lists the names of config.TessParams in alphabetical
+// order, so recognitions set them, and clients are keyed by them, the same
+// way every time.
+func tessParamNames(config OCRConfig) []string {
+	names := make([]string, 0, len(config.TessParams))
+	for name := range config.TessParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,47 @@
+package pdfocr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTesseractArgs(t *testing.T) {
+	config := OCRConfig{
+		Language:   "eng",
+		PSM:        6,
+		OEM:        OEMLSTM,
+		TessParams: map[string]string{"tessedit_char_whitelist": "0123456789", "preserve_interword_spaces": "1"},
+	}
+	if err := validateTessSettings(config); err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(tesseractArgs(config, PageOCROptions{}), " ")
+	want := "stdin stdout -l eng --psm 6 --oem 1 -c preserve_interword_spaces=1 -c tessedit_char_whitelist=0123456789"
+	if got != want {
+		t.Errorf("tesseractArgs = %q, want %q", got, want)
+	}
+	// The pipeline's choices for a single line win
+	got = strings.Join(tesseractArgs(config, PageOCROptions{SingleLine: true, Whitelist: "0123456789/"}), " ")
+	if !strings.Contains(got, "--psm 7") || strings.Contains(got, "--psm 6") || !strings.HasSuffix(got, "-c tessedit_char_whitelist=0123456789/") {
+		t.Errorf("tesseractArgs with a single line = %q", got)
+	}
+
+	for s, want := range map[string]string{"1": OEMLSTM, "0": OEMLegacy, "legacy+lstm": OEMLegacyLSTM, "3": OEMDefault, "4": "", "lstm2": ""} {
+		oem, err := ParseOEM(s)
+		if oem != want || (err != nil) != (want == "") {
+			t.Errorf("ParseOEM(%q) = %q, %v, want %q", s, oem, err, want)
+		}
+	}
+	if _, _, err := ParseTessParam("=1"); err == nil {
+		t.Error("ParseTessParam accepted a parameter without a name")
+	}
+	for _, bad := range []OCRConfig{
+		{PSM: 14},
+		{OEM: "cube"},
+		{TessParams: map[string]string{"tessedit_ocr_engine_mode": "0"}},
+	} {
+		if validateTessSettings(bad) == nil {
+			t.Errorf("validateTessSettings accepted %+v", bad)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Package testsupport provides a mock OCR engine and a renderer of fixture
+// documents, so tests can run the whole pdfocr pipeline quickly and without
+// MuPDF or Tesseract (build with -tags nomupdf,nogosseract to leave them
+// out entirely). Importing the package registers both backends:
+//
+//	fixture := testsupport.Fixture{Pages: []testsupport.FixturePage{
+//		{Text: "A page with a text layer long enough to be used as it is."},
+//		{OCR: "A scanned page"},
+//	}}
+//	ex, err := pdfocr.NewExtractor(testsupport.Config())
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	text, manifest, err := ex.ExtractData(ctx, fixture.Bytes(), "fixture.pdf")
+package testsupport
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"sync/atomic"
+	"unicode"
+
+	"ocr-tool/pdfocr"
+)
+
+// Names of the backends registered by the package.
+const (
+	EngineName   = "mock"
+	RendererName = "fixture"
+)
+
+// Default page size of fixture pages in points (US Letter), and the layout
+// of the words of their OCR text.
+const (
+	defaultWidth  = 612
+	defaultHeight = 792
+	margin        = 72 // points
+	charWidth     = 6
+	lineHeight    = 14
+)
+
+func init() {
+	pdfocr.RegisterEngine(Engine{})
+	pdfocr.RegisterRenderer(Renderer{})
+}
+
+// Config returns pdfocr.DefaultConfig set to the mock engine and the
+// fixture renderer.
+func Config() pdfocr.OCRConfig {
+	config := pdfocr.DefaultConfig()
+	config.Engine = EngineName
+	config.Renderer = RendererName
+	return config
+}
+
+// Fixture is a document for the fixture renderer, which reads it as JSON
+// in place of a PDF.
+type Fixture struct {
+	Pages []FixturePage `json:"pages"`
+}
+
+// FixturePage is one page of a Fixture.
+type FixturePage struct {
+	Text        string  `json:"text,omitempty"`         // text layer
+	OCR         string  `json:"ocr,omitempty"`          // text the mock engine recognizes on the page image
+	Width       float64 `json:"width,omitempty"`        // points, default 612
+	Height      float64 `json:"height,omitempty"`       // points, default 792
+	RenderError string  `json:"render_error,omitempty"` // error every render of the page fails with
+	TextError   string  `json:"text_error,omitempty"`   // error reading the text layer of the page fails with
+}
+
+// Bytes returns the fixture as the data of a document for the fixture
+// renderer.
+func (f Fixture) Bytes() []byte {
+	data, _ := json.Marshal(f)
+	return data
+}
+
+// uploadHeader is the PDF header Fixture.Upload puts before a fixture.
+const uploadHeader = "%PDF-fixture\n"
+
+// Upload returns the fixture as Bytes does, behind a PDF header, for the
+// server, which accepts uploads by their content.
+func (f Fixture) Upload() []byte {
+	return append([]byte(uploadHeader), f.Bytes()...)
+}
+
+// PageImage is a page rendered by the fixture renderer: white, with a dark
+// box for every word of the page's OCR text, which it carries for the mock
+// engine along with the boxes.
+type PageImage struct {
+	*image.Gray
+	Text  string
+	Words []pdfocr.OCRWord
+}
+
+// openDocuments counts the fixture documents opened and not yet closed.
+var openDocuments atomic.Int64
+
+// OpenDocuments returns the number of fixture documents that are open, for
+// tests that check every document opened by the pipeline is closed again.
+func OpenDocuments() int {
+	return int(openDocuments.Load())
+}
+
+// Renderer is the fixture renderer, registered as "fixture".
+type Renderer struct{}
+
+func (Renderer) Name() string    { return RendererName }
+func (Renderer) Version() string { return "testsupport" }
+
+// Open reads a document written by Fixture.Bytes or Fixture.Upload.
+func (Renderer) Open(data []byte) (pdfocr.RenderDocument, error) {
+	var f Fixture
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte(uploadHeader)), &f); err != nil {
+		return nil, fmt.Errorf("not a fixture document: %w", err)
+	}
+	if len(f.Pages) == 0 {
+		return nil, fmt.Errorf("fixture document has no pages")
+	}
+	openDocuments.Add(1)
+	return &document{pages: f.Pages}, nil
+}
+
+// document is an opened Fixture.
+type document struct {
+	pages  []FixturePage
+	closed atomic.Bool
+}
+
+func (d *document) NumPage() int { return len(d.pages) }
+
+func (d *document) page(pageNumber int) (FixturePage, error) {
+	if pageNumber < 0 || pageNumber >= len(d.pages) {
+		return FixturePage{}, fmt.Errorf("page %d out of range (%d pages)", pageNumber+1, len(d.pages))
+	}
+	return d.pages[pageNumber], nil
+}
+
+func (d *document) Text(pageNumber int) (string, error) {
+	p, err := d.page(pageNumber)
+	if err == nil && p.TextError != "" {
+		return "", fmt.Errorf("%s", p.TextError)
+	}
+	return p.Text, err
+}
+
+// ImageDPI renders the page as a PageImage.
+func (d *document) ImageDPI(pageNumber int, dpi float64) (image.Image, error) {
+	p, err := d.page(pageNumber)
+	if err != nil {
+		return nil, err
+	}
+	if p.RenderError != "" {
+		return nil, fmt.Errorf("%s", p.RenderError)
+	}
+	w, h := p.Width, p.Height
+	if w <= 0 {
+		w = defaultWidth
+	}
+	if h <= 0 {
+		h = defaultHeight
+	}
+	scale := dpi / 72
+	img := image.NewGray(image.Rect(0, 0, int(w*scale), int(h*scale)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	words := layoutWords(p.OCR, scale, img.Bounds())
+	for _, word := range words {
+		draw.Draw(img, word.Box, image.NewUniform(color.Gray{Y: 40}), image.Point{}, draw.Src)
+	}
+	return &PageImage{Gray: img, Text: p.OCR, Words: words}, nil
+}
+
+// Close fails on a document that is already closed, which the pipeline
+// never does.
+func (d *document) Close() error {
+	if d.closed.Swap(true) {
+		return fmt.Errorf("fixture document closed twice")
+	}
+	openDocuments.Add(-1)
+	return nil
+}
+
+// layoutWords places the words of text line by line from the top-left
+// margin in a monospaced font, so runs of spaces lay out columns, scale
+// being the pixels per point, leaving out those outside bounds.
+func layoutWords(text string, scale float64, bounds image.Rectangle) []pdfocr.OCRWord {
+	var words []pdfocr.OCRWord
+	for i, line := range strings.Split(text, "\n") {
+		y := float64(margin + i*lineHeight)
+		runes := []rune(line)
+		for start := 0; start < len(runes); {
+			if unicode.IsSpace(runes[start]) {
+				start++
+				continue
+			}
+			end := start
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			x := float64(margin + start*charWidth)
+			width := float64((end - start) * charWidth)
+			box := image.Rect(int(x*scale), int(y*scale), int((x+width)*scale), int((y+lineHeight*0.7)*scale))
+			if box.In(bounds) {
+				words = append(words, pdfocr.OCRWord{Text: string(runes[start:end]), Box: box})
+			}
+			start = end
+		}
+	}
+	return words
+}
+
+// Engine is the mock OCR engine, registered as "mock". On a PageImage it
+// recognizes the page's OCR text; images made by the pipeline from one
+// (preprocessed, rotated or cropped) and other images give DefaultText,
+// laid out at 300 DPI. Every word has Confidence, or 95 when it is zero.
+// Registering another Engine with pdfocr.RegisterEngine changes them.
+type Engine struct {
+	DefaultText string
+	Confidence  float64
+}
+
+func (Engine) Name() string    { return EngineName }
+func (Engine) Version() string { return "testsupport" }
+
+func (e Engine) Text(img image.Image, config pdfocr.OCRConfig, opts pdfocr.PageOCROptions) (string, error) {
+	if p, ok := img.(*PageImage); ok {
+		return p.Text, nil
+	}
+	return e.DefaultText, nil
+}
+
+func (e Engine) Words(img image.Image, config pdfocr.OCRConfig, opts pdfocr.PageOCROptions) ([]pdfocr.OCRWord, error) {
+	var words []pdfocr.OCRWord
+	if p, ok := img.(*PageImage); ok {
+		words = append(words, p.Words...)
+	} else {
+		words = layoutWords(e.DefaultText, 300.0/72, img.Bounds())
+	}
+	confidence := e.Confidence
+	if confidence == 0 {
+		confidence = 95
+	}
+	for i := range words {
+		words[i].Confidence = confidence
+	}
+	return words, nil
+}
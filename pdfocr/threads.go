@@ -0,0 +1,30 @@
+package pdfocr
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// SetOCRThreads caps the OpenMP threads Tesseract uses for one recognition
+// by setting OMP_THREAD_LIMIT, so parallel pages do not each spawn a thread
+// per CPU. It must run before the first OCR call: the in-process engine
+// reads the limit when OpenMP starts, and tesseract-cli processes inherit
+// it. n <= 0 keeps the environment as it is.
+func SetOCRThreads(n int) error {
+	if n <= 0 {
+		return nil
+	}
+	if err := os.Setenv("OMP_THREAD_LIMIT", strconv.Itoa(n)); err != nil {
+		return fmt.Errorf("error setting OMP_THREAD_LIMIT: %w", err)
+	}
+	return nil
+}
+
+// OCRThreadsDescription describes the effective Tesseract thread limit.
+func OCRThreadsDescription() string {
+	if v := os.Getenv("OMP_THREAD_LIMIT"); v != "" {
+		return "OMP_THREAD_LIMIT=" + v
+	}
+	return "unlimited (OMP_THREAD_LIMIT not set)"
+}
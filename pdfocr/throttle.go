@@ -0,0 +1,38 @@
+package pdfocr
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ParseCPULimit parses a -max-cpu value, a percentage of the machine's CPUs
+// such as "50%" (the sign is optional), into a number of CPUs, at least one.
+func ParseCPULimit(s string) (int, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		return 0, fmt.Errorf("invalid -max-cpu value %q (use a percentage like 50%%)", s)
+	}
+	cpus := int(float64(runtime.NumCPU()) * pct / 100)
+	if cpus < 1 {
+		cpus = 1
+	}
+	return cpus, nil
+}
+
+// ApplyCPULimit keeps a run on at most cpus CPUs: Go code is limited through
+// GOMAXPROCS, the worker pool to as many workers and Tesseract to its share
+// of them per worker through the OpenMP thread limit, unless -ocr-threads
+// set a lower one.
+func ApplyCPULimit(config *OCRConfig, cpus int) {
+	config.MaxCPUs = cpus
+	runtime.GOMAXPROCS(cpus)
+	if config.Workers > cpus {
+		config.Workers = cpus
+	}
+	perWorker := cpus / max(config.Workers, 1)
+	if config.OCRThreads == 0 || config.OCRThreads > perWorker {
+		config.OCRThreads = perWorker
+	}
+}
@@ -0,0 +1,123 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// tsvHeader is the first line of Tesseract's TSV output.
+const tsvHeader = "level\tpage_num\tblock_num\tpar_num\tline_num\tword_num\tleft\ttop\twidth\theight\tconf\ttext\n"
+
+// Levels of the rows of Tesseract's TSV output.
+const (
+	tsvPage = 1 + iota
+	tsvBlock
+	tsvParagraph
+	tsvLine
+	tsvWord
+)
+
+// DocumentTSV returns a processed document in the TSV format of Tesseract
+// (tesseract ... tsv): a row for every page, block, paragraph, line and
+// word, numbered within their parent, with boxes in pixels of the page
+// rendered at config.DPI and the confidences of the words, -1 for the
+// other rows. OCR'd pages carry their words with OCRConfig.WordBoxes; pages
+// from the text layer get rows synthesized from their lines, split into
+// words by character count, with a confidence of 100. Lines are grouped
+// into paragraphs at gaps taller than a line, and into blocks at gaps of
+// three lines. page_num is the page number in the document.
+func DocumentTSV(manifest DocumentManifest, config OCRConfig) []byte {
+	dpi := config.DPI
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	px := func(pt float64) int {
+		return int(math.Round(pt * dpi / 72))
+	}
+	var b strings.Builder
+	b.WriteString(tsvHeader)
+	for _, p := range manifest.PageResults {
+		if p.Width <= 0 || p.Height <= 0 {
+			continue
+		}
+		n := p.Page
+		writeTSVRow(&b, tsvPage, [5]int{n}, image.Rect(0, 0, px(p.Width), px(p.Height)), -1, "")
+		conf := func(w OCRWord) float64 {
+			if len(p.Words) == 0 {
+				return 100
+			}
+			return math.Min(math.Max(w.Confidence, 0), 100)
+		}
+		for bi, block := range tsvBlocks(pixelLines(p, px)) {
+			var blockBox image.Rectangle
+			for _, par := range block {
+				blockBox = blockBox.Union(linesBox(par))
+			}
+			writeTSVRow(&b, tsvBlock, [5]int{n, bi + 1}, blockBox, -1, "")
+			for pi, par := range block {
+				writeTSVRow(&b, tsvParagraph, [5]int{n, bi + 1, pi + 1}, linesBox(par), -1, "")
+				for li, line := range par {
+					writeTSVRow(&b, tsvLine, [5]int{n, bi + 1, pi + 1, li + 1}, line.Box, -1, "")
+					for wi, w := range line.Words {
+						writeTSVRow(&b, tsvWord, [5]int{n, bi + 1, pi + 1, li + 1, wi + 1}, w.Box, conf(w), w.Text)
+					}
+				}
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// tsvBlocks groups the lines of a page, top to bottom, into blocks of
+// paragraphs by the vertical gaps between them.
+func tsvBlocks(lines []ocrLine) [][][]ocrLine {
+	var blocks [][][]ocrLine
+	for i, l := range lines {
+		if i == 0 {
+			blocks = [][][]ocrLine{{{l}}}
+			continue
+		}
+		prev := lines[i-1]
+		gap := l.Box.Min.Y - prev.Box.Max.Y
+		height := (l.Box.Dy() + prev.Box.Dy()) / 2
+		switch {
+		case gap > 3*height:
+			blocks = append(blocks, [][]ocrLine{{l}})
+		case gap > height:
+			last := len(blocks) - 1
+			blocks[last] = append(blocks[last], []ocrLine{l})
+		default:
+			block := blocks[len(blocks)-1]
+			block[len(block)-1] = append(block[len(block)-1], l)
+		}
+	}
+	return blocks
+}
+
+// linesBox returns the box around lines.
+func linesBox(lines []ocrLine) image.Rectangle {
+	var box image.Rectangle
+	for _, l := range lines {
+		box = box.Union(l.Box)
+	}
+	return box
+}
+
+// writeTSVRow writes a row numbered page, block, paragraph, line and word;
+// text has its tabs and line breaks turned into spaces.
+func writeTSVRow(b *strings.Builder, level int, num [5]int, box image.Rectangle, conf float64, text string) {
+	text = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return ' '
+		}
+		return r
+	}, text)
+	confText := "-1"
+	if conf >= 0 {
+		confText = fmt.Sprintf("%f", conf)
+	}
+	fmt.Fprintf(b, "%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n",
+		level, num[0], num[1], num[2], num[3], num[4], box.Min.X, box.Min.Y, box.Dx(), box.Dy(), confText, text)
+}
@@ -0,0 +1,40 @@
+package pdfocr
+
+import "testing"
+
+func TestDocumentTSV(t *testing.T) {
+	manifest := DocumentManifest{PageResults: []PageResult{
+		{
+			Page: 1, Method: MethodOCR, Width: 100, Height: 100,
+			Words: []PageWord{
+				{Text: "Hello", Box: [4]float64{10, 10, 40, 20}, Confidence: 91.5},
+				{Text: "world", Box: [4]float64{45, 10, 80, 20}, Confidence: 88},
+				{Text: "Bye", Box: [4]float64{10, 40, 30, 50}, Confidence: 70},
+			},
+		},
+		{Page: 2, Method: MethodFailed, Error: "render failed"},
+		{
+			Page: 3, Method: MethodNative, Width: 50, Height: 20,
+			layout: []TextLine{{Text: "ab cd", X0: 0, Y0: 0, X1: 50, Y1: 10}},
+		},
+	}}
+	want := tsvHeader +
+		"1\t1\t0\t0\t0\t0\t0\t0\t100\t100\t-1\t\n" +
+		"2\t1\t1\t0\t0\t0\t10\t10\t70\t40\t-1\t\n" +
+		"3\t1\t1\t1\t0\t0\t10\t10\t70\t10\t-1\t\n" +
+		"4\t1\t1\t1\t1\t0\t10\t10\t70\t10\t-1\t\n" +
+		"5\t1\t1\t1\t1\t1\t10\t10\t30\t10\t91.500000\tHello\n" +
+		"5\t1\t1\t1\t1\t2\t45\t10\t35\t10\t88.000000\tworld\n" +
+		"3\t1\t1\t2\t0\t0\t10\t40\t20\t10\t-1\t\n" +
+		"4\t1\t1\t2\t1\t0\t10\t40\t20\t10\t-1\t\n" +
+		"5\t1\t1\t2\t1\t1\t10\t40\t20\t10\t70.000000\tBye\n" +
+		"1\t3\t0\t0\t0\t0\t0\t0\t50\t20\t-1\t\n" +
+		"2\t3\t1\t0\t0\t0\t0\t0\t50\t10\t-1\t\n" +
+		"3\t3\t1\t1\t0\t0\t0\t0\t50\t10\t-1\t\n" +
+		"4\t3\t1\t1\t1\t0\t0\t0\t50\t10\t-1\t\n" +
+		"5\t3\t1\t1\t1\t1\t0\t0\t20\t10\t100.000000\tab\n" +
+		"5\t3\t1\t1\t1\t2\t30\t0\t20\t10\t100.000000\tcd\n"
+	if got := string(DocumentTSV(manifest, OCRConfig{DPI: 72})); got != want {
+		t.Errorf("DocumentTSV() =\n%s\nwant:\n%s", got, want)
+	}
+}
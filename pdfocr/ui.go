@@ -0,0 +1,63 @@
+package pdfocr
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// localeFiles holds the translations of the messages, one <lang>.json per
+// language mapping each English message, format verbs
+// included, to its translation. Messages missing from a catalog are shown
+// in English.
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// uiMessages is the catalog of the UI language; nil shows English.
+var uiMessages map[string]string
+
+// SetUILanguage selects the language of the logged messages: "en",
+// or a language with a catalog in locales such as "sw".
+func SetUILanguage(lang string) error {
+	if lang == "" || lang == "en" {
+		uiMessages = nil
+		return nil
+	}
+	data, err := localeFiles.ReadFile("locales/" + lang + ".json")
+	if err != nil {
+		return fmt.Errorf("unsupported UI language %q (available: %s)", lang, strings.Join(UILanguages(), ", "))
+	}
+	var catalog map[string]string
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return fmt.Errorf("error reading %s messages: %w", lang, err)
+	}
+	uiMessages = catalog
+	return nil
+}
+
+// UILanguages lists the UI languages, English first.
+func UILanguages() []string {
+	langs := []string{"en"}
+	entries, _ := localeFiles.ReadDir("locales")
+	for _, e := range entries {
+		langs = append(langs, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return langs
+}
+
+// tr returns the translation of an English message in the UI language.
+func tr(message string) string {
+	if t, ok := uiMessages[message]; ok {
+		return t
+	}
+	return message
+}
+
+// Translate returns the translation of an English message in the UI
+// language, for programs showing messages of their own next to those of
+// the library.
+func Translate(message string) string {
+	return tr(message)
+}
@@ -0,0 +1,255 @@
+package pdfocr
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mediaPDF is the media type of PDF uploads.
+const mediaPDF = "application/pdf"
+
+// uploadMediaTypes are the media types the server processes.
+var uploadMediaTypes = []string{mediaPDF, mediaPNG, mediaJPEG, mediaTIFF}
+
+// scanTimeout bounds a malware scan of an upload.
+const scanTimeout = 2 * time.Minute
+
+// clamdChunkSize is the size of the chunks an upload is streamed to clamd
+// in.
+const clamdChunkSize = 64 << 10
+
+// uploadMediaType returns the media type of an upload from its content,
+// or "" when it is neither a PDF nor an image input.
+func uploadMediaType(data []byte) string {
+	if isPDFData(data) {
+		return mediaPDF
+	}
+	return inputMediaType(data)
+}
+
+// parseMediaTypes reads a comma-separated list of upload media types for
+// -allow-types; "pdf", "png", "jpeg" and "tiff" stand for theirs.
+func parseMediaTypes(list string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(list, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		switch t {
+		case "":
+			continue
+		case "pdf", "png", "jpeg", "tiff":
+			t = map[string]string{"pdf": mediaPDF, "png": mediaPNG, "jpeg": mediaJPEG, "tiff": mediaTIFF}[t]
+		}
+		if !slices.Contains(uploadMediaTypes, t) {
+			return nil, fmt.Errorf("unsupported upload type %q (use %s)", t, strings.Join(uploadMediaTypes, ", "))
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// uploadScanner checks an upload for malware before the server processes
+// it. Scan returns the name of the threat found, or "" for a clean upload.
+type uploadScanner interface {
+	Name() string
+	Scan(ctx context.Context, data []byte) (string, error)
+}
+
+// uploadPolicy holds the checks of uploads besides their size: the media
+// types accepted (nil for all of uploadMediaTypes) and the scanners every
+// upload must pass.
+type uploadPolicy struct {
+	mediaTypes []string
+	scanners   []uploadScanner
+}
+
+// uploadRejection is the reason an upload is not processed, as answered
+// by the server.
+type uploadRejection struct {
+	status    int
+	code      string
+	message   string
+	retryable bool
+}
+
+// check applies the policy to an upload. Uploads are refused when a
+// scanner cannot be reached, rather than processed unscanned.
+func (p uploadPolicy) check(ctx context.Context, data []byte) *uploadRejection {
+	allowed := p.mediaTypes
+	if allowed == nil {
+		allowed = uploadMediaTypes
+	}
+	if t := uploadMediaType(data); t == "" || !slices.Contains(allowed, t) {
+		kind := "this file type"
+		if t != "" {
+			kind = t
+		}
+		return &uploadRejection{http.StatusUnsupportedMediaType, errUnsupportedType,
+			fmt.Sprintf("%s is not accepted (upload %s)", kind, strings.Join(allowed, ", ")), false}
+	}
+	for _, s := range p.scanners {
+		scanCtx, cancel := context.WithTimeout(ctx, scanTimeout)
+		threat, err := s.Scan(scanCtx, data)
+		cancel()
+		if err != nil {
+			warnf("Warning: the %s scan failed: %v\n", s.Name(), err)
+			return &uploadRejection{http.StatusServiceUnavailable, errScanFailed, "the upload could not be scanned for malware", true}
+		}
+		if threat != "" {
+			warnf("Warning: %s rejected an upload: %s\n", s.Name(), threat)
+			return &uploadRejection{http.StatusUnprocessableEntity, errUploadRejected, fmt.Sprintf("the upload was rejected by the malware scan: %s", threat), false}
+		}
+	}
+	return nil
+}
+
+// clamdScanner streams uploads to a ClamAV daemon with its INSTREAM
+// command.
+type clamdScanner struct {
+	network, address string
+}
+
+// newClamdScanner returns a scanner for the clamd socket at address:
+// host:port or tcp://host:port, or a unix socket path, plain or as
+// unix:///path.
+func newClamdScanner(address string) (clamdScanner, error) {
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		return clamdScanner{"unix", strings.TrimPrefix(address, "unix://")}, nil
+	case strings.HasPrefix(address, "/"):
+		return clamdScanner{"unix", address}, nil
+	}
+	address = strings.TrimPrefix(address, "tcp://")
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return clamdScanner{}, fmt.Errorf("invalid clamd address %q (use host:port or a socket path)", address)
+	}
+	return clamdScanner{"tcp", address}, nil
+}
+
+func (s clamdScanner) Name() string { return "clamd" }
+
+func (s clamdScanner) Scan(ctx context.Context, data []byte) (string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	w := bufio.NewWriter(conn)
+	w.WriteString("zINSTREAM\x00")
+	for offset := 0; offset < len(data); offset += clamdChunkSize {
+		chunk := data[offset:min(offset+clamdChunkSize, len(data))]
+		binary.Write(w, binary.BigEndian, uint32(len(chunk)))
+		w.Write(chunk)
+	}
+	binary.Write(w, binary.BigEndian, uint32(0))
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	// "stream: OK", "stream: <signature> FOUND" or "<message> ERROR"
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	result := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	}
+	return "", fmt.Errorf("clamd: %s", reply)
+}
+
+// icapScanner sends uploads to an ICAP service (RFC 3507) as the body of
+// a REQMOD request; the service answers 204 for clean uploads and
+// modifies, that is blocks, the others.
+type icapScanner struct {
+	service *url.URL
+}
+
+// newICAPScanner returns a scanner for an icap://host[:port]/service URL.
+func newICAPScanner(service string) (icapScanner, error) {
+	u, err := url.Parse(service)
+	if err != nil || u.Scheme != "icap" || u.Host == "" {
+		return icapScanner{}, fmt.Errorf("invalid ICAP service %q (use icap://host[:port]/service)", service)
+	}
+	return icapScanner{u}, nil
+}
+
+func (s icapScanner) Name() string { return "ICAP" }
+
+func (s icapScanner) Scan(ctx context.Context, data []byte) (string, error) {
+	host := s.service.Host
+	if s.service.Port() == "" {
+		host = net.JoinHostPort(s.service.Hostname(), "1344")
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	reqHeader := "POST /upload HTTP/1.1\r\nHost: pdf-ocr-tool\r\nContent-Length: " + strconv.Itoa(len(data)) + "\r\n\r\n"
+	w := bufio.NewWriter(conn)
+	fmt.Fprintf(w, "REQMOD %s ICAP/1.0\r\nHost: %s\r\nAllow: 204\r\nEncapsulated: req-hdr=0, req-body=%d\r\n\r\n", s.service, s.service.Host, len(reqHeader))
+	w.WriteString(reqHeader)
+	if len(data) > 0 {
+		fmt.Fprintf(w, "%x\r\n", len(data))
+		w.Write(data)
+		w.WriteString("\r\n")
+	}
+	w.WriteString("0\r\n\r\n")
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	r := textproto.NewReader(bufio.NewReader(conn))
+	status, err := r.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	header, err := r.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	var code int
+	if _, err := fmt.Sscanf(status, "ICAP/1.0 %d", &code); err != nil {
+		return "", fmt.Errorf("ICAP: unexpected reply %q", status)
+	}
+	switch code {
+	case 204:
+		return "", nil
+	case 200:
+		// X-Infection-Found: Type=0; Resolution=2; Threat=<name>;
+		for _, field := range strings.Split(header.Get("X-Infection-Found"), ";") {
+			if name, ok := strings.CutPrefix(strings.TrimSpace(field), "Threat="); ok && name != "" {
+				return name, nil
+			}
+		}
+		if v := header.Get("X-Violations-Found"); v != "" {
+			return strings.TrimSpace(v), nil
+		}
+		return "blocked by the ICAP service", nil
+	}
+	return "", fmt.Errorf("ICAP: %s", status)
+}
@@ -0,0 +1,78 @@
+package pdfocr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestUploadPolicy(t *testing.T) {
+	// A clamd finding EICAR in any stream holding the word
+	clamd, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clamd.Close()
+	go func() {
+		for {
+			conn, err := clamd.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			r.ReadString(0)
+			var stream []byte
+			for {
+				var n uint32
+				if binary.Read(r, binary.BigEndian, &n) != nil || n == 0 {
+					break
+				}
+				chunk := make([]byte, n)
+				io.ReadFull(r, chunk)
+				stream = append(stream, chunk...)
+			}
+			if bytes.Contains(stream, []byte("EICAR")) {
+				io.WriteString(conn, "stream: Eicar-Test-Signature FOUND\x00")
+			} else {
+				io.WriteString(conn, "stream: OK\x00")
+			}
+			conn.Close()
+		}
+	}()
+	scanner, err := newClamdScanner("tcp://" + clamd.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := uploadPolicy{mediaTypes: []string{mediaPDF}, scanners: []uploadScanner{scanner}}
+	ctx := context.Background()
+	for _, c := range []struct {
+		data string
+		code string
+	}{
+		{"%PDF-1.7 clean", ""},
+		{"%PDF-1.7 EICAR", errUploadRejected},
+		{"\x89PNG\r\n\x1a\n", errUnsupportedType},
+		{"plain text", errUnsupportedType},
+	} {
+		code := ""
+		if rejection := policy.check(ctx, []byte(c.data)); rejection != nil {
+			code = rejection.code
+		}
+		if code != c.code {
+			t.Errorf("check(%q) = %q, want %q", c.data, code, c.code)
+		}
+	}
+
+	clamd.Close()
+	if rejection := policy.check(ctx, []byte("%PDF-1.7")); rejection == nil || rejection.code != errScanFailed || !rejection.retryable {
+		t.Errorf("check with clamd down = %+v, want a retryable %s", rejection, errScanFailed)
+	}
+	if _, err := parseMediaTypes("pdf,image/gif"); err == nil {
+		t.Error("parseMediaTypes accepted image/gif")
+	}
+}
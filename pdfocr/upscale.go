@@ -0,0 +1,164 @@
+package pdfocr
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// minScanDPI is the scan resolution below which pages are upscaled before
+// OCR: Tesseract needs glyphs of about 20 pixels, which smaller scans of
+// body text do not have.
+const minScanDPI = 150
+
+// scanCoverage is how closely an image must match the page's aspect ratio
+// to be taken for a full-page scan.
+const scanCoverage = 0.15
+
+// Upscaler enlarges a page image by scale (above 1) for OCR.
+type Upscaler func(img image.Image, scale float64) (image.Image, error)
+
+// scanDPI estimates the resolution of a scanned page from the largest image
+// it draws, assumed to cover the page as scans do. It returns 0 when the
+// page has no image with the page's proportions.
+func (pdf *rawPDF) scanDPI(pageNum int) float64 {
+	box, ok := pdf.mediaBox(pdf.page(pageNum))
+	if !ok {
+		return 0
+	}
+	pw, ph := box[2]-box[0], box[3]-box[1]
+
+	var w, h int
+	for _, obj := range pdf.pageImages(pageNum) {
+		dict, _ := obj.Value.(pdfDict)
+		iw, ih := pdf.int(dict["Width"]), pdf.int(dict["Height"])
+		if iw*ih > w*h {
+			w, h = iw, ih
+		}
+	}
+	if w == 0 || h == 0 {
+		return 0
+	}
+	if (w > h) != (pw > ph) {
+		// Drawn rotated
+		pw, ph = ph, pw
+	}
+	if ratio := (float64(w) / float64(h)) / (pw / ph); ratio < 1-scanCoverage || ratio > 1+scanCoverage {
+		return 0
+	}
+	return math.Min(float64(w)/(pw/72), float64(h)/(ph/72))
+}
+
+// lowScanDPI returns the resolution of a page that is a scan below
+// minScanDPI (and below the dpi it is OCR'd at, 0 meaning
+// defaultRenderDPI), or 0.
+func (src *pdfSource) lowScanDPI(pageNum int, dpi float64) float64 {
+	if src.raw == nil {
+		return 0
+	}
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	scan := src.raw.scanDPI(pageNum)
+	if scan <= 0 || scan >= minScanDPI || scan >= dpi {
+		return 0
+	}
+	return scan
+}
+
+// renderForOCR renders a page for recognition at dpi (0 means
+// defaultRenderDPI). A low-resolution scan is rendered at its own
+// resolution, so its pixels are not resampled twice, and enlarged to dpi
+// with config.Upscaler or bicubic interpolation.
+func (src *pdfSource) renderForOCR(pageNum int, dpi float64, config OCRConfig) (image.Image, error) {
+	if dpi <= 0 {
+		dpi = defaultRenderDPI
+	}
+	scan := src.lowScanDPI(pageNum, dpi)
+	if scan == 0 {
+		return src.renderPage(pageNum, dpi, config.RobustDecode)
+	}
+	img, err := src.renderPage(pageNum, scan, config.RobustDecode)
+	if err != nil {
+		return nil, err
+	}
+	upscale := config.Upscaler
+	if upscale == nil {
+		upscale = upscaleBicubic
+	}
+	config.debugf("Page %d is a %.0f DPI scan, upscaling it %.1fx for OCR\n", pageNum+1, scan, dpi/scan)
+	up, err := upscale(img, dpi/scan)
+	if err != nil {
+		return nil, fmt.Errorf("error upscaling page image: %w", err)
+	}
+	return up, nil
+}
+
+// upscaleBicubic enlarges an image by scale with Catmull-Rom interpolation,
+// which keeps glyph edges sharper than the bilinear filtering of renderers.
+func upscaleBicubic(img image.Image, scale float64) (image.Image, error) {
+	b := img.Bounds()
+	w, h := int(float64(b.Dx())*scale+0.5), int(float64(b.Dy())*scale+0.5)
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("invalid upscale of %dx%d by %g", b.Dx(), b.Dy(), scale)
+	}
+
+	// Rows first into a float buffer, then columns
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			src.Set(x, y, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	tmp := make([]float64, w*b.Dy()*4)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < w; x++ {
+			taps, weights := cubicTaps(x, scale, b.Dx())
+			for c := 0; c < 4; c++ {
+				var v float64
+				for i, t := range taps {
+					v += weights[i] * float64(src.Pix[y*src.Stride+t*4+c])
+				}
+				tmp[(y*w+x)*4+c] = v
+			}
+		}
+	}
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		taps, weights := cubicTaps(y, scale, b.Dy())
+		for x := 0; x < w; x++ {
+			var px [4]float64
+			for i, t := range taps {
+				for c := 0; c < 4; c++ {
+					px[c] += weights[i] * tmp[(t*w+x)*4+c]
+				}
+			}
+			out.SetRGBA(x, y, color.RGBA{clampByte(px[0]), clampByte(px[1]), clampByte(px[2]), clampByte(px[3])})
+		}
+	}
+	return out, nil
+}
+
+// cubicTaps returns the source samples and Catmull-Rom weights for output
+// sample i of an axis of n source samples enlarged by scale.
+func cubicTaps(i int, scale float64, n int) ([4]int, [4]float64) {
+	pos := (float64(i)+0.5)/scale - 0.5
+	base := int(math.Floor(pos))
+	t := pos - float64(base)
+	var taps [4]int
+	for k := range taps {
+		taps[k] = min(max(base-1+k, 0), n-1)
+	}
+	t2, t3 := t*t, t*t*t
+	return taps, [4]float64{
+		(-t3 + 2*t2 - t) / 2,
+		(3*t3 - 5*t2 + 2) / 2,
+		(-3*t3 + 4*t2 + t) / 2,
+		(t3 - t2) / 2,
+	}
+}
+
+func clampByte(v float64) uint8 {
+	return uint8(math.Max(0, math.Min(255, v+0.5)))
+}
@@ -0,0 +1,69 @@
+package pdfocr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modes for pages that contain vector drawings but no text layer.
+const (
+	VectorPagesOCR     = "ocr"     // OCR like any other page (default)
+	VectorPagesDrawing = "drawing" // high-DPI OCR tuned for drawing annotations
+	VectorPagesSkip    = "skip"    // do not OCR vector-only pages
+)
+
+const (
+	// vectorPathThreshold is the number of drawn paths above which a page
+	// without raster images is treated as a vector drawing.
+	vectorPathThreshold = 150
+
+	// defaultVectorDPI is the render resolution used for drawing pages, high
+	// enough for the small lettering used in dimensions and title blocks.
+	defaultVectorDPI = 600
+
+	// drawingWhitelist restricts recognition to what engineering drawings
+	// actually contain: upper-case lettering, digits and dimension symbols.
+	drawingWhitelist = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789.,:;-+=/\\()[]\"'°±×ØR%#<> "
+)
+
+// validateVectorPages checks a -vector-pages mode given on the command line.
+func validateVectorPages(mode string) error {
+	switch mode {
+	case "", VectorPagesOCR, VectorPagesDrawing, VectorPagesSkip:
+		return nil
+	}
+	return fmt.Errorf("unsupported vector page mode %q (use ocr, drawing or skip)", mode)
+}
+
+// isVectorOnlyPage reports whether a page is made of vector paths with no
+// raster images, such as a CAD export. It is meant to be called for pages that
+// have already been found to have no usable text layer. Backends without SVG
+// export never report vector pages.
+func isVectorOnlyPage(doc RenderDocument, pageNum int) (bool, error) {
+	exporter, ok := doc.(svgDocument)
+	if !ok {
+		return false, nil
+	}
+	svg, err := exporter.SVG(pageNum)
+	if err != nil {
+		return false, fmt.Errorf("error converting page to SVG: %w", err)
+	}
+	if strings.Contains(svg, "<image") {
+		return false, nil
+	}
+	return strings.Count(svg, "<path") >= vectorPathThreshold, nil
+}
+
+// drawingOCROptions returns the per-page OCR settings used for vector
+// drawings in VectorPagesDrawing mode.
+func drawingOCROptions(config OCRConfig) PageOCROptions {
+	dpi := config.VectorDPI
+	if dpi <= 0 {
+		dpi = defaultVectorDPI
+	}
+	return PageOCROptions{
+		DPI:        dpi,
+		SparseText: true,
+		Whitelist:  drawingWhitelist,
+	}
+}
@@ -0,0 +1,25 @@
+package pdfocr
+
+import "runtime/debug"
+
+// version is the tool version, set at build time with
+// -ldflags "-X ocr-tool/pdfocr.version=v1.2.3".
+var version = "dev"
+
+// languageLister is implemented by engines that can report their installed
+// recognition languages.
+type languageLister interface {
+	Languages() ([]string, error)
+}
+
+// Version returns the build-time version, falling back to the module
+// version recorded by the Go toolchain.
+func Version() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
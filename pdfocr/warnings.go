@@ -0,0 +1,67 @@
+package pdfocr
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Warning is a problem that did not stop an extraction, such as a page
+// that could not be rendered or recognized.
+type Warning struct {
+	Document string `json:"document"`
+	Page     int    `json:"page,omitempty"` // 1-based, 0 for the document as a whole
+	Message  string `json:"message"`
+}
+
+// WarningFunc receives the warnings of an extraction. The warnings of a
+// document are delivered one at a time, even with several workers, but
+// documents processed concurrently call it concurrently.
+type WarningFunc func(Warning)
+
+// warningLog collects the warnings of a document for
+// DocumentManifest.Warnings and delivers them to config.Warnings, or logs
+// them to config.Logger without one. A nil warningLog only logs.
+type warningLog struct {
+	fn       WarningFunc
+	logger   *slog.Logger
+	document string
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+func newWarningLog(config *OCRConfig, document string) *warningLog {
+	return &warningLog{fn: config.Warnings, logger: config.logger(), document: document}
+}
+
+// warnf records a warning about the zero-based page pageNum, or about the
+// document with -1. format is a log message starting with "Warning: ".
+func (w *warningLog) warnf(pageNum int, format string, a ...any) {
+	if w == nil {
+		logMessage(defaultLogger(), slog.LevelWarn, fmt.Sprintf(tr(format), a...))
+		return
+	}
+	if w.fn == nil {
+		logMessage(w.logger, slog.LevelWarn, fmt.Sprintf(tr(format), a...),
+			slog.String("document", w.document), slog.Int("page", pageNum+1))
+	}
+	message := strings.TrimSpace(strings.TrimPrefix(fmt.Sprintf(format, a...), "Warning: "))
+	warning := Warning{Document: w.document, Page: pageNum + 1, Message: message}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, warning)
+	if w.fn != nil {
+		w.fn(warning)
+	}
+}
+
+// list returns the warnings recorded so far.
+func (w *warningLog) list() []Warning {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Warning(nil), w.warnings...)
+}
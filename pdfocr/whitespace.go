@@ -0,0 +1,76 @@
+package pdfocr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Supported newline styles for text output.
+const (
+	NewlineLF   = "lf"
+	NewlineCRLF = "crlf"
+)
+
+// validateNewline checks a newline style name given on the command line.
+func validateNewline(style string) error {
+	switch style {
+	case "", NewlineLF, NewlineCRLF:
+		return nil
+	}
+	return fmt.Errorf("unsupported newline style %q (use lf or crlf)", style)
+}
+
+// expandTabs replaces tabs with spaces up to the next multiple of width.
+func expandTabs(line string, width int) string {
+	if width <= 0 || !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := width - col%width
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// NormalizeWhitespace applies the newline and whitespace options from config
+// to text: tab expansion, trailing-space trimming, collapsing runs of blank
+// lines into a single blank line, and the output newline style. Input may use
+// any mix of LF, CRLF and CR line endings.
+func NormalizeWhitespace(text string, config OCRConfig) string {
+	if config.Newline == "" && config.TabWidth <= 0 && !config.TrimTrailingSpace && !config.CollapseBlankLines {
+		return text
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	lines := strings.Split(text, "\n")
+
+	out := make([]string, 0, len(lines))
+	prevBlank := false
+	for _, line := range lines {
+		line = expandTabs(line, config.TabWidth)
+		if config.TrimTrailingSpace {
+			line = strings.TrimRight(line, " \t\u00a0")
+		}
+		blank := strings.TrimSpace(line) == ""
+		if config.CollapseBlankLines && blank && prevBlank {
+			continue
+		}
+		prevBlank = blank
+		out = append(out, line)
+	}
+
+	newline := "\n"
+	if config.Newline == NewlineCRLF {
+		newline = "\r\n"
+	}
+	return strings.Join(out, newline)
+}
@@ -0,0 +1,155 @@
+package pdfocr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// xfaPacket is one named part of an XFA form (template, datasets, ...).
+type xfaPacket struct {
+	Name string
+	Data []byte
+}
+
+// xfaPackets returns the XFA packets of the document in order, or nil if the
+// document is not an XFA form. /XFA is either a single stream or an array of
+// alternating packet names and streams.
+func (pdf *rawPDF) xfaPackets() ([]xfaPacket, error) {
+	form := pdf.dict(pdf.catalog()["AcroForm"])
+	if form == nil {
+		return nil, nil
+	}
+	xfa, ok := form["XFA"]
+	if !ok {
+		return nil, nil
+	}
+
+	if obj := pdf.object(xfa); obj != nil && obj.Stream != nil {
+		data, err := pdf.decodeStream(obj)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding XFA stream: %w", err)
+		}
+		return []xfaPacket{{Name: "xdp", Data: data}}, nil
+	}
+
+	var packets []xfaPacket
+	parts := pdf.array(xfa)
+	for i := 0; i+1 < len(parts); i += 2 {
+		obj := pdf.object(parts[i+1])
+		if obj == nil {
+			continue
+		}
+		data, err := pdf.decodeStream(obj)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding XFA packet %q: %w", pdf.text(parts[i]), err)
+		}
+		packets = append(packets, xfaPacket{Name: pdf.text(parts[i]), Data: data})
+	}
+	return packets, nil
+}
+
+// needsXFARendering reports whether the form is dynamic XFA, whose pages are
+// only a placeholder unless the viewer runs the XFA layout engine.
+func (pdf *rawPDF) needsXFARendering() bool {
+	needs, _ := pdf.resolve(pdf.catalog()["NeedsRendering"]).(bool)
+	return needs
+}
+
+// joinXFAPackets reassembles the complete XDP document from its packets.
+func joinXFAPackets(packets []xfaPacket) []byte {
+	var b bytes.Buffer
+	for _, p := range packets {
+		b.Write(p.Data)
+	}
+	return b.Bytes()
+}
+
+// xfaFormData flattens the <xfa:data> section of an XDP document into
+// "path: value" lines, one per filled-in field, in document order.
+func xfaFormData(xdp []byte) []string {
+	dec := xml.NewDecoder(bytes.NewReader(xdp))
+	dec.Strict = false
+
+	var lines []string
+	var stack []string
+	var text strings.Builder
+	inData := false
+	dataDepth := 0
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if !inData && t.Name.Local == "data" && (t.Name.Space == "" || strings.Contains(t.Name.Space, "xfa-data")) {
+				inData = true
+				dataDepth = len(stack) + 1
+			}
+			stack = append(stack, t.Name.Local)
+			text.Reset()
+		case xml.CharData:
+			if inData {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if inData && len(stack) > dataDepth {
+				if value := strings.TrimSpace(text.String()); value != "" {
+					lines = append(lines, strings.Join(stack[dataDepth:], ".")+": "+value)
+				}
+			}
+			text.Reset()
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			if inData && len(stack) < dataDepth {
+				inData = false
+			}
+		}
+	}
+	return lines
+}
+
+// isXFAPlaceholderText reports whether native page text is the stock message
+// that dynamic XFA forms show to viewers without XFA support.
+func isXFAPlaceholderText(text string) bool {
+	lower := strings.ToLower(text)
+	return strings.Contains(lower, "please wait") &&
+		(strings.Contains(lower, "not eventually replaced") || strings.Contains(lower, "does not support"))
+}
+
+// inspectXFA detects XFA forms, reports what the renderer can and cannot show,
+// saves the XDP document if requested and returns the filled-in form data.
+func inspectXFA(pdf *rawPDF, name string, config OCRConfig, depth int, manifest *DocumentManifest) []string {
+	packets, err := pdf.xfaPackets()
+	if err != nil {
+		config.warnings.warnf(-1, "Warning: %s: %v\n", name, err)
+	}
+	if len(packets) == 0 {
+		return nil
+	}
+	manifest.XFA = true
+
+	if pdf.needsXFARendering() {
+		config.warnings.warnf(-1, "Warning: %s is a dynamic XFA form; its pages only hold placeholder content. "+
+			"Placeholder pages are OCR'd and the form data is listed separately. "+
+			"For a faithful rendering, flatten the form (e.g. print to PDF from Adobe Reader) first.\n", name)
+	} else {
+		config.warnings.warnf(-1, "Warning: %s is an XFA form; field values may not appear in the rendered pages "+
+			"and are listed separately as XFA form data.\n", name)
+	}
+
+	xdp := joinXFAPackets(packets)
+	if config.XFAOutputFile != "" && depth == 0 {
+		if err := os.WriteFile(config.XFAOutputFile, xdp, 0644); err != nil {
+			config.warnings.warnf(-1, "Warning: could not save XFA data: %v\n", err)
+		} else {
+			config.logf("XFA form XML saved to: %s\n", config.XFAOutputFile)
+		}
+	}
+	return xfaFormData(xdp)
+}